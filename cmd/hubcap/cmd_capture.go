@@ -1,15 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"flag"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"os"
+	"regexp"
 
+	"github.com/tomyan/hubcap/internal/annotate"
 	"github.com/tomyan/hubcap/internal/chrome"
 )
 
+// interactiveSelector matches the elements numbered by screenshot
+// --annotate without --annotate-selector.
+const interactiveSelector = `a[href], button, input:not([type="hidden"]), select, textarea, summary, ` +
+	`[contenteditable=""], [contenteditable="true"], [tabindex]:not([tabindex="-1"]), [onclick], ` +
+	`[role="button"], [role="link"], [role="checkbox"], [role="radio"], [role="switch"], [role="tab"], ` +
+	`[role="menuitem"], [role="option"], [role="combobox"], [role="textbox"], [role="searchbox"], [role="slider"]`
+
+// nthSuffix matches a selector already picking one of its matches.
+var nthSuffix = regexp.MustCompile(`:nth=\d+$`)
+
 // ElementScreenshotResult contains metadata about an element screenshot.
 type ElementScreenshotResult struct {
 	Format   string              `json:"format"`
@@ -25,6 +41,26 @@ type Base64ScreenshotResult struct {
 	Data   string `json:"data"`
 }
 
+// AnnotatedScreenshotResult is returned by the screenshot command with
+// --annotate.
+type AnnotatedScreenshotResult struct {
+	Format string           `json:"format"`
+	Size   int              `json:"size"`
+	Data   string           `json:"data,omitempty"` // Only with --base64
+	Marks  []ScreenshotMark `json:"marks"`
+}
+
+// ScreenshotMark is an element numbered on an annotated screenshot.
+type ScreenshotMark struct {
+	Label         int                `json:"label"`
+	BackendNodeID int64              `json:"backendNodeId"`
+	Ref           string             `json:"ref,omitempty"`
+	Selector      string             `json:"selector"`
+	TagName       string             `json:"tagName"`
+	Text          string             `json:"text,omitempty"`
+	Bounds        chrome.BoundingBox `json:"bounds"`
+}
+
 func cmdScreenshot(cfg *Config, args []string) int {
 	// Parse screenshot-specific flags
 	fs := flag.NewFlagSet("screenshot", flag.ContinueOnError)
@@ -32,8 +68,10 @@ func cmdScreenshot(cfg *Config, args []string) int {
 	output := fs.String("output", "", "Output file path")
 	format := fs.String("format", "png", "Image format: png, jpeg, webp")
 	quality := fs.Int("quality", 80, "JPEG/WebP quality (0-100)")
-	selector := fs.String("selector", "", "CSS selector for element screenshot")
+	selector := fs.String("selector", "", "Selector for element screenshot")
 	base64Flag := fs.Bool("base64", false, "Return base64 data instead of writing to file")
+	annotateFlag := fs.Bool("annotate", false, "Number the interactive elements in the viewport on the image")
+	annotateSelector := fs.String("annotate-selector", "", "Number the elements matching this selector instead (implies --annotate)")
 
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
@@ -45,9 +83,26 @@ func cmdScreenshot(cfg *Config, args []string) int {
 	if *output == "" && !*base64Flag {
 		fmt.Fprintln(cfg.Stderr, "usage: hubcap screenshot --output <file> [--format png|jpeg|webp] [--quality 0-100] [--selector <css>]")
 		fmt.Fprintln(cfg.Stderr, "       hubcap screenshot --base64 [--format png|jpeg|webp] [--quality 0-100]")
+		fmt.Fprintln(cfg.Stderr, "       hubcap screenshot --output <file>|--base64 --annotate [--annotate-selector <sel>] [--format png|jpeg]")
 		return ExitError
 	}
 
+	if *annotateFlag || *annotateSelector != "" {
+		if *selector != "" {
+			fmt.Fprintln(cfg.Stderr, "error: --annotate cannot be combined with --selector")
+			return ExitError
+		}
+		if *format != "png" && *format != "jpeg" {
+			fmt.Fprintf(cfg.Stderr, "error: --annotate supports png and jpeg, not %s\n", *format)
+			return ExitError
+		}
+		marked := *annotateSelector
+		if marked == "" {
+			marked = interactiveSelector
+		}
+		return cmdAnnotatedScreenshot(cfg, marked, *output, *format, *quality, *base64Flag)
+	}
+
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
 		opts := chrome.ScreenshotOptions{
 			Format:  *format,
@@ -99,6 +154,91 @@ func cmdScreenshot(cfg *Config, args []string) int {
 	})
 }
 
+func cmdAnnotatedScreenshot(cfg *Config, selector, output, format string, quality int, base64Flag bool) int {
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		data, err := client.Screenshot(ctx, target.ID, chrome.ScreenshotOptions{Format: "png"})
+		if err != nil {
+			return nil, err
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decoding screenshot: %w", err)
+		}
+		ratio, err := client.DevicePixelRatio(ctx, target.ID)
+		if err != nil {
+			return nil, err
+		}
+		elements, err := client.QueryAll(ctx, target.ID, selector)
+		if err != nil {
+			return nil, err
+		}
+
+		marks, boxes := screenshotMarks(elements, selector, img.Bounds(), ratio)
+		ids := make([]int64, len(marks))
+		for i, m := range marks {
+			ids[i] = m.BackendNodeID
+		}
+		refs, err := handOutRefs(ctx, client, target.ID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range marks {
+			marks[i].Ref = refs[i]
+		}
+
+		var buf bytes.Buffer
+		annotated := annotate.Draw(img, boxes, ratio)
+		if format == "jpeg" {
+			err = jpeg.Encode(&buf, annotated, &jpeg.Options{Quality: quality})
+		} else {
+			err = png.Encode(&buf, annotated)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encoding screenshot: %w", err)
+		}
+
+		result := AnnotatedScreenshotResult{Format: format, Size: buf.Len(), Marks: marks}
+		if base64Flag {
+			result.Data = base64.StdEncoding.EncodeToString(buf.Bytes())
+			return result, nil
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+			return nil, fmt.Errorf("writing file: %w", err)
+		}
+		return result, nil
+	})
+}
+
+// screenshotMarks numbers the visible elements in a screenshot of the
+// viewport, returning them and their boxes to draw. ratio is the number of
+// the image's pixels to a CSS pixel.
+func screenshotMarks(elements []chrome.ElementInfo, selector string, img image.Rectangle, ratio float64) ([]ScreenshotMark, []annotate.Mark) {
+	viewport := chrome.BoundingBox{Width: float64(img.Dx()) / ratio, Height: float64(img.Dy()) / ratio}
+	marks := []ScreenshotMark{}
+	var boxes []annotate.Mark
+	for _, el := range elements {
+		b := el.Bounds
+		if !el.Visible || b.X >= viewport.Width || b.Y >= viewport.Height || b.X+b.Width <= 0 || b.Y+b.Height <= 0 {
+			continue
+		}
+		label := len(marks) + 1
+		sel := selector
+		if !nthSuffix.MatchString(sel) {
+			sel = fmt.Sprintf("%s:nth=%d", selector, el.Index)
+		}
+		marks = append(marks, ScreenshotMark{
+			Label:         label,
+			BackendNodeID: el.BackendNodeID,
+			Selector:      sel,
+			TagName:       el.TagName,
+			Text:          el.Text,
+			Bounds:        b,
+		})
+		boxes = append(boxes, annotate.Mark{Label: label, X: b.X, Y: b.Y, Width: b.Width, Height: b.Height})
+	}
+	return marks, boxes
+}
+
 func cmdPDF(cfg *Config, args []string) int {
 	// Parse pdf-specific flags
 	fs := flag.NewFlagSet("pdf", flag.ContinueOnError)
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["context"] = CommandInfo{
+		Name:     "context",
+		Desc:     "Create, list or close isolated browser contexts",
+		Category: "Navigate & manage tabs",
+		Run:      func(cfg *Config, args []string) int { return cmdContext(cfg, args) },
+	}
+}
+
+// contextNames maps the names given to browser contexts by context new to
+// their IDs.
+type contextNames map[string]string
+
+// contextNamesPath returns the path of the context names file for the given
+// Chrome endpoint. Each host:port pair has its own contexts.
+func contextNamesPath(dir, host string, port int) string {
+	return filepath.Join(dir, "contexts", fmt.Sprintf("%s-%d.json", host, port))
+}
+
+// loadContextNames loads the names given to the browser contexts of a
+// Chrome. Returns no names if none have been given.
+func loadContextNames(dir, host string, port int) (contextNames, error) {
+	names := contextNames{}
+	data, err := os.ReadFile(contextNamesPath(dir, host, port))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, fmt.Errorf("reading context names: %w", err)
+	}
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("parsing context names: %w", err)
+	}
+	return names, nil
+}
+
+// saveContextNames saves the names given to the browser contexts of a
+// Chrome.
+func saveContextNames(dir, host string, port int, names contextNames) error {
+	path := contextNamesPath(dir, host, port)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating contexts dir: %w", err)
+	}
+	data, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling context names: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing context names: %w", err)
+	}
+	return nil
+}
+
+// nameOf returns the name given to a browser context, or "".
+func (n contextNames) nameOf(id string) string {
+	for name, named := range n {
+		if named == id {
+			return name
+		}
+	}
+	return ""
+}
+
+// prune forgets the names of contexts that no longer exist, and reports
+// whether there were any.
+func (n contextNames) prune(ids []string) bool {
+	pruned := false
+	for name, id := range n {
+		if !slices.Contains(ids, id) {
+			delete(n, name)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// resolveContext returns the ID of the browser context named by ref, a name
+// given by context new or a context ID, in cfg's Chrome.
+func resolveContext(ctx context.Context, client *chrome.Client, cfg *Config, ref string) (string, error) {
+	names, err := loadContextNames(configDir(), cfg.Host, cfg.Port)
+	if err != nil {
+		return "", err
+	}
+	id := ref
+	if named, ok := names[ref]; ok {
+		id = named
+	}
+
+	ids, err := client.BrowserContexts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(ids, id) {
+		return "", fmt.Errorf("invalid context: %s (not found)", ref)
+	}
+	return id, nil
+}
+
+// contextPages returns the pages in a browser context.
+func contextPages(pages []chrome.TargetInfo, contextID string) []chrome.TargetInfo {
+	var in []chrome.TargetInfo
+	for _, p := range pages {
+		if p.BrowserContextID == contextID {
+			in = append(in, p)
+		}
+	}
+	return in
+}
+
+// ContextResult is returned by the context new and context close commands.
+type ContextResult struct {
+	ID              string `json:"id"`
+	Name            string `json:"name,omitempty"`
+	ProxyServer     string `json:"proxyServer,omitempty"`
+	ProxyBypassList string `json:"proxyBypassList,omitempty"`
+	Closed          bool   `json:"closed,omitempty"`
+}
+
+// ContextInfo describes a browser context in the output of context list.
+type ContextInfo struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Pages int    `json:"pages"`
+}
+
+func cmdContext(cfg *Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap context <new|list|close> [args]")
+		return ExitError
+	}
+
+	switch args[0] {
+	case "new":
+		return cmdContextNew(cfg, args[1:])
+	case "list":
+		return cmdContextList(cfg)
+	case "close":
+		return cmdContextClose(cfg, args[1:])
+	default:
+		fmt.Fprintf(cfg.Stderr, "unknown context subcommand: %s\n", args[0])
+		fmt.Fprintln(cfg.Stderr, "subcommands: new, list, close")
+		return ExitError
+	}
+}
+
+const contextNewUsage = "usage: hubcap context new [--name <name>] [--proxy-server <url>] [--proxy-bypass <hosts>]"
+
+func cmdContextNew(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("context new", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	name := fs.String("name", "", "Name to refer to the context by with -context")
+	proxyServer := fs.String("proxy-server", "", "Proxy for the context's pages, such as http://proxy.test:8080")
+	proxyBypass := fs.String("proxy-bypass", "", "Comma-separated hosts to reach without the proxy")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+	if fs.NArg() > 0 {
+		return cmdMissingArg(cfg, contextNewUsage)
+	}
+	if *proxyBypass != "" && *proxyServer == "" {
+		fmt.Fprintln(cfg.Stderr, "error: --proxy-bypass needs --proxy-server")
+		return ExitError
+	}
+
+	return withClient(cfg, func(ctx context.Context, client *chrome.Client) (interface{}, error) {
+		dir := configDir()
+		names, err := loadContextNames(dir, cfg.Host, cfg.Port)
+		if err != nil {
+			return nil, err
+		}
+		if *name != "" {
+			if _, ok := names[*name]; ok {
+				ids, err := client.BrowserContexts(ctx)
+				if err != nil {
+					return nil, err
+				}
+				names.prune(ids)
+			}
+			if _, ok := names[*name]; ok {
+				return nil, fmt.Errorf("context name %q is in use", *name)
+			}
+		}
+
+		id, err := client.CreateBrowserContext(ctx, chrome.BrowserContextOptions{
+			ProxyServer:     *proxyServer,
+			ProxyBypassList: *proxyBypass,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if *name != "" {
+			names[*name] = id
+			if err := saveContextNames(dir, cfg.Host, cfg.Port, names); err != nil {
+				return nil, err
+			}
+		}
+		return ContextResult{ID: id, Name: *name, ProxyServer: *proxyServer, ProxyBypassList: *proxyBypass}, nil
+	})
+}
+
+func cmdContextList(cfg *Config) int {
+	return withClient(cfg, func(ctx context.Context, client *chrome.Client) (interface{}, error) {
+		ids, err := client.BrowserContexts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		pages, err := client.Pages(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := configDir()
+		names, err := loadContextNames(dir, cfg.Host, cfg.Port)
+		if err != nil {
+			return nil, err
+		}
+		if names.prune(ids) {
+			if err := saveContextNames(dir, cfg.Host, cfg.Port, names); err != nil {
+				return nil, err
+			}
+		}
+
+		contexts := make([]ContextInfo, 0, len(ids))
+		for _, id := range ids {
+			info := ContextInfo{ID: id, Name: names.nameOf(id)}
+			for _, p := range pages {
+				if p.BrowserContextID == id {
+					info.Pages++
+				}
+			}
+			contexts = append(contexts, info)
+		}
+		return contexts, nil
+	})
+}
+
+func cmdContextClose(cfg *Config, args []string) int {
+	if len(args) != 1 {
+		return cmdMissingArg(cfg, "usage: hubcap context close <id|name>")
+	}
+	ref := args[0]
+
+	return withClient(cfg, func(ctx context.Context, client *chrome.Client) (interface{}, error) {
+		id, err := resolveContext(ctx, client, cfg, ref)
+		if err != nil {
+			return nil, err
+		}
+		pages, err := client.Pages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.DisposeBrowserContext(ctx, id); err != nil {
+			return nil, err
+		}
+
+		dir := configDir()
+		for _, p := range pages {
+			if p.BrowserContextID == id {
+				if err := clearTargetOverrides(dir, p.ID); err != nil {
+					return nil, err
+				}
+			}
+		}
+		names, err := loadContextNames(dir, cfg.Host, cfg.Port)
+		if err != nil {
+			return nil, err
+		}
+		name := names.nameOf(id)
+		if name != "" {
+			delete(names, name)
+			if err := saveContextNames(dir, cfg.Host, cfg.Port, names); err != nil {
+				return nil, err
+			}
+		}
+		return ContextResult{ID: id, Name: name, Closed: true}, nil
+	})
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func TestContextNames(t *testing.T) {
+	dir := t.TempDir()
+	names, err := loadContextNames(dir, "localhost", 9222)
+	if err != nil || len(names) != 0 {
+		t.Fatalf("loadContextNames() = %v, %v, want no names", names, err)
+	}
+
+	names["alice"] = "CTX1"
+	names["bob"] = "CTX2"
+	if err := saveContextNames(dir, "localhost", 9222, names); err != nil {
+		t.Fatalf("saveContextNames: %v", err)
+	}
+	loaded, err := loadContextNames(dir, "localhost", 9222)
+	if err != nil {
+		t.Fatalf("loadContextNames: %v", err)
+	}
+	if loaded["alice"] != "CTX1" || loaded["bob"] != "CTX2" {
+		t.Errorf("loaded names = %v", loaded)
+	}
+	if other, err := loadContextNames(dir, "localhost", 9333); err != nil || len(other) != 0 {
+		t.Errorf("loadContextNames() for another Chrome = %v, %v, want no names", other, err)
+	}
+
+	if got := loaded.nameOf("CTX2"); got != "bob" {
+		t.Errorf("nameOf(CTX2) = %q, want bob", got)
+	}
+	if got := loaded.nameOf("CTX3"); got != "" {
+		t.Errorf("nameOf(CTX3) = %q, want none", got)
+	}
+
+	if loaded.prune([]string{"CTX1", "CTX2"}) {
+		t.Error("expected nothing to be pruned")
+	}
+	if !loaded.prune([]string{"CTX1"}) {
+		t.Error("expected bob to be pruned")
+	}
+	if _, ok := loaded["bob"]; ok || loaded["alice"] != "CTX1" {
+		t.Errorf("pruned names = %v, want only alice", loaded)
+	}
+}
+
+func TestContextPages(t *testing.T) {
+	pages := []chrome.TargetInfo{
+		{ID: "A"},
+		{ID: "B", BrowserContextID: "CTX1"},
+		{ID: "C", BrowserContextID: "CTX2"},
+		{ID: "D", BrowserContextID: "CTX1"},
+	}
+	in := contextPages(pages, "CTX1")
+	if len(in) != 2 || in[0].ID != "B" || in[1].ID != "D" {
+		t.Errorf("contextPages(CTX1) = %+v, want B and D", in)
+	}
+	if in := contextPages(pages, "CTX3"); len(in) != 0 {
+		t.Errorf("contextPages(CTX3) = %+v, want none", in)
+	}
+}
+
+func TestRun_Context_UsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"context"}, "usage: hubcap context"},
+		{[]string{"context", "open"}, "unknown context subcommand: open"},
+		{[]string{"context", "new", "alice"}, "usage: hubcap context new"},
+		{[]string{"context", "new", "--proxy-bypass", "localhost"}, "--proxy-bypass needs --proxy-server"},
+		{[]string{"context", "close"}, "usage: hubcap context close"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		code := run(tt.args, cfg)
+		if code != ExitError {
+			t.Errorf("%q: expected exit code %d, got %d", tt.args, ExitError, code)
+		}
+		if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, tt.want) {
+			t.Errorf("%q: expected %q in stderr, got %q", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestRun_Context(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	t.Setenv("HUBCAP_CONFIG_DIR", t.TempDir())
+
+	cfg := testConfig()
+	if code := run([]string{"context", "new", "--name", "alice"}, cfg); code != ExitSuccess {
+		t.Fatalf("context new failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var created ContextResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &created); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if created.ID == "" || created.Name != "alice" {
+		t.Fatalf("unexpected context %+v", created)
+	}
+	defer run([]string{"context", "close", created.ID}, testConfig())
+
+	cfg = testConfig()
+	if code := run([]string{"context", "new", "--name", "alice"}, cfg); code != ExitError {
+		t.Errorf("expected a second context named alice to fail, got %d", code)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"-context", "alice", "tabs"}, cfg); code != ExitSuccess {
+		t.Fatalf("tabs failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if out := strings.TrimSpace(cfg.Stdout.(*bytes.Buffer).String()); out != "[]" {
+		t.Errorf("expected no tabs in the new context, got %s", out)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"-context", "alice", "title"}, cfg); code != ExitError {
+		t.Errorf("expected no target in an empty context, got %d", code)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"-context", "alice", "new", "data:text/html,<title>Alice</title>"}, cfg); code != ExitSuccess {
+		t.Fatalf("new in context failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var tab NewTabResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &tab); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if tab.BrowserContextID != created.ID {
+		t.Errorf("expected the tab to be in context %s, got %+v", created.ID, tab)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"-context", "alice", "eval", "document.title"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval in context failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "Alice") {
+		t.Errorf("expected -context alice to pick its tab, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"context", "list"}, cfg); code != ExitSuccess {
+		t.Fatalf("context list failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var contexts []ContextInfo
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &contexts); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	found := false
+	for _, c := range contexts {
+		if c.ID == created.ID {
+			found = c.Name == "alice" && c.Pages == 1
+		}
+	}
+	if !found {
+		t.Errorf("expected alice with one page in %+v", contexts)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"context", "close", "alice"}, cfg); code != ExitSuccess {
+		t.Fatalf("context close failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	cfg = testConfig()
+	if code := run([]string{"-context", "alice", "tabs"}, cfg); code != ExitError {
+		t.Errorf("expected a closed context not to be found, got %d", code)
+	}
+	if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, "invalid context: alice (not found)") {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+}
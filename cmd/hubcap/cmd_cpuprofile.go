@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["cpuprofile"] = CommandInfo{
+		Name:     "cpuprofile",
+		Desc:     "Record JavaScript CPU profile",
+		Category: "Profile",
+		Run:      func(cfg *Config, args []string) int { return cmdCPUProfile(cfg, args) },
+	}
+}
+
+// CPUProfileCLIResult is returned by the cpuprofile command.
+type CPUProfileCLIResult struct {
+	File    string `json:"file"`
+	Format  string `json:"format"`
+	Size    int    `json:"size"`
+	Samples int    `json:"samples"`
+}
+
+func cmdCPUProfile(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("cpuprofile", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	output := fs.String("output", "", "Output file path")
+	duration := fs.Duration("duration", 5*time.Second, "How long to profile")
+	interval := fs.Duration("interval", 0, "Sampling interval (default 1ms)")
+	format := fs.String("format", "pprof", "Output format: pprof or cpuprofile")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if *output == "" {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap cpuprofile --output <file> [--duration <d>] [--format pprof|cpuprofile]")
+		return ExitError
+	}
+	if *format != "pprof" && *format != "cpuprofile" {
+		fmt.Fprintf(cfg.Stderr, "error: invalid --format %q (must be pprof or cpuprofile)\n", *format)
+		return ExitError
+	}
+
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		started := time.Now()
+		profile, err := client.CaptureCPUProfile(ctx, target.ID, *duration, *interval)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := encodeCPUProfile(profile, *format, started)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			return nil, fmt.Errorf("writing file: %w", err)
+		}
+
+		return CPUProfileCLIResult{
+			File:    *output,
+			Format:  *format,
+			Size:    len(data),
+			Samples: len(profile.Samples),
+		}, nil
+	})
+}
+
+// encodeCPUProfile renders a profile as gzipped pprof protobuf or, for the
+// cpuprofile format, the DevTools JSON that Chrome's Performance panel loads.
+func encodeCPUProfile(profile *chrome.CPUProfile, format string, started time.Time) ([]byte, error) {
+	if format == "cpuprofile" {
+		data, err := json.Marshal(profile)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling profile: %w", err)
+		}
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := profile.Pprof(started).Write(&buf); err != nil {
+		return nil, fmt.Errorf("encoding pprof: %w", err)
+	}
+	return buf.Bytes(), nil
+}
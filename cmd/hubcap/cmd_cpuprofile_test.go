@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func TestCPUProfile_RequiresOutput(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"cpuprofile"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestCPUProfile_InvalidFormat(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"cpuprofile", "--output", "cpu.out", "--format", "svg"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "invalid --format") {
+		t.Errorf("expected invalid format error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestEncodeCPUProfile(t *testing.T) {
+	profile := &chrome.CPUProfile{
+		Nodes: []chrome.CPUProfileNode{
+			{ID: 1, CallFrame: chrome.CPUProfileCallFrame{FunctionName: "(root)"}, Children: []int{2}},
+			{ID: 2, CallFrame: chrome.CPUProfileCallFrame{FunctionName: "main", URL: "app.js"}},
+		},
+		StartTime:  0,
+		EndTime:    2000,
+		Samples:    []int{2, 2},
+		TimeDeltas: []int{0, 1000},
+	}
+
+	data, err := encodeCPUProfile(profile, "pprof", time.Now())
+	if err != nil {
+		t.Fatalf("encoding pprof: %v", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("pprof output is not gzipped: %v", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil || !bytes.Contains(raw, []byte("main")) {
+		t.Errorf("expected function name in profile, err = %v", err)
+	}
+
+	data, err = encodeCPUProfile(profile, "cpuprofile", time.Now())
+	if err != nil {
+		t.Fatalf("encoding cpuprofile: %v", err)
+	}
+	var decoded chrome.CPUProfile
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("cpuprofile output is not JSON: %v", err)
+	}
+	if len(decoded.Nodes) != 2 || len(decoded.Samples) != 2 {
+		t.Errorf("unexpected round trip: %+v", decoded)
+	}
+}
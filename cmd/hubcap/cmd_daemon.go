@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["daemon"] = CommandInfo{
+		Name:     "daemon",
+		Desc:     "Keep one Chrome connection alive across commands",
+		Category: "Utility",
+		Run:      func(cfg *Config, args []string) int { return cmdDaemon(cfg, args) },
+	}
+}
+
+// DaemonResult is returned by the daemon command.
+type DaemonResult struct {
+	Running    bool   `json:"running"`
+	PID        int    `json:"pid,omitempty"`
+	Socket     string `json:"socket"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	BrowserURL string `json:"browserUrl,omitempty"`
+	Sessions   int    `json:"sessions"`
+	Clients    int    `json:"clients"`
+}
+
+// daemonInfo is the on-disk record of a running daemon.
+type daemonInfo struct {
+	PID     int    `json:"pid"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Started string `json:"started"`
+}
+
+func cmdDaemon(cfg *Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap daemon <start|stop|status|run>")
+		return ExitError
+	}
+
+	switch args[0] {
+	case "start":
+		return cmdDaemonStart(cfg)
+	case "stop":
+		return cmdDaemonStop(cfg)
+	case "status":
+		return cmdDaemonStatus(cfg)
+	case "run":
+		return cmdDaemonRun(cfg)
+	default:
+		fmt.Fprintf(cfg.Stderr, "unknown daemon subcommand: %s\n", args[0])
+		fmt.Fprintln(cfg.Stderr, "subcommands: start, stop, status, run")
+		return ExitError
+	}
+}
+
+// daemonPath returns the path of a daemon file for the given Chrome endpoint.
+// Each host:port pair gets its own daemon.
+func daemonPath(dir, host string, port int, ext string) string {
+	return filepath.Join(dir, "daemon", fmt.Sprintf("%s-%d%s", host, port, ext))
+}
+
+// connectClient connects to Chrome, going through the daemon for cfg's
+// host and port when one is running.
+func connectClient(ctx context.Context, cfg *Config) (*chrome.Client, error) {
+	socket := daemonPath(configDir(), cfg.Host, cfg.Port, ".sock")
+	if _, err := os.Stat(socket); err == nil {
+		if client, err := chrome.ConnectSocket(ctx, socket); err == nil {
+			return client, nil
+		}
+	}
+	return chrome.Connect(ctx, cfg.Host, cfg.Port)
+}
+
+// queryDaemon reports the state of the daemon for cfg's host and port.
+func queryDaemon(ctx context.Context, cfg *Config) DaemonResult {
+	dir := configDir()
+	result := DaemonResult{
+		Socket: daemonPath(dir, cfg.Host, cfg.Port, ".sock"),
+		Host:   cfg.Host,
+		Port:   cfg.Port,
+	}
+
+	client, err := chrome.ConnectSocket(ctx, result.Socket)
+	if err != nil {
+		return result
+	}
+	defer client.Close()
+
+	status, err := client.DaemonStatus(ctx)
+	if err != nil {
+		return result
+	}
+
+	result.Running = true
+	result.BrowserURL = status.BrowserURL
+	result.Sessions = status.Sessions
+	result.Clients = status.Clients
+	if info, err := loadDaemonInfo(dir, cfg.Host, cfg.Port); err == nil {
+		result.PID = info.PID
+	}
+	return result
+}
+
+func cmdDaemonStatus(cfg *Config) int {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	return outputResult(cfg, queryDaemon(ctx, cfg))
+}
+
+func cmdDaemonStart(cfg *Config) int {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	if status := queryDaemon(ctx, cfg); status.Running {
+		fmt.Fprintf(cfg.Stderr, "error: daemon already running for %s:%d\n", cfg.Host, cfg.Port)
+		return ExitError
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	dir := configDir()
+	if err := os.MkdirAll(filepath.Join(dir, "daemon"), 0755); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: creating daemon dir: %v\n", err)
+		return ExitError
+	}
+
+	logFile, err := os.OpenFile(daemonPath(dir, cfg.Host, cfg.Port, ".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: opening daemon log: %v\n", err)
+		return ExitError
+	}
+	defer logFile.Close()
+
+	args := []string{"-host", cfg.Host, "-port", strconv.Itoa(cfg.Port)}
+	if cfg.DownloadDir != "" {
+		dir, err := downloadDir(cfg)
+		if err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+		args = append(args, "-download-dir", dir)
+	}
+	cmd := exec.Command(exe, append(args, "daemon", "run")...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: starting daemon: %v\n", err)
+		return ExitError
+	}
+
+	// Reap the child if it exits while we are still waiting for it
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(cfg.Stderr, "error: timeout")
+			return ExitTimeout
+		case <-exited:
+			fmt.Fprintf(cfg.Stderr, "error: daemon exited during startup (see %s)\n", logFile.Name())
+			return ExitConnFailed
+		case <-ticker.C:
+			if status := queryDaemon(ctx, cfg); status.Running {
+				return outputResult(cfg, status)
+			}
+		}
+	}
+}
+
+func cmdDaemonStop(cfg *Config) int {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	socket := daemonPath(configDir(), cfg.Host, cfg.Port, ".sock")
+	client, err := chrome.ConnectSocket(ctx, socket)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: daemon not running for %s:%d\n", cfg.Host, cfg.Port)
+		return ExitError
+	}
+	defer client.Close()
+
+	if err := client.ShutdownDaemon(ctx); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	// Wait for the daemon to remove its socket
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if _, err := os.Stat(socket); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(cfg.Stderr, "error: timeout")
+			return ExitTimeout
+		case <-ticker.C:
+		}
+	}
+
+	return outputResult(cfg, DaemonResult{
+		Running: false,
+		Socket:  socket,
+		Host:    cfg.Host,
+		Port:    cfg.Port,
+	})
+}
+
+// cmdDaemonRun runs the daemon in the foreground until it is stopped,
+// interrupted, or Chrome goes away.
+func cmdDaemonRun(cfg *Config) int {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	// Chrome keeps the download behavior only while the connection that set
+	// it is open, so it is set here for every command the daemon serves
+	if cfg.DownloadDir != "" {
+		dir, err := downloadDir(cfg)
+		if err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+		if err := client.SetDownloadBehavior(ctx, chrome.DownloadAllow, dir); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	dir := configDir()
+	if err := os.MkdirAll(filepath.Join(dir, "daemon"), 0755); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: creating daemon dir: %v\n", err)
+		return ExitError
+	}
+
+	// A socket left behind by a daemon that died is safe to replace
+	socket := daemonPath(dir, cfg.Host, cfg.Port, ".sock")
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		fmt.Fprintf(cfg.Stderr, "error: daemon already running for %s:%d\n", cfg.Host, cfg.Port)
+		return ExitError
+	}
+	os.Remove(socket)
+
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: listening on %s: %v\n", socket, err)
+		return ExitError
+	}
+	defer os.Remove(socket)
+
+	info := &daemonInfo{
+		PID:     os.Getpid(),
+		Host:    cfg.Host,
+		Port:    cfg.Port,
+		Started: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := saveDaemonInfo(dir, info); err != nil {
+		ln.Close()
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	defer os.Remove(daemonPath(dir, cfg.Host, cfg.Port, ".json"))
+
+	// The daemon outlives the terminal that started it
+	signal.Ignore(syscall.SIGHUP)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			ln.Close()
+		}
+	}()
+
+	if err := client.Serve(ln); err != nil && !errors.Is(err, net.ErrClosed) {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	return ExitSuccess
+}
+
+// loadDaemonInfo loads the record of the daemon for host and port.
+func loadDaemonInfo(dir, host string, port int) (*daemonInfo, error) {
+	data, err := os.ReadFile(daemonPath(dir, host, port, ".json"))
+	if err != nil {
+		return nil, err
+	}
+	var info daemonInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// saveDaemonInfo writes the record of a running daemon.
+func saveDaemonInfo(dir string, info *daemonInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(daemonPath(dir, info.Host, info.Port, ".json"), data, 0644)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonStatus_NotRunning(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+
+	code := run([]string{"daemon", "status"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("daemon status failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result DaemonResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if result.Running {
+		t.Error("expected running=false with no daemon")
+	}
+	if !strings.HasSuffix(result.Socket, "localhost-9301.sock") {
+		t.Errorf("unexpected socket path %q", result.Socket)
+	}
+}
+
+func TestDaemonStop_NotRunning(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+
+	code := run([]string{"daemon", "stop"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "daemon not running") {
+		t.Errorf("unexpected stderr: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestDaemon_UnknownSubcommand(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+
+	code := run([]string{"daemon", "bogus"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+}
+
+func TestDaemon_CommandsUseRunningDaemon(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	cfg, _ := setupTestConfig(t)
+
+	done := make(chan int, 1)
+	go func() {
+		daemonCfg := *cfg
+		daemonCfg.Stdout = &bytes.Buffer{}
+		daemonCfg.Stderr = &bytes.Buffer{}
+		done <- run([]string{"daemon", "run"}, &daemonCfg)
+	}()
+
+	// Wait for the daemon to come up
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		statusCfg := *cfg
+		statusCfg.Stdout = &bytes.Buffer{}
+		run([]string{"daemon", "status"}, &statusCfg)
+		var status DaemonResult
+		json.Unmarshal(statusCfg.Stdout.(*bytes.Buffer).Bytes(), &status)
+		if status.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("daemon did not start")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Two commands against the same tab share one cached session
+	for i := 0; i < 2; i++ {
+		titleCfg := *cfg
+		titleCfg.Stdout = &bytes.Buffer{}
+		titleCfg.Stderr = &bytes.Buffer{}
+		if code := run([]string{"title"}, &titleCfg); code != ExitSuccess {
+			t.Fatalf("title via daemon failed: %s", titleCfg.Stderr.(*bytes.Buffer).String())
+		}
+	}
+
+	statusCfg := *cfg
+	statusCfg.Stdout = &bytes.Buffer{}
+	run([]string{"daemon", "status"}, &statusCfg)
+	var status DaemonResult
+	if err := json.Unmarshal(statusCfg.Stdout.(*bytes.Buffer).Bytes(), &status); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if status.Sessions != 1 {
+		t.Errorf("expected 1 cached session, got %d", status.Sessions)
+	}
+
+	stopCfg := *cfg
+	stopCfg.Stdout = &bytes.Buffer{}
+	stopCfg.Stderr = &bytes.Buffer{}
+	if code := run([]string{"daemon", "stop"}, &stopCfg); code != ExitSuccess {
+		t.Fatalf("daemon stop failed: %s", stopCfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	select {
+	case code := <-done:
+		if code != ExitSuccess {
+			t.Errorf("daemon run exited with %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not exit after stop")
+	}
+}
@@ -10,7 +10,37 @@ import (
 
 func cmdQuery(cfg *Config, selector string) int {
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		return client.Query(ctx, target.ID, selector)
+		result, err := client.Query(ctx, target.ID, selector)
+		if err != nil || result.BackendNodeID == 0 {
+			return result, err
+		}
+		refs, err := handOutRefs(ctx, client, target.ID, []int64{result.BackendNodeID})
+		if err != nil {
+			return nil, err
+		}
+		result.Ref = refs[0]
+		return result, nil
+	})
+}
+
+func cmdQueryAll(cfg *Config, selector string) int {
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		elements, err := client.QueryAll(ctx, target.ID, selector)
+		if err != nil || len(elements) == 0 {
+			return elements, err
+		}
+		ids := make([]int64, len(elements))
+		for i, el := range elements {
+			ids[i] = el.BackendNodeID
+		}
+		refs, err := handOutRefs(ctx, client, target.ID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range elements {
+			elements[i].Ref = refs[i]
+		}
+		return elements, nil
 	})
 }
 
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+// downloadDir returns the absolute path of the directory downloads are saved
+// to, the current directory if -download-dir is not set, creating it if need
+// be. Chrome must be able to write to it at the same path.
+func downloadDir(cfg *Config) (string, error) {
+	dir := cfg.DownloadDir
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating download dir: %w", err)
+	}
+	return dir, nil
+}
+
+// WaitDownloadResult is returned by the waitdownload command.
+type WaitDownloadResult struct {
+	Filename string `json:"filename"` // Name suggested by the page
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	URL      string `json:"url"`
+}
+
+const waitDownloadUsage = "usage: hubcap waitdownload [--trigger <command>] [--timeout <duration>]"
+
+func cmdWaitDownload(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("waitdownload", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	timeout := fs.Duration("timeout", 30*time.Second, "Max wait time")
+	triggerLine := fs.String("trigger", "", "Command that starts the download, such as \"click #export\"")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+	if fs.NArg() > 0 {
+		return cmdMissingArg(cfg, waitDownloadUsage)
+	}
+
+	var trigger *trigger
+	if *triggerLine != "" {
+		var err error
+		if trigger, err = newTrigger(splitArgs(*triggerLine)); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	dir, err := downloadDir(cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client, err := connectClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(ctx, client, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	var fire func() error
+	if trigger != nil {
+		fire = func() error { return trigger.fire(cfg, target.ID) }
+	}
+	download, err := client.WaitForDownloadComplete(ctx, target.ID, dir, fire)
+	if err != nil {
+		switch {
+		case errors.Is(err, errTriggerFailed):
+			return trigger.code
+		case errors.Is(err, context.DeadlineExceeded):
+			fmt.Fprintln(cfg.Stderr, "error: timeout waiting for a download")
+			return ExitTimeout
+		}
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	result, err := saveDownload(download, dir)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	return outputResult(cfg, result)
+}
+
+// saveDownload gives a download Chrome saved under its GUID the name the
+// page suggested, made unique within dir, and hashes it.
+func saveDownload(download *chrome.DownloadResult, dir string) (*WaitDownloadResult, error) {
+	name := filepath.Base(download.SuggestedFilename)
+	if name == "." || name == string(filepath.Separator) {
+		name = "download"
+	}
+	path := uniquePath(filepath.Join(dir, name))
+	if err := os.Rename(download.Path, path); err != nil {
+		return nil, fmt.Errorf("saving download: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading download: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("reading download: %w", err)
+	}
+
+	return &WaitDownloadResult{
+		Filename: download.SuggestedFilename,
+		Path:     path,
+		Size:     size,
+		SHA256:   hex.EncodeToString(h.Sum(nil)),
+		URL:      download.URL,
+	}, nil
+}
+
+// uniquePath returns path, or if a file is there already, the path with
+// " (n)" added before the extension for the lowest n that is free, as
+// browsers name downloads.
+func uniquePath(path string) string {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, n, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
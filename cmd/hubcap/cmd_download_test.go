@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func TestUniquePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.csv")
+	if got := uniquePath(path); got != path {
+		t.Errorf("uniquePath() = %q, want %q", got, path)
+	}
+
+	os.WriteFile(path, nil, 0644)
+	os.WriteFile(filepath.Join(dir, "report (1).csv"), nil, 0644)
+	if got, want := uniquePath(path), filepath.Join(dir, "report (2).csv"); got != want {
+		t.Errorf("uniquePath() = %q, want %q", got, want)
+	}
+
+	os.WriteFile(filepath.Join(dir, "README"), nil, 0644)
+	if got, want := uniquePath(filepath.Join(dir, "README")), filepath.Join(dir, "README (1)"); got != want {
+		t.Errorf("uniquePath() = %q, want %q", got, want)
+	}
+}
+
+func TestSaveDownload(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "report.csv"), []byte("older"), 0644)
+	saved := filepath.Join(dir, "6a1f-guid")
+	os.WriteFile(saved, []byte("hello"), 0644)
+
+	download := &chrome.DownloadResult{
+		DownloadInfo: chrome.DownloadInfo{GUID: "6a1f-guid", URL: "https://x.test/export", SuggestedFilename: "report.csv"},
+		Path:         saved,
+		Size:         5,
+	}
+	result, err := saveDownload(download, dir)
+	if err != nil {
+		t.Fatalf("saveDownload: %v", err)
+	}
+
+	want := WaitDownloadResult{
+		Filename: "report.csv",
+		Path:     filepath.Join(dir, "report (1).csv"),
+		Size:     5,
+		SHA256:   "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		URL:      "https://x.test/export",
+	}
+	if *result != want {
+		t.Errorf("saveDownload() = %+v, want %+v", *result, want)
+	}
+	if _, err := os.Stat(saved); !os.IsNotExist(err) {
+		t.Error("expected the download to be renamed")
+	}
+}
+
+func TestSaveDownload_UnsafeName(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "guid")
+	os.WriteFile(saved, []byte("x"), 0644)
+
+	download := &chrome.DownloadResult{DownloadInfo: chrome.DownloadInfo{SuggestedFilename: "../../etc/passwd"}, Path: saved}
+	result, err := saveDownload(download, dir)
+	if err != nil {
+		t.Fatalf("saveDownload: %v", err)
+	}
+	if result.Path != filepath.Join(dir, "passwd") {
+		t.Errorf("expected the download to stay in the download dir, got %q", result.Path)
+	}
+}
+
+func TestDownloadDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "new", "downloads")
+	got, err := downloadDir(&Config{DownloadDir: dir})
+	if err != nil {
+		t.Fatalf("downloadDir: %v", err)
+	}
+	if got != dir {
+		t.Errorf("downloadDir() = %q, want %q", got, dir)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("expected the download dir to be created: %v", err)
+	}
+
+	cwd, _ := os.Getwd()
+	if got, err := downloadDir(&Config{}); err != nil || got != cwd {
+		t.Errorf("downloadDir() with no dir = %q, %v, want %q", got, err, cwd)
+	}
+}
+
+func TestRun_WaitDownload_UsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"waitdownload", "report.csv"}, "usage:"},
+		{[]string{"waitdownload", "--trigger", "nosuchcommand #export"}, "unknown command: nosuchcommand"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		code := run(tt.args, cfg)
+		if code != ExitError {
+			t.Errorf("%q: expected exit code %d, got %d", tt.args, ExitError, code)
+		}
+		if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, tt.want) {
+			t.Errorf("%q: expected %q in stderr, got %q", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestRun_WaitDownload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	t.Setenv("HUBCAP_CONFIG_DIR", t.TempDir())
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	page := `data:text/html,<html><body><a id="export" download="report.csv" href="data:text/csv,hello">Export</a></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	dir := t.TempDir()
+	cfg = testConfig()
+	code := run([]string{"--target", tabID, "--download-dir", dir, "waitdownload", "--trigger", "click #export", "--timeout", "10s"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result WaitDownloadResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Filename != "report.csv" || result.Path != filepath.Join(dir, "report.csv") || result.Size != 5 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected hash %s", result.SHA256)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"flag"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/tomyan/hubcap/internal/chrome"
+	"github.com/tomyan/hubcap/internal/heapsnapshot"
+	"github.com/tomyan/hubcap/internal/trace"
 )
 
 // --- EMULATION ---
@@ -38,6 +41,14 @@ func cmdEmulate(cfg *Config, deviceName string) int {
 		if err != nil {
 			return nil, err
 		}
+		err = updateTargetOverrides(configDir(), target.ID, func(o *targetOverrides) {
+			o.Device = &device
+			o.Viewport = nil
+			o.UserAgent = ""
+		})
+		if err != nil {
+			return nil, err
+		}
 		return EmulateResult{
 			Device:            device.Name,
 			Width:             device.Width,
@@ -58,6 +69,12 @@ func cmdUserAgent(cfg *Config, userAgent string) int {
 		if err != nil {
 			return nil, err
 		}
+		err = updateTargetOverrides(configDir(), target.ID, func(o *targetOverrides) {
+			o.UserAgent = userAgent
+		})
+		if err != nil {
+			return nil, err
+		}
 		return UserAgentResult{UserAgent: userAgent}, nil
 	})
 }
@@ -85,7 +102,14 @@ func cmdGeolocation(cfg *Config, latStr, lonStr string) int {
 		if err != nil {
 			return nil, err
 		}
-		return GeolocationResult{Latitude: lat, Longitude: lon, Accuracy: 1.0}, nil
+		result := GeolocationResult{Latitude: lat, Longitude: lon, Accuracy: 1.0}
+		err = updateTargetOverrides(configDir(), target.ID, func(o *targetOverrides) {
+			o.Geolocation = &result
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
 	})
 }
 
@@ -105,6 +129,12 @@ func cmdOffline(cfg *Config, offlineStr string) int {
 		if err != nil {
 			return nil, err
 		}
+		err = updateTargetOverrides(configDir(), target.ID, func(o *targetOverrides) {
+			o.Offline = offline
+		})
+		if err != nil {
+			return nil, err
+		}
 		return OfflineResult{Offline: offline}, nil
 	})
 }
@@ -146,11 +176,18 @@ func cmdMedia(cfg *Config, args []string) int {
 		if err != nil {
 			return nil, err
 		}
-		return MediaResult{
+		result := MediaResult{
 			ColorScheme:   *colorScheme,
 			ReducedMotion: *reducedMotion,
 			ForcedColors:  *forcedColors,
-		}, nil
+		}
+		err = updateTargetOverrides(configDir(), target.ID, func(o *targetOverrides) {
+			o.Media = &result
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
 	})
 }
 
@@ -519,6 +556,17 @@ func cmdA11y(cfg *Config) int {
 		if err != nil {
 			return nil, err
 		}
+		ids := make([]int64, len(nodes))
+		for i, n := range nodes {
+			ids[i] = n.BackendNodeID
+		}
+		refs, err := handOutPageRefs(ctx, client, target.ID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range nodes {
+			nodes[i].Ref = refs[i]
+		}
 		return A11yResult{Nodes: nodes}, nil
 	})
 }
@@ -532,6 +580,15 @@ type HeapSnapshotCLIResult struct {
 }
 
 func cmdHeapSnapshot(cfg *Config, args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "summary":
+			return cmdHeapSnapshotSummary(cfg, args[1:])
+		case "diff":
+			return cmdHeapSnapshotDiff(cfg, args[1:])
+		}
+	}
+
 	fs := flag.NewFlagSet("heapsnapshot", flag.ContinueOnError)
 	fs.SetOutput(cfg.Stderr)
 	output := fs.String("output", "", "Output file path")
@@ -550,22 +607,145 @@ func cmdHeapSnapshot(cfg *Config, args []string) int {
 
 	outputFile := *output
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		data, err := client.TakeHeapSnapshot(ctx, target.ID)
+		size, err := writeHeapSnapshot(ctx, client, target.ID, outputFile)
 		if err != nil {
 			return nil, err
 		}
 
-		if err := os.WriteFile(outputFile, data, 0644); err != nil {
-			return nil, fmt.Errorf("writing file: %w", err)
-		}
-
 		return HeapSnapshotCLIResult{
 			File: outputFile,
-			Size: len(data),
+			Size: int(size),
 		}, nil
 	})
 }
 
+// writeHeapSnapshot streams a heap snapshot of the target to path,
+// removing the partial file if the snapshot fails.
+func writeHeapSnapshot(ctx context.Context, client *chrome.Client, targetID, path string) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("writing file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	size, err := client.TakeHeapSnapshot(ctx, targetID, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return size, nil
+}
+
+// readHeapSnapshot parses a heap snapshot file.
+func readHeapSnapshot(path string) (*heapsnapshot.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading heap snapshot: %w", err)
+	}
+	defer f.Close()
+
+	snapshot, err := heapsnapshot.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+func cmdHeapSnapshotSummary(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("heapsnapshot summary", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	limit := fs.Int("limit", 20, "Number of constructors to list (0 = all)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() > 1 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap heapsnapshot summary [--limit <n>] [file]")
+		return ExitError
+	}
+
+	summarize := func(path string) (*heapsnapshot.Summary, error) {
+		snapshot, err := readHeapSnapshot(path)
+		if err != nil {
+			return nil, err
+		}
+		summary := snapshot.Summarize()
+		if *limit > 0 && len(summary.Constructors) > *limit {
+			summary.Constructors = summary.Constructors[:*limit]
+		}
+		return summary, nil
+	}
+
+	if fs.NArg() == 1 {
+		summary, err := summarize(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+		return outputResult(cfg, summary)
+	}
+
+	// No file given, so summarize a fresh snapshot of the target
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		f, err := os.CreateTemp("", "hubcap-*.heapsnapshot")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+
+		if _, err := writeHeapSnapshot(ctx, client, target.ID, f.Name()); err != nil {
+			return nil, err
+		}
+		return summarize(f.Name())
+	})
+}
+
+func cmdHeapSnapshotDiff(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("heapsnapshot diff", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	limit := fs.Int("limit", 20, "Number of constructors to list (0 = all)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() != 2 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap heapsnapshot diff [--limit <n>] <before> <after>")
+		return ExitError
+	}
+
+	before, err := readHeapSnapshot(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	after, err := readHeapSnapshot(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	diff := heapsnapshot.Compare(before, after)
+	if *limit > 0 && len(diff.Constructors) > *limit {
+		diff.Constructors = diff.Constructors[:*limit]
+	}
+	return outputResult(cfg, diff)
+}
+
 // TraceCLIResult wraps trace result for CLI output.
 type TraceCLIResult struct {
 	File string `json:"file"`
@@ -573,10 +753,15 @@ type TraceCLIResult struct {
 }
 
 func cmdTrace(cfg *Config, args []string) int {
+	if len(args) > 0 && args[0] == "summary" {
+		return cmdTraceSummary(cfg, args[1:])
+	}
+
 	fs := flag.NewFlagSet("trace", flag.ContinueOnError)
 	fs.SetOutput(cfg.Stderr)
 	output := fs.String("output", "", "Output file path")
-	duration := fs.Duration("duration", 1*time.Second, "Trace duration")
+	until := addStopFlags(fs, 1*time.Second, "Trace duration")
+	categories := fs.String("categories", "", "Comma-separated trace categories (default DevTools timeline categories)")
 
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
@@ -591,9 +776,12 @@ func cmdTrace(cfg *Config, args []string) int {
 	}
 
 	outputFile := *output
-	traceDuration := *duration
+	opts := chrome.TraceOptions{
+		Categories: *categories,
+		Until:      until.condition(),
+	}
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		data, err := client.CaptureTrace(ctx, target.ID, traceDuration)
+		data, err := client.CaptureTrace(ctx, target.ID, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -609,6 +797,42 @@ func cmdTrace(cfg *Config, args []string) int {
 	})
 }
 
+func cmdTraceSummary(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("trace summary", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap trace summary <file>")
+		return ExitError
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: reading trace: %v\n", err)
+		return ExitError
+	}
+	defer f.Close()
+
+	t, err := trace.Parse(f)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	summary, err := t.Summarize()
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	return outputResult(cfg, summary)
+}
+
 // --- ADVANCED ---
 
 func cmdEval(cfg *Config, expression string) int {
@@ -689,7 +913,7 @@ func cmdRaw(cfg *Config, args []string) int {
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
 	defer cancel()
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
@@ -703,7 +927,7 @@ func cmdRaw(cfg *Config, args []string) int {
 		result, err = client.RawCall(ctx, method, params)
 	} else {
 		// Session-level command (to resolved target)
-		target, targetErr := resolveTarget(ctx, client, cfg)
+		target, targetErr := prepareTarget(ctx, client, cfg)
 		if targetErr != nil {
 			fmt.Fprintf(cfg.Stderr, "error: %v\n", targetErr)
 			return ExitError
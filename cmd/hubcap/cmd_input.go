@@ -2,7 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -76,9 +81,30 @@ type TypeResult struct {
 	Text  string `json:"text"`
 }
 
-func cmdType(cfg *Config, text string) int {
+func cmdType(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("type", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	delay := fs.Duration("delay", 0, "Pause between characters, such as 50ms")
+	insert := fs.Bool("insert", false, "Insert the text as an input method would, without key events")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() != 1 {
+		return cmdMissingArg(cfg, "usage: hubcap type [--delay <d>] [--insert] <text>")
+	}
+	if *delay < 0 {
+		fmt.Fprintln(cfg.Stderr, "error: --delay must not be negative")
+		return ExitError
+	}
+	text := fs.Arg(0)
+
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		err := client.Type(ctx, target.ID, text)
+		err := client.TypeWithOptions(ctx, target.ID, text, chrome.TypeOptions{Delay: *delay, Insert: *insert})
 		if err != nil {
 			return nil, err
 		}
@@ -108,34 +134,15 @@ type PressResult struct {
 	Key     string `json:"key"`
 }
 
-func cmdPress(cfg *Config, key string) int {
+func cmdPress(cfg *Config, keys []string) int {
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		// Parse modifier+key combinations like "Ctrl+A", "Shift+End", "Ctrl+Shift+N"
-		mods := chrome.KeyModifiers{}
-		actualKey := key
-
-		parts := strings.Split(key, "+")
-		if len(parts) > 1 {
-			actualKey = parts[len(parts)-1]
-			for _, mod := range parts[:len(parts)-1] {
-				switch strings.ToLower(mod) {
-				case "ctrl", "control":
-					mods.Ctrl = true
-				case "alt":
-					mods.Alt = true
-				case "shift":
-					mods.Shift = true
-				case "meta", "cmd", "command":
-					mods.Meta = true
-				}
-			}
-		}
-
-		err := client.PressKeyWithModifiers(ctx, target.ID, actualKey, mods)
+		// Each argument is a key or a chord such as "Control+Shift+K",
+		// pressed in turn
+		err := client.PressKeys(ctx, target.ID, keys)
 		if err != nil {
 			return nil, err
 		}
-		return PressResult{Pressed: true, Key: key}, nil
+		return PressResult{Pressed: true, Key: strings.Join(keys, " ")}, nil
 	})
 }
 
@@ -176,15 +183,36 @@ type DragResult struct {
 	Dragged bool   `json:"dragged"`
 	Source  string `json:"source"`
 	Dest    string `json:"dest"`
+	Mode    string `json:"mode"` // html5 or pointer
 }
 
-func cmdDrag(cfg *Config, source, dest string) int {
+func cmdDrag(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("drag", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	steps := fs.Int("steps", 1, "Number of mouse moves from the source to the destination")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() != 2 {
+		return cmdMissingArg(cfg, "usage: hubcap drag [--steps <n>] <source-selector> <dest-selector>")
+	}
+	if *steps < 1 {
+		fmt.Fprintln(cfg.Stderr, "error: --steps must be at least 1")
+		return ExitError
+	}
+	source, dest := fs.Arg(0), fs.Arg(1)
+
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		err := client.Drag(ctx, target.ID, source, dest)
+		mode, err := client.DragWithOptions(ctx, target.ID, source, dest, chrome.DragOptions{Steps: *steps})
 		if err != nil {
 			return nil, err
 		}
-		return DragResult{Dragged: true, Source: source, Dest: dest}, nil
+		return DragResult{Dragged: true, Source: source, Dest: dest, Mode: mode}, nil
 	})
 }
 
@@ -287,18 +315,131 @@ func cmdUncheck(cfg *Config, selector string) int {
 
 // UploadResult is returned by the upload command.
 type UploadResult struct {
-	Uploaded bool     `json:"uploaded"`
+	Uploaded bool                      `json:"uploaded"`
+	Selector string                    `json:"selector,omitempty"`
+	Files    []string                  `json:"files"`
+	Chooser  *chrome.FileChooserResult `json:"chooser,omitempty"`
+}
+
+const uploadUsage = "usage: hubcap upload <selector> <file>...\n" +
+	"       hubcap upload --on-chooser <file>... -- <command> [args...]"
+
+func cmdUpload(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	onChooser := fs.Bool("on-chooser", false, "Give the files to the file chooser opened by the command after --")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if !*onChooser {
+		if fs.NArg() < 2 {
+			return cmdMissingArg(cfg, uploadUsage)
+		}
+		selector, files := fs.Arg(0), fs.Args()[1:]
+		return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+			err := client.UploadFile(ctx, target.ID, selector, files)
+			if err != nil {
+				return nil, err
+			}
+			return UploadResult{Uploaded: true, Selector: selector, Files: files}, nil
+		})
+	}
+
+	rest := fs.Args()
+	dashes := slices.Index(rest, "--")
+	if dashes < 1 || dashes == len(rest)-1 {
+		return cmdMissingArg(cfg, uploadUsage)
+	}
+	files, err := absFiles(rest[:dashes])
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	trigger, err := newTrigger(rest[dashes+1:])
+	if err != nil {
+		fmt.Fprintln(cfg.Stderr, err)
+		return ExitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	client, err := connectClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(ctx, client, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	chooser, err := client.UploadOnChooser(ctx, target.ID, files, func() error {
+		return trigger.fire(cfg, target.ID)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTriggerFailed):
+			return trigger.code
+		case errors.Is(err, context.DeadlineExceeded):
+			fmt.Fprintln(cfg.Stderr, "error: timeout waiting for a file chooser")
+			return ExitTimeout
+		}
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	return outputResult(cfg, UploadResult{Uploaded: true, Files: files, Chooser: chooser})
+}
+
+// absFiles returns the absolute paths of files, which must exist, for
+// Chrome to read them whatever its working directory.
+func absFiles(files []string) ([]string, error) {
+	abs := make([]string, len(files))
+	for i, file := range files {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		abs[i] = path
+	}
+	return abs, nil
+}
+
+// DropFilesResult is returned by the dropfiles command.
+type DropFilesResult struct {
+	Dropped  bool     `json:"dropped"`
 	Selector string   `json:"selector"`
 	Files    []string `json:"files"`
 }
 
-func cmdUpload(cfg *Config, selector string, files []string) int {
+func cmdDropFiles(cfg *Config, args []string) int {
+	if len(args) < 2 {
+		return cmdMissingArg(cfg, "usage: hubcap dropfiles <selector> <file>...")
+	}
+	selector := args[0]
+	files, err := absFiles(args[1:])
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		err := client.UploadFile(ctx, target.ID, selector, files)
-		if err != nil {
+		if err := client.DropFiles(ctx, target.ID, selector, files); err != nil {
 			return nil, err
 		}
-		return UploadResult{Uploaded: true, Selector: selector, Files: files}, nil
+		return DropFilesResult{Dropped: true, Selector: selector, Files: files}, nil
 	})
 }
 
@@ -328,23 +469,6 @@ func cmdDispatch(cfg *Config, selector, eventType string) int {
 	})
 }
 
-func cmdMouse(cfg *Config, xStr, yStr string) int {
-	x, err := strconv.ParseFloat(xStr, 64)
-	if err != nil {
-		fmt.Fprintf(cfg.Stderr, "invalid x coordinate: %v\n", err)
-		return ExitError
-	}
-	y, err := strconv.ParseFloat(yStr, 64)
-	if err != nil {
-		fmt.Fprintf(cfg.Stderr, "invalid y coordinate: %v\n", err)
-		return ExitError
-	}
-
-	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		return client.MouseMove(ctx, target.ID, x, y)
-	})
-}
-
 // SwipeCLIResult wraps swipe result for CLI output.
 type SwipeCLIResult struct {
 	Swiped    bool   `json:"swiped"`
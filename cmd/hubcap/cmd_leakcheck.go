@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+	"github.com/tomyan/hubcap/internal/heapsnapshot"
+)
+
+func init() {
+	commands["leakcheck"] = CommandInfo{
+		Name:     "leakcheck",
+		Desc:     "Find objects that leak when repeating a script",
+		Category: "Profile",
+		Run:      func(cfg *Config, args []string) int { return cmdLeakCheck(cfg, args) },
+	}
+}
+
+// maxRetainerPaths is the number of distinct retainer paths reported per
+// leaking constructor.
+const maxRetainerPaths = 3
+
+// elementIndex matches array indexes in retainer paths.
+var elementIndex = regexp.MustCompile(`\[\d+\]`)
+
+// LeakCheckResult is returned by the leakcheck command.
+type LeakCheckResult struct {
+	Iterations     int    `json:"iterations"`
+	NodeCountDelta int    `json:"nodeCountDelta"`
+	TotalSizeDelta int64  `json:"totalSizeDelta"`
+	Leaks          []Leak `json:"leaks"`
+}
+
+// Leak is a constructor whose objects grew by about the same number with
+// every iteration.
+type Leak struct {
+	Name         string   `json:"name"`
+	CountBefore  int      `json:"countBefore"`
+	CountAfter   int      `json:"countAfter"`
+	PerIteration int      `json:"perIteration"`
+	Counts       []int    `json:"counts"` // Before the first iteration and after each
+	SizeDelta    int64    `json:"sizeDelta"`
+	Retainers    []string `json:"retainers"`
+}
+
+// scriptLine is a hubcap command read from a script file.
+type scriptLine struct {
+	Line int
+	Args []string
+}
+
+func cmdLeakCheck(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("leakcheck", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	script := fs.String("script", "", "File of hubcap commands performing the action")
+	iterations := fs.Int("iterations", 5, "Number of times to repeat the action")
+	warmup := fs.Int("warmup", 1, "Runs before the first snapshot")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if *script == "" || fs.NArg() > 0 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap leakcheck --script <file> [--iterations <n>] [--warmup <n>]")
+		return ExitError
+	}
+	if *iterations < 1 || *warmup < 0 {
+		fmt.Fprintln(cfg.Stderr, "error: --iterations must be at least 1 and --warmup at least 0")
+		return ExitError
+	}
+
+	lines, err := loadScript(*script)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Each step, such as connecting, a run of the script or a snapshot,
+	// must finish within the global -timeout
+	step := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, cfg.Timeout)
+	}
+
+	stepCtx, cancel := step()
+	defer cancel()
+	client, err := connectClient(stepCtx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(stepCtx, client, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	// Script commands run against the same target, with their output dropped
+	scriptCfg := *cfg
+	scriptCfg.Target = target.ID
+	scriptCfg.Stdout = io.Discard
+	runStep := func() int {
+		stepCtx, cancel := step()
+		defer cancel()
+		return runScript(stepCtx, &scriptCfg, *script, lines)
+	}
+	snapshotStep := func() (*heapsnapshot.Snapshot, int) {
+		stepCtx, cancel := step()
+		defer cancel()
+		snap, err := leakSnapshot(stepCtx, client, target.ID)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				fmt.Fprintln(cfg.Stderr, "error: timeout taking a heap snapshot")
+				return nil, ExitTimeout
+			}
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return nil, ExitError
+		}
+		return snap, ExitSuccess
+	}
+
+	for i := 0; i < *warmup; i++ {
+		if code := runStep(); code != ExitSuccess {
+			return code
+		}
+	}
+
+	before, code := snapshotStep()
+	if code != ExitSuccess {
+		return code
+	}
+
+	// A snapshot after every iteration tells steady growth from a one-off
+	// allocation of the same size
+	counts := []map[string]int{before.ClassCounts()}
+	after := before
+	for i := 0; i < *iterations; i++ {
+		if code := runStep(); code != ExitSuccess {
+			return code
+		}
+		if after, code = snapshotStep(); code != ExitSuccess {
+			return code
+		}
+		counts = append(counts, after.ClassCounts())
+	}
+
+	result := findLeaks(before, after, counts)
+	if code := outputResult(cfg, result); code != ExitSuccess {
+		return code
+	}
+	if len(result.Leaks) > 0 {
+		fmt.Fprintf(cfg.Stderr, "error: %d constructor(s) grew with every iteration\n", len(result.Leaks))
+		return ExitError
+	}
+	return ExitSuccess
+}
+
+// loadScript reads a file of hubcap commands, one per line as for pipe.
+// Blank lines and lines starting with # are skipped.
+func loadScript(path string) ([]scriptLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading script: %w", err)
+	}
+	defer f.Close()
+
+	var lines []scriptLine
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args := splitArgs(line)
+		if _, ok := commands[args[0]]; !ok {
+			return nil, fmt.Errorf("%s:%d: unknown command: %s", path, n, args[0])
+		}
+		if args[0] == "leakcheck" || args[0] == "vitals" {
+			return nil, fmt.Errorf("%s:%d: %s cannot be used in a script", path, n, args[0])
+		}
+		lines = append(lines, scriptLine{Line: n, Args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading script: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%s: no commands", path)
+	}
+	return lines, nil
+}
+
+// runScript runs each command of a script, stopping at the first failure.
+func runScript(ctx context.Context, cfg *Config, path string, lines []scriptLine) int {
+	for _, line := range lines {
+		if ctx.Err() != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %s:%d: timeout before %s\n", path, line.Line, line.Args[0])
+			return ExitTimeout
+		}
+		if code := commands[line.Args[0]].Run(cfg, line.Args[1:]); code != ExitSuccess {
+			fmt.Fprintf(cfg.Stderr, "error: %s:%d: %s failed\n", path, line.Line, line.Args[0])
+			return code
+		}
+	}
+	return ExitSuccess
+}
+
+// leakSnapshot collects garbage and parses a heap snapshot of the target.
+func leakSnapshot(ctx context.Context, client *chrome.Client, targetID string) (*heapsnapshot.Snapshot, error) {
+	if err := client.CollectGarbage(ctx, targetID); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "hubcap-*.heapsnapshot")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if _, err := writeHeapSnapshot(ctx, client, targetID, f.Name()); err != nil {
+		return nil, err
+	}
+	return readHeapSnapshot(f.Name())
+}
+
+// findLeaks reports the constructors whose count grew by a roughly
+// constant amount with every iteration, given the counts of each
+// constructor before the first iteration and after each, with example
+// retainer paths of their new objects.
+func findLeaks(before, after *heapsnapshot.Snapshot, counts []map[string]int) *LeakCheckResult {
+	diff := heapsnapshot.Compare(before, after)
+	result := &LeakCheckResult{
+		Iterations:     len(counts) - 1,
+		NodeCountDelta: diff.NodeCountDelta,
+		TotalSizeDelta: diff.TotalSizeDelta,
+		Leaks:          []Leak{},
+	}
+
+	var retainers *heapsnapshot.Retainers
+	for _, c := range diff.Constructors {
+		series := make([]int, len(counts))
+		for i, count := range counts {
+			series[i] = count[c.Name]
+		}
+		perIteration, ok := steadyGrowth(series)
+		if !ok {
+			continue
+		}
+		if retainers == nil {
+			retainers = after.Retainers()
+		}
+
+		leak := Leak{
+			Name:         c.Name,
+			CountBefore:  c.CountBefore,
+			CountAfter:   c.CountAfter,
+			PerIteration: perIteration,
+			Counts:       series,
+			SizeDelta:    c.SizeDelta,
+			Retainers:    []string{},
+		}
+		// Objects leaked into the same array differ only by index, so
+		// report one of them
+		seen := make(map[string]bool)
+		for _, node := range heapsnapshot.NewObjects(before, after, c.Name) {
+			path := retainers.Path(node)
+			shape := elementIndex.ReplaceAllString(path, "[]")
+			if path == "" || seen[shape] {
+				continue
+			}
+			seen[shape] = true
+			leak.Retainers = append(leak.Retainers, path)
+			if len(leak.Retainers) == maxRetainerPaths {
+				break
+			}
+		}
+		result.Leaks = append(result.Leaks, leak)
+	}
+	return result
+}
+
+// steadyGrowth reports whether a series of counts, taken before the first
+// iteration and after each, grew with every iteration by a roughly constant
+// amount: between half and twice the median growth. It returns the mean
+// growth per iteration, rounded.
+func steadyGrowth(series []int) (int, bool) {
+	n := len(series) - 1
+	if n < 1 {
+		return 0, false
+	}
+	deltas := make([]int, n)
+	for i := range deltas {
+		deltas[i] = series[i+1] - series[i]
+	}
+	sorted := slices.Clone(deltas)
+	slices.Sort(sorted)
+	median := sorted[(n-1)/2]
+	if median < 1 {
+		return 0, false
+	}
+	for _, d := range deltas {
+		if 2*d < median || d > 2*median {
+			return 0, false
+		}
+	}
+	total := series[n] - series[0]
+	return (total + n/2) / n, true
+}
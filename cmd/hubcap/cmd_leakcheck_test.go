@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/heapsnapshot"
+)
+
+func writeScript(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "action.hubcap")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	return path
+}
+
+func TestLoadScript(t *testing.T) {
+	path := writeScript(t, `# Open and close the dialog
+click "#open dialog"
+
+wait '.dialog'
+press Escape
+`)
+
+	lines, err := loadScript(path)
+	if err != nil {
+		t.Fatalf("loadScript: %v", err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	if lines[0].Line != 2 || lines[0].Args[0] != "click" || lines[0].Args[1] != "#open dialog" {
+		t.Errorf("unexpected first line: %+v", lines[0])
+	}
+	if lines[2].Line != 5 || strings.Join(lines[2].Args, " ") != "press Escape" {
+		t.Errorf("unexpected last line: %+v", lines[2])
+	}
+}
+
+func TestRunScript_Timeout(t *testing.T) {
+	path := writeScript(t, "click '#go'\n")
+	lines, err := loadScript(path)
+	if err != nil {
+		t.Fatalf("loadScript: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg := testConfig()
+	if code := runScript(ctx, cfg, path, lines); code != ExitTimeout {
+		t.Errorf("expected exit code %d, got %d", ExitTimeout, code)
+	}
+	if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, ":1: timeout before click") {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+}
+
+func TestLoadScript_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "# nothing\n", "no commands"},
+		{"unknown command", "click a\nfrobnicate\n", ":2: unknown command: frobnicate"},
+		{"recursive", "leakcheck --script x\n", "leakcheck cannot be used in a script"},
+		{"vitals", "click a\nvitals --script x\n", ":2: vitals cannot be used in a script"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadScript(writeScript(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindLeaks(t *testing.T) {
+	parse := func(data string) *heapsnapshot.Snapshot {
+		s, err := heapsnapshot.Parse(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("parsing snapshot: %v", err)
+		}
+		return s
+	}
+
+	before := parse(testHeapSnapshot(2))
+	counts := func(foos ...int) []map[string]int {
+		var c []map[string]int
+		for _, n := range foos {
+			c = append(c, map[string]int{"Window": 1, "Foo": n})
+		}
+		return c
+	}
+
+	// Three iterations adding one Foo each is a leak
+	result := findLeaks(before, parse(testHeapSnapshot(5)), counts(2, 3, 4, 5))
+	if len(result.Leaks) != 1 {
+		t.Fatalf("expected one leak, got %+v", result.Leaks)
+	}
+	leak := result.Leaks[0]
+	if leak.Name != "Foo" || leak.CountBefore != 2 || leak.CountAfter != 5 || leak.PerIteration != 1 || leak.SizeDelta != 300 {
+		t.Errorf("unexpected leak: %+v", leak)
+	}
+	if !slices.Equal(leak.Counts, []int{2, 3, 4, 5}) {
+		t.Errorf("unexpected counts: %v", leak.Counts)
+	}
+	if len(leak.Retainers) != 1 || leak.Retainers[0] != "Window.foo → Foo" {
+		t.Errorf("unexpected retainers: %q", leak.Retainers)
+	}
+
+	// The same growth all in one iteration is not
+	result = findLeaks(before, parse(testHeapSnapshot(5)), counts(2, 5, 5, 5))
+	if len(result.Leaks) != 0 {
+		t.Errorf("expected no leaks, got %+v", result.Leaks)
+	}
+}
+
+func TestSteadyGrowth(t *testing.T) {
+	tests := []struct {
+		series []int
+		want   int
+		ok     bool
+	}{
+		{[]int{10, 20, 30, 40}, 10, true},
+		{[]int{10, 19, 31, 40, 52}, 11, true}, // Noisy, but growing steadily
+		{[]int{5, 6}, 1, true},
+		{[]int{10, 40, 40, 40}, 0, false}, // One-off allocation
+		{[]int{10, 40, 41, 42}, 0, false}, // One-off, then slow growth
+		{[]int{10, 20, 20, 30}, 0, false}, // No growth in one iteration
+		{[]int{10, 20, 30, 25}, 0, false}, // Shrank
+		{[]int{10, 12, 14, 30}, 0, false}, // Jumped
+		{[]int{10, 10, 10, 10}, 0, false},
+		{[]int{10}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := steadyGrowth(tt.series)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("steadyGrowth(%v) = %d, %v, want %d, %v", tt.series, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLeakCheck_RequiresScript(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"leakcheck"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
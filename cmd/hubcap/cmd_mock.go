@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"mime"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["mock"] = CommandInfo{
+		Name:     "mock",
+		Desc:     "Answer requests from a rules file",
+		Category: "Network & monitor",
+		Run:      func(cfg *Config, args []string) int { return cmdMock(cfg, args) },
+	}
+}
+
+// mockRuleSpec is a rule as written in a rules file.
+type mockRuleSpec struct {
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	URLRegex     string            `json:"urlRegex"`
+	Method       string            `json:"method"`
+	ResourceType string            `json:"resourceType"`
+	BodyContains string            `json:"bodyContains"`
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers"`
+	Body         *string           `json:"body"`
+	Fixture      string            `json:"fixture"`
+	Delay        string            `json:"delay"`
+	Abort        string            `json:"abort"`
+}
+
+func cmdMock(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("mock", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	rulesFile := fs.String("rules", "", "JSON file of mock rules (required)")
+	duration := fs.Duration("duration", 0, "How long to mock (0 = until interrupted)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if *rulesFile == "" {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap mock --rules <file> [--duration <d>]")
+		return ExitError
+	}
+
+	rules, err := loadMockRules(*rulesFile)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	client, err := connectClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(ctx, client, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	events, stopMock, err := client.Mock(ctx, target.ID, rules)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	defer stopMock() // Stop intercepting on exit
+
+	enc := json.NewEncoder(cfg.Stdout)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return ExitSuccess
+			}
+			if err := enc.Encode(ev); err != nil {
+				fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+				return ExitError
+			}
+		case <-ctx.Done():
+			return ExitSuccess
+		}
+	}
+}
+
+// loadMockRules reads a rules file, which holds either an array of rules or
+// an object with a "rules" array. Fixture paths are relative to the file.
+func loadMockRules(path string) ([]chrome.MockRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules: %w", err)
+	}
+
+	var specs []mockRuleSpec
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var wrapper struct {
+			Rules []mockRuleSpec `json:"rules"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("parsing rules: %w", err)
+		}
+		specs = wrapper.Rules
+	} else if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("parsing rules: %w", err)
+	}
+
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no rules in %s", path)
+	}
+
+	dir := filepath.Dir(path)
+	rules := make([]chrome.MockRule, 0, len(specs))
+	for i, spec := range specs {
+		name := spec.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		rule := chrome.MockRule{
+			Name:         spec.Name,
+			URL:          spec.URL,
+			URLRegex:     spec.URLRegex,
+			Method:       spec.Method,
+			ResourceType: spec.ResourceType,
+			BodyContains: spec.BodyContains,
+			Status:       spec.Status,
+			Headers:      spec.Headers,
+			Abort:        spec.Abort,
+		}
+
+		if spec.Body != nil && spec.Fixture != "" {
+			return nil, fmt.Errorf("rule %s: body and fixture are mutually exclusive", name)
+		}
+		if spec.Body != nil {
+			rule.Body = []byte(*spec.Body)
+		}
+		if spec.Fixture != "" {
+			fixture := spec.Fixture
+			if !filepath.IsAbs(fixture) {
+				fixture = filepath.Join(dir, fixture)
+			}
+			rule.Body, err = os.ReadFile(fixture)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: reading fixture: %w", name, err)
+			}
+			if contentType := mime.TypeByExtension(filepath.Ext(fixture)); contentType != "" && !hasHeader(rule.Headers, "Content-Type") {
+				if rule.Headers == nil {
+					rule.Headers = map[string]string{}
+				}
+				rule.Headers["Content-Type"] = contentType
+			}
+		}
+
+		if spec.Delay != "" {
+			rule.Delay, err = time.ParseDuration(spec.Delay)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid delay: %w", name, err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// hasHeader reports whether headers contains name, ignoring case.
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
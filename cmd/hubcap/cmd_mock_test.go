@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRulesFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "mocks.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing rules: %v", err)
+	}
+	return path
+}
+
+func TestLoadMockRules_Array(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRulesFile(t, dir, `[
+		{"name": "users", "url": "*/api/users", "method": "GET", "status": 201, "body": "[]", "delay": "250ms"},
+		{"urlRegex": "\\.png$", "abort": "BlockedByClient"}
+	]`)
+
+	rules, err := loadMockRules(path)
+	if err != nil {
+		t.Fatalf("loadMockRules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	if rules[0].Name != "users" || rules[0].Status != 201 || string(rules[0].Body) != "[]" {
+		t.Errorf("unexpected first rule: %+v", rules[0])
+	}
+	if rules[0].Delay != 250*time.Millisecond {
+		t.Errorf("Delay = %v, want 250ms", rules[0].Delay)
+	}
+	if rules[1].Abort != "BlockedByClient" || rules[1].Body != nil {
+		t.Errorf("unexpected second rule: %+v", rules[1])
+	}
+}
+
+func TestLoadMockRules_FixtureRelativeToRulesFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "user.json"), []byte(`{"id":1}`), 0644); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	path := writeRulesFile(t, dir, `{"rules": [{"url": "*/api/user", "fixture": "user.json"}]}`)
+
+	rules, err := loadMockRules(path)
+	if err != nil {
+		t.Fatalf("loadMockRules: %v", err)
+	}
+	if string(rules[0].Body) != `{"id":1}` {
+		t.Errorf("Body = %q, want fixture contents", rules[0].Body)
+	}
+	if ct := rules[0].Headers["Content-Type"]; !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
+
+func TestLoadMockRules_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", `[]`, "no rules"},
+		{"bad delay", `[{"url": "*", "delay": "soon"}]`, "invalid delay"},
+		{"body and fixture", `[{"url": "*", "body": "x", "fixture": "x.json"}]`, "mutually exclusive"},
+		{"missing fixture", `[{"url": "*", "fixture": "missing.json"}]`, "reading fixture"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeRulesFile(t, t.TempDir(), tt.content)
+			_, err := loadMockRules(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMock_RequiresRules(t *testing.T) {
+	cfg, _ := setupTestConfig(t)
+
+	code := run([]string{"mock"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
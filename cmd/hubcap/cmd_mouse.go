@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+// mouseState is where a target's mouse was left and the buttons held down,
+// so that a press, moves and release can be separate commands.
+type mouseState struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Buttons int     `json:"buttons,omitempty"` // Buttons held, as MouseEvent.buttons
+}
+
+// mouseStatePath returns the path of the mouse state file for a target.
+func mouseStatePath(dir, targetID string) string {
+	return filepath.Join(dir, "mouse", targetID+".json")
+}
+
+// loadMouseState loads the mouse state of a target.
+// Returns the mouse at the top left with no buttons held if none is saved.
+func loadMouseState(dir, targetID string) (*mouseState, error) {
+	s := &mouseState{}
+	data, err := os.ReadFile(mouseStatePath(dir, targetID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("reading mouse state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing mouse state: %w", err)
+	}
+	return s, nil
+}
+
+// saveMouseState saves the mouse state of a target.
+func saveMouseState(dir, targetID string, s *mouseState) error {
+	if err := os.MkdirAll(filepath.Join(dir, "mouse"), 0755); err != nil {
+		return fmt.Errorf("creating mouse dir: %w", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshaling mouse state: %w", err)
+	}
+	if err := os.WriteFile(mouseStatePath(dir, targetID), data, 0644); err != nil {
+		return fmt.Errorf("writing mouse state: %w", err)
+	}
+	return nil
+}
+
+// MouseResult is returned by the mouse command.
+type MouseResult struct {
+	Action  string   `json:"action"`
+	X       float64  `json:"x"`
+	Y       float64  `json:"y"`
+	Button  string   `json:"button,omitempty"`
+	DeltaX  float64  `json:"deltaX,omitempty"`
+	DeltaY  float64  `json:"deltaY,omitempty"`
+	Buttons []string `json:"buttons"` // Buttons held after the action
+}
+
+const mouseUsage = "usage: hubcap mouse [move] [--steps <n>] <x> <y>\n" +
+	"       hubcap mouse down|up [--button <b>] [--click-count <n>] [--modifiers <keys>] [<x> <y>]\n" +
+	"       hubcap mouse wheel [--modifiers <keys>] <dx> <dy> [<x> <y>]"
+
+func cmdMouse(cfg *Config, args []string) int {
+	if len(args) == 0 {
+		return cmdMissingArg(cfg, mouseUsage)
+	}
+
+	action := "move"
+	switch args[0] {
+	case "move", "down", "up", "wheel":
+		action = args[0]
+		args = args[1:]
+	default:
+		// mouse <x> <y> moves, as it always has
+		if _, err := strconv.ParseFloat(args[0], 64); err != nil && !strings.HasPrefix(args[0], "-") {
+			fmt.Fprintf(cfg.Stderr, "unknown mouse subcommand: %s\n", args[0])
+			fmt.Fprintln(cfg.Stderr, "subcommands: move, down, up, wheel")
+			return ExitError
+		}
+	}
+
+	fs := flag.NewFlagSet("mouse "+action, flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	modifiers := fs.String("modifiers", "", "Modifier keys held, such as Shift,Control")
+	var steps, clickCount *int
+	var button *string
+	switch action {
+	case "move":
+		steps = fs.Int("steps", 1, "Number of mouse moves to get there")
+	case "down", "up":
+		button = fs.String("button", "left", "Button: left, right, middle, back or forward")
+		clickCount = fs.Int("click-count", 1, "Presses in quick succession, 2 for the second of a double click")
+	}
+
+	nums, err := parseNumericArgs(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		if _, ok := err.(*strconv.NumError); ok {
+			fmt.Fprintf(cfg.Stderr, "error: invalid coordinate: %v\n", err)
+		}
+		return ExitError
+	}
+
+	opts := chrome.MouseOptions{}
+	if opts.Modifiers, err = chrome.ParseKeyModifiers(*modifiers); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	if button != nil {
+		if _, err := chrome.MouseButtonMask(*button); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+		if *clickCount < 1 {
+			fmt.Fprintln(cfg.Stderr, "error: --click-count must be at least 1")
+			return ExitError
+		}
+		opts.Button = *button
+		opts.ClickCount = *clickCount
+	}
+	if steps != nil && *steps < 1 {
+		fmt.Fprintln(cfg.Stderr, "error: --steps must be at least 1")
+		return ExitError
+	}
+
+	// The point is required to move, and optional elsewhere
+	var point []float64
+	var dx, dy float64
+	switch {
+	case action == "move" && len(nums) == 2:
+		point = nums
+	case (action == "down" || action == "up") && (len(nums) == 0 || len(nums) == 2):
+		point = nums
+	case action == "wheel" && (len(nums) == 2 || len(nums) == 4):
+		dx, dy, point = nums[0], nums[1], nums[2:]
+	default:
+		return cmdMissingArg(cfg, mouseUsage)
+	}
+
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		dir := configDir()
+		state, err := loadMouseState(dir, target.ID)
+		if err != nil {
+			return nil, err
+		}
+		x, y := state.X, state.Y
+		if len(point) == 2 {
+			x, y = point[0], point[1]
+		}
+		opts.Buttons = state.Buttons
+
+		result := MouseResult{Action: action, X: x, Y: y, Button: opts.Button}
+		switch action {
+		case "move":
+			err = client.MouseMoveSteps(ctx, target.ID, state.X, state.Y, x, y, *steps, opts)
+		case "down":
+			err = client.MouseDown(ctx, target.ID, x, y, opts)
+			mask, _ := chrome.MouseButtonMask(opts.Button)
+			state.Buttons |= mask
+		case "up":
+			err = client.MouseUp(ctx, target.ID, x, y, opts)
+			mask, _ := chrome.MouseButtonMask(opts.Button)
+			state.Buttons &^= mask
+		case "wheel":
+			err = client.MouseWheel(ctx, target.ID, x, y, dx, dy, opts)
+			result.DeltaX, result.DeltaY = dx, dy
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		state.X, state.Y = x, y
+		if err := saveMouseState(dir, target.ID, state); err != nil {
+			return nil, err
+		}
+		result.Buttons = chrome.HeldMouseButtons(state.Buttons)
+		if result.Buttons == nil {
+			result.Buttons = []string{}
+		}
+		return result, nil
+	})
+}
+
+// parseNumericArgs parses flags mixed with numeric arguments, which may be
+// negative and so look like flags, and returns the numbers.
+func parseNumericArgs(fs *flag.FlagSet, args []string) ([]float64, error) {
+	var flagArgs, numArgs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if _, err := strconv.ParseFloat(arg, 64); err == nil || !strings.HasPrefix(arg, "-") || arg == "-" {
+			numArgs = append(numArgs, arg)
+			continue
+		}
+		flagArgs = append(flagArgs, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		// A flag's value is the next argument, unless it is a bool flag
+		if f := fs.Lookup(name); f != nil && i+1 < len(args) {
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+				i++
+				flagArgs = append(flagArgs, args[i])
+			}
+		}
+	}
+	if err := fs.Parse(flagArgs); err != nil {
+		return nil, err
+	}
+	numArgs = append(numArgs, fs.Args()...)
+
+	nums := make([]float64, len(numArgs))
+	for i, arg := range numArgs {
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, err
+		}
+		nums[i] = n
+	}
+	return nums, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNumericArgs(t *testing.T) {
+	tests := []struct {
+		args      []string
+		want      []float64
+		modifiers string
+	}{
+		{[]string{"10", "20"}, []float64{10, 20}, ""},
+		{[]string{"0", "-120"}, []float64{0, -120}, ""},
+		{[]string{"--modifiers", "Shift", "-5.5", "3"}, []float64{-5.5, 3}, "Shift"},
+		{[]string{"-5", "--modifiers=Alt", "3"}, []float64{-5, 3}, "Alt"},
+		{[]string{"1", "2", "--modifiers", "Control"}, []float64{1, 2}, "Control"},
+		{nil, []float64{}, ""},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("mouse", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		modifiers := fs.String("modifiers", "", "")
+		got, err := parseNumericArgs(fs, tt.args)
+		if err != nil {
+			t.Errorf("parseNumericArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) || *modifiers != tt.modifiers {
+			t.Errorf("parseNumericArgs(%q) = %v with modifiers %q, want %v with %q", tt.args, got, *modifiers, tt.want, tt.modifiers)
+		}
+	}
+
+	fs := flag.NewFlagSet("mouse", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := parseNumericArgs(fs, []string{"--steps", "3"}); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+	if _, err := parseNumericArgs(fs, []string{"1", "x"}); err == nil {
+		t.Error("expected an error for a non-number")
+	}
+}
+
+func TestMouseState_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := loadMouseState(dir, "T1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if *s != (mouseState{}) {
+		t.Errorf("expected the mouse at the top left with nothing held, got %+v", s)
+	}
+
+	if err := saveMouseState(dir, "T1", &mouseState{X: 10, Y: 20, Buttons: 1}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	s, err = loadMouseState(dir, "T1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if *s != (mouseState{X: 10, Y: 20, Buttons: 1}) {
+		t.Errorf("loaded %+v", s)
+	}
+
+	other, err := loadMouseState(dir, "T2")
+	if err != nil {
+		t.Fatalf("load other: %v", err)
+	}
+	if *other != (mouseState{}) {
+		t.Errorf("expected other targets to have their own mouse, got %+v", other)
+	}
+}
+
+func TestRun_Mouse_UsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"mouse", "click"}, "unknown mouse subcommand: click"},
+		{[]string{"mouse", "move", "1"}, "usage:"},
+		{[]string{"mouse", "down", "1"}, "usage:"},
+		{[]string{"mouse", "wheel"}, "usage:"},
+		{[]string{"mouse", "move", "--steps", "0", "1", "2"}, "--steps must be at least 1"},
+		{[]string{"mouse", "down", "--button", "thumb"}, `invalid mouse button "thumb"`},
+		{[]string{"mouse", "up", "--click-count", "0"}, "--click-count must be at least 1"},
+		{[]string{"mouse", "wheel", "--modifiers", "Shift+Q", "0", "100"}, `invalid modifier "Q"`},
+		{[]string{"mouse", "move", "1", "y"}, "invalid coordinate"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		code := run(tt.args, cfg)
+		if code != ExitError {
+			t.Errorf("%q: expected exit code %d, got %d", tt.args, ExitError, code)
+		}
+		if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, tt.want) {
+			t.Errorf("%q: expected %q in stderr, got %q", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestRun_Mouse_DownMoveUp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	t.Setenv("HUBCAP_CONFIG_DIR", t.TempDir())
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	page := `data:text/html,<html><body style="height:3000px"><script>window.log=[];` +
+		`for (const t of ["mousedown","mousemove","mouseup"]) addEventListener(t, e => log.push(t+":"+e.clientX+","+e.clientY+":"+e.buttons));` +
+		`</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	steps := [][]string{
+		{"mouse", "down", "10", "10"},
+		{"mouse", "move", "--steps", "2", "30", "10"},
+		{"mouse", "up"},
+	}
+	var result MouseResult
+	for _, args := range steps {
+		cfg = testConfig()
+		if code := run(append([]string{"--target", tabID}, args...), cfg); code != ExitSuccess {
+			t.Fatalf("%q: exit code %d, stderr: %s", args, code, cfg.Stderr.(*bytes.Buffer).String())
+		}
+		if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+			t.Fatalf("%q: output is not valid JSON: %v", args, err)
+		}
+		if args[1] == "move" && !reflect.DeepEqual(result.Buttons, []string{"left"}) {
+			t.Errorf("expected the left button held while moving, got %v", result.Buttons)
+		}
+	}
+	if result.X != 30 || result.Y != 10 || len(result.Buttons) != 0 {
+		t.Errorf("expected release at 30,10 with nothing held, got %+v", result)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "eval", "log.join(' ')"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	for _, want := range []string{"mousedown:10,10:1", "mousemove:20,10:1", "mousemove:30,10:1", "mouseup:30,10:0"} {
+		if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), want) {
+			t.Errorf("expected %s in events, got %s", want, cfg.Stdout.(*bytes.Buffer).String())
+		}
+	}
+}
+
+func TestRun_Mouse_Wheel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	t.Setenv("HUBCAP_CONFIG_DIR", t.TempDir())
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	if code := run([]string{"--target", tabID, "goto", `data:text/html,<html><body style="height:3000px"></body></html>`}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "mouse", "wheel", "0", "400", "50", "50"}, cfg); code != ExitSuccess {
+		t.Fatalf("exit code %d, stderr: %s", code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var result MouseResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Action != "wheel" || result.DeltaY != 400 || result.X != 50 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
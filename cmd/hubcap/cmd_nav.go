@@ -16,7 +16,18 @@ func cmdVersion(cfg *Config) int {
 
 func cmdTabs(cfg *Config) int {
 	return withClient(cfg, func(ctx context.Context, client *chrome.Client) (interface{}, error) {
-		return client.Pages(ctx)
+		pages, err := client.Pages(ctx)
+		if err != nil || cfg.Context == "" {
+			return pages, err
+		}
+		contextID, err := resolveContext(ctx, client, cfg, cfg.Context)
+		if err != nil {
+			return nil, err
+		}
+		if pages = contextPages(pages, contextID); pages == nil {
+			pages = []chrome.TargetInfo{}
+		}
+		return pages, nil
 	})
 }
 
@@ -156,12 +167,21 @@ func cmdURL(cfg *Config) int {
 
 // NewTabResult is returned by the new command.
 type NewTabResult struct {
-	TargetID string `json:"targetId"`
-	URL      string `json:"url"`
+	TargetID         string `json:"targetId"`
+	URL              string `json:"url"`
+	BrowserContextID string `json:"browserContextId,omitempty"`
 }
 
 func cmdNew(cfg *Config, url string) int {
 	return withClient(cfg, func(ctx context.Context, client *chrome.Client) (interface{}, error) {
+		var contextID string
+		if cfg.Context != "" {
+			var err error
+			if contextID, err = resolveContext(ctx, client, cfg, cfg.Context); err != nil {
+				return nil, err
+			}
+			client.SetBrowserContext(contextID)
+		}
 		targetID, err := client.NewTab(ctx, url)
 		if err != nil {
 			return nil, err
@@ -169,7 +189,7 @@ func cmdNew(cfg *Config, url string) int {
 		if url == "" {
 			url = "about:blank"
 		}
-		return NewTabResult{TargetID: targetID, URL: url}, nil
+		return NewTabResult{TargetID: targetID, URL: url, BrowserContextID: contextID}, nil
 	})
 }
 
@@ -185,6 +205,9 @@ func cmdClose(cfg *Config) int {
 		if err != nil {
 			return nil, err
 		}
+		if err := clearTargetOverrides(configDir(), target.ID); err != nil {
+			return nil, err
+		}
 		return CloseTabResult{Closed: true, TargetID: target.ID}, nil
 	})
 }
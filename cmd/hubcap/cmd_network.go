@@ -2,10 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"mime"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/tomyan/hubcap/internal/chrome"
@@ -32,14 +39,14 @@ func cmdConsole(cfg *Config, args []string) int {
 		defer cancel()
 	}
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
 	}
 	defer client.Close()
 
-	target, err := resolveTarget(ctx, client, cfg)
+	target, err := prepareTarget(ctx, client, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitError
@@ -92,14 +99,14 @@ func cmdErrors(cfg *Config, args []string) int {
 		defer cancel()
 	}
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
 	}
 	defer client.Close()
 
-	target, err := resolveTarget(ctx, client, cfg)
+	target, err := prepareTarget(ctx, client, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitError
@@ -151,14 +158,14 @@ func cmdNetwork(cfg *Config, args []string) int {
 		defer cancel()
 	}
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
 	}
 	defer client.Close()
 
-	target, err := resolveTarget(ctx, client, cfg)
+	target, err := prepareTarget(ctx, client, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitError
@@ -194,11 +201,64 @@ func cmdNetwork(cfg *Config, args []string) int {
 	}
 }
 
+// stopFlags are the --duration, --until-idle and --until-selector flags of
+// commands that capture until a condition is met.
+type stopFlags struct {
+	fs       *flag.FlagSet
+	duration *time.Duration
+	idle     *time.Duration
+	selector *string
+}
+
+func addStopFlags(fs *flag.FlagSet, defaultDuration time.Duration, usage string) *stopFlags {
+	return &stopFlags{
+		fs:       fs,
+		duration: fs.Duration("duration", defaultDuration, usage),
+		idle:     fs.Duration("until-idle", 0, "Stop once no requests have been in flight for this long"),
+		selector: fs.String("until-selector", "", "Stop once an element matches this selector"),
+	}
+}
+
+// condition returns the parsed stop condition. The default duration only
+// applies when no other condition is given.
+func (f *stopFlags) condition() chrome.StopCondition {
+	until := chrome.StopCondition{
+		Duration: *f.duration,
+		Idle:     *f.idle,
+		Selector: *f.selector,
+	}
+	if until.Idle > 0 || until.Selector != "" {
+		durationSet := false
+		f.fs.Visit(func(fl *flag.Flag) {
+			if fl.Name == "duration" {
+				durationSet = true
+			}
+		})
+		if !durationSet {
+			until.Duration = 0
+		}
+	}
+	return until
+}
+
+// HARFileResult is returned by the har command when writing to a file.
+type HARFileResult struct {
+	Output  string `json:"output"`
+	Entries int    `json:"entries"`
+	Files   int    `json:"files,omitempty"` // Bodies written next to the HAR
+}
+
 func cmdHar(cfg *Config, args []string) int {
+	if len(args) > 0 && args[0] == "replay" {
+		return cmdHarReplay(cfg, args[1:])
+	}
+
 	// Parse har-specific flags
 	fs := flag.NewFlagSet("har", flag.ContinueOnError)
 	fs.SetOutput(cfg.Stderr)
-	duration := fs.Duration("duration", 5*time.Second, "How long to capture")
+	until := addStopFlags(fs, 5*time.Second, "How long to capture")
+	content := fs.String("content", "omit", "Response bodies: embed, omit or separate-files")
+	output := fs.String("output", "", "Write the HAR to this file instead of stdout")
 
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
@@ -207,11 +267,211 @@ func cmdHar(cfg *Config, args []string) int {
 		return ExitError
 	}
 
+	if *content != "embed" && *content != "omit" && *content != "separate-files" {
+		fmt.Fprintf(cfg.Stderr, "error: invalid --content %q (must be embed, omit or separate-files)\n", *content)
+		return ExitError
+	}
+	if *content == "separate-files" && *output == "" {
+		fmt.Fprintln(cfg.Stderr, "error: --content separate-files requires --output")
+		return ExitError
+	}
+
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		return client.CaptureHAR(ctx, target.ID, *duration)
+		har, err := client.CaptureHAR(ctx, target.ID, chrome.HARCaptureOptions{
+			Until:  until.condition(),
+			Bodies: *content != "omit",
+		})
+		if err != nil {
+			return nil, err
+		}
+		if *output == "" {
+			return har, nil
+		}
+
+		result := HARFileResult{Output: *output, Entries: len(har.Log.Entries)}
+		if *content == "separate-files" {
+			result.Files, err = writeHARContentFiles(har, filepath.Dir(*output))
+			if err != nil {
+				return nil, err
+			}
+		}
+		data, err := json.MarshalIndent(har, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("marshaling HAR: %w", err)
+		}
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			return nil, fmt.Errorf("writing file: %w", err)
+		}
+		return result, nil
 	})
 }
 
+// writeHARContentFiles moves embedded response bodies into files in dir,
+// named by the SHA-1 of their contents, and records each file name in the
+// entry's content._file. Returns the number of files written.
+func writeHARContentFiles(har *chrome.HARLog, dir string) (int, error) {
+	written := make(map[string]bool)
+	for i := range har.Log.Entries {
+		content := &har.Log.Entries[i].Response.Content
+		if content.Text == "" {
+			continue
+		}
+
+		body := []byte(content.Text)
+		if content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(content.Text)
+			if err != nil {
+				return 0, fmt.Errorf("decoding body of %s: %w", har.Log.Entries[i].Request.URL, err)
+			}
+			body = decoded
+		}
+
+		name := fmt.Sprintf("%x", sha1.Sum(body)) + harFileExtension(content.MimeType)
+		if !written[name] {
+			if err := os.WriteFile(filepath.Join(dir, name), body, 0644); err != nil {
+				return 0, fmt.Errorf("writing body: %w", err)
+			}
+			written[name] = true
+		}
+
+		content.File = name
+		content.Text = ""
+		content.Encoding = ""
+	}
+	return len(written), nil
+}
+
+// harFileExtension picks a file extension for a MIME type, preferring the
+// one named after the subtype (".html" over ".htm" or ".ehtml").
+func harFileExtension(mimeType string) string {
+	exts, _ := mime.ExtensionsByType(mimeType)
+	if len(exts) == 0 {
+		return ""
+	}
+	if _, subtype, ok := strings.Cut(mimeType, "/"); ok {
+		subtype, _, _ = strings.Cut(subtype, ";")
+		for _, ext := range exts {
+			if ext == "."+strings.TrimSpace(subtype) {
+				return ext
+			}
+		}
+	}
+	return exts[0]
+}
+
+// loadHARContentFiles reads bodies stored by writeHARContentFiles back into
+// the entries, resolving file names relative to dir.
+func loadHARContentFiles(har *chrome.HARLog, dir string) error {
+	for i := range har.Log.Entries {
+		content := &har.Log.Entries[i].Response.Content
+		if content.File == "" {
+			continue
+		}
+
+		path := content.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading body: %w", err)
+		}
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+	return nil
+}
+
+func cmdHarReplay(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("har replay", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	match := fs.String("match", "strict", "How requests are matched: strict (method, URL, body), url (method, URL) or path (method, URL without query)")
+	notFound := fs.String("not-found", "abort", "What to do with requests not in the archive: abort or passthrough")
+	duration := fs.Duration("duration", 0, "How long to replay (0 = until interrupted)")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap har replay [--match strict|url|path] [--not-found abort|passthrough] <file>")
+		return ExitError
+	}
+	if *match != "strict" && *match != "url" && *match != "path" {
+		fmt.Fprintf(cfg.Stderr, "error: invalid --match %q (must be strict, url or path)\n", *match)
+		return ExitError
+	}
+	if *notFound != "abort" && *notFound != "passthrough" {
+		fmt.Fprintf(cfg.Stderr, "error: invalid --not-found %q (must be abort or passthrough)\n", *notFound)
+		return ExitError
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: reading HAR: %v\n", err)
+		return ExitError
+	}
+	var har chrome.HARLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: parsing HAR: %v\n", err)
+		return ExitError
+	}
+	if err := loadHARContentFiles(&har, filepath.Dir(fs.Arg(0))); err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	client, err := connectClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(ctx, client, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	events, stopReplay, err := client.ReplayHAR(ctx, target.ID, &har, chrome.HARReplayOptions{
+		Match:    *match,
+		NotFound: *notFound,
+	})
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+	defer stopReplay() // Stop intercepting on exit
+
+	enc := json.NewEncoder(cfg.Stdout)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return ExitSuccess
+			}
+			if err := enc.Encode(ev); err != nil {
+				fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+				return ExitError
+			}
+		case <-ctx.Done():
+			return ExitSuccess
+		}
+	}
+}
+
 func cmdCoverage(cfg *Config) int {
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
 		return client.GetCoverage(ctx, target.ID)
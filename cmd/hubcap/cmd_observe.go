@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["observe"] = CommandInfo{
+		Name:     "observe",
+		Desc:     "Outline the page's landmarks, headings and controls",
+		Category: "Query DOM",
+		Run:      func(cfg *Config, args []string) int { return cmdObserve(cfg, args) },
+	}
+}
+
+// ObserveResult is returned by the observe command.
+type ObserveResult struct {
+	URL     string               `json:"url"`
+	Title   string               `json:"title"`
+	Nodes   []chrome.OutlineNode `json:"nodes"`
+	Omitted int                  `json:"omitted,omitempty"` // Nodes left out to keep within --max-tokens
+}
+
+// TextValue renders the outline as an indented list, one node per line.
+func (r ObserveResult) TextValue() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "page %q %s\n", r.Title, r.URL)
+	var write func(nodes []chrome.OutlineNode, depth int)
+	write = func(nodes []chrome.OutlineNode, depth int) {
+		for i := range nodes {
+			b.WriteString(outlineLine(&nodes[i], depth))
+			b.WriteByte('\n')
+			write(nodes[i].Children, depth+1)
+		}
+	}
+	write(r.Nodes, 0)
+	if r.Omitted > 0 {
+		fmt.Fprintf(&b, "... %d more (raise --max-tokens to see them)\n", r.Omitted)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// outlineLine renders an outline node as a line of text, such as
+// `- textbox "Email" value="a@b.test" @e3 [required]`.
+func outlineLine(n *chrome.OutlineNode, depth int) string {
+	var b strings.Builder
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString("- ")
+	b.WriteString(n.Role)
+	if n.Name != "" {
+		fmt.Fprintf(&b, " %q", n.Name)
+	}
+	if n.Level > 0 {
+		fmt.Fprintf(&b, " level=%d", n.Level)
+	}
+	if n.Value != "" {
+		fmt.Fprintf(&b, " value=%q", n.Value)
+	}
+	if n.Ref != "" {
+		b.WriteString(" " + n.Ref)
+	}
+	if len(n.States) > 0 {
+		fmt.Fprintf(&b, " [%s]", strings.Join(n.States, ", "))
+	}
+	return b.String()
+}
+
+// estimateTokens roughly estimates the tokens a language model reads a
+// text as, at four characters a token.
+func estimateTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// trimOutline keeps the nodes of an outline, in the order they are
+// listed, whose lines fit within maxTokens. Returns the kept nodes and the
+// number left out.
+func trimOutline(nodes []chrome.OutlineNode, maxTokens int) ([]chrome.OutlineNode, int) {
+	used, omitted := 0, 0
+	var trim func(nodes []chrome.OutlineNode, depth int) []chrome.OutlineNode
+	trim = func(nodes []chrome.OutlineNode, depth int) []chrome.OutlineNode {
+		var kept []chrome.OutlineNode
+		for _, n := range nodes {
+			if omitted > 0 {
+				omitted += countOutline([]chrome.OutlineNode{n})
+				continue
+			}
+			tokens := estimateTokens(outlineLine(&n, depth)) + 1
+			if used+tokens > maxTokens {
+				omitted += countOutline([]chrome.OutlineNode{n})
+				continue
+			}
+			used += tokens
+			n.Children = trim(n.Children, depth+1)
+			kept = append(kept, n)
+		}
+		return kept
+	}
+	kept := trim(nodes, 0)
+	if kept == nil {
+		kept = []chrome.OutlineNode{}
+	}
+	return kept, omitted
+}
+
+// countOutline returns the number of nodes in an outline.
+func countOutline(nodes []chrome.OutlineNode) int {
+	count := len(nodes)
+	for _, n := range nodes {
+		count += countOutline(n.Children)
+	}
+	return count
+}
+
+// interactiveNodes returns the interactive nodes of an outline, in the
+// order they are listed.
+func interactiveNodes(nodes []chrome.OutlineNode) []*chrome.OutlineNode {
+	var found []*chrome.OutlineNode
+	for i := range nodes {
+		if nodes[i].Interactive() {
+			found = append(found, &nodes[i])
+		}
+		found = append(found, interactiveNodes(nodes[i].Children)...)
+	}
+	return found
+}
+
+func cmdObserve(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("observe", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	maxTokens := fs.Int("max-tokens", 0, "Leave out nodes beyond roughly this many tokens of outline (0 for no limit)")
+	fullPage := fs.Bool("full-page", false, "Include elements outside the viewport")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap observe [--max-tokens <n>] [--full-page]")
+		return ExitError
+	}
+	if *maxTokens < 0 {
+		fmt.Fprintln(cfg.Stderr, "error: --max-tokens must be at least 0")
+		return ExitError
+	}
+
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		nodes, err := client.Observe(ctx, target.ID, chrome.ObserveOptions{FullPage: *fullPage})
+		if err != nil {
+			return nil, err
+		}
+
+		// Refs are handed out before trimming so that lines are measured
+		// as they are shown
+		interactive := interactiveNodes(nodes)
+		ids := make([]int64, len(interactive))
+		for i, n := range interactive {
+			ids[i] = n.BackendNodeID
+		}
+		refs, err := handOutPageRefs(ctx, client, target.ID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, n := range interactive {
+			n.Ref = refs[i]
+		}
+
+		result := ObserveResult{URL: target.URL, Title: target.Title, Nodes: nodes}
+		if *maxTokens > 0 {
+			result.Nodes, result.Omitted = trimOutline(nodes, *maxTokens)
+		}
+		return result, nil
+	})
+}
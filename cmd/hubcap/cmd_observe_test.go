@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func testOutline() []chrome.OutlineNode {
+	return []chrome.OutlineNode{
+		{Role: "navigation", Children: []chrome.OutlineNode{
+			{Role: "link", Name: "Home", Ref: "@e1"},
+		}},
+		{Role: "main", Children: []chrome.OutlineNode{
+			{Role: "heading", Name: "Checkout", Level: 1},
+			{Role: "textbox", Name: "Email", Value: "a@b.test", Ref: "@e2", States: []string{"required"}},
+			{Role: "button", Name: "Pay", Ref: "@e3", States: []string{"disabled", "collapsed"}},
+		}},
+	}
+}
+
+func TestObserve_TooManyArgs(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"observe", "main"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestObserve_NegativeMaxTokens(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"observe", "--max-tokens", "-1"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "--max-tokens must be at least 0") {
+		t.Errorf("expected max-tokens error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestObserveResult_TextValue(t *testing.T) {
+	result := ObserveResult{URL: "https://shop.test/checkout", Title: "Shop", Nodes: testOutline(), Omitted: 2}
+	want := `page "Shop" https://shop.test/checkout
+- navigation
+  - link "Home" @e1
+- main
+  - heading "Checkout" level=1
+  - textbox "Email" value="a@b.test" @e2 [required]
+  - button "Pay" @e3 [disabled, collapsed]
+... 2 more (raise --max-tokens to see them)`
+	if got := result.TextValue(); got != want {
+		t.Errorf("TextValue() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTrimOutline(t *testing.T) {
+	// The first four lines take 3, 5, 2 and 8 tokens, and one more each
+	// for its line break
+	kept, omitted := trimOutline(testOutline(), 22)
+	if omitted != 2 {
+		t.Errorf("expected 2 nodes omitted, got %d", omitted)
+	}
+	if len(kept) != 2 || len(kept[0].Children) != 1 || len(kept[1].Children) != 1 || kept[1].Children[0].Name != "Checkout" {
+		t.Errorf("expected the first four nodes, got %+v", kept)
+	}
+
+	kept, omitted = trimOutline(testOutline(), 1000)
+	if omitted != 0 || countOutline(kept) != 6 {
+		t.Errorf("expected the whole outline, got %d nodes with %d omitted", countOutline(kept), omitted)
+	}
+
+	kept, omitted = trimOutline(testOutline(), 1)
+	if omitted != 6 || kept == nil || len(kept) != 0 {
+		t.Errorf("expected nothing kept, got %+v with %d omitted", kept, omitted)
+	}
+}
+
+func TestInteractiveNodes(t *testing.T) {
+	outline := testOutline()
+	nodes := interactiveNodes(outline)
+	if len(nodes) != 3 || nodes[0].Name != "Home" || nodes[2].Name != "Pay" {
+		t.Fatalf("unexpected interactive nodes: %+v", nodes)
+	}
+	nodes[1].Ref = "@e9"
+	if outline[1].Children[1].Ref != "@e9" {
+		t.Error("expected the nodes to point into the outline")
+	}
+}
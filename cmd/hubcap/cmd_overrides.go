@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["overrides"] = CommandInfo{
+		Name:     "overrides",
+		Desc:     "Show or clear emulation state kept across commands",
+		Category: "Emulate",
+		Run:      func(cfg *Config, args []string) int { return cmdOverrides(cfg, args) },
+	}
+}
+
+// targetOverrides is the emulation state recorded for a target. Chrome drops
+// emulation overrides when the session that set them detaches, so hubcap
+// reapplies them each time a command attaches to the target.
+type targetOverrides struct {
+	Device      *chrome.DeviceInfo `json:"device,omitempty"`
+	Viewport    *ViewportResult    `json:"viewport,omitempty"`
+	UserAgent   string             `json:"userAgent,omitempty"`
+	Geolocation *GeolocationResult `json:"geolocation,omitempty"`
+	Media       *MediaResult       `json:"media,omitempty"`
+	Offline     bool               `json:"offline,omitempty"`
+}
+
+func (o *targetOverrides) empty() bool {
+	return *o == targetOverrides{}
+}
+
+// OverridesResult is returned by the overrides command.
+type OverridesResult struct {
+	TargetID  string           `json:"targetId"`
+	Overrides *targetOverrides `json:"overrides"`
+	Cleared   bool             `json:"cleared,omitempty"`
+}
+
+func cmdOverrides(cfg *Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap overrides <show|clear> [--all]")
+		return ExitError
+	}
+
+	switch args[0] {
+	case "show":
+		return cmdOverridesShow(cfg)
+	case "clear":
+		return cmdOverridesClear(cfg, args[1:])
+	default:
+		fmt.Fprintf(cfg.Stderr, "unknown overrides subcommand: %s\n", args[0])
+		fmt.Fprintln(cfg.Stderr, "subcommands: show, clear")
+		return ExitError
+	}
+}
+
+func cmdOverridesShow(cfg *Config) int {
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		o, err := loadTargetOverrides(configDir(), target.ID)
+		if err != nil {
+			return nil, err
+		}
+		return OverridesResult{TargetID: target.ID, Overrides: o}, nil
+	})
+}
+
+func cmdOverridesClear(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("overrides clear", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	all := fs.Bool("all", false, "Forget the recorded state of every target")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if *all {
+		if err := os.RemoveAll(filepath.Join(configDir(), "overrides")); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+		return outputResult(cfg, OverridesResult{Overrides: &targetOverrides{}, Cleared: true})
+	}
+
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		if err := clearTargetOverrides(configDir(), target.ID); err != nil {
+			return nil, err
+		}
+		if err := client.ClearEmulation(ctx, target.ID); err != nil {
+			return nil, err
+		}
+		return OverridesResult{TargetID: target.ID, Overrides: &targetOverrides{}, Cleared: true}, nil
+	})
+}
+
+// applyOverrides reapplies the recorded emulation state of a target.
+func applyOverrides(ctx context.Context, client *chrome.Client, targetID string) error {
+	o, err := loadTargetOverrides(configDir(), targetID)
+	if err != nil || o.empty() {
+		return err
+	}
+
+	// Order matters: a later viewport or user agent wins over the device's
+	if o.Device != nil {
+		if err := client.Emulate(ctx, targetID, *o.Device); err != nil {
+			return fmt.Errorf("reapplying device: %w", err)
+		}
+	}
+	if o.Viewport != nil {
+		if err := client.SetViewport(ctx, targetID, o.Viewport.Width, o.Viewport.Height); err != nil {
+			return fmt.Errorf("reapplying viewport: %w", err)
+		}
+	}
+	if o.UserAgent != "" {
+		if err := client.SetUserAgent(ctx, targetID, o.UserAgent); err != nil {
+			return fmt.Errorf("reapplying user agent: %w", err)
+		}
+	}
+	if o.Geolocation != nil {
+		if err := client.SetGeolocation(ctx, targetID, o.Geolocation.Latitude, o.Geolocation.Longitude, o.Geolocation.Accuracy); err != nil {
+			return fmt.Errorf("reapplying geolocation: %w", err)
+		}
+	}
+	if o.Media != nil {
+		features := chrome.MediaFeatures{
+			ColorScheme:   o.Media.ColorScheme,
+			ReducedMotion: o.Media.ReducedMotion,
+			ForcedColors:  o.Media.ForcedColors,
+		}
+		if err := client.SetEmulatedMedia(ctx, targetID, features); err != nil {
+			return fmt.Errorf("reapplying media: %w", err)
+		}
+	}
+	if o.Offline {
+		if err := client.SetOfflineMode(ctx, targetID, true); err != nil {
+			return fmt.Errorf("reapplying offline mode: %w", err)
+		}
+	}
+	return nil
+}
+
+// overridesPath returns the path of the state file for a target.
+func overridesPath(dir, targetID string) string {
+	return filepath.Join(dir, "overrides", targetID+".json")
+}
+
+// loadTargetOverrides loads the recorded state of a target.
+// Returns empty state if nothing has been recorded.
+func loadTargetOverrides(dir, targetID string) (*targetOverrides, error) {
+	data, err := os.ReadFile(overridesPath(dir, targetID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &targetOverrides{}, nil
+		}
+		return nil, fmt.Errorf("reading overrides: %w", err)
+	}
+
+	var o targetOverrides
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("parsing overrides: %w", err)
+	}
+	return &o, nil
+}
+
+// updateTargetOverrides applies fn to the recorded state of a target and saves it.
+// Commands run at the same time update it one after another, and the state
+// is replaced whole, so that it is never read half written.
+func updateTargetOverrides(dir, targetID string, fn func(o *targetOverrides)) error {
+	if err := os.MkdirAll(filepath.Join(dir, "overrides"), 0755); err != nil {
+		return fmt.Errorf("creating overrides dir: %w", err)
+	}
+	unlock, err := lockPath(overridesPath(dir, targetID) + ".lock")
+	if err != nil {
+		return fmt.Errorf("locking overrides: %w", err)
+	}
+	defer unlock()
+
+	o, err := loadTargetOverrides(dir, targetID)
+	if err != nil {
+		return err
+	}
+	fn(o)
+
+	if o.empty() {
+		return clearTargetOverrides(dir, targetID)
+	}
+
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling overrides: %w", err)
+	}
+	if err := writeFileAtomic(overridesPath(dir, targetID), data); err != nil {
+		return fmt.Errorf("writing overrides: %w", err)
+	}
+	return nil
+}
+
+// lockPath takes an exclusive lock on the file at path, creating it if need
+// be, and returns a function that releases it.
+func lockPath(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic replaces the file at path with data, by writing it beside
+// the file and renaming it into place.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// clearTargetOverrides forgets the recorded state of a target.
+func clearTargetOverrides(dir, targetID string) error {
+	err := os.Remove(overridesPath(dir, targetID))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing overrides: %w", err)
+	}
+	return nil
+}
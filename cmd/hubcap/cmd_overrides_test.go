@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func TestTargetOverrides_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	device := chrome.CommonDevices["iPhone 12"]
+	err := updateTargetOverrides(dir, "T1", func(o *targetOverrides) {
+		o.Device = &device
+		o.Media = &MediaResult{ColorScheme: "dark"}
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	o, err := loadTargetOverrides(dir, "T1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if o.Device == nil || o.Device.Name != "iPhone 12" {
+		t.Errorf("Device = %+v, want iPhone 12", o.Device)
+	}
+	if o.Media == nil || o.Media.ColorScheme != "dark" {
+		t.Errorf("Media = %+v, want dark color scheme", o.Media)
+	}
+
+	// Other targets are unaffected
+	other, err := loadTargetOverrides(dir, "T2")
+	if err != nil {
+		t.Fatalf("load other: %v", err)
+	}
+	if !other.empty() {
+		t.Errorf("expected no overrides for T2, got %+v", other)
+	}
+}
+
+func TestTargetOverrides_EmptyStateRemovesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := updateTargetOverrides(dir, "T1", func(o *targetOverrides) { o.Offline = true }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := os.Stat(overridesPath(dir, "T1")); err != nil {
+		t.Fatalf("expected state file: %v", err)
+	}
+
+	if err := updateTargetOverrides(dir, "T1", func(o *targetOverrides) { o.Offline = false }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := os.Stat(overridesPath(dir, "T1")); !os.IsNotExist(err) {
+		t.Errorf("expected state file to be removed, got %v", err)
+	}
+}
+
+func TestTargetOverrides_ConcurrentUpdates(t *testing.T) {
+	dir := t.TempDir()
+
+	// Commands updating the state at once each see the others' updates
+	const updates = 50
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := updateTargetOverrides(dir, "T1", func(o *targetOverrides) { o.UserAgent += "x" })
+			if err != nil {
+				t.Errorf("update: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	o, err := loadTargetOverrides(dir, "T1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(o.UserAgent) != updates {
+		t.Errorf("expected %d updates to be kept, got %d", updates, len(o.UserAgent))
+	}
+}
+
+func TestOverrides_ClearAll(t *testing.T) {
+	cfg, dir := setupTestConfig(t)
+
+	if err := updateTargetOverrides(dir, "T1", func(o *targetOverrides) { o.UserAgent = "test" }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	code := run([]string{"overrides", "clear", "--all"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("overrides clear --all failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if _, err := os.Stat(overridesPath(dir, "T1")); !os.IsNotExist(err) {
+		t.Errorf("expected state file to be removed, got %v", err)
+	}
+}
+
+func TestOverrides_EmulateSurvivesAcrossCommands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	cfg, _ := setupTestConfig(t)
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+	cfg.Target = tabID
+
+	if code := run([]string{"emulate", "iPhone 12"}, cfg); code != ExitSuccess {
+		t.Fatalf("emulate failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	// A separate invocation sees the emulated viewport
+	evalCfg := *cfg
+	evalCfg.Stdout = &bytes.Buffer{}
+	if code := run([]string{"eval", "window.innerWidth"}, &evalCfg); code != ExitSuccess {
+		t.Fatalf("eval failed: %s", evalCfg.Stderr.(*bytes.Buffer).String())
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(evalCfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if result["value"] != float64(390) {
+		t.Errorf("expected innerWidth 390, got %v", result["value"])
+	}
+
+	showCfg := *cfg
+	showCfg.Stdout = &bytes.Buffer{}
+	if code := run([]string{"overrides", "show"}, &showCfg); code != ExitSuccess {
+		t.Fatalf("overrides show failed: %s", showCfg.Stderr.(*bytes.Buffer).String())
+	}
+	var shown OverridesResult
+	if err := json.Unmarshal(showCfg.Stdout.(*bytes.Buffer).Bytes(), &shown); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if shown.Overrides == nil || shown.Overrides.Device == nil {
+		t.Errorf("expected device override, got %+v", shown.Overrides)
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"os"
 	"time"
-
-	"github.com/tomyan/hubcap/internal/chrome"
 )
 
 func cmdRecord(cfg *Config, args []string) int {
@@ -45,14 +43,14 @@ func cmdRecord(cfg *Config, args []string) int {
 	connectCtx, connectCancel := context.WithTimeout(ctx, cfg.Timeout)
 	defer connectCancel()
 
-	client, err := chrome.Connect(connectCtx, cfg.Host, cfg.Port)
+	client, err := connectClient(connectCtx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
 	}
 	defer client.Close()
 
-	target, err := resolveTarget(connectCtx, client, cfg)
+	target, err := prepareTarget(connectCtx, client, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitError
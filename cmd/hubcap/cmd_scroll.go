@@ -105,6 +105,13 @@ func cmdViewport(cfg *Config, widthStr, heightStr string) int {
 		if err != nil {
 			return nil, err
 		}
-		return ViewportResult{Width: width, Height: height}, nil
+		result := ViewportResult{Width: width, Height: height}
+		err = updateTargetOverrides(configDir(), target.ID, func(o *targetOverrides) {
+			o.Viewport = &result
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
 	})
 }
@@ -140,6 +140,7 @@ func cmdSetupShow(cfg *Config, args []string) int {
 		ChromeDataDir    string `json:"chrome_data_dir,omitempty"`
 		Ephemeral        bool   `json:"ephemeral,omitempty"`
 		EphemeralTimeout string `json:"ephemeral_timeout,omitempty"`
+		DownloadDir      string `json:"download_dir,omitempty"`
 		IsDefault        bool   `json:"is_default,omitempty"`
 	}
 
@@ -155,6 +156,7 @@ func cmdSetupShow(cfg *Config, args []string) int {
 		ChromeDataDir:    p.ChromeDataDir,
 		Ephemeral:        p.Ephemeral,
 		EphemeralTimeout: p.EphemeralTimeout,
+		DownloadDir:      p.DownloadDir,
 		IsDefault:        name == pf.Default,
 	})
 }
@@ -297,6 +299,9 @@ func cmdSetupEdit(cfg *Config, args []string) int {
 	if editFlags["ephemeral-timeout"] {
 		existing.EphemeralTimeout = *p.ephemeralTimeout
 	}
+	if editFlags["download-dir"] {
+		existing.DownloadDir = *p.downloadDir
+	}
 
 	pf.Profiles[name] = existing
 
@@ -532,6 +537,7 @@ type profileFlags struct {
 	chromeDataDir    *string
 	ephemeral        *bool
 	ephemeralTimeout *string
+	downloadDir      *string
 }
 
 func registerProfileFlags(fs *flag.FlagSet) (*profileFlags, *bool) {
@@ -545,6 +551,7 @@ func registerProfileFlags(fs *flag.FlagSet) (*profileFlags, *bool) {
 		chromeDataDir:    fs.String("chrome-data-dir", "", "Chrome data directory"),
 		ephemeral:        fs.Bool("ephemeral", false, "Auto-launch and cleanup Chrome"),
 		ephemeralTimeout: fs.String("ephemeral-timeout", "", "Ephemeral session timeout"),
+		downloadDir:      fs.String("download-dir", "", "Directory downloads are saved to"),
 	}
 	setDefault := fs.Bool("set-default", false, "Set as default profile")
 	return p, setDefault
@@ -561,6 +568,7 @@ func buildProfile(p *profileFlags) Profile {
 		ChromeDataDir:    *p.chromeDataDir,
 		Ephemeral:        *p.ephemeral,
 		EphemeralTimeout: *p.ephemeralTimeout,
+		DownloadDir:      *p.downloadDir,
 	}
 }
 
@@ -30,6 +30,11 @@ func cmdShell(cfg *Config, args []string) int {
 			fmt.Fprintf(cfg.Stdout, "target set to %q\n", cfg.Target)
 			continue
 		}
+		if strings.HasPrefix(line, ".context ") {
+			cfg.Context = strings.TrimSpace(strings.TrimPrefix(line, ".context"))
+			fmt.Fprintf(cfg.Stdout, "context set to %q\n", cfg.Context)
+			continue
+		}
 		if strings.HasPrefix(line, ".output ") {
 			cfg.Output = strings.TrimSpace(strings.TrimPrefix(line, ".output"))
 			fmt.Fprintf(cfg.Stdout, "output set to %q\n", cfg.Output)
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func init() {
+	commands["vitals"] = CommandInfo{
+		Name:     "vitals",
+		Desc:     "Measure Core Web Vitals of a page load",
+		Category: "Profile",
+		Run:      func(cfg *Config, args []string) int { return cmdVitals(cfg, args) },
+	}
+}
+
+// vitalsMetrics are the metric names accepted in a budget file.
+var vitalsMetrics = []string{"lcp", "cls", "inp", "fcp", "ttfb"}
+
+// VitalsResult is returned by the vitals command.
+type VitalsResult struct {
+	chrome.Vitals
+	OverBudget []BudgetMiss `json:"overBudget,omitempty"`
+}
+
+// BudgetMiss is a metric that exceeded its budget.
+type BudgetMiss struct {
+	Metric string  `json:"metric"`
+	Value  float64 `json:"value"`
+	Budget float64 `json:"budget"`
+}
+
+func cmdVitals(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("vitals", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	script := fs.String("script", "", "File of hubcap commands to run after the page loads")
+	budgetFile := fs.String("budget", "", "JSON file of metric limits; exit 1 if any is exceeded")
+	stop := addStopFlags(fs, 2*time.Second, "Time to keep measuring after load and the script")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() > 1 {
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap vitals [--script <file>] [--budget <file>] [--duration <d>] [url]")
+		return ExitError
+	}
+	url := fs.Arg(0)
+
+	var budget map[string]float64
+	if *budgetFile != "" {
+		var err error
+		if budget, err = loadBudget(*budgetFile); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	var lines []scriptLine
+	if *script != "" {
+		var err error
+		if lines, err = loadScript(*script); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Loading the page and running the script must finish within the
+	// global -timeout; the measuring after them is bounded by --duration
+	loadCtx, cancelLoad := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancelLoad()
+
+	client, err := connectClient(loadCtx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(loadCtx, client, cfg)
+	if err != nil {
+		return vitalsLoadFailed(cfg, err)
+	}
+
+	id, err := client.StartVitals(loadCtx, target.ID)
+	if err != nil {
+		return vitalsLoadFailed(cfg, err)
+	}
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		client.StopVitals(stopCtx, target.ID, id)
+	}()
+
+	// Without a URL, the current page is loaded again unless the script
+	// navigates itself
+	if url == "" && *script == "" {
+		if url, err = client.GetURL(loadCtx, target.ID); err != nil {
+			return vitalsLoadFailed(cfg, err)
+		}
+	}
+	if url != "" {
+		nav, err := client.NavigateAndWait(loadCtx, target.ID, url)
+		if err != nil {
+			return vitalsLoadFailed(cfg, err)
+		}
+		if nav.ErrorText != "" {
+			fmt.Fprintf(cfg.Stderr, "error: navigating: %s\n", nav.ErrorText)
+			return ExitError
+		}
+	}
+
+	if lines != nil {
+		// Script commands run against the same target, with their output dropped
+		scriptCfg := *cfg
+		scriptCfg.Target = target.ID
+		scriptCfg.Stdout = io.Discard
+		if code := runScript(loadCtx, &scriptCfg, *script, lines); code != ExitSuccess {
+			return code
+		}
+	}
+
+	cancelLoad()
+
+	vitals, err := client.CollectVitals(ctx, target.ID, stop.condition())
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	result := VitalsResult{Vitals: *vitals, OverBudget: checkBudget(vitals, budget)}
+	if code := outputResult(cfg, result); code != ExitSuccess {
+		return code
+	}
+	if len(result.OverBudget) > 0 {
+		fmt.Fprintf(cfg.Stderr, "error: %d metric(s) over budget\n", len(result.OverBudget))
+		return ExitError
+	}
+	return ExitSuccess
+}
+
+// vitalsLoadFailed reports an error loading the page, and returns the exit
+// code for it.
+func vitalsLoadFailed(cfg *Config, err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintln(cfg.Stderr, "error: timeout loading the page")
+		return ExitTimeout
+	}
+	fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+	return ExitError
+}
+
+// loadBudget reads a budget file: a JSON object of metric limits, such as
+// {"lcp": 2500, "cls": 0.1}.
+func loadBudget(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading budget: %w", err)
+	}
+	var budget map[string]float64
+	if err := json.Unmarshal(data, &budget); err != nil {
+		return nil, fmt.Errorf("parsing budget: %w", err)
+	}
+	for metric := range budget {
+		if !slices.Contains(vitalsMetrics, metric) {
+			return nil, fmt.Errorf("budget: unknown metric %q, want one of %s", metric, strings.Join(vitalsMetrics, ", "))
+		}
+	}
+	return budget, nil
+}
+
+// checkBudget returns the measured metrics over their budget, in the order
+// of vitalsMetrics. Metrics the page did not produce are not checked.
+func checkBudget(v *chrome.Vitals, budget map[string]float64) []BudgetMiss {
+	var misses []BudgetMiss
+	for _, metric := range vitalsMetrics {
+		limit, ok := budget[metric]
+		if !ok {
+			continue
+		}
+		if value := vitalsMetric(v, metric); value != nil && *value > limit {
+			misses = append(misses, BudgetMiss{Metric: metric, Value: *value, Budget: limit})
+		}
+	}
+	return misses
+}
+
+// vitalsMetric returns the value of the named metric, or nil if it was not
+// measured.
+func vitalsMetric(v *chrome.Vitals, metric string) *float64 {
+	switch metric {
+	case "lcp":
+		if v.LCP != nil {
+			return &v.LCP.Value
+		}
+	case "cls":
+		if v.CLS != nil {
+			return &v.CLS.Value
+		}
+	case "inp":
+		if v.INP != nil {
+			return &v.INP.Value
+		}
+	case "fcp":
+		if v.FCP != nil {
+			return &v.FCP.Value
+		}
+	case "ttfb":
+		if v.TTFB != nil {
+			return &v.TTFB.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func writeBudget(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "budget.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("writing budget: %v", err)
+	}
+	return path
+}
+
+func TestVitals_TooManyArgs(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"vitals", "https://a.test", "https://b.test"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestVitals_InvalidBudget(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"vitals", "--budget", writeBudget(t, `{"lcp": 2500, "speed": 1}`)}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), `unknown metric "speed"`) {
+		t.Errorf("expected unknown metric error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestLoadBudget(t *testing.T) {
+	budget, err := loadBudget(writeBudget(t, `{"lcp": 2500, "cls": 0.1}`))
+	if err != nil {
+		t.Fatalf("loadBudget: %v", err)
+	}
+	if len(budget) != 2 || budget["lcp"] != 2500 || budget["cls"] != 0.1 {
+		t.Errorf("unexpected budget: %v", budget)
+	}
+
+	if _, err := loadBudget(writeBudget(t, `[2500]`)); err == nil || !strings.Contains(err.Error(), "parsing budget") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	vitals := &chrome.Vitals{
+		LCP:  &chrome.LCPMetric{VitalMetric: chrome.VitalMetric{Value: 3100}},
+		CLS:  &chrome.CLSMetric{VitalMetric: chrome.VitalMetric{Value: 0.02}},
+		TTFB: &chrome.VitalMetric{Value: 900},
+	}
+	budget := map[string]float64{"ttfb": 800, "lcp": 2500, "cls": 0.1, "inp": 200}
+
+	misses := checkBudget(vitals, budget)
+
+	// INP was not measured, so is not over budget
+	if len(misses) != 2 {
+		t.Fatalf("expected 2 misses, got %+v", misses)
+	}
+	if misses[0] != (BudgetMiss{Metric: "lcp", Value: 3100, Budget: 2500}) {
+		t.Errorf("unexpected first miss: %+v", misses[0])
+	}
+	if misses[1].Metric != "ttfb" {
+		t.Errorf("expected ttfb second, got %+v", misses[1])
+	}
+
+	if misses := checkBudget(vitals, nil); misses != nil {
+		t.Errorf("expected no misses without a budget, got %+v", misses)
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/tomyan/hubcap/internal/chrome"
@@ -13,6 +16,7 @@ import (
 type WaitResult struct {
 	Found    bool   `json:"found"`
 	Selector string `json:"selector"`
+	State    string `json:"state"`
 }
 
 func cmdWait(cfg *Config, args []string) int {
@@ -20,6 +24,7 @@ func cmdWait(cfg *Config, args []string) int {
 	fs := flag.NewFlagSet("wait", flag.ContinueOnError)
 	fs.SetOutput(cfg.Stderr)
 	timeout := fs.Duration("timeout", 30*time.Second, "Max wait time")
+	state := fs.String("state", chrome.StateAttached, "State to wait for: "+strings.Join(chrome.ElementStates, ", "))
 
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
@@ -30,17 +35,21 @@ func cmdWait(cfg *Config, args []string) int {
 
 	remaining := fs.Args()
 	if len(remaining) < 1 {
-		fmt.Fprintln(cfg.Stderr, "usage: hubcap wait <selector> [--timeout <duration>]")
+		fmt.Fprintln(cfg.Stderr, "usage: hubcap wait [--state <state>] <selector> [--timeout <duration>]")
+		return ExitError
+	}
+	if !slices.Contains(chrome.ElementStates, *state) {
+		fmt.Fprintf(cfg.Stderr, "error: invalid --state %q (use %s)\n", *state, strings.Join(chrome.ElementStates, ", "))
 		return ExitError
 	}
 	selector := remaining[0]
 
 	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
-		err := client.WaitFor(ctx, target.ID, selector, *timeout)
+		err := client.WaitForState(ctx, target.ID, selector, *state, *timeout)
 		if err != nil {
 			return nil, err
 		}
-		return WaitResult{Found: true, Selector: selector}, nil
+		return WaitResult{Found: true, Selector: selector, State: *state}, nil
 	})
 }
 
@@ -214,14 +223,14 @@ func cmdWaitLoad(cfg *Config, args []string) int {
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
 	}
 	defer client.Close()
 
-	target, err := resolveTarget(ctx, client, cfg)
+	target, err := prepareTarget(ctx, client, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitError
@@ -319,3 +328,140 @@ func cmdWaitResponse(cfg *Config, args []string) int {
 		return result, nil
 	})
 }
+
+// WaitAllResult is returned by the waitall command.
+type WaitAllResult struct {
+	First      string                   `json:"first"`      // The condition met first
+	Conditions []chrome.ConditionResult `json:"conditions"` // In the order given
+}
+
+// cmdWaitConditions runs waitany, which waits for the first of several
+// conditions, or waitall, which waits for all of them.
+func cmdWaitConditions(cfg *Config, name string, args []string) int {
+	usage := "usage: hubcap " + name + " [--timeout <duration>] <kind:value>..."
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	timeout := fs.Duration("timeout", 30*time.Second, "Max wait time")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+
+	if fs.NArg() < 1 {
+		return cmdMissingArg(cfg, usage)
+	}
+	conds := make([]chrome.WaitCondition, fs.NArg())
+	for i, spec := range fs.Args() {
+		cond, err := chrome.ParseWaitCondition(spec)
+		if err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+		conds[i] = cond
+	}
+
+	return withClientTarget(cfg, func(ctx context.Context, client *chrome.Client, target *chrome.TargetInfo) (interface{}, error) {
+		if name == "waitany" {
+			return client.WaitForAny(ctx, target.ID, conds, *timeout)
+		}
+		results, err := client.WaitForAll(ctx, target.ID, conds, *timeout)
+		if err != nil {
+			return nil, err
+		}
+		first := results[0]
+		for _, r := range results[1:] {
+			if r.ElapsedMs < first.ElapsedMs {
+				first = r
+			}
+		}
+		return WaitAllResult{First: first.Condition, Conditions: results}, nil
+	})
+}
+
+// WaitPopupResult is returned by the waitpopup command.
+type WaitPopupResult struct {
+	TargetID string `json:"targetId"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	OpenerID string `json:"openerId"`
+}
+
+const waitPopupUsage = "usage: hubcap waitpopup [--trigger <command>] [--timeout <duration>]"
+
+func cmdWaitPopup(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("waitpopup", flag.ContinueOnError)
+	fs.SetOutput(cfg.Stderr)
+	timeout := fs.Duration("timeout", 30*time.Second, "Max wait time")
+	triggerLine := fs.String("trigger", "", "Command that opens the popup, such as \"click #share\"")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return ExitSuccess
+		}
+		return ExitError
+	}
+	if fs.NArg() > 0 {
+		return cmdMissingArg(cfg, waitPopupUsage)
+	}
+
+	var trigger *trigger
+	if *triggerLine != "" {
+		var err error
+		if trigger, err = newTrigger(splitArgs(*triggerLine)); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client, err := connectClient(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitConnFailed
+	}
+	defer client.Close()
+
+	target, err := prepareTarget(ctx, client, cfg)
+	if err != nil {
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	var fire func() error
+	if trigger != nil {
+		fire = func() error { return trigger.fire(cfg, target.ID) }
+	}
+	popup, err := client.WaitForPopup(ctx, target.ID, fire)
+	if err != nil {
+		switch {
+		case errors.Is(err, errTriggerFailed):
+			return trigger.code
+		case errors.Is(err, context.DeadlineExceeded):
+			fmt.Fprintln(cfg.Stderr, "error: timeout waiting for a popup")
+			return ExitTimeout
+		}
+		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	// Note the popup now so that -target last picks it
+	if pages, err := client.Pages(ctx); err == nil {
+		if _, err := notePages(configDir(), cfg.Host, cfg.Port, pages); err != nil {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	return outputResult(cfg, WaitPopupResult{
+		TargetID: popup.ID,
+		URL:      popup.URL,
+		Title:    popup.Title,
+		OpenerID: popup.OpenerID,
+	})
+}
@@ -14,6 +14,9 @@ type fileConfig struct {
 	Timeout *string `json:"timeout,omitempty"` // duration string, e.g. "30s"
 	Output  *string `json:"output,omitempty"`
 	Target  *string `json:"target,omitempty"`
+	Strict  *bool   `json:"strict,omitempty"`
+
+	DownloadDir *string `json:"download_dir,omitempty"`
 }
 
 // loadConfigFile loads a .hubcaprc file and applies it to cfg.
@@ -59,4 +62,10 @@ func applyFileConfig(cfg *Config, fc *fileConfig) {
 	if fc.Target != nil {
 		cfg.Target = *fc.Target
 	}
+	if fc.Strict != nil {
+		cfg.Strict = *fc.Strict
+	}
+	if fc.DownloadDir != nil {
+		cfg.DownloadDir = *fc.DownloadDir
+	}
 }
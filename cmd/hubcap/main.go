@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -28,6 +29,12 @@ type Config struct {
 	Output  string // json, ndjson, text
 	Quiet   bool
 	Target  string // target index or ID
+	Context string // browser context ID or name, or "" for any
+	Frame   string // child frame by name, URL glob or selector
+	Strict  bool   // fail when a selector for one element matches several
+	Index   *int   // match taken by selectors without :nth, or nil for the first
+
+	DownloadDir string // directory waitdownload and the daemon save downloads to, or ""
 
 	Stdin  io.Reader
 	Stdout io.Writer
@@ -65,6 +72,12 @@ type flagValues struct {
 	output  string
 	quiet   bool
 	target  string
+	context string
+	frame   string
+	strict  bool
+	index   int
+
+	downloadDir string
 }
 
 func run(args []string, cfg *Config) int {
@@ -77,7 +90,12 @@ func run(args []string, cfg *Config) int {
 	fs.DurationVar(&fv.timeout, "timeout", cfg.Timeout, "Command timeout")
 	fs.StringVar(&fv.output, "output", cfg.Output, "Output format: json, ndjson, text")
 	fs.BoolVar(&fv.quiet, "quiet", cfg.Quiet, "Suppress non-essential output")
-	fs.StringVar(&fv.target, "target", cfg.Target, "Target page (index or ID)")
+	fs.StringVar(&fv.target, "target", cfg.Target, "Target page (index, ID, last, opener, url:<glob> or title:<glob>)")
+	fs.StringVar(&fv.context, "context", cfg.Context, "Browser context of the target, by ID or name (see context new)")
+	fs.StringVar(&fv.frame, "frame", cfg.Frame, "Child frame for DOM, input and wait commands (name, URL glob or selector)")
+	fs.BoolVar(&fv.strict, "strict", cfg.Strict, "Fail when a selector for one element matches several")
+	fs.IntVar(&fv.index, "index", 0, "Match to use, from 0, for selectors without :nth")
+	fs.StringVar(&fv.downloadDir, "download-dir", cfg.DownloadDir, "Directory waitdownload and the daemon save downloads to")
 	profileName := fs.String("profile", "", "Named profile (env: HUBCAP_PROFILE)")
 	helpCommands := fs.Bool("help-commands", false, "List all commands with descriptions")
 
@@ -184,6 +202,9 @@ func applyProfile(cfg *Config, flagProfile string) int {
 	if p.Target != "" {
 		cfg.Target = p.Target
 	}
+	if p.DownloadDir != "" {
+		cfg.DownloadDir = p.DownloadDir
+	}
 
 	// Ephemeral profile: auto-launch Chrome if needed
 	if p.Ephemeral {
@@ -236,12 +257,26 @@ func reapplyExplicitFlags(cfg *Config, fv *flagValues, explicit map[string]bool)
 	if explicit["target"] {
 		cfg.Target = fv.target
 	}
+	if explicit["context"] {
+		cfg.Context = fv.context
+	}
+	if explicit["frame"] {
+		cfg.Frame = fv.frame
+	}
+	if explicit["strict"] {
+		cfg.Strict = fv.strict
+	}
+	if explicit["index"] {
+		cfg.Index = &fv.index
+	}
+	if explicit["download-dir"] {
+		cfg.DownloadDir = fv.downloadDir
+	}
 }
 
-// resolveTarget resolves the target page from cfg.Target.
-// If cfg.Target is empty, returns the first page.
-// If cfg.Target is a number, uses it as an index into the pages list.
-// Otherwise, treats cfg.Target as a target ID.
+// resolveTarget resolves the target page from cfg.Target, as selectTarget
+// describes, among the pages in the browser context set by -context, if
+// any, noting any pages opened since hubcap last looked.
 func resolveTarget(ctx context.Context, client *chrome.Client, cfg *Config) (*chrome.TargetInfo, error) {
 	pages, err := client.Pages(ctx)
 	if err != nil {
@@ -251,27 +286,57 @@ func resolveTarget(ctx context.Context, client *chrome.Client, cfg *Config) (*ch
 		return nil, fmt.Errorf("no pages available")
 	}
 
-	// Default: first page
-	if cfg.Target == "" {
-		return &pages[0], nil
+	order, err := notePages(configDir(), cfg.Host, cfg.Port, pages)
+	if err != nil {
+		return nil, err
 	}
 
-	// Try as index first
-	if idx, err := strconv.Atoi(cfg.Target); err == nil {
-		if idx < 0 || idx >= len(pages) {
-			return nil, fmt.Errorf("invalid target index: %d (have %d pages)", idx, len(pages))
+	if cfg.Context != "" {
+		contextID, err := resolveContext(ctx, client, cfg, cfg.Context)
+		if err != nil {
+			return nil, err
+		}
+		pages = contextPages(pages, contextID)
+		if len(pages) == 0 {
+			return nil, fmt.Errorf("no pages in context %s", cfg.Context)
 		}
-		return &pages[idx], nil
 	}
+	return selectTarget(pages, cfg.Target, order)
+}
 
-	// Otherwise, treat as target ID
-	for i := range pages {
-		if pages[i].ID == cfg.Target {
-			return &pages[i], nil
+// prepareTarget resolves the target page, restores the per-target state
+// hubcap keeps between commands, such as emulation overrides, scopes the
+// client to the frame selected by -frame and sets how selectors pick an
+// element, including the element refs handed out for the target.
+func prepareTarget(ctx context.Context, client *chrome.Client, cfg *Config) (*chrome.TargetInfo, error) {
+	target, err := resolveTarget(ctx, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverrides(ctx, client, target.ID); err != nil {
+		return nil, err
+	}
+	if cfg.Frame != "" {
+		if err := client.SetFrame(ctx, target.ID, cfg.Frame); err != nil {
+			return nil, err
 		}
 	}
-
-	return nil, fmt.Errorf("invalid target: %s (not found)", cfg.Target)
+	refs, err := loadTargetRefs(configDir(), target.ID)
+	if err != nil {
+		return nil, err
+	}
+	client.SetRefs(refs.Refs)
+	client.SetStrict(cfg.Strict)
+	if cfg.Index != nil {
+		if *cfg.Index < 0 {
+			return nil, fmt.Errorf("invalid -index %d: must be at least 0", *cfg.Index)
+		}
+		client.SetIndex(*cfg.Index)
+	}
+	if cfg.Context != "" {
+		client.SetBrowserContext(target.BrowserContextID)
+	}
+	return target, nil
 }
 
 // withClient executes a function with a connected Chrome client.
@@ -279,7 +344,7 @@ func withClient(cfg *Config, fn func(ctx context.Context, client *chrome.Client)
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
 	defer cancel()
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
@@ -304,14 +369,14 @@ func withClientTarget(cfg *Config, fn func(ctx context.Context, client *chrome.C
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
 	defer cancel()
 
-	client, err := chrome.Connect(ctx, cfg.Host, cfg.Port)
+	client, err := connectClient(ctx, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitConnFailed
 	}
 	defer client.Close()
 
-	target, err := resolveTarget(ctx, client, cfg)
+	target, err := prepareTarget(ctx, client, cfg)
 	if err != nil {
 		fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
 		return ExitError
@@ -319,6 +384,12 @@ func withClientTarget(cfg *Config, fn func(ctx context.Context, client *chrome.C
 
 	result, err := fn(ctx, client, target)
 	if err != nil {
+		// Actionability errors say which check timed out
+		var notActionable *chrome.ActionabilityError
+		if errors.As(err, &notActionable) {
+			fmt.Fprintf(cfg.Stderr, "error: %v\n", err)
+			return ExitTimeout
+		}
 		if ctx.Err() == context.DeadlineExceeded {
 			fmt.Fprintln(cfg.Stderr, "error: timeout")
 			return ExitTimeout
@@ -2,13 +2,20 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/tomyan/hubcap/internal/annotate"
+	"github.com/tomyan/hubcap/internal/chrome"
 	"github.com/tomyan/hubcap/internal/testutil"
 )
 
@@ -19,8 +26,16 @@ var chromeInstance *testutil.ChromeInstance
 
 // TestMain sets up and tears down Chrome for all tests
 func TestMain(m *testing.M) {
+	// Keep state such as the order pages were seen in out of the real
+	// config dir
+	dir, err := os.MkdirTemp("", "hubcap-config")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create config dir: %v\n", err)
+		os.Exit(1)
+	}
+	os.Setenv("HUBCAP_CONFIG_DIR", dir)
+
 	// Start Chrome for this package's tests
-	var err error
 	chromeInstance, err = testutil.StartChrome(testChromePort)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start Chrome: %v\n", err)
@@ -32,6 +47,7 @@ func TestMain(m *testing.M) {
 
 	// Stop Chrome
 	chromeInstance.Stop()
+	os.RemoveAll(dir)
 
 	os.Exit(code)
 }
@@ -520,6 +536,89 @@ func TestRun_Screenshot_JPEG(t *testing.T) {
 	}
 }
 
+func TestRun_Screenshot_Annotate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	cfg := testConfig()
+	tmpFile := t.TempDir() + "/annotated.png"
+
+	code := run([]string{"screenshot", "--output", tmpFile, "--annotate"}, cfg)
+	if code != ExitSuccess {
+		stderr := cfg.Stderr.(*bytes.Buffer).String()
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, stderr)
+	}
+
+	var result AnnotatedScreenshotResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Marks == nil {
+		t.Error("expected 'marks' field in output")
+	}
+
+	f, err := os.Open(tmpFile)
+	if err != nil {
+		t.Fatalf("failed to open screenshot file: %v", err)
+	}
+	defer f.Close()
+	if _, err := png.Decode(f); err != nil {
+		t.Fatalf("file is not valid PNG: %v", err)
+	}
+}
+
+func TestRun_Screenshot_AnnotateWithSelector(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"screenshot", "--output", "/tmp/test.png", "--annotate", "--selector", "#main"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "cannot be combined with --selector") {
+		t.Errorf("expected combination error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_Screenshot_AnnotateWebP(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"screenshot", "--base64", "--annotate-selector", "a", "--format", "webp"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "supports png and jpeg") {
+		t.Errorf("expected format error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestScreenshotMarks(t *testing.T) {
+	elements := []chrome.ElementInfo{
+		{Index: 0, BackendNodeID: 10, TagName: "A", Text: "Home", Visible: true, Bounds: chrome.BoundingBox{X: 10, Y: 10, Width: 40, Height: 20}},
+		{Index: 1, BackendNodeID: 11, TagName: "BUTTON", Visible: false},
+		{Index: 2, BackendNodeID: 12, TagName: "BUTTON", Text: "Below", Visible: true, Bounds: chrome.BoundingBox{X: 10, Y: 700, Width: 40, Height: 20}},
+		{Index: 3, BackendNodeID: 13, TagName: "INPUT", Visible: true, Bounds: chrome.BoundingBox{X: 380, Y: 290, Width: 100, Height: 20}},
+	}
+
+	// A 400x300 viewport at a device pixel ratio of 2
+	marks, boxes := screenshotMarks(elements, "a, button, input", image.Rect(0, 0, 800, 600), 2)
+	if len(marks) != 2 || len(boxes) != 2 {
+		t.Fatalf("expected 2 marks, got %+v", marks)
+	}
+	if marks[0].Label != 1 || marks[0].BackendNodeID != 10 || marks[0].Selector != "a, button, input:nth=0" || marks[0].Text != "Home" {
+		t.Errorf("unexpected first mark: %+v", marks[0])
+	}
+	if marks[1].Label != 2 || marks[1].BackendNodeID != 13 || marks[1].Selector != "a, button, input:nth=3" {
+		t.Errorf("unexpected second mark: %+v", marks[1])
+	}
+	if boxes[1] != (annotate.Mark{Label: 2, X: 380, Y: 290, Width: 100, Height: 20}) {
+		t.Errorf("unexpected box: %+v", boxes[1])
+	}
+
+	marks, _ = screenshotMarks(elements[:1], "#home:nth=0", image.Rect(0, 0, 800, 600), 2)
+	if len(marks) != 1 || marks[0].Selector != "#home:nth=0" {
+		t.Errorf("expected the selector kept, got %+v", marks)
+	}
+}
+
 func TestRun_Screenshot_NoChrome(t *testing.T) {
 	cfg := testConfig()
 	cfg.Port = 1
@@ -653,6 +752,19 @@ func TestRun_Query_NoChrome(t *testing.T) {
 	}
 }
 
+func TestRun_QueryAll_MissingSelector(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"queryall"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+
+	stderr := cfg.Stderr.(*bytes.Buffer).String()
+	if !strings.Contains(stderr, "usage:") {
+		t.Errorf("expected usage message, got: %s", stderr)
+	}
+}
+
 func TestRun_Click_MissingSelector(t *testing.T) {
 	cfg := testConfig()
 	code := run([]string{"click"}, cfg)
@@ -822,6 +934,17 @@ func TestRun_Wait_MissingSelector(t *testing.T) {
 	}
 }
 
+func TestRun_Wait_InvalidState(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"wait", "--state", "open", "#x"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), `invalid --state "open"`) {
+		t.Errorf("expected state error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 func TestRun_Wait_Success(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -865,6 +988,28 @@ func TestRun_Type_MissingText(t *testing.T) {
 	}
 }
 
+func TestRun_Type_NegativeDelay(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"type", "--delay", "-5ms", "hello"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "--delay must not be negative") {
+		t.Errorf("expected delay error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_Type_TooManyArgs(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"type", "hello", "world"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 func TestRun_Type_Success(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -2635,6 +2780,122 @@ func TestRun_Upload_NoChrome(t *testing.T) {
 	}
 }
 
+func TestRun_Upload_OnChooserUsage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(file, []byte("a"), 0644)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"upload", "--on-chooser", file}, "usage:"},
+		{[]string{"upload", "--on-chooser", "--", "click", "#b"}, "usage:"},
+		{[]string{"upload", "--on-chooser", file, "--"}, "usage:"},
+		{[]string{"upload", "--on-chooser", file, "--", "nosuchcommand"}, "unknown command: nosuchcommand"},
+		{[]string{"upload", "--on-chooser", file + ".missing", "--", "click", "#b"}, "no such file"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		code := run(tt.args, cfg)
+		if code != ExitError {
+			t.Errorf("%q: expected exit code %d, got %d", tt.args, ExitError, code)
+		}
+		if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, tt.want) {
+			t.Errorf("%q: expected %q in stderr, got %q", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestRun_Upload_OnChooser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	// A custom widget clicks a hidden file input
+	cfg := testConfig()
+	page := `data:text/html,<html><body><input type="file" id="f" multiple hidden><button id="b" onclick="f.click()">Upload</button>` +
+		`<script>window.names='';f.onchange=()=>names=[...f.files].map(x=>x.name).join(',')</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+	}
+
+	cfg = testConfig()
+	code := run([]string{"--target", tabID, "upload", "--on-chooser", filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), "--", "click", "#b"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var result UploadResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Chooser == nil || result.Chooser.Mode != "selectMultiple" {
+		t.Errorf("expected a multiple file chooser, got %+v", result.Chooser)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "eval", "names"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "a.txt,b.txt") {
+		t.Errorf("expected both files chosen, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_DropFiles_MissingArgs(t *testing.T) {
+	cfg := testConfig()
+	if code := run([]string{"dropfiles", "#zone"}, cfg); code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"dropfiles", "#zone", filepath.Join(t.TempDir(), "missing.txt")}, cfg); code != ExitError {
+		t.Errorf("expected exit code %d for a missing file, got %d", ExitError, code)
+	}
+}
+
+func TestRun_DropFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	page := `data:text/html,<html><body><div id="zone" style="width:200px;height:200px">Drop here</div>` +
+		`<script>window.names='';zone.ondragover=e=>e.preventDefault();zone.ondrop=e=>{e.preventDefault();names=[...e.dataTransfer.files].map(x=>x.name).join(',')}</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	file := filepath.Join(t.TempDir(), "photo.txt")
+	os.WriteFile(file, []byte("photo"), 0644)
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "dropfiles", "#zone", file}, cfg); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "eval", "names"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "photo.txt") {
+		t.Errorf("expected the file dropped, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+}
+
 func TestRun_Exists_MissingSelector(t *testing.T) {
 	cfg := testConfig()
 	code := run([]string{"exists"}, cfg)
@@ -3997,6 +4258,91 @@ func TestRun_Drag_Success(t *testing.T) {
 	}
 }
 
+func TestRun_Drag_HTML5(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	// The drop target records what was dropped on it
+	cfg := testConfig()
+	dataURL := `data:text/html,<html><body><div id="src" draggable="true" style="width:50px;height:50px;background:red;position:absolute;left:10px;top:10px"></div><div id="dst" style="width:100px;height:100px;background:blue;position:absolute;left:200px;top:10px"></div>` +
+		`<script>src.ondragstart=e=>e.dataTransfer.setData("text/plain","moved");dst.ondragover=e=>e.preventDefault();dst.ondrop=e=>{e.preventDefault();window.dropped=e.dataTransfer.getData("text/plain")}</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", dataURL}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "drag", "--steps", "5", "#src", "#dst"}, cfg); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var result DragResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Mode != "html5" {
+		t.Errorf("expected an html5 drag, got %q", result.Mode)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "eval", "window.dropped"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "moved") {
+		t.Errorf("expected the drop to carry the drag data, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_Drag_Pointer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	// A pointer-based library counts the moves made while pressed
+	cfg := testConfig()
+	dataURL := `data:text/html,<html><body><div id="src" style="width:50px;height:50px;background:red;position:absolute;left:10px;top:10px"></div><div id="dst" style="width:100px;height:100px;background:blue;position:absolute;left:200px;top:10px"></div>` +
+		`<script>window.moves=0;src.onpointerdown=()=>{onpointermove=()=>moves++;onpointerup=e=>window.released=e.clientX}</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", dataURL}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "drag", "--steps", "4", "#src", "#dst"}, cfg); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+	var result DragResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Mode != "pointer" {
+		t.Errorf("expected a pointer drag, got %q", result.Mode)
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", tabID, "eval", "moves + ',' + released"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "4,250") {
+		t.Errorf("expected 4 moves and a release at the destination, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_Drag_InvalidSteps(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"drag", "--steps", "0", "#src", "#dst"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "--steps must be at least 1") {
+		t.Errorf("expected steps error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 func TestRun_Drag_NoChrome(t *testing.T) {
 	cfg := testConfig()
 	cfg.Port = 1 // Invalid port
@@ -4192,6 +4538,86 @@ func TestRun_Har_NoChrome(t *testing.T) {
 	}
 }
 
+func TestRun_Har_InvalidContent(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"har", "--content", "inline"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+}
+
+func TestRun_Har_SeparateFilesRequiresOutput(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"har", "--content", "separate-files"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "--output") {
+		t.Errorf("expected --output error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestHARContentFiles_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	har := &chrome.HARLog{}
+	har.Log.Entries = []chrome.HAREntry{
+		{Response: chrome.HARResponse{Content: chrome.HARContent{MimeType: "text/html", Text: "<p>hi</p>"}}},
+		{Response: chrome.HARResponse{Content: chrome.HARContent{MimeType: "image/png", Text: "iVBORw0K", Encoding: "base64"}}},
+		{Response: chrome.HARResponse{Content: chrome.HARContent{MimeType: "text/html", Text: "<p>hi</p>"}}},
+		{Response: chrome.HARResponse{Content: chrome.HARContent{MimeType: "text/plain"}}},
+	}
+
+	files, err := writeHARContentFiles(har, dir)
+	if err != nil {
+		t.Fatalf("writeHARContentFiles: %v", err)
+	}
+	if files != 2 {
+		t.Errorf("expected 2 files (identical bodies shared), got %d", files)
+	}
+	html := har.Log.Entries[0].Response.Content
+	if html.Text != "" || !strings.HasSuffix(html.File, ".html") {
+		t.Errorf("expected body moved to an .html file, got %+v", html)
+	}
+	if har.Log.Entries[3].Response.Content.File != "" {
+		t.Errorf("expected no file for an empty body")
+	}
+
+	if err := loadHARContentFiles(har, dir); err != nil {
+		t.Fatalf("loadHARContentFiles: %v", err)
+	}
+	html = har.Log.Entries[0].Response.Content
+	if html.Encoding != "base64" || html.Text != base64.StdEncoding.EncodeToString([]byte("<p>hi</p>")) {
+		t.Errorf("expected body restored, got %+v", html)
+	}
+	png := har.Log.Entries[1].Response.Content
+	if png.Text != "iVBORw0K" {
+		t.Errorf("expected binary body restored, got %q", png.Text)
+	}
+}
+
+func TestRun_HarReplay_RequiresFile(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"har", "replay"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_HarReplay_InvalidPolicy(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"har", "replay", "--not-found", "ignore", "recording.har"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "--not-found") {
+		t.Errorf("expected not-found error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 func TestRun_Coverage_Success(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -4652,6 +5078,57 @@ func TestRun_Press_WithModifiers(t *testing.T) {
 	}
 }
 
+func TestRun_Press_ChordSequence(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	// Record the keydown events and the modifiers held for each
+	cfg := testConfig()
+	code := run([]string{"--target", tabID, "goto", "data:text/html,<html><body><script>window.keys=[];addEventListener('keydown',e=>keys.push((e.ctrlKey?'C':'')+(e.shiftKey?'S':'')+e.code))</script></body></html>"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	code = run([]string{"--target", tabID, "press", "Control+Shift+K", "F5"}, cfg)
+	if code != ExitSuccess {
+		stderr := cfg.Stderr.(*bytes.Buffer).String()
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, stderr)
+	}
+
+	cfg = testConfig()
+	code = run([]string{"--target", tabID, "eval", "keys.join(' ')"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("failed to eval")
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result["value"] != "CControlLeft CSShiftLeft CSKeyK F5" {
+		t.Errorf("unexpected key events: %v", result["value"])
+	}
+}
+
+func TestRun_Press_UnknownKey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	cfg := testConfig()
+	code := run([]string{"press", "Control+Hyper"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), `unknown key "Hyper"`) {
+		t.Errorf("expected unknown key error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 func TestRun_Mouse_MissingArgs(t *testing.T) {
 	cfg := testConfig()
 	code := run([]string{"mouse"}, cfg)
@@ -4841,6 +5318,111 @@ func TestRun_WaitResponse_NoChrome(t *testing.T) {
 	}
 }
 
+func TestRun_WaitAny_UsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"waitany"}, "usage:"},
+		{[]string{"waitall", "--timeout", "1s"}, "usage:"},
+		{[]string{"waitany", "#success"}, `invalid condition "#success"`},
+		{[]string{"waitall", "selector:#a", "text"}, `condition "text" needs a value`},
+		{[]string{"waitany", "status:9xx"}, `invalid status "9xx"`},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		code := run(tt.args, cfg)
+		if code != ExitError {
+			t.Errorf("%q: expected exit code %d, got %d", tt.args, ExitError, code)
+		}
+		if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, tt.want) {
+			t.Errorf("%q: expected %q in stderr, got %q", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestRun_WaitAny_Success(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	page := `data:text/html,<html><body><script>setTimeout(() => { document.body.innerHTML = '<p id="success">Saved</p>'; }, 300);</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	code := run([]string{"--target", tabID, "waitany", "--timeout", "5s", "url:/error", "selector:#success", "status:5xx", "console:failed"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result chrome.ConditionResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Condition != "selector:#success" || result.Index != 1 {
+		t.Errorf("expected selector:#success to fire, got %+v", result)
+	}
+}
+
+func TestRun_WaitAny_Timeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	code := run([]string{"--target", tabID, "waitany", "--timeout", "300ms", "selector:#never", "text:never"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, "timeout waiting for any of 2 conditions") {
+		t.Errorf("expected timeout error, got %q", stderr)
+	}
+}
+
+func TestRun_WaitAll_Success(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	page := `data:text/html,<html><body><script>` +
+		`setTimeout(() => console.log('app ready'), 200);` +
+		`setTimeout(() => { document.body.innerHTML = '<p id="done">Done</p>'; }, 500);` +
+		`</script></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	code := run([]string{"--target", tabID, "waitall", "--timeout", "5s", "text:Done", "console:ready"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result WaitAllResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.First != "console:ready" {
+		t.Errorf("expected console:ready first, got %q", result.First)
+	}
+	if len(result.Conditions) != 2 || result.Conditions[0].Condition != "text:Done" {
+		t.Errorf("expected both conditions in order, got %+v", result.Conditions)
+	}
+}
+
 func TestRun_Computed_MissingArgs(t *testing.T) {
 	cfg := testConfig()
 	code := run([]string{"computed"}, cfg)
@@ -5807,6 +6389,118 @@ func TestRun_HeapSnapshot_NoChrome(t *testing.T) {
 	}
 }
 
+// testHeapSnapshot is a heap snapshot of a window holding count Foo objects.
+func testHeapSnapshot(count int) string {
+	nodes := "9,0,1,0,1,0,0,3,1,3,10," + strconv.Itoa(count) + ",0,0"
+	edges := "1,0,7"
+	for i := 0; i < count; i++ {
+		nodes += fmt.Sprintf(",3,2,%d,100,0,0,0", 5+2*i)
+		edges += fmt.Sprintf(",2,3,%d", 14+7*i)
+	}
+	return `{"snapshot":{"meta":{` +
+		`"node_fields":["type","name","id","self_size","edge_count","trace_node_id","detachedness"],` +
+		`"node_types":[["hidden","array","string","object","code","closure","regexp","number","native","synthetic"],"string","number","number","number","number","number"],` +
+		`"edge_fields":["type","name_or_index","to_node"],` +
+		`"edge_types":[["context","element","property","internal","hidden","shortcut","weak"],"string_or_number","node"]}},` +
+		`"nodes":[` + nodes + `],"edges":[` + edges + `],"strings":["","Window","Foo","foo"]}`
+}
+
+func TestRun_HeapSnapshotSummary_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.heapsnapshot")
+	if err := os.WriteFile(path, []byte(testHeapSnapshot(3)), 0644); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+
+	cfg := testConfig()
+	code := run([]string{"heapsnapshot", "summary", path}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result struct {
+		NodeCount    int `json:"nodeCount"`
+		Constructors []struct {
+			Name         string `json:"name"`
+			Count        int    `json:"count"`
+			RetainedSize int    `json:"retainedSize"`
+		} `json:"constructors"`
+	}
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.NodeCount != 5 {
+		t.Errorf("expected 5 nodes, got %d", result.NodeCount)
+	}
+	foo := result.Constructors[1]
+	if foo.Name != "Foo" || foo.Count != 3 || foo.RetainedSize != 300 {
+		t.Errorf("unexpected constructors: %+v", result.Constructors)
+	}
+}
+
+func TestRun_HeapSnapshotSummary_InvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.heapsnapshot")
+	if err := os.WriteFile(path, []byte(`{"nodes":[1,2,3]}`), 0644); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+
+	cfg := testConfig()
+	code := run([]string{"heapsnapshot", "summary", path}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "parsing heap snapshot") {
+		t.Errorf("expected parse error, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
+func TestRun_HeapSnapshotDiff(t *testing.T) {
+	dir := t.TempDir()
+	before := filepath.Join(dir, "before.heapsnapshot")
+	after := filepath.Join(dir, "after.heapsnapshot")
+	if err := os.WriteFile(before, []byte(testHeapSnapshot(1)), 0644); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+	if err := os.WriteFile(after, []byte(testHeapSnapshot(4)), 0644); err != nil {
+		t.Fatalf("writing snapshot: %v", err)
+	}
+
+	cfg := testConfig()
+	code := run([]string{"heapsnapshot", "diff", before, after}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result struct {
+		Constructors []struct {
+			Name       string `json:"name"`
+			CountDelta int    `json:"countDelta"`
+			New        int    `json:"new"`
+			SizeDelta  int    `json:"sizeDelta"`
+		} `json:"constructors"`
+	}
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if len(result.Constructors) != 1 {
+		t.Fatalf("expected only Foo to grow, got %+v", result.Constructors)
+	}
+	foo := result.Constructors[0]
+	if foo.Name != "Foo" || foo.CountDelta != 3 || foo.New != 3 || foo.SizeDelta != 300 {
+		t.Errorf("unexpected Foo diff: %+v", foo)
+	}
+}
+
+func TestRun_HeapSnapshotDiff_MissingArgs(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"heapsnapshot", "diff", "before.heapsnapshot"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 // Slice 117: Performance trace
 
 func TestRun_Trace_MissingArgs(t *testing.T) {
@@ -5875,6 +6569,58 @@ func TestRun_Trace_NoChrome(t *testing.T) {
 	}
 }
 
+func TestRun_TraceSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trace.json")
+	data := `[
+		{"name": "thread_name", "ph": "M", "pid": 1, "tid": 1, "ts": 0, "args": {"name": "CrRendererMain"}},
+		{"name": "RunTask", "ph": "X", "pid": 1, "tid": 1, "ts": 1000, "dur": 70000},
+		{"name": "FunctionCall", "ph": "X", "pid": 1, "tid": 1, "ts": 2000, "dur": 60000, "args": {"data": {"url": "app.js", "functionName": "render"}}},
+		{"name": "RunTask", "ph": "X", "pid": 1, "tid": 1, "ts": 80000, "dur": 5000}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("writing trace: %v", err)
+	}
+
+	cfg := testConfig()
+	code := run([]string{"trace", "summary", path}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result struct {
+		Tasks  int `json:"tasks"`
+		Totals struct {
+			Scripting float64 `json:"scripting"`
+		} `json:"totals"`
+		LongTasks []struct {
+			Duration    float64 `json:"duration"`
+			Attribution struct {
+				Function string `json:"function"`
+			} `json:"attribution"`
+		} `json:"longTasks"`
+	}
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.Tasks != 2 || result.Totals.Scripting != 60 {
+		t.Errorf("expected 2 tasks and 60ms scripting, got %+v", result)
+	}
+	if len(result.LongTasks) != 1 || result.LongTasks[0].Duration != 70 || result.LongTasks[0].Attribution.Function != "render" {
+		t.Errorf("unexpected long tasks: %+v", result.LongTasks)
+	}
+}
+
+func TestRun_TraceSummary_MissingFile(t *testing.T) {
+	cfg := testConfig()
+	code := run([]string{"trace", "summary"}, cfg)
+	if code != ExitError {
+		t.Errorf("expected exit code %d, got %d", ExitError, code)
+	}
+	if !strings.Contains(cfg.Stderr.(*bytes.Buffer).String(), "usage:") {
+		t.Errorf("expected usage message, got %q", cfg.Stderr.(*bytes.Buffer).String())
+	}
+}
+
 func TestConfig_FromFile(t *testing.T) {
 	t.Parallel()
 
@@ -5909,6 +6655,29 @@ func TestConfig_FromFile(t *testing.T) {
 	}
 }
 
+func TestConfig_StrictAndIndex(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	if cfg.Strict || cfg.Index != nil {
+		t.Fatalf("expected first match by default, got strict %v index %v", cfg.Strict, cfg.Index)
+	}
+
+	strict := true
+	applyFileConfig(cfg, &fileConfig{Strict: &strict})
+	if !cfg.Strict {
+		t.Error("expected strict from file config")
+	}
+
+	run([]string{"-strict=false", "-index", "2", "version"}, cfg)
+	if cfg.Strict {
+		t.Error("expected -strict=false to override file config")
+	}
+	if cfg.Index == nil || *cfg.Index != 2 {
+		t.Errorf("expected index 2 from CLI flag, got %v", cfg.Index)
+	}
+}
+
 func TestConfig_CLIOverridesFile(t *testing.T) {
 	t.Parallel()
 
@@ -6380,6 +7149,23 @@ func TestRun_Shell_DotTarget(t *testing.T) {
 	}
 }
 
+func TestRun_Shell_DotContext(t *testing.T) {
+	t.Parallel()
+	cfg := testConfig()
+	cfg.Port = 1
+	cfg.Stdin = strings.NewReader(".context alice\n.quit\n")
+	cfg.Stdout = &bytes.Buffer{}
+	cfg.Stderr = &bytes.Buffer{}
+
+	code := run([]string{"shell"}, cfg)
+	if code != ExitSuccess {
+		t.Errorf("expected ExitSuccess, got %d", code)
+	}
+	if cfg.Context != "alice" {
+		t.Errorf("expected context 'alice', got %q", cfg.Context)
+	}
+}
+
 func TestRun_Shell_DotOutput(t *testing.T) {
 	t.Parallel()
 	cfg := testConfig()
@@ -23,6 +23,7 @@ type Profile struct {
 	ChromeDataDir    string `json:"chrome_data_dir,omitempty"`
 	Ephemeral        bool   `json:"ephemeral,omitempty"`
 	EphemeralTimeout string `json:"ephemeral_timeout,omitempty"`
+	DownloadDir      string `json:"download_dir,omitempty"`
 }
 
 // ProfilesFile represents the on-disk profiles.json structure.
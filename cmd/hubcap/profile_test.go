@@ -194,7 +194,8 @@ func TestProfileAllFields(t *testing.T) {
 				"headless": true,
 				"chrome_data_dir": "/tmp/data",
 				"ephemeral": true,
-				"ephemeral_timeout": "10m"
+				"ephemeral_timeout": "10m",
+				"download_dir": "/tmp/downloads"
 			}
 		}
 	}`
@@ -238,4 +239,7 @@ func TestProfileAllFields(t *testing.T) {
 	if p.EphemeralTimeout != "10m" {
 		t.Errorf("EphemeralTimeout = %q", p.EphemeralTimeout)
 	}
+	if p.DownloadDir != "/tmp/downloads" {
+		t.Errorf("DownloadDir = %q", p.DownloadDir)
+	}
 }
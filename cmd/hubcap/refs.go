@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+// maxRefs is the number of element refs kept per target; the oldest are
+// forgotten first.
+const maxRefs = 10000
+
+// targetRefs are the element refs handed out for a target by commands that
+// list elements, so that later commands can select the same elements as
+// @e1, @e2 and so on. A ref names a backend node ID in the frame and
+// document it was listed in, as Chrome reuses backend node IDs in other
+// renderer processes.
+type targetRefs struct {
+	Next int                          `json:"next"`     // Number of the next ref
+	Refs map[string]chrome.ElementRef `json:"elements"` // Ref name, such as e12 -> element
+}
+
+// refsPath returns the path of the element refs file for a target.
+func refsPath(dir, targetID string) string {
+	return filepath.Join(dir, "refs", targetID+".json")
+}
+
+// loadTargetRefs loads the element refs of a target.
+// Returns no refs if none have been handed out.
+func loadTargetRefs(dir, targetID string) (*targetRefs, error) {
+	r := &targetRefs{Next: 1, Refs: map[string]chrome.ElementRef{}}
+	data, err := os.ReadFile(refsPath(dir, targetID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, fmt.Errorf("reading element refs: %w", err)
+	}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parsing element refs: %w", err)
+	}
+	if r.Refs == nil {
+		r.Refs = map[string]chrome.ElementRef{}
+	}
+	return r, nil
+}
+
+// saveTargetRefs saves the element refs of a target.
+func saveTargetRefs(dir, targetID string, r *targetRefs) error {
+	if err := os.MkdirAll(filepath.Join(dir, "refs"), 0755); err != nil {
+		return fmt.Errorf("creating refs dir: %w", err)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshaling element refs: %w", err)
+	}
+	if err := os.WriteFile(refsPath(dir, targetID), data, 0644); err != nil {
+		return fmt.Errorf("writing element refs: %w", err)
+	}
+	return nil
+}
+
+// add hands out a new ref for an element.
+func (r *targetRefs) add(element chrome.ElementRef) string {
+	name := fmt.Sprintf("e%d", r.Next)
+	r.Next++
+	r.Refs[name] = element
+	return "@" + name
+}
+
+// prune forgets the oldest refs beyond maxRefs.
+func (r *targetRefs) prune() {
+	if len(r.Refs) <= maxRefs {
+		return
+	}
+	names := make([]string, 0, len(r.Refs))
+	for name := range r.Refs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return refNumber(names[i]) < refNumber(names[j]) })
+	for _, name := range names[:len(names)-maxRefs] {
+		delete(r.Refs, name)
+	}
+}
+
+// refNumber returns the number of a ref name such as e12.
+func refNumber(name string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(name, "e"))
+	return n
+}
+
+// handOutRefs hands out refs for the backend node IDs of elements a command
+// listed in a target, returning them in the same order. Zero IDs, for nodes
+// without a DOM node, get no ref.
+func handOutRefs(ctx context.Context, client *chrome.Client, targetID string, backendNodeIDs []int64) ([]string, error) {
+	frameID, loaderID, err := client.RefDocument(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return assignRefs(configDir(), targetID, frameID, loaderID, backendNodeIDs)
+}
+
+// handOutPageRefs is handOutRefs for elements a command listed from the
+// page's main frame, whatever the -frame flag picked.
+func handOutPageRefs(ctx context.Context, client *chrome.Client, targetID string, backendNodeIDs []int64) ([]string, error) {
+	frameID, loaderID, err := client.PageDocument(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	return assignRefs(configDir(), targetID, frameID, loaderID, backendNodeIDs)
+}
+
+// assignRefs hands out refs for backend node IDs in a document of a
+// target's frame, returning them in the same order. Zero IDs, for nodes
+// without a DOM node, get no ref.
+func assignRefs(dir, targetID, frameID, loaderID string, backendNodeIDs []int64) ([]string, error) {
+	r, err := loadTargetRefs(dir, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look refs up by element rather than searching for each
+	byElement := make(map[chrome.ElementRef]string, len(r.Refs))
+	for name, element := range r.Refs {
+		byElement[element] = "@" + name
+	}
+
+	refs := make([]string, len(backendNodeIDs))
+	for i, id := range backendNodeIDs {
+		if id == 0 {
+			continue
+		}
+		element := chrome.ElementRef{BackendNodeID: id, FrameID: frameID, LoaderID: loaderID}
+		if ref, ok := byElement[element]; ok {
+			refs[i] = ref
+			continue
+		}
+		refs[i] = r.add(element)
+		byElement[element] = refs[i]
+	}
+	r.prune()
+
+	if err := saveTargetRefs(dir, targetID, r); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
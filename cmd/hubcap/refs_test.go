@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func TestAssignRefs_ReusesRefs(t *testing.T) {
+	dir := t.TempDir()
+
+	refs, err := assignRefs(dir, "T1", "F1", "L1", []int64{10, 0, 11})
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if want := []string{"@e1", "", "@e2"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs = %v, want %v", refs, want)
+	}
+
+	// Nodes listed again keep their refs
+	refs, err = assignRefs(dir, "T1", "F1", "L1", []int64{11, 12})
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if want := []string{"@e2", "@e3"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs = %v, want %v", refs, want)
+	}
+
+	// The same backend node ID in another frame or document is another
+	// element
+	refs, err = assignRefs(dir, "T1", "F2", "L2", []int64{11})
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if want := []string{"@e4"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs = %v, want %v", refs, want)
+	}
+	refs, err = assignRefs(dir, "T1", "F1", "L3", []int64{11})
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if want := []string{"@e5"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("refs = %v, want %v", refs, want)
+	}
+
+	r, err := loadTargetRefs(dir, "T1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := map[string]chrome.ElementRef{
+		"e1": {BackendNodeID: 10, FrameID: "F1", LoaderID: "L1"},
+		"e2": {BackendNodeID: 11, FrameID: "F1", LoaderID: "L1"},
+		"e3": {BackendNodeID: 12, FrameID: "F1", LoaderID: "L1"},
+		"e4": {BackendNodeID: 11, FrameID: "F2", LoaderID: "L2"},
+		"e5": {BackendNodeID: 11, FrameID: "F1", LoaderID: "L3"},
+	}
+	if !reflect.DeepEqual(r.Refs, want) {
+		t.Errorf("Refs = %v, want %v", r.Refs, want)
+	}
+
+	// Other targets have their own refs
+	other, err := loadTargetRefs(dir, "T2")
+	if err != nil {
+		t.Fatalf("load other: %v", err)
+	}
+	if len(other.Refs) != 0 || other.Next != 1 {
+		t.Errorf("expected no refs for T2, got %+v", other)
+	}
+}
+
+func TestLoadTargetRefs_BackendNodeIDsOnly(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "refs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Refs saved before they held their frame and document are dropped
+	if err := os.WriteFile(refsPath(dir, "T1"), []byte(`{"next":3,"refs":{"e1":10,"e2":11}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := loadTargetRefs(dir, "T1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(r.Refs) != 0 || r.Next != 3 {
+		t.Errorf("expected no refs and the next ref to be e3, got %+v", r)
+	}
+	if got := r.add(chrome.ElementRef{BackendNodeID: 10}); got != "@e3" {
+		t.Errorf("add() = %s, want @e3", got)
+	}
+}
+
+func TestTargetRefs_Prune(t *testing.T) {
+	r := &targetRefs{Next: 1, Refs: map[string]chrome.ElementRef{}}
+	for i := 0; i < maxRefs+2; i++ {
+		r.add(chrome.ElementRef{BackendNodeID: int64(100 + i)})
+	}
+	r.prune()
+
+	if len(r.Refs) != maxRefs {
+		t.Fatalf("expected %d refs, got %d", maxRefs, len(r.Refs))
+	}
+	if _, ok := r.Refs["e2"]; ok {
+		t.Error("expected the oldest refs to be forgotten")
+	}
+	if _, ok := r.Refs["e3"]; !ok {
+		t.Error("expected newer refs to be kept")
+	}
+}
@@ -52,6 +52,12 @@ var commands = map[string]CommandInfo{
 		}
 		return cmdQuery(cfg, args[0])
 	}},
+	"queryall": {Name: "queryall", Desc: "Describe every element matching a selector", Category: "Query DOM", Run: func(cfg *Config, args []string) int {
+		if len(args) < 1 {
+			return cmdMissingArg(cfg, "usage: hubcap queryall <selector>")
+		}
+		return cmdQueryAll(cfg, args[0])
+	}},
 	"html": {Name: "html", Desc: "Get element outer HTML", Category: "Query DOM", Run: func(cfg *Config, args []string) int {
 		if len(args) < 1 {
 			return cmdMissingArg(cfg, "usage: hubcap html <selector>")
@@ -199,17 +205,12 @@ var commands = map[string]CommandInfo{
 		}
 		return cmdClear(cfg, args[0])
 	}},
-	"type": {Name: "type", Desc: "Type text (keystrokes)", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
-		if len(args) < 1 {
-			return cmdMissingArg(cfg, "usage: hubcap type <text>")
-		}
-		return cmdType(cfg, args[0])
-	}},
+	"type": {Name: "type", Desc: "Type text (keystrokes)", Category: "Click & interact", Run: func(cfg *Config, args []string) int { return cmdType(cfg, args) }},
 	"press": {Name: "press", Desc: "Press a key", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
 		if len(args) < 1 {
-			return cmdMissingArg(cfg, "usage: hubcap press <key>")
+			return cmdMissingArg(cfg, "usage: hubcap press <key>...")
 		}
-		return cmdPress(cfg, args[0])
+		return cmdPress(cfg, args)
 	}},
 	"select": {Name: "select", Desc: "Select a dropdown option", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
 		if len(args) < 2 {
@@ -235,11 +236,8 @@ var commands = map[string]CommandInfo{
 		}
 		return cmdSetValue(cfg, args[0], args[1])
 	}},
-	"upload": {Name: "upload", Desc: "Upload files to input", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
-		if len(args) < 2 {
-			return cmdMissingArg(cfg, "usage: hubcap upload <selector> <file>...")
-		}
-		return cmdUpload(cfg, args[0], args[1:])
+	"dropfiles": {Name: "dropfiles", Desc: "Drop files onto an element", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
+		return cmdDropFiles(cfg, args)
 	}},
 	"dispatch": {Name: "dispatch", Desc: "Dispatch a DOM event", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
 		if len(args) < 2 {
@@ -248,16 +246,10 @@ var commands = map[string]CommandInfo{
 		return cmdDispatch(cfg, args[0], args[1])
 	}},
 	"drag": {Name: "drag", Desc: "Drag from one element to another", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
-		if len(args) < 2 {
-			return cmdMissingArg(cfg, "usage: hubcap drag <source-selector> <dest-selector>")
-		}
-		return cmdDrag(cfg, args[0], args[1])
+		return cmdDrag(cfg, args)
 	}},
-	"mouse": {Name: "mouse", Desc: "Move mouse to coordinates", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
-		if len(args) < 2 {
-			return cmdMissingArg(cfg, "usage: hubcap mouse <x> <y>")
-		}
-		return cmdMouse(cfg, args[0], args[1])
+	"mouse": {Name: "mouse", Desc: "Move, press, release or scroll the mouse", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
+		return cmdMouse(cfg, args)
 	}},
 	"swipe": {Name: "swipe", Desc: "Swipe gesture on element", Category: "Click & interact", Run: func(cfg *Config, args []string) int {
 		if len(args) < 2 {
@@ -289,9 +281,9 @@ var commands = map[string]CommandInfo{
 	"scrollbottom": {Name: "scrollbottom", Desc: "Scroll to bottom of page", Category: "Scroll", Run: func(cfg *Config, args []string) int { return cmdScrollBottom(cfg) }},
 
 	// Wait
-	"wait": {Name: "wait", Desc: "Wait for element to appear", Category: "Wait", Run: func(cfg *Config, args []string) int {
+	"wait": {Name: "wait", Desc: "Wait for element to appear or reach a state", Category: "Wait", Run: func(cfg *Config, args []string) int {
 		if len(args) < 1 {
-			return cmdMissingArg(cfg, "usage: hubcap wait <selector> [--timeout <duration>]")
+			return cmdMissingArg(cfg, "usage: hubcap wait [--state <state>] <selector> [--timeout <duration>]")
 		}
 		return cmdWait(cfg, args)
 	}},
@@ -314,6 +306,8 @@ var commands = map[string]CommandInfo{
 	}},
 	"waitrequest": {Name: "waitrequest", Desc: "Wait for a network request", Category: "Wait", Run: func(cfg *Config, args []string) int { return cmdWaitRequest(cfg, args) }},
 	"waitresponse": {Name: "waitresponse", Desc: "Wait for a network response", Category: "Wait", Run: func(cfg *Config, args []string) int { return cmdWaitResponse(cfg, args) }},
+	"waitany": {Name: "waitany", Desc: "Wait for the first of several conditions", Category: "Wait", Run: func(cfg *Config, args []string) int { return cmdWaitConditions(cfg, "waitany", args) }},
+	"waitall": {Name: "waitall", Desc: "Wait for all of several conditions", Category: "Wait", Run: func(cfg *Config, args []string) int { return cmdWaitConditions(cfg, "waitall", args) }},
 
 	// Capture
 	"screenshot": {Name: "screenshot", Desc: "Take a screenshot", Category: "Capture", Run: func(cfg *Config, args []string) int { return cmdScreenshot(cfg, args) }},
@@ -321,7 +315,7 @@ var commands = map[string]CommandInfo{
 
 	// Network & monitoring
 	"network":      {Name: "network", Desc: "Capture network events", Category: "Network & monitor", Run: func(cfg *Config, args []string) int { return cmdNetwork(cfg, args) }},
-	"har":          {Name: "har", Desc: "Capture HAR log", Category: "Network & monitor", Run: func(cfg *Config, args []string) int { return cmdHar(cfg, args) }},
+	"har":          {Name: "har", Desc: "Capture or replay HAR log", Category: "Network & monitor", Run: func(cfg *Config, args []string) int { return cmdHar(cfg, args) }},
 	"intercept":    {Name: "intercept", Desc: "Intercept requests/responses", Category: "Network & monitor", Run: func(cfg *Config, args []string) int { return cmdIntercept(cfg, args) }},
 	"block":        {Name: "block", Desc: "Block URL patterns", Category: "Network & monitor", Run: func(cfg *Config, args []string) int { return cmdBlock(cfg, args) }},
 	"throttle":     {Name: "throttle", Desc: "Throttle network speed", Category: "Network & monitor", Run: func(cfg *Config, args []string) int { return cmdThrottle(cfg, args) }},
@@ -394,8 +388,8 @@ var commands = map[string]CommandInfo{
 	"domsnapshot": {Name: "domsnapshot", Desc: "Get DOM snapshot", Category: "Analyze", Run: func(cfg *Config, args []string) int { return cmdDOMSnapshot(cfg) }},
 
 	// Profiling
-	"heapsnapshot": {Name: "heapsnapshot", Desc: "Take heap snapshot", Category: "Profile", Run: func(cfg *Config, args []string) int { return cmdHeapSnapshot(cfg, args) }},
-	"trace":        {Name: "trace", Desc: "Capture trace", Category: "Profile", Run: func(cfg *Config, args []string) int { return cmdTrace(cfg, args) }},
+	"heapsnapshot": {Name: "heapsnapshot", Desc: "Take, summarize or diff heap snapshots", Category: "Profile", Run: func(cfg *Config, args []string) int { return cmdHeapSnapshot(cfg, args) }},
+	"trace":        {Name: "trace", Desc: "Capture or summarize performance trace", Category: "Profile", Run: func(cfg *Config, args []string) int { return cmdTrace(cfg, args) }},
 
 	// Advanced
 	"eval":      {Name: "eval", Desc: "Evaluate JavaScript", Category: "Advanced", Run: func(cfg *Config, args []string) int {
@@ -438,6 +432,9 @@ func init() {
 	commands["retry"] = CommandInfo{Name: "retry", Desc: "Retry a command on failure", Category: "Utility", Run: func(cfg *Config, args []string) int { return cmdRetry(cfg, args) }}
 	commands["pipe"] = CommandInfo{Name: "pipe", Desc: "Read commands from stdin", Category: "Utility", Run: func(cfg *Config, args []string) int { return cmdPipe(cfg, args) }}
 	commands["shell"] = CommandInfo{Name: "shell", Desc: "Interactive REPL", Category: "Utility", Run: func(cfg *Config, args []string) int { return cmdShell(cfg, args) }}
+	commands["upload"] = CommandInfo{Name: "upload", Desc: "Upload files to input or file chooser", Category: "Click & interact", Run: func(cfg *Config, args []string) int { return cmdUpload(cfg, args) }}
+	commands["waitdownload"] = CommandInfo{Name: "waitdownload", Desc: "Wait for a download to finish", Category: "Wait", Run: func(cfg *Config, args []string) int { return cmdWaitDownload(cfg, args) }}
+	commands["waitpopup"] = CommandInfo{Name: "waitpopup", Desc: "Wait for the page to open a popup", Category: "Wait", Run: func(cfg *Config, args []string) int { return cmdWaitPopup(cfg, args) }}
 }
 
 // cmdMissingArg prints a usage message and returns ExitError.
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+// pageOrder is the order in which hubcap first saw each open page, oldest
+// first, so that -target last can pick the newest page. Chrome does not say
+// when pages were opened, nor list them in that order. Pages first seen
+// together are kept in the order Chrome listed them.
+type pageOrder struct {
+	Pages  []string `json:"pages"`            // Target IDs, oldest first
+	Opener string   `json:"opener,omitempty"` // Page that opened the newest popup seen
+}
+
+// pageOrderPath returns the path of the page order file for the given
+// Chrome endpoint. Each host:port pair has its own pages.
+func pageOrderPath(dir, host string, port int) string {
+	return filepath.Join(dir, "pages", fmt.Sprintf("%s-%d.json", host, port))
+}
+
+// loadPageOrder loads the order pages of a Chrome were seen in.
+// Returns no pages if none have been seen.
+func loadPageOrder(dir, host string, port int) (*pageOrder, error) {
+	o := &pageOrder{}
+	data, err := os.ReadFile(pageOrderPath(dir, host, port))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return o, nil
+		}
+		return nil, fmt.Errorf("reading page order: %w", err)
+	}
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, fmt.Errorf("parsing page order: %w", err)
+	}
+	return o, nil
+}
+
+// savePageOrder saves the order pages of a Chrome were seen in.
+func savePageOrder(dir, host string, port int, o *pageOrder) error {
+	path := pageOrderPath(dir, host, port)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating pages dir: %w", err)
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return fmt.Errorf("marshaling page order: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing page order: %w", err)
+	}
+	return nil
+}
+
+// update forgets pages that have closed and adds new pages as the newest,
+// noting the opener of any popup among them. It reports whether anything
+// changed.
+func (o *pageOrder) update(pages []chrome.TargetInfo) bool {
+	open := func(id string) bool {
+		return slices.ContainsFunc(pages, func(p chrome.TargetInfo) bool { return p.ID == id })
+	}
+	before := len(o.Pages)
+	o.Pages = slices.DeleteFunc(o.Pages, func(id string) bool { return !open(id) })
+	changed := len(o.Pages) != before
+	for _, p := range pages {
+		if slices.Contains(o.Pages, p.ID) {
+			continue
+		}
+		o.Pages = append(o.Pages, p.ID)
+		if p.OpenerID != "" {
+			o.Opener = p.OpenerID
+		}
+		changed = true
+	}
+	return changed
+}
+
+// notePages updates the saved order pages of a Chrome were seen in with its
+// open pages, and returns it.
+func notePages(dir, host string, port int, pages []chrome.TargetInfo) (*pageOrder, error) {
+	o, err := loadPageOrder(dir, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if o.update(pages) {
+		if err := savePageOrder(dir, host, port, o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// selectTarget picks the page that -target names from the open pages:
+//   - "" for the first page
+//   - a number for the page at that index
+//   - "last" for the newest page
+//   - "opener" for the page that opened the newest popup
+//   - "url:<glob>" or "title:<glob>" for the newest page matching the glob
+//   - anything else for the page with that target ID
+func selectTarget(pages []chrome.TargetInfo, target string, order *pageOrder) (*chrome.TargetInfo, error) {
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages available")
+	}
+
+	// Default: first page
+	if target == "" {
+		return &pages[0], nil
+	}
+
+	// Try as index first
+	if idx, err := strconv.Atoi(target); err == nil {
+		if idx < 0 || idx >= len(pages) {
+			return nil, fmt.Errorf("invalid target index: %d (have %d pages)", idx, len(pages))
+		}
+		return &pages[idx], nil
+	}
+
+	newest := slices.Clone(pages)
+	seen := func(p chrome.TargetInfo) int { return slices.Index(order.Pages, p.ID) }
+	slices.SortStableFunc(newest, func(a, b chrome.TargetInfo) int {
+		sa, sb := seen(a), seen(b)
+		if sa == -1 || sb == -1 {
+			return sa - sb // Pages not seen yet are newer still
+		}
+		return sb - sa
+	})
+
+	byID := func(id string) *chrome.TargetInfo {
+		for i := range pages {
+			if pages[i].ID == id {
+				return &pages[i]
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case target == "last":
+		return &newest[0], nil
+	case target == "opener":
+		if order.Opener == "" {
+			return nil, fmt.Errorf("invalid target: opener (no popup has been seen)")
+		}
+		if p := byID(order.Opener); p != nil {
+			return p, nil
+		}
+		return nil, fmt.Errorf("invalid target: opener (the page that opened the last popup has closed)")
+	case strings.HasPrefix(target, "url:"), strings.HasPrefix(target, "title:"):
+		field, glob, _ := strings.Cut(target, ":")
+		for _, p := range newest {
+			value := p.URL
+			if field == "title" {
+				value = p.Title
+			}
+			if chrome.MatchGlob(glob, value) {
+				return &p, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid target: %s (no page matches)", target)
+	}
+
+	// Otherwise, treat as target ID
+	if p := byID(target); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("invalid target: %s (not found)", target)
+}
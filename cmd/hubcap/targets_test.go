@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tomyan/hubcap/internal/chrome"
+)
+
+func TestPageOrder_Update(t *testing.T) {
+	o := &pageOrder{}
+	if !o.update([]chrome.TargetInfo{{ID: "A"}, {ID: "B"}}) {
+		t.Error("expected new pages to change the order")
+	}
+	if o.update([]chrome.TargetInfo{{ID: "B"}, {ID: "A"}}) {
+		t.Error("expected the same pages not to change the order")
+	}
+
+	changed := o.update([]chrome.TargetInfo{{ID: "C", OpenerID: "B"}, {ID: "B"}})
+	if !changed {
+		t.Error("expected a closed and a new page to change the order")
+	}
+	if want := []string{"B", "C"}; !slices.Equal(o.Pages, want) {
+		t.Errorf("Pages = %v, want %v", o.Pages, want)
+	}
+	if o.Opener != "B" {
+		t.Errorf("Opener = %q, want B", o.Opener)
+	}
+}
+
+func TestNotePages(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := notePages(dir, "localhost", 9222, []chrome.TargetInfo{{ID: "A"}}); err != nil {
+		t.Fatalf("notePages: %v", err)
+	}
+	o, err := notePages(dir, "localhost", 9222, []chrome.TargetInfo{{ID: "B"}, {ID: "A"}})
+	if err != nil {
+		t.Fatalf("notePages: %v", err)
+	}
+	if want := []string{"A", "B"}; !slices.Equal(o.Pages, want) {
+		t.Errorf("Pages = %v, want %v", o.Pages, want)
+	}
+
+	loaded, err := loadPageOrder(dir, "localhost", 9222)
+	if err != nil {
+		t.Fatalf("loadPageOrder: %v", err)
+	}
+	if !slices.Equal(loaded.Pages, o.Pages) {
+		t.Errorf("loaded Pages = %v, want %v", loaded.Pages, o.Pages)
+	}
+
+	// Another Chrome has pages of its own
+	other, err := loadPageOrder(dir, "localhost", 9333)
+	if err != nil {
+		t.Fatalf("loadPageOrder: %v", err)
+	}
+	if len(other.Pages) != 0 {
+		t.Errorf("other Chrome's Pages = %v, want none", other.Pages)
+	}
+}
+
+func TestSelectTarget(t *testing.T) {
+	pages := []chrome.TargetInfo{
+		{ID: "C", URL: "https://shop.test/checkout", Title: "Checkout"},
+		{ID: "A", URL: "https://shop.test/", Title: "Shop"},
+		{ID: "B", URL: "https://shop.test/cart", Title: "Cart"},
+		{ID: "D", URL: "https://auth.test/signin", Title: "Sign in", OpenerID: "B"},
+	}
+	order := &pageOrder{Pages: []string{"A", "B", "C"}, Opener: "B"}
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"", "C"},
+		{"1", "A"},
+		{"B", "B"},
+		{"last", "D"}, // Not seen yet
+		{"opener", "B"},
+		{"url:https://shop.test/*", "C"},
+		{"url:*/cart", "B"},
+		{"title:Sign*", "D"},
+	}
+	for _, tt := range tests {
+		got, err := selectTarget(pages, tt.target, order)
+		if err != nil {
+			t.Errorf("selectTarget(%q): %v", tt.target, err)
+			continue
+		}
+		if got.ID != tt.want {
+			t.Errorf("selectTarget(%q) = %s, want %s", tt.target, got.ID, tt.want)
+		}
+	}
+
+	order.update(pages)
+	if got, _ := selectTarget(pages[:3], "last", order); got.ID != "C" {
+		t.Errorf("selectTarget(last) = %s, want C", got.ID)
+	}
+}
+
+func TestSelectTarget_Errors(t *testing.T) {
+	pages := []chrome.TargetInfo{{ID: "A", URL: "https://shop.test/"}}
+
+	tests := []struct {
+		target string
+		order  *pageOrder
+		want   string
+	}{
+		{"3", &pageOrder{}, "invalid target index: 3 (have 1 pages)"},
+		{"Z", &pageOrder{}, "invalid target: Z (not found)"},
+		{"opener", &pageOrder{}, "no popup has been seen"},
+		{"opener", &pageOrder{Opener: "B"}, "has closed"},
+		{"url:*/cart", &pageOrder{}, "invalid target: url:*/cart (no page matches)"},
+	}
+	for _, tt := range tests {
+		_, err := selectTarget(pages, tt.target, tt.order)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("selectTarget(%q) error = %v, want %q", tt.target, err, tt.want)
+		}
+	}
+
+	if _, err := selectTarget(nil, "", &pageOrder{}); err == nil {
+		t.Error("expected an error with no pages")
+	}
+}
+
+func TestRun_WaitPopup_UsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"waitpopup", "extra"}, "usage:"},
+		{[]string{"waitpopup", "--trigger", "nosuchcommand #share"}, "unknown command: nosuchcommand"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		code := run(tt.args, cfg)
+		if code != ExitError {
+			t.Errorf("%q: expected exit code %d, got %d", tt.args, ExitError, code)
+		}
+		if stderr := cfg.Stderr.(*bytes.Buffer).String(); !strings.Contains(stderr, tt.want) {
+			t.Errorf("%q: expected %q in stderr, got %q", tt.args, tt.want, stderr)
+		}
+	}
+}
+
+func TestRun_WaitPopup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	t.Setenv("HUBCAP_CONFIG_DIR", t.TempDir())
+
+	tabID, cleanup := createTestTabCLI(t)
+	defer cleanup()
+
+	cfg := testConfig()
+	page := `data:text/html,<html><body><button id="open" onclick="window.open('data:text/html,<title>Popup</title>')">Open</button></body></html>`
+	if code := run([]string{"--target", tabID, "goto", page}, cfg); code != ExitSuccess {
+		t.Fatalf("failed to navigate")
+	}
+
+	cfg = testConfig()
+	code := run([]string{"--target", tabID, "waitpopup", "--trigger", "click #open", "--timeout", "10s"}, cfg)
+	if code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d, stderr: %s", ExitSuccess, code, cfg.Stderr.(*bytes.Buffer).String())
+	}
+
+	var result WaitPopupResult
+	if err := json.Unmarshal(cfg.Stdout.(*bytes.Buffer).Bytes(), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if result.OpenerID != tabID || result.TargetID == "" || !strings.HasPrefix(result.URL, "data:") {
+		t.Errorf("unexpected result %+v", result)
+	}
+	defer run([]string{"--target", result.TargetID, "close"}, testConfig())
+
+	cfg = testConfig()
+	if code := run([]string{"--target", "last", "eval", "document.title"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval in last failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "Popup") {
+		t.Errorf("expected -target last to be the popup, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+
+	cfg = testConfig()
+	if code := run([]string{"--target", "opener", "eval", "location.href"}, cfg); code != ExitSuccess {
+		t.Fatalf("eval in opener failed: %s", cfg.Stderr.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(cfg.Stdout.(*bytes.Buffer).String(), "Open") {
+		t.Errorf("expected -target opener to be the page that opened the popup, got %s", cfg.Stdout.(*bytes.Buffer).String())
+	}
+}
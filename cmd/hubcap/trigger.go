@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errTriggerFailed reports that the command run to trigger something failed.
+var errTriggerFailed = errors.New("trigger command failed")
+
+// trigger is a command run to set off something another command waits for,
+// such as the click that opens a file chooser or starts a download.
+type trigger struct {
+	run  func(cfg *Config, args []string) int
+	args []string
+	code int // Exit code of the command once fired
+}
+
+// newTrigger looks up the command that args run.
+func newTrigger(args []string) (*trigger, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no trigger command given")
+	}
+	info, ok := commands[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown command: %s", args[0])
+	}
+	return &trigger{run: info.Run, args: args[1:]}, nil
+}
+
+// fire runs the command against the target, with its output dropped. The
+// command reports its own errors; fire returns errTriggerFailed if it fails.
+func (t *trigger) fire(cfg *Config, targetID string) error {
+	triggerCfg := *cfg
+	triggerCfg.Target = targetID
+	triggerCfg.Stdout = io.Discard
+	if t.code = t.run(&triggerCfg, t.args); t.code != ExitSuccess {
+		return errTriggerFailed
+	}
+	return nil
+}
@@ -2,9 +2,9 @@ module github.com/tomyan/hubcap
 
 go 1.25.5
 
-require github.com/gorilla/websocket v1.5.3
-
 require (
-	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/term v0.39.0
 )
+
+require golang.org/x/sys v0.40.0
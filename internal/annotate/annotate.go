@@ -0,0 +1,128 @@
+// Package annotate draws numbered boxes over screenshots, so that the
+// elements in them can be referred to by number ("click 7") by people and
+// vision models alike.
+//
+// Labels are drawn with a built-in digit font, leaving the page itself
+// untouched.
+package annotate
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+)
+
+// Mark is an element to number, with its box in CSS pixels of the
+// screenshot's viewport.
+type Mark struct {
+	Label  int // From 1
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// palette holds the colors marks cycle through, chosen to stand out from
+// most pages and from each other.
+var palette = []color.RGBA{
+	{R: 0xe6, G: 0x19, B: 0x4b, A: 0xff},
+	{R: 0x00, G: 0x82, B: 0xc8, A: 0xff},
+	{R: 0x3c, G: 0xb4, B: 0x4b, A: 0xff},
+	{R: 0xf5, G: 0x82, B: 0x31, A: 0xff},
+	{R: 0x91, G: 0x1e, B: 0xb4, A: 0xff},
+	{R: 0x80, G: 0x80, B: 0x00, A: 0xff},
+	{R: 0xf0, G: 0x32, B: 0xe6, A: 0xff},
+	{R: 0x00, G: 0x80, B: 0x80, A: 0xff},
+}
+
+// digits are 3x5 glyphs for 0-9, a row of three bits at a time, most
+// significant bit leftmost.
+var digits = [10][5]uint8{
+	{7, 5, 5, 5, 7},
+	{2, 6, 2, 2, 7},
+	{7, 1, 7, 4, 7},
+	{7, 1, 7, 1, 7},
+	{5, 5, 7, 1, 1},
+	{7, 4, 7, 1, 7},
+	{7, 4, 7, 5, 7},
+	{7, 1, 1, 1, 1},
+	{7, 5, 7, 5, 7},
+	{7, 5, 7, 1, 7},
+}
+
+// Draw returns a copy of src with each mark's box outlined and its label
+// drawn at the box's top left corner. scale is the number of image pixels
+// to a CSS pixel, the page's device pixel ratio.
+func Draw(src image.Image, marks []Mark, scale float64) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+
+	if scale <= 0 {
+		scale = 1
+	}
+	stroke := max(1, int(math.Round(2*scale)))
+	unit := max(1, int(math.Round(2*scale)))
+
+	for _, m := range marks {
+		c := palette[(m.Label-1+len(palette))%len(palette)]
+		box := image.Rect(
+			int(math.Round(m.X*scale)), int(math.Round(m.Y*scale)),
+			int(math.Round((m.X+m.Width)*scale)), int(math.Round((m.Y+m.Height)*scale)),
+		).Add(bounds.Min)
+		outline(dst, box, stroke, c)
+		label(dst, box, strconv.Itoa(m.Label), unit, c)
+	}
+	return dst
+}
+
+// outline draws the edges of a box, the given number of pixels wide,
+// inside it.
+func outline(dst *image.RGBA, box image.Rectangle, stroke int, c color.RGBA) {
+	fill(dst, image.Rect(box.Min.X, box.Min.Y, box.Max.X, box.Min.Y+stroke), c)
+	fill(dst, image.Rect(box.Min.X, box.Max.Y-stroke, box.Max.X, box.Max.Y), c)
+	fill(dst, image.Rect(box.Min.X, box.Min.Y, box.Min.X+stroke, box.Max.Y), c)
+	fill(dst, image.Rect(box.Max.X-stroke, box.Min.Y, box.Max.X, box.Max.Y), c)
+}
+
+// label draws text in white on a tag of the mark's color, above the box's
+// top left corner or, without room above, just inside it. unit is the size
+// of a glyph's pixel.
+func label(dst *image.RGBA, box image.Rectangle, text string, unit int, c color.RGBA) {
+	// Glyphs are three units wide with a unit between them and around
+	// the tag
+	width := (4*len(text) + 1) * unit
+	height := 7 * unit
+
+	bounds := dst.Bounds()
+	x := min(box.Min.X, bounds.Max.X-width)
+	y := box.Min.Y - height
+	if y < bounds.Min.Y {
+		y = box.Min.Y
+	}
+	x = max(x, bounds.Min.X)
+	fill(dst, image.Rect(x, y, x+width, y+height), c)
+
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	for i, ch := range text {
+		glyph := digits[ch-'0']
+		left := x + (1+4*i)*unit
+		for row, bits := range glyph {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) == 0 {
+					continue
+				}
+				px := left + col*unit
+				py := y + (1+row)*unit
+				fill(dst, image.Rect(px, py, px+unit, py+unit), white)
+			}
+		}
+	}
+}
+
+// fill paints a rectangle, clipped to the image.
+func fill(dst *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(dst, r.Intersect(dst.Bounds()), image.NewUniform(c), image.Point{}, draw.Src)
+}
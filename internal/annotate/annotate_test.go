@@ -0,0 +1,83 @@
+package annotate
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var background = color.RGBA{R: 0x10, G: 0x10, B: 0x10, A: 0xff}
+
+func blank(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	return img
+}
+
+func TestDraw_Outline(t *testing.T) {
+	src := blank(200, 100)
+	got := Draw(src, []Mark{{Label: 1, X: 50, Y: 40, Width: 60, Height: 30}}, 1)
+
+	c := palette[0]
+	for _, p := range []image.Point{{50, 40}, {109, 69}, {80, 41}, {51, 55}, {108, 55}, {80, 68}} {
+		if got.RGBAAt(p.X, p.Y) != c {
+			t.Errorf("expected the outline at %v, got %v", p, got.RGBAAt(p.X, p.Y))
+		}
+	}
+	if got.RGBAAt(80, 55) != background {
+		t.Errorf("expected the box's inside untouched, got %v", got.RGBAAt(80, 55))
+	}
+	if src.RGBAAt(50, 40) != background {
+		t.Error("expected the source image untouched")
+	}
+}
+
+func TestDraw_Label(t *testing.T) {
+	got := Draw(blank(200, 100), []Mark{{Label: 7, X: 50, Y: 40, Width: 60, Height: 30}}, 1)
+
+	// A tag 10x14 pixels for one digit sits above the box, the digit's
+	// top row white
+	c := palette[6]
+	if got.RGBAAt(50, 26) != c || got.RGBAAt(59, 39) != c {
+		t.Errorf("expected the tag above the box, got %v and %v", got.RGBAAt(50, 26), got.RGBAAt(59, 39))
+	}
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	for _, x := range []int{52, 54, 56} {
+		if got.RGBAAt(x, 28) != white {
+			t.Errorf("expected the 7's top stroke at (%d, 28), got %v", x, got.RGBAAt(x, 28))
+		}
+	}
+	if got.RGBAAt(52, 30) != c {
+		t.Errorf("expected the 7 open on the left, got %v", got.RGBAAt(52, 30))
+	}
+	if got.RGBAAt(60, 26) != background {
+		t.Errorf("expected the tag to end, got %v", got.RGBAAt(60, 26))
+	}
+}
+
+func TestDraw_LabelWithoutRoomAbove(t *testing.T) {
+	got := Draw(blank(200, 100), []Mark{{Label: 12, X: 190, Y: 0, Width: 10, Height: 30}}, 1)
+
+	// Two digits make a tag 18 pixels wide, moved in from the right edge
+	// and inside the box's top
+	c := palette[3]
+	if got.RGBAAt(182, 0) != c || got.RGBAAt(199, 13) != c {
+		t.Errorf("expected the tag inside the image, got %v and %v", got.RGBAAt(182, 0), got.RGBAAt(199, 13))
+	}
+	if got.RGBAAt(181, 0) != background {
+		t.Errorf("expected the tag to start at 182, got %v", got.RGBAAt(181, 0))
+	}
+}
+
+func TestDraw_Scale(t *testing.T) {
+	got := Draw(blank(400, 200), []Mark{{Label: 1, X: 50, Y: 40, Width: 60, Height: 30}}, 2)
+
+	c := palette[0]
+	if got.RGBAAt(100, 80) != c || got.RGBAAt(219, 139) != c || got.RGBAAt(103, 110) != c {
+		t.Error("expected the outline in device pixels, four wide")
+	}
+	if got.RGBAAt(104, 110) != background {
+		t.Errorf("expected the outline to end, got %v", got.RGBAAt(104, 110))
+	}
+}
@@ -0,0 +1,338 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Actionability checks, in the order they are made before an input action.
+const (
+	CheckAttached      = "attached"
+	CheckVisible       = "visible"
+	CheckStable        = "stable"
+	CheckEnabled       = "enabled"
+	CheckPointerEvents = "receives-events"
+)
+
+// defaultActionTimeout bounds the wait for an element to become actionable
+// when the context has no deadline.
+const defaultActionTimeout = 30 * time.Second
+
+// actionRetryDelays are the pauses between actionability checks; the last
+// is repeated until the context is done.
+var actionRetryDelays = []time.Duration{0, 20 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 500 * time.Millisecond}
+
+// actionability selects the checks an input action makes beyond attached,
+// visible and stable.
+type actionability struct {
+	Enabled bool // The element must not be disabled
+	Pointer bool // The element must receive pointer events at its center
+}
+
+var (
+	clickChecks = actionability{Enabled: true, Pointer: true}
+	hoverChecks = actionability{Pointer: true}
+	fillChecks  = actionability{Enabled: true}
+)
+
+// ActionabilityError reports the check that the element of an input action
+// was still failing when the wait for it timed out.
+type ActionabilityError struct {
+	Selector    string `json:"selector"`
+	Check       string `json:"check"`
+	Interceptor string `json:"interceptor,omitempty"` // Element receiving pointer events in its place
+}
+
+func (e *ActionabilityError) Error() string {
+	switch e.Check {
+	case CheckAttached:
+		return fmt.Sprintf("element not found: %s", e.Selector)
+	case CheckVisible:
+		return fmt.Sprintf("element not visible: %s", e.Selector)
+	case CheckStable:
+		return fmt.Sprintf("element still moving: %s", e.Selector)
+	case CheckEnabled:
+		return fmt.Sprintf("element disabled: %s", e.Selector)
+	case CheckPointerEvents:
+		return fmt.Sprintf("element does not receive pointer events: %s: %s intercepts them", e.Selector, e.Interceptor)
+	}
+	return fmt.Sprintf("element not actionable: %s: %s", e.Selector, e.Check)
+}
+
+// actionabilityScript reports the state of an element, waiting two
+// animation frames to see whether its box is stable.
+const actionabilityScript = `async function() {
+	const frame = () => new Promise(resolve => {
+		requestAnimationFrame(() => resolve());
+		// Pages that are not being rendered, such as background tabs, have no animation frames
+		setTimeout(resolve, 100);
+	});
+	const box = () => {
+		const r = this.getBoundingClientRect();
+		return [r.x, r.y, r.width, r.height].join();
+	};
+	const before = box();
+	await frame();
+	await frame();
+	const rect = this.getBoundingClientRect();
+	return {
+		attached: this.isConnected,
+		visible: rect.width > 0 && rect.height > 0 && getComputedStyle(this).visibility !== 'hidden',
+		stable: box() === before,
+		enabled: !this.matches(':disabled') && !this.closest('[aria-disabled="true"]'),
+	};
+}`
+
+// waitActionable waits until the first element matching selector passes
+// the actionability checks, returning its node ID and center point. When
+// the context is done first, it returns an *ActionabilityError for the
+// check that was failing.
+func (c *Client) waitActionable(ctx context.Context, sessionID string, selector string, checks actionability) (nodeID int64, x, y float64, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultActionTimeout)
+		defer cancel()
+	}
+
+	var last *ActionabilityError
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			if last != nil {
+				return 0, 0, 0, last
+			}
+			return 0, 0, 0, ctx.Err()
+		case <-time.After(actionRetryDelays[min(attempt, len(actionRetryDelays)-1)]):
+		}
+
+		nodeID, x, y, failed, err := c.checkActionable(ctx, sessionID, selector, checks)
+		if err != nil {
+			if ctx.Err() != nil && last != nil {
+				return 0, 0, 0, last
+			}
+			return 0, 0, 0, err
+		}
+		if failed == "" {
+			return nodeID, x, y, nil
+		}
+		last = &ActionabilityError{Selector: selector, Check: failed}
+		if failed == CheckPointerEvents {
+			last.Interceptor = c.describeNodeAt(ctx, sessionID, x, y)
+		}
+	}
+}
+
+// checkActionable makes the actionability checks once, returning the
+// first that fails, or "" and the element's node ID and center point.
+func (c *Client) checkActionable(ctx context.Context, sessionID string, selector string, checks actionability) (nodeID int64, x, y float64, failed string, err error) {
+	nodeID, err = c.queryNodeID(ctx, sessionID, selector)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	if nodeID == 0 {
+		return 0, 0, 0, CheckAttached, nil
+	}
+
+	objectID, err := c.resolveObjectID(ctx, sessionID, map[string]interface{}{"nodeId": nodeID})
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+	defer c.releaseObjects(sessionID, objectID)
+
+	result, err := c.CallSession(ctx, sessionID, "Runtime.callFunctionOn", map[string]interface{}{
+		"objectId":            objectID,
+		"functionDeclaration": actionabilityScript,
+		"awaitPromise":        true,
+		"returnByValue":       true,
+	})
+	if err != nil {
+		return 0, 0, 0, "", fmt.Errorf("checking element: %w", err)
+	}
+
+	var resp struct {
+		Result struct {
+			Value struct {
+				Attached bool `json:"attached"`
+				Visible  bool `json:"visible"`
+				Stable   bool `json:"stable"`
+				Enabled  bool `json:"enabled"`
+			} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return 0, 0, 0, "", fmt.Errorf("parsing element state: %w", err)
+	}
+	if resp.ExceptionDetails != nil {
+		return 0, 0, 0, "", fmt.Errorf("checking element: %w", resp.ExceptionDetails)
+	}
+
+	state := resp.Result.Value
+	switch {
+	case !state.Attached:
+		return 0, 0, 0, CheckAttached, nil
+	case !state.Visible:
+		return 0, 0, 0, CheckVisible, nil
+	case !state.Stable:
+		return 0, 0, 0, CheckStable, nil
+	case checks.Enabled && !state.Enabled:
+		return 0, 0, 0, CheckEnabled, nil
+	}
+
+	if checks.Pointer {
+		_, err = c.CallSession(ctx, sessionID, "DOM.scrollIntoViewIfNeeded", map[string]interface{}{
+			"nodeId": nodeID,
+		})
+		if err != nil {
+			return 0, 0, 0, "", fmt.Errorf("scrolling into view: %w", err)
+		}
+	}
+
+	x, y, err = c.getNodeCenter(ctx, sessionID, nodeID)
+	if err != nil {
+		return 0, 0, 0, "", err
+	}
+
+	if checks.Pointer {
+		hit, err := c.receivesPointerEvents(ctx, sessionID, objectID, x, y)
+		if err != nil {
+			return 0, 0, 0, "", err
+		}
+		if !hit {
+			return 0, x, y, CheckPointerEvents, nil
+		}
+	}
+	return nodeID, x, y, "", nil
+}
+
+// receivesPointerEvents reports whether the node at x, y is the element or
+// inside it, including inside its shadow root.
+func (c *Client) receivesPointerEvents(ctx context.Context, sessionID string, objectID string, x, y float64) (bool, error) {
+	result, err := c.CallSession(ctx, sessionID, "DOM.getNodeForLocation", map[string]interface{}{
+		"x":                         int(x),
+		"y":                         int(y),
+		"includeUserAgentShadowDOM": false,
+	})
+	if err != nil {
+		// Nothing is at the point, such as when it is outside the viewport
+		return false, nil
+	}
+
+	var nodeResp struct {
+		BackendNodeID int64 `json:"backendNodeId"`
+	}
+	if err := json.Unmarshal(result, &nodeResp); err != nil {
+		return false, fmt.Errorf("parsing node for location: %w", err)
+	}
+
+	hitID, err := c.resolveObjectID(ctx, sessionID, map[string]interface{}{"backendNodeId": nodeResp.BackendNodeID})
+	if err != nil {
+		return false, err
+	}
+	defer c.releaseObjects(sessionID, hitID)
+
+	result, err = c.CallSession(ctx, sessionID, "Runtime.callFunctionOn", map[string]interface{}{
+		"objectId":            objectID,
+		"functionDeclaration": `function(hit) { for (let n = hit; n; n = n.parentNode || n.host) { if (n === this) return true; } return false; }`,
+		"arguments":           []map[string]interface{}{{"objectId": hitID}},
+		"returnByValue":       true,
+	})
+	if err != nil {
+		// The node at the point is in another frame
+		return false, nil
+	}
+
+	var containsResp struct {
+		Result struct {
+			Value bool `json:"value"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(result, &containsResp); err != nil {
+		return false, fmt.Errorf("parsing hit test: %w", err)
+	}
+	return containsResp.Result.Value, nil
+}
+
+// describeNodeAt describes the element at x, y, for reporting what
+// intercepts pointer events.
+func (c *Client) describeNodeAt(ctx context.Context, sessionID string, x, y float64) string {
+	result, err := c.CallSession(ctx, sessionID, "DOM.getNodeForLocation", map[string]interface{}{
+		"x":                         int(x),
+		"y":                         int(y),
+		"includeUserAgentShadowDOM": false,
+	})
+	if err != nil {
+		return "nothing"
+	}
+
+	var nodeResp struct {
+		BackendNodeID int64 `json:"backendNodeId"`
+	}
+	if err := json.Unmarshal(result, &nodeResp); err != nil {
+		return "another element"
+	}
+
+	result, err = c.CallSession(ctx, sessionID, "DOM.describeNode", map[string]interface{}{
+		"backendNodeId": nodeResp.BackendNodeID,
+	})
+	if err != nil {
+		return "another element"
+	}
+
+	var descResp struct {
+		Node struct {
+			LocalName  string   `json:"localName"`
+			Attributes []string `json:"attributes"`
+		} `json:"node"`
+	}
+	if err := json.Unmarshal(result, &descResp); err != nil || descResp.Node.LocalName == "" {
+		return "another element"
+	}
+	return describeElement(descResp.Node.LocalName, descResp.Node.Attributes)
+}
+
+// describeElement formats an element as its start tag with only its id
+// and class attributes, such as <div id="modal" class="overlay">.
+func describeElement(localName string, attributes []string) string {
+	var sb strings.Builder
+	sb.WriteString("<" + localName)
+	for i := 0; i+1 < len(attributes); i += 2 {
+		if attributes[i] == "id" || attributes[i] == "class" {
+			fmt.Fprintf(&sb, " %s=%q", attributes[i], attributes[i+1])
+		}
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+// resolveObjectID returns a remote object ID for a node given by nodeId or
+// backendNodeId.
+func (c *Client) resolveObjectID(ctx context.Context, sessionID string, node map[string]interface{}) (string, error) {
+	result, err := c.CallSession(ctx, sessionID, "DOM.resolveNode", node)
+	if err != nil {
+		return "", fmt.Errorf("resolving node: %w", err)
+	}
+
+	var resp struct {
+		Object remoteObject `json:"object"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return "", fmt.Errorf("parsing resolve response: %w", err)
+	}
+	return resp.Object.ObjectID, nil
+}
+
+// releaseObjects lets go of remote objects that are no longer needed. A
+// daemon keeps sessions, and with them their objects, between commands.
+func (c *Client) releaseObjects(sessionID string, objectIDs ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, id := range objectIDs {
+		if id != "" {
+			c.CallSession(ctx, sessionID, "Runtime.releaseObject", map[string]interface{}{"objectId": id})
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package chrome
+
+import "testing"
+
+func TestDescribeElement(t *testing.T) {
+	tests := []struct {
+		localName  string
+		attributes []string
+		want       string
+	}{
+		{"div", nil, "<div>"},
+		{"div", []string{"class", "overlay", "style", "position:fixed", "id", "modal"}, `<div class="overlay" id="modal">`},
+		{"span", []string{"title", `say "hi"`}, "<span>"},
+		{"p", []string{"id", `a"b`}, `<p id="a\"b">`},
+	}
+	for _, tt := range tests {
+		if got := describeElement(tt.localName, tt.attributes); got != tt.want {
+			t.Errorf("describeElement(%q, %q) = %s, want %s", tt.localName, tt.attributes, got, tt.want)
+		}
+	}
+}
+
+func TestActionabilityError(t *testing.T) {
+	tests := []struct {
+		err  ActionabilityError
+		want string
+	}{
+		{ActionabilityError{Selector: "#a", Check: CheckAttached}, "element not found: #a"},
+		{ActionabilityError{Selector: "#a", Check: CheckVisible}, "element not visible: #a"},
+		{ActionabilityError{Selector: "#a", Check: CheckStable}, "element still moving: #a"},
+		{ActionabilityError{Selector: "#a", Check: CheckEnabled}, "element disabled: #a"},
+		{
+			ActionabilityError{Selector: "#a", Check: CheckPointerEvents, Interceptor: `<div class="overlay">`},
+			`element does not receive pointer events: #a: <div class="overlay"> intercepts them`,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CreateBrowserContext creates a browser context, like an incognito window,
+// whose pages share no cookies, storage or cache with pages in other
+// contexts, and returns its ID. The context lasts until it is disposed of
+// or Chrome exits.
+func (c *Client) CreateBrowserContext(ctx context.Context, opts BrowserContextOptions) (string, error) {
+	params := map[string]interface{}{}
+	if opts.ProxyServer != "" {
+		params["proxyServer"] = opts.ProxyServer
+	}
+	if opts.ProxyBypassList != "" {
+		params["proxyBypassList"] = opts.ProxyBypassList
+	}
+
+	result, err := c.Call(ctx, "Target.createBrowserContext", params)
+	if err != nil {
+		return "", fmt.Errorf("creating browser context: %w", err)
+	}
+
+	var resp struct {
+		BrowserContextID string `json:"browserContextId"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return "", fmt.Errorf("parsing response: %w", err)
+	}
+	return resp.BrowserContextID, nil
+}
+
+// BrowserContexts returns the IDs of the browser contexts made by
+// CreateBrowserContext, not including the default context.
+func (c *Client) BrowserContexts(ctx context.Context) ([]string, error) {
+	result, err := c.Call(ctx, "Target.getBrowserContexts", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting browser contexts: %w", err)
+	}
+
+	var resp struct {
+		BrowserContextIDs []string `json:"browserContextIds"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+	if resp.BrowserContextIDs == nil {
+		return []string{}, nil
+	}
+	return resp.BrowserContextIDs, nil
+}
+
+// DisposeBrowserContext closes a browser context and every page in it,
+// discarding its cookies and storage.
+func (c *Client) DisposeBrowserContext(ctx context.Context, browserContextID string) error {
+	if _, err := c.Call(ctx, "Target.disposeBrowserContext", map[string]interface{}{
+		"browserContextId": browserContextID,
+	}); err != nil {
+		return fmt.Errorf("disposing browser context: %w", err)
+	}
+	return nil
+}
+
+// SetBrowserContext sets the browser context new tabs are opened in and
+// download settings apply to, or "" for the default context.
+func (c *Client) SetBrowserContext(browserContextID string) {
+	c.browserContext = browserContextID
+}
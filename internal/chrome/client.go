@@ -12,9 +12,17 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// messageConn is the transport a Client exchanges protocol messages over:
+// a WebSocket to Chrome, or a Unix socket to a hubcap daemon.
+type messageConn interface {
+	ReadJSON(v interface{}) error
+	WriteJSON(v interface{}) error
+	Close() error
+}
+
 // Client is a Chrome DevTools Protocol client.
 type Client struct {
-	conn            *websocket.Conn
+	conn            messageConn
 	wsURL           string
 	mu              sync.Mutex
 	messageID       atomic.Int64
@@ -22,8 +30,18 @@ type Client struct {
 	pendingMu       sync.Mutex
 	eventHandlers   map[string][]chan json.RawMessage // key: "sessionID:method"
 	eventHandlersMu sync.Mutex
-	sessions        map[string]string // targetID -> sessionID (session cache)
+	lossless        map[chan json.RawMessage]*eventQueue // handlers that receive every event, see subscribeEventLossless
+	sessions        map[string]string                    // targetID -> sessionID (session cache)
 	sessionsMu      sync.Mutex
+	frames          map[string]*frameScope // targetID -> frame set by SetFrame
+	refs            map[string]ElementRef  // element ref -> element, see SetRefs
+	strict          bool                   // single-element selectors must match one element, see SetStrict
+	index           int                    // match taken by selectors without :nth, or -1, see SetIndex
+	browserContext  string                 // browser context new tabs and download settings are for, see SetBrowserContext
+	downloads       map[string]interface{} // params of the last SetDownloadBehavior, restored for socket clients, see Serve
+	downloadsMu     sync.Mutex
+	taps            []*eventTap // receive every event, see Serve
+	tapsMu          sync.Mutex
 	closed          atomic.Bool
 	closeOnce       sync.Once
 	closeCh         chan struct{}
@@ -68,19 +86,27 @@ func Connect(ctx context.Context, host string, port int) (*Client, error) {
 		return nil, fmt.Errorf("connecting to WebSocket: %w", err)
 	}
 
+	return newClient(conn, versionResp.WebSocketDebuggerURL), nil
+}
+
+// newClient wraps an established connection and starts reading from it.
+func newClient(conn messageConn, url string) *Client {
 	client := &Client{
 		conn:          conn,
-		wsURL:         versionResp.WebSocketDebuggerURL,
+		wsURL:         url,
 		pending:       make(map[int64]chan callResult),
 		eventHandlers: make(map[string][]chan json.RawMessage),
+		lossless:      make(map[chan json.RawMessage]*eventQueue),
 		sessions:      make(map[string]string),
+		frames:        make(map[string]*frameScope),
+		index:         -1,
 		closeCh:       make(chan struct{}),
 	}
 
 	// Start message reader
 	go client.readMessages()
 
-	return client, nil
+	return client
 }
 
 // WebSocketURL returns the WebSocket URL used for this connection.
@@ -319,10 +345,19 @@ func (c *Client) readMessages() {
 
 		// Route events to handlers
 		if resp.Method != "" {
+			if resp.Method == "Target.detachedFromTarget" {
+				c.forgetSession(resp.Params)
+			}
+			c.tapEvent(cdpEvent{Method: resp.Method, Params: resp.Params, SessionID: resp.SessionID})
+
 			key := resp.SessionID + ":" + resp.Method
 			c.eventHandlersMu.Lock()
 			handlers := c.eventHandlers[key]
 			for _, h := range handlers {
+				if q := c.lossless[h]; q != nil {
+					q.push(resp.Params)
+					continue
+				}
 				select {
 				case h <- resp.Params:
 				default:
@@ -334,6 +369,25 @@ func (c *Client) readMessages() {
 	}
 }
 
+// forgetSession drops a cached session once Chrome reports it detached,
+// for example because its target was closed.
+func (c *Client) forgetSession(params json.RawMessage) {
+	var detached struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := json.Unmarshal(params, &detached); err != nil || detached.SessionID == "" {
+		return
+	}
+
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	for targetID, sessionID := range c.sessions {
+		if sessionID == detached.SessionID {
+			delete(c.sessions, targetID)
+		}
+	}
+}
+
 // subscribeEvent registers a handler for protocol events.
 func (c *Client) subscribeEvent(sessionID, method string) chan json.RawMessage {
 	ch := make(chan json.RawMessage, 100)
@@ -346,6 +400,74 @@ func (c *Client) subscribeEvent(sessionID, method string) chan json.RawMessage {
 	return ch
 }
 
+// subscribeEventLossless registers a handler for protocol events that
+// receives every event. Events the handler is not ready for are queued
+// rather than dropped, without holding up the reading of messages. The
+// caller must keep receiving until the channel is closed, which happens
+// once it unsubscribes and the queued events have been received.
+func (c *Client) subscribeEventLossless(sessionID, method string) chan json.RawMessage {
+	ch := make(chan json.RawMessage, 100)
+	key := sessionID + ":" + method
+	q := &eventQueue{ready: make(chan struct{}, 1), done: make(chan struct{})}
+	go q.forward(ch)
+
+	c.eventHandlersMu.Lock()
+	c.eventHandlers[key] = append(c.eventHandlers[key], ch)
+	c.lossless[ch] = q
+	c.eventHandlersMu.Unlock()
+
+	return ch
+}
+
+// eventQueue holds the events of a lossless handler until its channel has
+// room for them.
+type eventQueue struct {
+	mu      sync.Mutex
+	pending []json.RawMessage
+	ready   chan struct{} // signalled when an event is pushed
+	done    chan struct{} // closed when the handler is unsubscribed, after the last push
+}
+
+func (q *eventQueue) push(params json.RawMessage) {
+	q.mu.Lock()
+	q.pending = append(q.pending, params)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// forward sends queued events to ch in order, closing it once the handler
+// is unsubscribed and every event has been sent.
+func (q *eventQueue) forward(ch chan json.RawMessage) {
+	defer close(ch)
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.ready:
+			case <-q.done:
+				// Events pushed just before unsubscribing may be waiting
+				q.mu.Lock()
+				empty := len(q.pending) == 0
+				q.mu.Unlock()
+				if empty {
+					return
+				}
+			}
+			continue
+		}
+		params := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		ch <- params
+	}
+}
+
 // unsubscribeEvent removes an event handler.
 func (c *Client) unsubscribeEvent(sessionID, method string, ch chan json.RawMessage) {
 	key := sessionID + ":" + method
@@ -357,6 +479,12 @@ func (c *Client) unsubscribeEvent(sessionID, method string, ch chan json.RawMess
 	for i, h := range handlers {
 		if h == ch {
 			c.eventHandlers[key] = append(handlers[:i], handlers[i+1:]...)
+			if q := c.lossless[ch]; q != nil {
+				// The queue closes the channel, as it sends on it
+				delete(c.lossless, ch)
+				close(q.done)
+				return
+			}
 			close(ch)
 			return
 		}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -832,7 +835,10 @@ func TestClient_Click_NotFound(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Try to click non-existent element
-	err = client.Click(ctx, tabID, "#nonexistent-element-12345")
+	// The wait for the element to appear ends with the context
+	actionCtx, actionCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer actionCancel()
+	err = client.Click(actionCtx, tabID, "#nonexistent-element-12345")
 	if err == nil {
 		t.Error("expected error for non-existent element")
 	}
@@ -905,7 +911,10 @@ func TestClient_Fill_NotFound(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Try to fill non-existent element
-	err = client.Fill(ctx, tabID, "#nonexistent-input-12345", "test")
+	// The wait for the element to appear ends with the context
+	actionCtx, actionCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer actionCancel()
+	err = client.Fill(actionCtx, tabID, "#nonexistent-input-12345", "test")
 	if err == nil {
 		t.Error("expected error for non-existent element")
 	}
@@ -1050,6 +1059,57 @@ func TestClient_WaitFor_DelayedAppear(t *testing.T) {
 	}
 }
 
+func TestClient_WaitForState(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tabID, err := client.NewTab(ctx, "about:blank")
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, tabID)
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		name     string
+		setup    string
+		selector string
+		state    string
+	}{
+		{"flash", `setTimeout(() => { const el = document.createElement('div'); el.id = 'flash'; document.body.append(el); el.remove(); }, 300)`, "#flash", chrome.StateAttached},
+		{"visible", `document.body.innerHTML = '<p id="p" style="display:none">Hi</p>'; setTimeout(() => p.style.display = '', 300)`, "text=Hi", chrome.StateVisible},
+		{"enabled", `document.body.innerHTML = '<button id="b" disabled>Go</button>'; setTimeout(() => b.disabled = false, 300)`, "#b", chrome.StateEnabled},
+		{"hidden", `document.body.innerHTML = '<button id="b">Go</button>'; setTimeout(() => b.style.visibility = 'hidden', 300)`, `role=button[name="Go"]`, chrome.StateHidden},
+		{"detached", `document.body.innerHTML = '<i id="i"></i>'; setTimeout(() => i.remove(), 300)`, "#i", chrome.StateDetached},
+	}
+	for _, tt := range tests {
+		if _, err := client.Eval(ctx, tabID, tt.setup); err != nil {
+			t.Fatalf("%s: setting up: %v", tt.name, err)
+		}
+		if err := client.WaitForState(ctx, tabID, tt.selector, tt.state, 5*time.Second); err != nil {
+			t.Errorf("%s: WaitForState failed: %v", tt.name, err)
+		}
+	}
+
+	err = client.WaitForState(ctx, tabID, "#b", chrome.StateVisible, 200*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), `timeout waiting for "#b" to be visible`) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+	if err := client.WaitForState(ctx, tabID, "#b", "open", time.Second); err == nil || !strings.Contains(err.Error(), "invalid state") {
+		t.Errorf("expected invalid state error, got %v", err)
+	}
+}
+
 func TestClient_WaitFor_Timeout(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -1616,6 +1676,55 @@ func TestClient_PressKey_Success(t *testing.T) {
 	}
 }
 
+func TestClient_TypeWithOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	dataURL := `data:text/html,<html><body><input id="test-input" type="text"/><script>window.codes=[];document.getElementById('test-input').addEventListener('keydown',e=>codes.push(e.code))</script></body></html>`
+	tabID, err := client.NewTab(ctx, dataURL)
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, tabID)
+	time.Sleep(200 * time.Millisecond)
+
+	if err := client.Focus(ctx, tabID, "#test-input"); err != nil {
+		t.Fatalf("failed to focus: %v", err)
+	}
+
+	// Typed with a delay, each character has its key
+	start := time.Now()
+	if err := client.TypeWithOptions(ctx, tabID, "A1é", chrome.TypeOptions{Delay: 50 * time.Millisecond}); err != nil {
+		t.Fatalf("failed to type: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected two delays, took %v", elapsed)
+	}
+
+	// Inserted, there are no key events
+	if err := client.TypeWithOptions(ctx, tabID, "👍", chrome.TypeOptions{Insert: true}); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	result, err := client.Eval(ctx, tabID, `document.querySelector('#test-input').value + ' ' + codes.join(',')`)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if result.Value != "A1é👍 KeyA,Digit1" {
+		t.Errorf("unexpected value and key codes: %v", result.Value)
+	}
+}
+
 func TestClient_Hover_Success(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -2310,6 +2419,184 @@ func TestClient_InterceptModifyResponse(t *testing.T) {
 	}
 }
 
+func TestClient_CaptureHAR_Bodies(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	type captureResult struct {
+		har *chrome.HARLog
+		err error
+	}
+	done := make(chan captureResult, 1)
+	go func() {
+		har, err := client.CaptureHAR(ctx, tabID, chrome.HARCaptureOptions{
+			Until:  chrome.StopCondition{Duration: 2 * time.Second},
+			Bodies: true,
+		})
+		done <- captureResult{har, err}
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	targetURL := fmt.Sprintf("http://localhost:%d/json/version", testChromePort)
+	if _, err := client.Navigate(ctx, tabID, targetURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	result := <-done
+	if result.err != nil {
+		t.Fatalf("CaptureHAR failed: %v", result.err)
+	}
+
+	var found *chrome.HAREntry
+	for i, entry := range result.har.Log.Entries {
+		if entry.Request.URL == targetURL {
+			found = &result.har.Log.Entries[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("expected an entry for %s, got %d entries", targetURL, len(result.har.Log.Entries))
+	}
+	if found.Response.Status != 200 {
+		t.Errorf("expected status 200, got %d", found.Response.Status)
+	}
+	if !strings.Contains(found.Response.Content.Text, "Browser") {
+		t.Errorf("expected embedded body, got %q", found.Response.Content.Text)
+	}
+	if found.Timings.Wait < 0 || found.Time <= 0 {
+		t.Errorf("expected timings, got %+v (time %v)", found.Timings, found.Time)
+	}
+}
+
+func TestClient_ReplayHAR(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	har := &chrome.HARLog{}
+	har.Log.Entries = []chrome.HAREntry{{
+		Request: chrome.HARRequest{Method: "GET", URL: "https://replay.invalid/"},
+		Response: chrome.HARResponse{
+			Status:  200,
+			Headers: []chrome.HARHeader{{Name: "Content-Type", Value: "text/html"}},
+			Content: chrome.HARContent{MimeType: "text/html", Text: "<html><body>Replayed page</body></html>"},
+		},
+	}}
+
+	events, stop, err := client.ReplayHAR(ctx, tabID, har, chrome.HARReplayOptions{})
+	if err != nil {
+		t.Fatalf("failed to start replay: %v", err)
+	}
+	defer stop()
+
+	if _, err := client.Navigate(ctx, tabID, "https://replay.invalid/"); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Action != "replay" || ev.Status != 200 {
+			t.Errorf("expected replayed 200, got %+v", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for replay event")
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	result, err := client.Eval(ctx, tabID, "document.body.innerText")
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if result.Value != "Replayed page" {
+		t.Errorf("expected replayed content, got %v", result.Value)
+	}
+}
+
+func TestClient_Mock(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	rules := []chrome.MockRule{
+		{Name: "slow", URL: "*/json/version", Delay: 200 * time.Millisecond},
+		{Name: "modify", URL: "*/json/version", Status: 201, Headers: map[string]string{"X-Mocked": "yes"}},
+		{Name: "fulfill", URL: "*/mocked", Body: []byte(`{"mocked":true}`), Headers: map[string]string{"Content-Type": "application/json"}},
+		{Name: "abort", URL: "*/blocked", Abort: "BlockedByClient"},
+	}
+	events, stop, err := client.Mock(ctx, tabID, rules)
+	if err != nil {
+		t.Fatalf("failed to start mocking: %v", err)
+	}
+	defer stop()
+
+	targetURL := fmt.Sprintf("http://localhost:%d/json/version", testChromePort)
+	if _, err := client.Navigate(ctx, tabID, targetURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+	_, err = client.Eval(ctx, tabID, `
+		fetch('/mocked').then(r => r.json()).then(j => window.mocked = j.mocked);
+		fetch('/blocked').catch(() => window.blocked = true);
+		0`)
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+
+	// Collect events until every rule has matched, then a little longer to
+	// catch any request that is answered twice
+	got := make(map[string][]chrome.MockEvent)
+	var settle <-chan time.Time
+	for waiting := true; waiting; {
+		select {
+		case ev := <-events:
+			got[ev.Rule] = append(got[ev.Rule], ev)
+			if len(got) == len(rules) {
+				settle = time.After(500 * time.Millisecond)
+			}
+		case <-settle:
+			waiting = false
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for mock events, got %+v", got)
+		}
+	}
+
+	want := map[string]string{"slow": "continue", "modify": "modify", "fulfill": "fulfill", "abort": "abort"}
+	for rule, action := range want {
+		if len(got[rule]) != 1 {
+			t.Errorf("expected one %s event, got %+v", rule, got[rule])
+			continue
+		}
+		if ev := got[rule][0]; ev.Action != action || ev.Error != "" {
+			t.Errorf("expected %s to %s, got %+v", rule, action, ev)
+		}
+	}
+	if ev := got["modify"]; len(ev) == 1 && ev[0].Status != 201 {
+		t.Errorf("expected modified status 201, got %d", ev[0].Status)
+	}
+	if ev := got["abort"]; len(ev) == 1 && ev[0].Reason != "BlockedByClient" {
+		t.Errorf("expected abort reason BlockedByClient, got %q", ev[0].Reason)
+	}
+
+	result, err := client.Eval(ctx, tabID, "[window.mocked === true, window.blocked === true, document.body.innerText.includes('Browser')]")
+	if err != nil {
+		t.Fatalf("failed to evaluate: %v", err)
+	}
+	if values, ok := result.Value.([]interface{}); !ok || len(values) != 3 || values[0] != true || values[1] != true || values[2] != true {
+		t.Errorf("expected the fulfilled body, the aborted fetch and the real page body, got %v", result.Value)
+	}
+}
+
 func TestClient_BlockURLs(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -2510,6 +2797,64 @@ func TestClient_UploadFile(t *testing.T) {
 	}
 }
 
+func TestClient_UploadOnChooser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tmpFile, err := os.CreateTemp("", "hubcap-test-upload-*.txt")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	// Create isolated tab whose button opens a hidden file input's chooser
+	dataURL := `data:text/html,<html><body><input type="file" id="f" hidden><button id="b" onclick="f.click()">Upload</button><script>window.fileName='';f.addEventListener('change',e=>{window.fileName=e.target.files[0]?.name||''});</script></body></html>`
+	tabID, err := client.NewTab(ctx, dataURL)
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, tabID)
+	time.Sleep(200 * time.Millisecond)
+
+	chooser, err := client.UploadOnChooser(ctx, tabID, []string{tmpFile.Name()}, func() error {
+		return client.Click(ctx, tabID, "#b")
+	})
+	if err != nil {
+		t.Fatalf("failed to upload through chooser: %v", err)
+	}
+	if chooser.Mode != "selectSingle" || chooser.BackendNodeID == 0 {
+		t.Errorf("expected a single file chooser for the input, got %+v", chooser)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	result, err := client.Eval(ctx, tabID, "window.fileName")
+	if err != nil {
+		t.Fatalf("failed to verify file upload: %v", err)
+	}
+	if fileName, ok := result.Value.(string); !ok || fileName == "" {
+		t.Errorf("expected file name to be set, got: %v", result.Value)
+	}
+
+	// A single file chooser cannot take two files
+	_, err = client.UploadOnChooser(ctx, tabID, []string{tmpFile.Name(), tmpFile.Name()}, func() error {
+		return client.Click(ctx, tabID, "#b")
+	})
+	if err == nil || !strings.Contains(err.Error(), "single file") {
+		t.Errorf("expected single file error, got %v", err)
+	}
+}
+
 func TestClient_GetValue(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -2632,6 +2977,77 @@ func TestClient_WaitForFunction_DelayedTrue(t *testing.T) {
 	}
 }
 
+func TestClient_WaitForAny_Dialog(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tabID, err := client.NewTab(ctx, "about:blank")
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, tabID)
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := client.Eval(ctx, tabID, `setTimeout(() => alert('Session expired'), 300)`); err != nil {
+		t.Fatalf("failed to schedule alert: %v", err)
+	}
+	if err := client.HandleDialog(ctx, tabID, "accept", ""); err != nil {
+		t.Fatalf("HandleDialog failed: %v", err)
+	}
+
+	conds := []chrome.WaitCondition{{Kind: "selector", Value: "#never"}, {Kind: "dialog", Value: "expired"}}
+	result, err := client.WaitForAny(ctx, tabID, conds, 5*time.Second)
+	if err != nil {
+		t.Fatalf("WaitForAny failed: %v", err)
+	}
+	dialog, ok := result.Result.(*chrome.DialogInfo)
+	if result.Index != 1 || !ok || dialog.Type != "alert" || dialog.Message != "Session expired" {
+		t.Errorf("expected the alert to fire, got %+v", result)
+	}
+}
+
+func TestClient_WaitForFunction_ThrowsUntilTrue(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// window.app is undefined until the timer fires
+	dataURL := `data:text/html,<html><body><script>setTimeout(() => { window.app = {ready: true}; }, 300);</script></body></html>`
+	tabID, err := client.NewTab(ctx, dataURL)
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, tabID)
+
+	if err := client.WaitForFunction(ctx, tabID, "window.app.ready", 5*time.Second); err != nil {
+		t.Fatalf("WaitForFunction failed: %v", err)
+	}
+
+	// Scripts that are not a single expression are polled
+	if err := client.WaitForFunction(ctx, tabID, "const ready = window.app.ready; ready", 5*time.Second); err != nil {
+		t.Fatalf("WaitForFunction with a script failed: %v", err)
+	}
+}
+
 func TestClient_WaitForFunction_Timeout(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
@@ -3084,3 +3500,612 @@ func TestClient_WaitForGone_Timeout(t *testing.T) {
 		t.Errorf("expected timeout error, got: %v", err)
 	}
 }
+
+func TestClient_Serve_SharesSessions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	socket := filepath.Join(t.TempDir(), "hubcap.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- client.Serve(ln) }()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	// Each socket client attaches, evaluates and detaches like a CLI command
+	for i := 0; i < 2; i++ {
+		remote, err := chrome.ConnectSocket(ctx, socket)
+		if err != nil {
+			t.Fatalf("failed to connect to socket: %v", err)
+		}
+		result, err := remote.Eval(ctx, tabID, "1 + 1")
+		if err != nil {
+			t.Fatalf("eval over socket failed: %v", err)
+		}
+		if v, ok := result.Value.(float64); !ok || v != 2 {
+			t.Errorf("expected 2, got %v", result.Value)
+		}
+		remote.Close()
+	}
+
+	remote, err := chrome.ConnectSocket(ctx, socket)
+	if err != nil {
+		t.Fatalf("failed to connect to socket: %v", err)
+	}
+	defer remote.Close()
+
+	status, err := remote.DaemonStatus(ctx)
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if status.Sessions != 1 {
+		t.Errorf("expected 1 shared session, got %d", status.Sessions)
+	}
+
+	if err := remote.ShutdownDaemon(ctx); err != nil {
+		t.Fatalf("failed to shut down: %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("expected Serve to return nil, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+}
+
+func TestClient_CaptureCPUProfile(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	// Keep the main thread busy while profiling
+	if _, err := client.Eval(ctx, tabID, `setInterval(() => { const end = Date.now() + 20; while (Date.now() < end) {} }, 25)`); err != nil {
+		t.Fatalf("failed to start work: %v", err)
+	}
+
+	profile, err := client.CaptureCPUProfile(ctx, tabID, 500*time.Millisecond, 0)
+	if err != nil {
+		t.Fatalf("failed to capture profile: %v", err)
+	}
+
+	if len(profile.Nodes) == 0 || len(profile.Samples) == 0 {
+		t.Fatalf("expected nodes and samples, got %d nodes, %d samples", len(profile.Nodes), len(profile.Samples))
+	}
+	if len(profile.TimeDeltas) != len(profile.Samples) {
+		t.Errorf("expected one time delta per sample, got %d for %d", len(profile.TimeDeltas), len(profile.Samples))
+	}
+	if profile.EndTime <= profile.StartTime {
+		t.Errorf("expected end after start, got %v..%v", profile.StartTime, profile.EndTime)
+	}
+}
+
+func TestClient_Vitals(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	id, err := client.StartVitals(ctx, tabID)
+	if err != nil {
+		t.Fatalf("failed to start vitals: %v", err)
+	}
+	defer client.StopVitals(ctx, tabID, id)
+
+	dataURL := `data:text/html,<html><body><h1 id="title">Vitals Test</h1><p>Some text</p></body></html>`
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	vitals, err := client.CollectVitals(ctx, tabID, chrome.StopCondition{Duration: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("failed to collect vitals: %v", err)
+	}
+
+	if vitals.LCP == nil || vitals.LCP.Element != "#title" {
+		t.Errorf("expected LCP of #title, got %+v", vitals.LCP)
+	}
+	if vitals.FCP == nil || vitals.FCP.Rating == "" {
+		t.Errorf("expected rated FCP, got %+v", vitals.FCP)
+	}
+	if vitals.CLS == nil || vitals.CLS.Value != 0 || vitals.CLS.Rating != "good" {
+		t.Errorf("expected no layout shift, got %+v", vitals.CLS)
+	}
+	if vitals.INP != nil {
+		t.Errorf("expected no INP without interaction, got %+v", vitals.INP)
+	}
+}
+
+func TestClient_SelectorEngines(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	dataURL := `data:text/html,<html><body>` +
+		`<form id="login"><label>Name <input id="name"></label><button type="button" id="signin">Sign in</button></form>` +
+		`<div id="other"><button type="button" id="save" data-testid="save-btn">Save draft</button><button type="button" id="cancel">Cancel</button></div>` +
+		`</body></html>`
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"xpath=//button[@id='cancel']", "cancel"},
+		{"//form//button", "signin"},
+		{`text="Sign in"`, "signin"},
+		{"text=save", "save"},
+		{`role=button[name="Cancel"]`, "cancel"},
+		{"role=button[name=draft]", "save"},
+		{"role=textbox[name=Name]", "name"},
+		{"data-testid=save-btn", "save"},
+		{"#other >> text=Cancel", "cancel"},
+		{"#other >> role=button", "save"},
+		{"text=Sign in >> xpath=..", "login"},
+	}
+
+	for _, tt := range tests {
+		id, err := client.GetAttribute(ctx, tabID, tt.selector, "id")
+		if err != nil {
+			t.Errorf("%s: %v", tt.selector, err)
+			continue
+		}
+		if id != tt.want {
+			t.Errorf("%s: expected #%s, got #%s", tt.selector, tt.want, id)
+		}
+	}
+
+	count, err := client.CountElements(ctx, tabID, "#other >> role=button")
+	if err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 buttons in #other, got %d", count)
+	}
+
+	if err := client.Click(ctx, tabID, `role=button[name="Cancel"]`); err != nil {
+		t.Errorf("failed to click by role: %v", err)
+	}
+
+	exists, err := client.Exists(ctx, tabID, `text="Sign"`)
+	if err != nil {
+		t.Fatalf("failed to check existence: %v", err)
+	}
+	if exists {
+		t.Error("expected exact text to not match a substring")
+	}
+
+	if _, err := client.GetText(ctx, tabID, "role=button[level=1]"); err == nil {
+		t.Error("expected error for unsupported role attribute")
+	}
+}
+
+func TestClient_ShadowAndFrames(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	dataURL := `data:text/html,<html><body><button>Outside</button><pay-widget></pay-widget>` +
+		`<iframe name="pay" srcdoc="<button id='buy' onclick='this.textContent=&quot;Bought&quot;'>Buy</button>"></iframe>` +
+		`<script>document.querySelector('pay-widget').attachShadow({mode: 'open'}).innerHTML = '<span id="total">Total: 42</span>';</script>` +
+		`</body></html>`
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	for _, selector := range []string{">>> #total", "pay-widget >>> span", ">>> text=Total"} {
+		text, err := client.GetText(ctx, tabID, selector)
+		if err != nil {
+			t.Errorf("%s: %v", selector, err)
+		} else if text != "Total: 42" {
+			t.Errorf("%s: expected 'Total: 42', got %q", selector, text)
+		}
+	}
+
+	exists, err := client.Exists(ctx, tabID, "#total")
+	if err != nil {
+		t.Fatalf("failed to check existence: %v", err)
+	}
+	if exists {
+		t.Error("expected a plain selector to not see inside the shadow root")
+	}
+
+	if err := client.SetFrame(ctx, tabID, "no-such-frame"); err == nil {
+		t.Error("expected error for unknown frame")
+	}
+
+	if err := client.SetFrame(ctx, tabID, "iframe[name=pay]"); err != nil {
+		t.Fatalf("failed to set frame: %v", err)
+	}
+	if err := client.Click(ctx, tabID, "#buy"); err != nil {
+		t.Fatalf("failed to click in frame: %v", err)
+	}
+	text, err := client.GetText(ctx, tabID, "#buy")
+	if err != nil {
+		t.Fatalf("failed to get text in frame: %v", err)
+	}
+	if text != "Bought" {
+		t.Errorf("expected click to reach the frame, got %q", text)
+	}
+	if _, err := client.GetText(ctx, tabID, "text=Outside"); err == nil {
+		t.Error("expected the page outside the frame to be out of scope")
+	}
+}
+
+func TestClient_Actionability(t *testing.T) {
+	client := getSharedClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	dataURL := `data:text/html,<html><body>` +
+		`<button id="covered" onclick="this.textContent='Clicked'">Covered</button>` +
+		`<div class="overlay" style="position:fixed;inset:0"></div>` +
+		`<input id="off" disabled>` +
+		`<script>setTimeout(() => { const b = document.createElement('button'); b.id = 'late'; b.onclick = () => b.textContent = 'Clicked'; document.body.prepend(b); }, 300);</script>` +
+		`</body></html>`
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	// Clicks wait for elements that are not yet in the page
+	if err := client.Click(ctx, tabID, "#late"); err != nil {
+		t.Fatalf("failed to click late element: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		action      func(ctx context.Context) error
+		check       string
+		interceptor string
+	}{
+		{"covered", func(ctx context.Context) error { return client.Click(ctx, tabID, "#covered") }, chrome.CheckPointerEvents, `<div class="overlay">`},
+		{"disabled", func(ctx context.Context) error { return client.Fill(ctx, tabID, "#off", "x") }, chrome.CheckEnabled, ""},
+		{"missing", func(ctx context.Context) error { return client.Hover(ctx, tabID, "#missing") }, chrome.CheckAttached, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actionCtx, actionCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+			defer actionCancel()
+
+			var actErr *chrome.ActionabilityError
+			if err := tt.action(actionCtx); !errors.As(err, &actErr) {
+				t.Fatalf("expected actionability error, got: %v", err)
+			}
+			if actErr.Check != tt.check {
+				t.Errorf("expected check %q, got %q", tt.check, actErr.Check)
+			}
+			if actErr.Interceptor != tt.interceptor {
+				t.Errorf("expected interceptor %q, got %q", tt.interceptor, actErr.Interceptor)
+			}
+		})
+	}
+}
+
+func TestClient_StrictAndNth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// Strict mode is per client, so this test has its own
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	dataURL := `data:text/html,<html><body>` +
+		`<button id="one" class="a">One</button><button id="two" onclick="this.textContent='Clicked'">Two</button>` +
+		`<p style="display:none">Hidden</p>` +
+		`</body></html>`
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	elements, err := client.QueryAll(ctx, tabID, "button, p")
+	if err != nil {
+		t.Fatalf("failed to query all: %v", err)
+	}
+	if len(elements) != 3 {
+		t.Fatalf("expected 3 elements, got %d", len(elements))
+	}
+	if elements[1].Index != 1 || elements[1].TagName != "BUTTON" || elements[1].Attributes["id"] != "two" || elements[1].Text != "Two" {
+		t.Errorf("unexpected second element: %+v", elements[1])
+	}
+	if !elements[0].Visible || elements[0].Bounds.Width == 0 {
+		t.Errorf("expected first button visible with a box, got %+v", elements[0])
+	}
+	if elements[2].Visible {
+		t.Errorf("expected hidden paragraph to not be visible, got %+v", elements[2])
+	}
+
+	text, err := client.GetText(ctx, tabID, "button:nth=1")
+	if err != nil {
+		t.Fatalf("failed to get text: %v", err)
+	}
+	if text != "Two" {
+		t.Errorf("expected :nth=1 to select the second button, got %q", text)
+	}
+
+	client.SetStrict(true)
+	var ambiguous *chrome.AmbiguousSelectorError
+	if _, err := client.GetText(ctx, tabID, "button"); !errors.As(err, &ambiguous) {
+		t.Fatalf("expected ambiguous selector error, got: %v", err)
+	}
+	if ambiguous.Count != 2 || len(ambiguous.Candidates) != 2 || ambiguous.Candidates[0] != `<button id="one" class="a">` {
+		t.Errorf("unexpected ambiguous selector error: %+v", ambiguous)
+	}
+
+	client.SetIndex(1)
+	if err := client.Click(ctx, tabID, "button"); err != nil {
+		t.Fatalf("failed to click with index: %v", err)
+	}
+	text, err = client.GetText(ctx, tabID, "#two")
+	if err != nil {
+		t.Fatalf("failed to get text: %v", err)
+	}
+	if text != "Clicked" {
+		t.Errorf("expected the index to select the second button, got %q", text)
+	}
+}
+
+func TestClient_ElementRefs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// Refs are per client, so this test has its own
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tabID, cleanup := createTestTab(t, client, ctx)
+	defer cleanup()
+
+	dataURL := `data:text/html,<html><body>` +
+		`<div id="card"><button onclick="this.textContent='Clicked'">Buy</button></div>` +
+		`</body></html>`
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+
+	card, err := client.Query(ctx, tabID, "#card")
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	if card.BackendNodeID == 0 {
+		t.Fatal("expected a backend node ID")
+	}
+	frameID, loaderID, err := client.RefDocument(ctx, tabID)
+	if err != nil {
+		t.Fatalf("failed to get the ref document: %v", err)
+	}
+	if frameID != tabID || loaderID == "" {
+		t.Fatalf("expected the page's main frame and a loader ID, got %q, %q", frameID, loaderID)
+	}
+	client.SetRefs(map[string]chrome.ElementRef{
+		"e1": {BackendNodeID: card.BackendNodeID, FrameID: frameID, LoaderID: loaderID},
+	})
+
+	if err := client.Click(ctx, tabID, "@e1 >> button"); err != nil {
+		t.Fatalf("failed to click within ref: %v", err)
+	}
+	text, err := client.GetText(ctx, tabID, "@e1")
+	if err != nil {
+		t.Fatalf("failed to get text of ref: %v", err)
+	}
+	if text != "Clicked" {
+		t.Errorf("expected 'Clicked', got %q", text)
+	}
+
+	if _, err := client.GetText(ctx, tabID, "@e2"); err == nil || !strings.Contains(err.Error(), "unknown element ref") {
+		t.Errorf("expected unknown ref error, got: %v", err)
+	}
+
+	if _, err := client.Eval(ctx, tabID, `document.getElementById('card').remove()`); err != nil {
+		t.Fatalf("failed to remove element: %v", err)
+	}
+	var stale *chrome.StaleElementError
+	if _, err := client.GetText(ctx, tabID, "@e1"); !errors.As(err, &stale) {
+		t.Errorf("expected stale element error from text, got: %v", err)
+	}
+	if err := client.Click(ctx, tabID, "@e1"); !errors.As(err, &stale) {
+		t.Errorf("expected stale element error from click, got: %v", err)
+	}
+
+	// A ref from a document that has been navigated away from is stale,
+	// even if a node of the new document has the same backend node ID
+	if _, err := client.NavigateAndWait(ctx, tabID, dataURL); err != nil {
+		t.Fatalf("failed to navigate: %v", err)
+	}
+	card, err = client.Query(ctx, tabID, "#card")
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	client.SetRefs(map[string]chrome.ElementRef{
+		"e1": {BackendNodeID: card.BackendNodeID, FrameID: frameID, LoaderID: loaderID},
+	})
+	if _, err := client.GetText(ctx, tabID, "@e1"); !errors.As(err, &stale) {
+		t.Errorf("expected stale element error after navigating, got: %v", err)
+	}
+}
+
+func TestCPUProfile_Pprof(t *testing.T) {
+	profile := &chrome.CPUProfile{
+		Nodes: []chrome.CPUProfileNode{
+			{ID: 1, CallFrame: chrome.CPUProfileCallFrame{FunctionName: "(root)"}, Children: []int{2, 4}},
+			{ID: 2, CallFrame: chrome.CPUProfileCallFrame{FunctionName: "main", URL: "app.js", LineNumber: 9}, Children: []int{3}},
+			{ID: 3, CallFrame: chrome.CPUProfileCallFrame{URL: "app.js", LineNumber: 19, ColumnNumber: 4}},
+			{ID: 4, CallFrame: chrome.CPUProfileCallFrame{FunctionName: "(idle)"}},
+		},
+		StartTime:  1000,
+		EndTime:    6000,
+		Samples:    []int{2, 3, 3, 4, 3},
+		TimeDeltas: []int{0, 1000, 1000, 1000, 1000},
+	}
+
+	started := time.Unix(1700000000, 0)
+	p := profile.Pprof(started)
+
+	if p.TimeNanos != started.UnixNano() || p.DurationNanos != 5000000 {
+		t.Errorf("unexpected time %d / duration %d", p.TimeNanos, p.DurationNanos)
+	}
+	if len(p.Locations) != 2 {
+		t.Fatalf("expected 2 locations (root and idle dropped), got %d", len(p.Locations))
+	}
+	if len(p.Functions) != 2 || p.Functions[1].Name != "(anonymous)" || p.Functions[1].StartLine != 20 {
+		t.Errorf("unexpected functions: %+v", p.Functions)
+	}
+	if len(p.Samples) != 2 {
+		t.Fatalf("expected 2 samples (one per sampled node), got %d", len(p.Samples))
+	}
+
+	// Node 3 is sampled three times, each until the next sample 1ms later
+	leaf := p.Samples[1]
+	if len(leaf.LocationIDs) != 2 || leaf.LocationIDs[0] != 3 || leaf.LocationIDs[1] != 2 {
+		t.Errorf("expected stack [3 2], got %v", leaf.LocationIDs)
+	}
+	if leaf.Values[0] != 3 || leaf.Values[1] != 3000000 {
+		t.Errorf("expected 3 samples / 3ms, got %v", leaf.Values)
+	}
+}
+
+func TestClient_WaitForPopup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	tabID, err := client.NewTab(ctx, "about:blank")
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, tabID)
+	time.Sleep(100 * time.Millisecond)
+
+	popup, err := client.WaitForPopup(ctx, tabID, func() error {
+		_, err := client.Eval(ctx, tabID, `window.open('data:text/html,popup'); true`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WaitForPopup failed: %v", err)
+	}
+	defer client.CloseTab(ctx, popup.ID)
+
+	if popup.OpenerID != tabID || popup.URL != "data:text/html,popup" {
+		t.Errorf("unexpected popup %+v", popup)
+	}
+}
+
+func TestClient_BrowserContexts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := chrome.Connect(ctx, "localhost", testChromePort)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	contextID, err := client.CreateBrowserContext(ctx, chrome.BrowserContextOptions{})
+	if err != nil {
+		t.Fatalf("CreateBrowserContext failed: %v", err)
+	}
+	ids, err := client.BrowserContexts(ctx)
+	if err != nil {
+		t.Fatalf("BrowserContexts failed: %v", err)
+	}
+	if !slices.Contains(ids, contextID) {
+		t.Errorf("expected %s in %v", contextID, ids)
+	}
+
+	client.SetBrowserContext(contextID)
+	tabID, err := client.NewTab(ctx, "data:text/html,isolated")
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	client.SetBrowserContext("")
+	time.Sleep(100 * time.Millisecond)
+
+	otherID, err := client.NewTab(ctx, "data:text/html,default")
+	if err != nil {
+		t.Fatalf("failed to create tab: %v", err)
+	}
+	defer client.CloseTab(ctx, otherID)
+
+	pages, err := client.Pages(ctx)
+	if err != nil {
+		t.Fatalf("Pages failed: %v", err)
+	}
+	for _, p := range pages {
+		if p.ID == tabID && p.BrowserContextID != contextID {
+			t.Errorf("expected tab in context %s, got %q", contextID, p.BrowserContextID)
+		}
+		if p.ID == otherID && p.BrowserContextID == contextID {
+			t.Error("expected the other tab in the default context")
+		}
+	}
+
+	if err := client.DisposeBrowserContext(ctx, contextID); err != nil {
+		t.Fatalf("DisposeBrowserContext failed: %v", err)
+	}
+	if ids, _ := client.BrowserContexts(ctx); slices.Contains(ids, contextID) {
+		t.Error("expected the context to be disposed of")
+	}
+	pages, _ = client.Pages(ctx)
+	for _, p := range pages {
+		if p.ID == tabID {
+			t.Error("expected the context's tab to close with it")
+		}
+	}
+}
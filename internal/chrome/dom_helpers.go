@@ -6,58 +6,37 @@ import (
 	"fmt"
 )
 
-// resolveNodeID enables DOM, gets the document root, and runs querySelector
-// to find the first element matching selector. Returns the node ID.
+// resolveNodeID finds the first element matching selector and returns its
+// node ID, or an error if there is none.
 func (c *Client) resolveNodeID(ctx context.Context, sessionID string, selector string) (int64, error) {
-	_, err := c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	nodeID, err := c.queryNodeID(ctx, sessionID, selector)
 	if err != nil {
-		return 0, fmt.Errorf("enabling DOM domain: %w", err)
+		return 0, err
 	}
 
-	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-	if err != nil {
-		return 0, fmt.Errorf("getting document: %w", err)
-	}
-
-	var docResp struct {
-		Root struct {
-			NodeID int64 `json:"nodeId"`
-		} `json:"root"`
-	}
-	if err := json.Unmarshal(docResult, &docResp); err != nil {
-		return 0, fmt.Errorf("parsing document response: %w", err)
-	}
-
-	queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": selector,
-	})
-	if err != nil {
-		return 0, fmt.Errorf("querying selector: %w", err)
-	}
-
-	var queryResp struct {
-		NodeID int64 `json:"nodeId"`
-	}
-	if err := json.Unmarshal(queryResult, &queryResp); err != nil {
-		return 0, fmt.Errorf("parsing query response: %w", err)
-	}
-
-	if queryResp.NodeID == 0 {
+	if nodeID == 0 {
 		return 0, fmt.Errorf("element not found: %s", selector)
 	}
 
-	return queryResp.NodeID, nil
+	return nodeID, nil
+}
+
+// resolveElementCenter waits for an element to be actionable and returns
+// its center coordinates.
+func (c *Client) resolveElementCenter(ctx context.Context, sessionID string, selector string, checks actionability) (x, y float64, err error) {
+	_, x, y, err = c.waitActionable(ctx, sessionID, selector, checks)
+	return x, y, err
 }
 
-// resolveElementCenter finds an element by selector and returns its center coordinates.
-func (c *Client) resolveElementCenter(ctx context.Context, sessionID string, selector string) (x, y float64, err error) {
-	nodeID, err := c.resolveNodeID(ctx, sessionID, selector)
+// waitEnabled waits for an element of the target's frame to be visible,
+// stable and enabled, for actions that set its state with script.
+func (c *Client) waitEnabled(ctx context.Context, targetID string, selector string) error {
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
-		return 0, 0, err
+		return err
 	}
-
-	return c.getNodeCenter(ctx, sessionID, nodeID)
+	_, _, _, err = c.waitActionable(ctx, sessionID, selector, fillChecks)
+	return err
 }
 
 // getNodeCenter returns the center coordinates of a DOM node by its node ID.
@@ -90,7 +69,7 @@ func (c *Client) getNodeCenter(ctx context.Context, sessionID string, nodeID int
 
 // dispatchMouseClick dispatches mouseMoved, mousePressed, and mouseReleased events.
 func (c *Client) dispatchMouseClick(ctx context.Context, sessionID string, x, y float64, button string, clickCount int) error {
-	_, err := c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type": "mouseMoved",
 		"x":    x,
 		"y":    y,
@@ -99,7 +78,7 @@ func (c *Client) dispatchMouseClick(ctx context.Context, sessionID string, x, y
 		return fmt.Errorf("dispatching mouseMoved: %w", err)
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type":       "mousePressed",
 		"x":          x,
 		"y":          y,
@@ -110,7 +89,7 @@ func (c *Client) dispatchMouseClick(ctx context.Context, sessionID string, x, y
 		return fmt.Errorf("dispatching mousePressed: %w", err)
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type":       "mouseReleased",
 		"x":          x,
 		"y":          y,
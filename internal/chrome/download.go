@@ -0,0 +1,190 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// Download behaviors for SetDownloadBehavior.
+const (
+	DownloadDeny         = "deny"         // Cancel downloads
+	DownloadAllow        = "allow"        // Save downloads to the download path under their own names
+	DownloadAllowAndName = "allowAndName" // Save downloads to the download path named by their GUIDs
+	DownloadDefault      = "default"      // Chrome's own behavior
+)
+
+// SetDownloadBehavior sets what happens to downloads and where they are
+// saved, in the browser context set by SetBrowserContext, and turns on
+// download events. downloadPath must be absolute, and may be empty for deny
+// and default. Chrome goes back to its own behavior when the client
+// disconnects, and a daemon puts back the behavior it set when one of its
+// clients that changed it does.
+func (c *Client) SetDownloadBehavior(ctx context.Context, behavior string, downloadPath string) error {
+	params := map[string]interface{}{
+		"behavior":      behavior,
+		"eventsEnabled": true,
+	}
+	if downloadPath != "" {
+		params["downloadPath"] = downloadPath
+	}
+	if c.browserContext != "" {
+		params["browserContextId"] = c.browserContext
+	}
+	if _, err := c.Call(ctx, "Browser.setDownloadBehavior", params); err != nil {
+		return fmt.Errorf("setting download behavior: %w", err)
+	}
+
+	c.downloadsMu.Lock()
+	c.downloads = params
+	c.downloadsMu.Unlock()
+	return nil
+}
+
+// enableDownloadEvents turns on download events in the browser context set
+// by SetBrowserContext, keeping the behavior set with SetDownloadBehavior
+// for it or, without one, Chrome's own. A daemon keeps the behavior it set.
+func (c *Client) enableDownloadEvents(ctx context.Context) error {
+	c.downloadsMu.Lock()
+	params := c.downloads
+	c.downloadsMu.Unlock()
+
+	if id, _ := params["browserContextId"].(string); params == nil || id != c.browserContext {
+		params = map[string]interface{}{
+			"behavior":      DownloadDefault,
+			"eventsEnabled": true,
+		}
+		if c.browserContext != "" {
+			params["browserContextId"] = c.browserContext
+		}
+	}
+	if _, err := c.Call(ctx, "Browser.setDownloadBehavior", params); err != nil {
+		return fmt.Errorf("enabling download events: %w", err)
+	}
+	return nil
+}
+
+// restoreDownloadBehavior undoes a socket client's change to the download
+// behavior of a browser context, as Chrome does when a connection that set
+// it closes. The behavior set with SetDownloadBehavior is put back if it was
+// for the same context, otherwise Chrome's own.
+func (c *Client) restoreDownloadBehavior(ctx context.Context, browserContextID string) error {
+	c.downloadsMu.Lock()
+	params := c.downloads
+	c.downloadsMu.Unlock()
+
+	if id, _ := params["browserContextId"].(string); params == nil || id != browserContextID {
+		params = map[string]interface{}{"behavior": DownloadDefault}
+		if browserContextID != "" {
+			params["browserContextId"] = browserContextID
+		}
+	}
+	if _, err := c.Call(ctx, "Browser.setDownloadBehavior", params); err != nil {
+		return fmt.Errorf("restoring download behavior: %w", err)
+	}
+	return nil
+}
+
+// downloadProgress is the part of a Browser.downloadProgress event used.
+type downloadProgress struct {
+	GUID          string  `json:"guid"`
+	ReceivedBytes float64 `json:"receivedBytes"`
+	State         string  `json:"state"`    // "inProgress", "completed" or "canceled"
+	FilePath      string  `json:"filePath"` // Set by newer Chromes once completed
+}
+
+// WaitForDownloadComplete runs trigger, if not nil, then waits for the page
+// to start a download and for the download to finish. While it waits,
+// downloads are saved to dir, which must be absolute, named by their GUIDs.
+// It returns an error if trigger does or the download is canceled.
+func (c *Client) WaitForDownloadComplete(ctx context.Context, targetID string, dir string, trigger func() error) (*DownloadResult, error) {
+	// Download events come from the browser rather than the page
+	beginCh := c.subscribeEvent("", "Browser.downloadWillBegin")
+	defer c.unsubscribeEvent("", "Browser.downloadWillBegin", beginCh)
+	progressCh := c.subscribeEvent("", "Browser.downloadProgress")
+	defer c.unsubscribeEvent("", "Browser.downloadProgress", progressCh)
+
+	if err := c.SetDownloadBehavior(ctx, DownloadAllowAndName, dir); err != nil {
+		return nil, err
+	}
+
+	if trigger != nil {
+		if err := trigger(); err != nil {
+			return nil, err
+		}
+	}
+
+	var download *DownloadResult
+	// Downloads that ended before the events saying they began were read
+	ended := map[string]downloadProgress{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case params, ok := <-beginCh:
+			if !ok {
+				return nil, fmt.Errorf("event channel closed")
+			}
+			var event struct {
+				FrameID string `json:"frameId"`
+				DownloadInfo
+			}
+			if download != nil || json.Unmarshal(params, &event) != nil {
+				continue
+			}
+			fromTarget, err := c.hasFrame(ctx, targetID, event.FrameID)
+			if err != nil {
+				return nil, err
+			}
+			if !fromTarget {
+				continue
+			}
+			download = &DownloadResult{DownloadInfo: event.DownloadInfo}
+			if progress, ok := ended[download.GUID]; ok {
+				return endDownload(download, progress, dir)
+			}
+		case params, ok := <-progressCh:
+			if !ok {
+				return nil, fmt.Errorf("event channel closed")
+			}
+			var progress downloadProgress
+			if json.Unmarshal(params, &progress) != nil || progress.State == "inProgress" {
+				continue
+			}
+			if download == nil || progress.GUID != download.GUID {
+				ended[progress.GUID] = progress
+				continue
+			}
+			return endDownload(download, progress, dir)
+		}
+	}
+}
+
+// endDownload completes the result for a download that has ended.
+func endDownload(download *DownloadResult, progress downloadProgress, dir string) (*DownloadResult, error) {
+	if progress.State != "completed" {
+		return nil, fmt.Errorf("download of %s was %s", download.SuggestedFilename, progress.State)
+	}
+	download.Path = progress.FilePath
+	if download.Path == "" {
+		download.Path = filepath.Join(dir, download.GUID)
+	}
+	download.Size = int64(progress.ReceivedBytes)
+	return download, nil
+}
+
+// hasFrame reports whether frameID is the page of a target or one of its
+// frames.
+func (c *Client) hasFrame(ctx context.Context, targetID string, frameID string) (bool, error) {
+	// A page's main frame has the page's ID
+	if frameID == targetID {
+		return true, nil
+	}
+	frames, err := c.GetFrames(ctx, targetID)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(frames, func(f FrameInfo) bool { return f.ID == frameID }), nil
+}
@@ -197,6 +197,36 @@ func (c *Client) Emulate(ctx context.Context, targetID string, device DeviceInfo
 	return nil
 }
 
+// ClearEmulation removes device metrics, user agent, geolocation, media and
+// network condition overrides from the specified target.
+func (c *Client) ClearEmulation(ctx context.Context, targetID string) error {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	calls := []struct {
+		method string
+		params map[string]interface{}
+	}{
+		{"Emulation.clearDeviceMetricsOverride", nil},
+		{"Emulation.setUserAgentOverride", map[string]interface{}{"userAgent": ""}},
+		{"Emulation.clearGeolocationOverride", nil},
+		{"Emulation.setEmulatedMedia", map[string]interface{}{"features": []interface{}{}}},
+	}
+	for _, call := range calls {
+		var params interface{}
+		if call.params != nil {
+			params = call.params
+		}
+		if _, err := c.CallSession(ctx, sessionID, call.method, params); err != nil {
+			return fmt.Errorf("clearing emulation: %w", err)
+		}
+	}
+
+	return c.SetOfflineMode(ctx, targetID, false)
+}
+
 // HandleDialog sets up automatic dialog handling.
 // action can be "accept" or "dismiss".
 // promptText is the text to enter for prompts (optional).
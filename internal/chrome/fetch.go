@@ -0,0 +1,185 @@
+package chrome
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// headerEntry is a single HTTP header as used by the Fetch domain.
+type headerEntry struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// pausedRequest is the payload of a Fetch.requestPaused event.
+type pausedRequest struct {
+	RequestID    string `json:"requestId"`
+	ResourceType string `json:"resourceType"`
+	Request      struct {
+		URL             string            `json:"url"`
+		Method          string            `json:"method"`
+		Headers         map[string]string `json:"headers"`
+		PostData        string            `json:"postData"`
+		PostDataEntries []struct {
+			Bytes string `json:"bytes"`
+		} `json:"postDataEntries"`
+	} `json:"request"`
+	ResponseStatusCode  int           `json:"responseStatusCode"`
+	ResponseErrorReason string        `json:"responseErrorReason"`
+	ResponseHeaders     []headerEntry `json:"responseHeaders"`
+}
+
+// atResponse reports whether the request was paused at the response stage.
+func (p *pausedRequest) atResponse() bool {
+	return p.ResponseStatusCode != 0 || p.ResponseErrorReason != ""
+}
+
+// body returns the request body, if any.
+func (p *pausedRequest) body() string {
+	if p.Request.PostData != "" {
+		return p.Request.PostData
+	}
+	var sb strings.Builder
+	for _, entry := range p.Request.PostDataEntries {
+		data, err := base64.StdEncoding.DecodeString(entry.Bytes)
+		if err != nil {
+			continue
+		}
+		sb.Write(data)
+	}
+	return sb.String()
+}
+
+// fetchErrorReasons are the network errors Fetch.failRequest accepts.
+var fetchErrorReasons = map[string]bool{
+	"Failed":               true,
+	"Aborted":              true,
+	"TimedOut":             true,
+	"AccessDenied":         true,
+	"ConnectionClosed":     true,
+	"ConnectionReset":      true,
+	"ConnectionRefused":    true,
+	"ConnectionAborted":    true,
+	"ConnectionFailed":     true,
+	"NameNotResolved":      true,
+	"InternetDisconnected": true,
+	"AddressUnreachable":   true,
+	"BlockedByClient":      true,
+	"BlockedByResponse":    true,
+}
+
+// continueRequest lets a paused request proceed unmodified.
+func (c *Client) continueRequest(ctx context.Context, sessionID, requestID string) error {
+	_, err := c.CallSession(ctx, sessionID, "Fetch.continueRequest", map[string]interface{}{
+		"requestId": requestID,
+	})
+	return err
+}
+
+// failRequest fails a paused request with a network error reason.
+func (c *Client) failRequest(ctx context.Context, sessionID, requestID, reason string) error {
+	_, err := c.CallSession(ctx, sessionID, "Fetch.failRequest", map[string]interface{}{
+		"requestId":   requestID,
+		"errorReason": reason,
+	})
+	return err
+}
+
+// fulfillRequest answers a paused request with the given response.
+func (c *Client) fulfillRequest(ctx context.Context, sessionID, requestID string, status int, headers []headerEntry, body []byte) error {
+	if headers == nil {
+		headers = []headerEntry{}
+	}
+	_, err := c.CallSession(ctx, sessionID, "Fetch.fulfillRequest", map[string]interface{}{
+		"requestId":       requestID,
+		"responseCode":    status,
+		"responseHeaders": headers,
+		"body":            base64.StdEncoding.EncodeToString(body),
+	})
+	return err
+}
+
+// getPausedResponseBody returns the body of a request paused at the response stage.
+func (c *Client) getPausedResponseBody(ctx context.Context, sessionID, requestID string) ([]byte, error) {
+	result, err := c.CallSession(ctx, sessionID, "Fetch.getResponseBody", map[string]interface{}{
+		"requestId": requestID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var body ResponseBodyResult
+	if err := json.Unmarshal(result, &body); err != nil {
+		return nil, err
+	}
+	if body.Base64Encoded {
+		return base64.StdEncoding.DecodeString(body.Body)
+	}
+	return []byte(body.Body), nil
+}
+
+// mergeHeaders returns original with each of overrides replacing any header of
+// the same name (case-insensitively) or, failing that, appended. The original
+// Content-Length and Content-Encoding are dropped, as Fetch.getResponseBody
+// returns the decoded body and Chrome recomputes the length when fulfilling.
+func mergeHeaders(original []headerEntry, overrides map[string]string) []headerEntry {
+	merged := make([]headerEntry, 0, len(original)+len(overrides))
+	for _, h := range original {
+		if strings.EqualFold(h.Name, "Content-Length") || strings.EqualFold(h.Name, "Content-Encoding") {
+			continue
+		}
+		if _, ok := lookupHeader(overrides, h.Name); ok {
+			continue
+		}
+		merged = append(merged, h)
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged = append(merged, headerEntry{Name: name, Value: overrides[name]})
+	}
+	return merged
+}
+
+// lookupHeader finds a header by name, ignoring case.
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// MatchGlob reports whether s matches a glob in which * matches any run of
+// characters and ? any one character.
+func MatchGlob(pattern, s string) bool {
+	return globRegexp(pattern).MatchString(s)
+}
+
+// globRegexp compiles a URL glob in which * matches any run of characters
+// and ? matches a single character.
+func globRegexp(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
@@ -0,0 +1,412 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// frameScope is the child frame that a target's DOM, input, wait and
+// JavaScript methods act on, set by SetFrame.
+type frameScope struct {
+	FrameID       string
+	SessionID     string  // Session of the process the frame is in: the page's or an out-of-process iframe's
+	ContextID     int64   // Execution context of the frame, or 0 for the main frame of SessionID
+	PageSessionID string  // Session of the page, which input is sent to
+	OffsetX       float64 // Position of SessionID's viewport in the page's
+	OffsetY       float64
+	OriginX       float64 // Position of the frame's viewport in the page's
+	OriginY       float64
+}
+
+// frameCandidate is a frame that SetFrame can select.
+type frameCandidate struct {
+	ID        string
+	Name      string
+	URL       string
+	SessionID string
+	Local     bool // Not the main frame of SessionID, so it needs its own execution context
+	OffsetX   float64
+	OffsetY   float64
+}
+
+// SetFrame scopes the DOM, input, wait and JavaScript methods of a target
+// to a child frame, given by its name, a glob matching its URL, or a
+// selector matching its iframe element in the page. Out-of-process iframes
+// are reached through Target.setAutoAttach, with input still sent to the
+// page at the frame's position.
+func (c *Client) SetFrame(ctx context.Context, targetID string, frame string) error {
+	pageSessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	frames, err := c.findFrames(ctx, pageSessionID, true, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	match, err := c.matchFrame(ctx, pageSessionID, frames, frame)
+	if err != nil {
+		return err
+	}
+
+	scope := &frameScope{
+		FrameID:       match.ID,
+		SessionID:     match.SessionID,
+		PageSessionID: pageSessionID,
+		OffsetX:       match.OffsetX,
+		OffsetY:       match.OffsetY,
+		OriginX:       match.OffsetX,
+		OriginY:       match.OffsetY,
+	}
+	if match.Local {
+		if scope.ContextID, err = c.frameContext(ctx, match.SessionID, match.ID); err != nil {
+			return err
+		}
+		x, y, err := c.frameOwnerOrigin(ctx, match.SessionID, match.ID)
+		if err != nil {
+			return err
+		}
+		scope.OriginX += x
+		scope.OriginY += y
+	}
+
+	c.sessionsMu.Lock()
+	c.frames[targetID] = scope
+	c.sessionsMu.Unlock()
+	return nil
+}
+
+// findFrames returns the child frames reachable from a session, followed
+// by those of its out-of-process iframes. offsetX and offsetY are the
+// position of the session's viewport in the page's.
+func (c *Client) findFrames(ctx context.Context, sessionID string, isPage bool, offsetX, offsetY float64) ([]frameCandidate, error) {
+	children, err := c.attachChildFrames(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.CallSession(ctx, sessionID, "Page.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling Page domain: %w", err)
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "Page.getFrameTree", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting frame tree: %w", err)
+	}
+
+	var resp struct {
+		FrameTree frameTreeNode `json:"frameTree"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing frame tree: %w", err)
+	}
+
+	var frames []frameCandidate
+	var walk func(node frameTreeNode, local bool)
+	walk = func(node frameTreeNode, local bool) {
+		if local && slices.ContainsFunc(children, func(child childFrame) bool { return child.FrameID == node.Frame.ID }) {
+			return
+		}
+		// The page itself is not a child frame
+		if local || !isPage {
+			frames = append(frames, frameCandidate{
+				ID:        node.Frame.ID,
+				Name:      node.Frame.Name,
+				URL:       node.Frame.URL,
+				SessionID: sessionID,
+				Local:     local,
+				OffsetX:   offsetX,
+				OffsetY:   offsetY,
+			})
+		}
+		for _, child := range node.ChildFrames {
+			walk(child, true)
+		}
+	}
+	walk(resp.FrameTree, false)
+
+	for _, child := range children {
+		x, y, err := c.frameOwnerOrigin(ctx, sessionID, child.FrameID)
+		if err != nil {
+			return nil, err
+		}
+		nested, err := c.findFrames(ctx, child.SessionID, false, offsetX+x, offsetY+y)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, nested...)
+	}
+	return frames, nil
+}
+
+// childFrame is an out-of-process iframe attached to by attachChildFrames.
+type childFrame struct {
+	FrameID   string
+	SessionID string
+}
+
+// attachChildFrames attaches to the out-of-process iframes of a session.
+func (c *Client) attachChildFrames(ctx context.Context, sessionID string) ([]childFrame, error) {
+	ch := c.subscribeEvent(sessionID, "Target.attachedToTarget")
+	defer c.unsubscribeEvent(sessionID, "Target.attachedToTarget", ch)
+
+	_, err := c.CallSession(ctx, sessionID, "Target.setAutoAttach", map[string]interface{}{
+		"autoAttach":             true,
+		"waitForDebuggerOnStart": false,
+		"flatten":                true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attaching to iframes: %w", err)
+	}
+
+	// Chrome reports the iframes that already exist before it responds
+	var children []childFrame
+	for {
+		select {
+		case params := <-ch:
+			var event struct {
+				SessionID  string `json:"sessionId"`
+				TargetInfo struct {
+					TargetID string `json:"targetId"`
+					Type     string `json:"type"`
+				} `json:"targetInfo"`
+			}
+			if err := json.Unmarshal(params, &event); err == nil && event.TargetInfo.Type == "iframe" {
+				children = append(children, childFrame{FrameID: event.TargetInfo.TargetID, SessionID: event.SessionID})
+			}
+		default:
+			return children, nil
+		}
+	}
+}
+
+// frameOwnerOrigin returns the position of the content box of a frame's
+// iframe element in the session's viewport.
+func (c *Client) frameOwnerOrigin(ctx context.Context, sessionID string, frameID string) (x, y float64, err error) {
+	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("enabling DOM domain: %w", err)
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "DOM.getFrameOwner", map[string]interface{}{
+		"frameId": frameID,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("getting frame owner: %w", err)
+	}
+
+	var ownerResp struct {
+		BackendNodeID int64 `json:"backendNodeId"`
+	}
+	if err := json.Unmarshal(result, &ownerResp); err != nil {
+		return 0, 0, fmt.Errorf("parsing frame owner: %w", err)
+	}
+
+	result, err = c.CallSession(ctx, sessionID, "DOM.getBoxModel", map[string]interface{}{
+		"backendNodeId": ownerResp.BackendNodeID,
+	})
+	if err != nil {
+		// A hidden iframe has no box, and no input can reach it
+		return 0, 0, nil
+	}
+
+	var boxResp struct {
+		Model struct {
+			Content []float64 `json:"content"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(result, &boxResp); err != nil {
+		return 0, 0, fmt.Errorf("parsing box model response: %w", err)
+	}
+	if len(boxResp.Model.Content) < 2 {
+		return 0, 0, nil
+	}
+	return boxResp.Model.Content[0], boxResp.Model.Content[1], nil
+}
+
+// matchFrame finds the frame with the given name, else the first frame
+// whose URL matches it as a glob, else the frame of the first iframe
+// element in the page matching it as a selector.
+func (c *Client) matchFrame(ctx context.Context, pageSessionID string, frames []frameCandidate, frame string) (*frameCandidate, error) {
+	for i := range frames {
+		if frames[i].Name == frame {
+			return &frames[i], nil
+		}
+	}
+
+	glob := globRegexp(frame)
+	for i := range frames {
+		if glob.MatchString(frames[i].URL) {
+			return &frames[i], nil
+		}
+	}
+
+	// Anything that is not a valid selector simply matches no frame
+	elements, err := c.queryElements(ctx, pageSessionID, frame)
+	if err != nil || len(elements) == 0 {
+		return nil, fmt.Errorf("no frame matches %q", frame)
+	}
+	defer c.releaseObjects(pageSessionID, elements...)
+
+	result, err := c.CallSession(ctx, pageSessionID, "DOM.describeNode", map[string]interface{}{
+		"objectId": elements[0],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describing frame element: %w", err)
+	}
+
+	var descResp struct {
+		Node struct {
+			NodeName string `json:"nodeName"`
+			FrameID  string `json:"frameId"`
+		} `json:"node"`
+	}
+	if err := json.Unmarshal(result, &descResp); err != nil {
+		return nil, fmt.Errorf("parsing describe response: %w", err)
+	}
+	if descResp.Node.FrameID == "" {
+		return nil, fmt.Errorf("%s is not a frame element: %s", frame, descResp.Node.NodeName)
+	}
+
+	for i := range frames {
+		if frames[i].ID == descResp.Node.FrameID {
+			return &frames[i], nil
+		}
+	}
+	return nil, fmt.Errorf("frame of %s has not loaded", frame)
+}
+
+// frameContext returns the execution context of a frame's page scripts.
+// Chrome only reports contexts when Runtime is first enabled in a session,
+// so if that has already happened an isolated world is created in the
+// frame instead, as EvalInFrame does.
+func (c *Client) frameContext(ctx context.Context, sessionID string, frameID string) (int64, error) {
+	ch := c.subscribeEvent(sessionID, "Runtime.executionContextCreated")
+	defer c.unsubscribeEvent(sessionID, "Runtime.executionContextCreated", ch)
+
+	_, err := c.CallSession(ctx, sessionID, "Runtime.enable", nil)
+	if err != nil {
+		return 0, fmt.Errorf("enabling Runtime domain: %w", err)
+	}
+
+	for reported := true; reported; {
+		select {
+		case params := <-ch:
+			var event struct {
+				Context struct {
+					ID      int64 `json:"id"`
+					AuxData struct {
+						FrameID   string `json:"frameId"`
+						IsDefault bool   `json:"isDefault"`
+					} `json:"auxData"`
+				} `json:"context"`
+			}
+			if err := json.Unmarshal(params, &event); err == nil && event.Context.AuxData.FrameID == frameID && event.Context.AuxData.IsDefault {
+				return event.Context.ID, nil
+			}
+		default:
+			reported = false
+		}
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "Page.createIsolatedWorld", map[string]interface{}{
+		"frameId":   frameID,
+		"worldName": "hubcap",
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating isolated world: %w", err)
+	}
+
+	var worldResp struct {
+		ExecutionContextID int64 `json:"executionContextId"`
+	}
+	if err := json.Unmarshal(result, &worldResp); err != nil {
+		return 0, fmt.Errorf("parsing world response: %w", err)
+	}
+	return worldResp.ExecutionContextID, nil
+}
+
+// attachToFrame returns the session for a target's DOM and JavaScript
+// methods: that of the frame set by SetFrame, or else the page's.
+func (c *Client) attachToFrame(ctx context.Context, targetID string) (string, error) {
+	c.sessionsMu.Lock()
+	scope := c.frames[targetID]
+	c.sessionsMu.Unlock()
+	if scope != nil {
+		return scope.SessionID, nil
+	}
+	return c.attachToTarget(ctx, targetID)
+}
+
+// frameOrigin returns the position of the viewport of the frame set by
+// SetFrame for a target in the page's viewport, or 0, 0 without one.
+func (c *Client) frameOrigin(targetID string) (x, y float64) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	if scope := c.frames[targetID]; scope != nil {
+		return scope.OriginX, scope.OriginY
+	}
+	return 0, 0
+}
+
+// scopeOf returns the frame set by SetFrame whose session is sessionID,
+// or nil.
+func (c *Client) scopeOf(sessionID string) *frameScope {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	for _, scope := range c.frames {
+		if scope.SessionID == sessionID {
+			return scope
+		}
+	}
+	return nil
+}
+
+// evaluate calls Runtime.evaluate in the session, within the frame set by
+// SetFrame if there is one.
+func (c *Client) evaluate(ctx context.Context, sessionID string, params map[string]interface{}) (json.RawMessage, error) {
+	if scope := c.scopeOf(sessionID); scope != nil && scope.ContextID != 0 {
+		params["contextId"] = scope.ContextID
+	}
+	return c.CallSession(ctx, sessionID, "Runtime.evaluate", params)
+}
+
+// callInput sends an Input command. For a frame set by SetFrame it goes to
+// the page, with coordinates moved from the frame's viewport to the page's.
+func (c *Client) callInput(ctx context.Context, sessionID string, method string, params map[string]interface{}) (json.RawMessage, error) {
+	scope := c.scopeOf(sessionID)
+	if scope == nil {
+		return c.CallSession(ctx, sessionID, method, params)
+	}
+	return c.CallSession(ctx, scope.PageSessionID, method, offsetInput(params, scope.OffsetX, scope.OffsetY))
+}
+
+// offsetInput returns a copy of Input command parameters with their x and y
+// coordinates, including those of touch points, moved by dx and dy.
+func offsetInput(params map[string]interface{}, dx, dy float64) map[string]interface{} {
+	if params == nil || (dx == 0 && dy == 0) {
+		return params
+	}
+	moved := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		moved[k] = v
+	}
+	if x, ok := params["x"].(float64); ok {
+		moved["x"] = x + dx
+	}
+	if y, ok := params["y"].(float64); ok {
+		moved["y"] = y + dy
+	}
+	if points, ok := params["touchPoints"].([]map[string]interface{}); ok {
+		movedPoints := make([]map[string]interface{}, len(points))
+		for i, point := range points {
+			movedPoints[i] = offsetInput(point, dx, dy)
+		}
+		moved["touchPoints"] = movedPoints
+	}
+	return moved
+}
@@ -0,0 +1,37 @@
+package chrome
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOffsetInput(t *testing.T) {
+	params := map[string]interface{}{
+		"type": "mousePressed",
+		"x":    10.0,
+		"y":    20.0,
+	}
+	got := offsetInput(params, 100, 50)
+	want := map[string]interface{}{"type": "mousePressed", "x": 110.0, "y": 70.0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("offsetInput = %v, want %v", got, want)
+	}
+	if params["x"] != 10.0 {
+		t.Errorf("offsetInput modified its argument: %v", params)
+	}
+
+	touch := map[string]interface{}{
+		"type":        "touchStart",
+		"touchPoints": []map[string]interface{}{{"x": 1.0, "y": 2.0}, {"x": 3.0, "y": 4.0}},
+	}
+	got = offsetInput(touch, 10, 10)
+	wantPoints := []map[string]interface{}{{"x": 11.0, "y": 12.0}, {"x": 13.0, "y": 14.0}}
+	if !reflect.DeepEqual(got["touchPoints"], wantPoints) {
+		t.Errorf("offsetInput touch points = %v, want %v", got["touchPoints"], wantPoints)
+	}
+
+	keys := map[string]interface{}{"type": "keyDown", "key": "a"}
+	if got := offsetInput(keys, 10, 10); !reflect.DeepEqual(got, keys) {
+		t.Errorf("offsetInput without coordinates = %v, want %v", got, keys)
+	}
+}
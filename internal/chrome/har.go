@@ -0,0 +1,799 @@
+package chrome
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resourceTiming is the CDP Network.ResourceTiming of a response. Offsets are
+// in milliseconds relative to RequestTime, which is in seconds; -1 means the
+// phase did not happen.
+type resourceTiming struct {
+	RequestTime       float64 `json:"requestTime"`
+	ProxyStart        float64 `json:"proxyStart"`
+	ProxyEnd          float64 `json:"proxyEnd"`
+	DNSStart          float64 `json:"dnsStart"`
+	DNSEnd            float64 `json:"dnsEnd"`
+	ConnectStart      float64 `json:"connectStart"`
+	ConnectEnd        float64 `json:"connectEnd"`
+	SSLStart          float64 `json:"sslStart"`
+	SSLEnd            float64 `json:"sslEnd"`
+	SendStart         float64 `json:"sendStart"`
+	SendEnd           float64 `json:"sendEnd"`
+	ReceiveHeadersEnd float64 `json:"receiveHeadersEnd"`
+}
+
+// networkResponse is the subset of a CDP Network.Response recorded in a HAR.
+type networkResponse struct {
+	URL               string            `json:"url"`
+	Status            int               `json:"status"`
+	StatusText        string            `json:"statusText"`
+	Headers           map[string]string `json:"headers"`
+	MimeType          string            `json:"mimeType"`
+	Protocol          string            `json:"protocol"`
+	RemoteIPAddress   string            `json:"remoteIPAddress"`
+	ConnectionID      float64           `json:"connectionId"`
+	EncodedDataLength float64           `json:"encodedDataLength"`
+	Timing            *resourceTiming   `json:"timing"`
+}
+
+// harRecord tracks one request/response exchange while capturing. A request
+// that is redirected produces one record per hop.
+type harRecord struct {
+	requestID   string
+	wallTime    float64 // Seconds since the epoch when the request was issued
+	issued      float64 // Monotonic seconds when the request was issued
+	method      string
+	url         string
+	headers     map[string]string
+	postData    string
+	hasPostData bool
+	response    *networkResponse
+	respondedAt float64 // Monotonic seconds when the response headers arrived
+	finishedAt  float64 // Monotonic seconds when loading finished or failed
+	redirectURL string
+	dataLength  int
+	encodedSize float64
+	errorText   string
+}
+
+// CaptureHAR captures network activity until opts.Until is met and returns it
+// as a HAR 1.2 log. Redirects are recorded as separate entries.
+func (c *Client) CaptureHAR(ctx context.Context, targetID string, opts HARCaptureOptions) (*HARLog, error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Network events are read from a single tap so that they are seen in the
+	// order Chrome sent them; per-method subscriptions would lose that order.
+	events := c.tapEvents()
+
+	defer func() {
+		c.untapEvents(events)
+		disableCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		c.CallSession(disableCtx, sessionID, "Network.disable", nil)
+	}()
+
+	// Enable Network domain
+	_, err = c.CallSession(ctx, sessionID, "Network.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling Network domain: %w", err)
+	}
+
+	stopCh := make(chan error, 1)
+	stopCtx, cancelStop := context.WithCancel(ctx)
+	defer cancelStop()
+	go func() {
+		stopCh <- c.waitStop(stopCtx, targetID, opts.Until)
+	}()
+
+	recorder := newHARRecorder(sessionID)
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				goto done
+			}
+			recorder.handle(ev)
+
+		case err := <-stopCh:
+			if err != nil {
+				return nil, err
+			}
+			// Take in events that arrived before the condition was met
+			for {
+				select {
+				case ev := <-events:
+					recorder.handle(ev)
+				default:
+					goto done
+				}
+			}
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case <-c.closeCh:
+			goto done
+		}
+	}
+
+done:
+	// Build HAR log
+	har := &HARLog{}
+	har.Log.Version = "1.2"
+	har.Log.Creator = HARCreator{Name: "hubcap", Version: "1.0"}
+	har.Log.Entries = make([]HAREntry, 0, len(recorder.records))
+
+	for _, r := range recorder.records {
+		entry := r.entry()
+
+		if r.hasPostData && r.postData == "" && r.redirectURL == "" {
+			if data, err := c.requestPostData(ctx, sessionID, r.requestID); err == nil {
+				entry.Request.PostData.Text = data
+				entry.Request.BodySize = len(data)
+			}
+		}
+
+		// Bodies are only available for the final hop of completed requests
+		if opts.Bodies && r.response != nil && r.redirectURL == "" && r.errorText == "" && r.finishedAt > 0 {
+			if body, err := c.responseBody(ctx, sessionID, r.requestID); err == nil {
+				entry.Response.Content.Size = len(body.data)
+				entry.Response.Content.Text = body.text
+				entry.Response.Content.Encoding = body.encoding
+			}
+		}
+
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+
+	return har, nil
+}
+
+// harRecorder builds harRecords from the network events of one session.
+type harRecorder struct {
+	sessionID string
+	records   []*harRecord
+	current   map[string]*harRecord // requestID -> latest hop
+}
+
+func newHARRecorder(sessionID string) *harRecorder {
+	return &harRecorder{
+		sessionID: sessionID,
+		current:   make(map[string]*harRecord),
+	}
+}
+
+// handle updates the records for a protocol event.
+func (r *harRecorder) handle(ev cdpEvent) {
+	if ev.SessionID != r.sessionID {
+		return
+	}
+
+	switch ev.Method {
+	case "Network.requestWillBeSent":
+		var event struct {
+			RequestID string  `json:"requestId"`
+			Timestamp float64 `json:"timestamp"`
+			WallTime  float64 `json:"wallTime"`
+			Request   struct {
+				URL         string            `json:"url"`
+				Method      string            `json:"method"`
+				Headers     map[string]string `json:"headers"`
+				PostData    string            `json:"postData"`
+				HasPostData bool              `json:"hasPostData"`
+			} `json:"request"`
+			RedirectResponse *networkResponse `json:"redirectResponse"`
+		}
+		if err := json.Unmarshal(ev.Params, &event); err != nil {
+			return
+		}
+		if prev, ok := r.current[event.RequestID]; ok && event.RedirectResponse != nil {
+			prev.response = event.RedirectResponse
+			prev.respondedAt = event.Timestamp
+			prev.finishedAt = event.Timestamp
+			prev.redirectURL = event.Request.URL
+			prev.encodedSize = event.RedirectResponse.EncodedDataLength
+		}
+		rec := &harRecord{
+			requestID:   event.RequestID,
+			wallTime:    event.WallTime,
+			issued:      event.Timestamp,
+			method:      event.Request.Method,
+			url:         event.Request.URL,
+			headers:     event.Request.Headers,
+			postData:    event.Request.PostData,
+			hasPostData: event.Request.HasPostData,
+		}
+		r.current[event.RequestID] = rec
+		r.records = append(r.records, rec)
+
+	case "Network.responseReceived":
+		var event struct {
+			RequestID string          `json:"requestId"`
+			Timestamp float64         `json:"timestamp"`
+			Response  networkResponse `json:"response"`
+		}
+		if err := json.Unmarshal(ev.Params, &event); err != nil {
+			return
+		}
+		if rec, ok := r.current[event.RequestID]; ok {
+			rec.response = &event.Response
+			rec.respondedAt = event.Timestamp
+		}
+
+	case "Network.dataReceived":
+		var event struct {
+			RequestID  string `json:"requestId"`
+			DataLength int    `json:"dataLength"`
+		}
+		if err := json.Unmarshal(ev.Params, &event); err != nil {
+			return
+		}
+		if rec, ok := r.current[event.RequestID]; ok {
+			rec.dataLength += event.DataLength
+		}
+
+	case "Network.loadingFinished":
+		var event struct {
+			RequestID         string  `json:"requestId"`
+			Timestamp         float64 `json:"timestamp"`
+			EncodedDataLength float64 `json:"encodedDataLength"`
+		}
+		if err := json.Unmarshal(ev.Params, &event); err != nil {
+			return
+		}
+		if rec, ok := r.current[event.RequestID]; ok {
+			rec.finishedAt = event.Timestamp
+			rec.encodedSize = event.EncodedDataLength
+		}
+
+	case "Network.loadingFailed":
+		var event struct {
+			RequestID string  `json:"requestId"`
+			Timestamp float64 `json:"timestamp"`
+			ErrorText string  `json:"errorText"`
+		}
+		if err := json.Unmarshal(ev.Params, &event); err != nil {
+			return
+		}
+		if rec, ok := r.current[event.RequestID]; ok {
+			rec.finishedAt = event.Timestamp
+			rec.errorText = event.ErrorText
+		}
+	}
+}
+
+// entry converts a captured exchange into a HAR entry.
+func (r *harRecord) entry() HAREntry {
+	started := time.Unix(0, int64(r.wallTime*float64(time.Second)))
+
+	entry := HAREntry{
+		StartedDateTime: started.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Request: HARRequest{
+			Method:      r.method,
+			URL:         r.url,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     requestCookies(r.headers),
+			Headers:     harHeaders(r.headers),
+			QueryString: harQueryString(r.url),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []HARCookie{},
+			Headers:     []HARHeader{},
+			Content:     HARContent{Size: r.dataLength},
+			RedirectURL: r.redirectURL,
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+
+	if r.hasPostData || r.postData != "" {
+		entry.Request.PostData = &HARPostData{
+			MimeType: headerValue(r.headers, "Content-Type"),
+			Text:     r.postData,
+		}
+		entry.Request.BodySize = len(r.postData)
+	}
+
+	if resp := r.response; resp != nil {
+		version := harHTTPVersion(resp.Protocol)
+		entry.Request.HTTPVersion = version
+		entry.Response.HTTPVersion = version
+		entry.Response.Status = resp.Status
+		entry.Response.StatusText = resp.StatusText
+		entry.Response.Cookies = responseCookies(resp.Headers)
+		entry.Response.Headers = harHeaders(resp.Headers)
+		entry.Response.Content.MimeType = resp.MimeType
+		if r.redirectURL == "" {
+			entry.Response.RedirectURL = headerValue(resp.Headers, "Location")
+		}
+		if r.encodedSize > 0 {
+			entry.Response.BodySize = int(r.encodedSize)
+		}
+		entry.ServerIPAddress = strings.Trim(resp.RemoteIPAddress, "[]")
+		if resp.ConnectionID > 0 {
+			entry.Connection = strconv.FormatFloat(resp.ConnectionID, 'f', -1, 64)
+		}
+	} else if r.errorText != "" {
+		entry.Response.StatusText = r.errorText
+	}
+
+	entry.Timings = r.timings()
+	for _, phase := range []float64{entry.Timings.Blocked, entry.Timings.DNS, entry.Timings.Connect, entry.Timings.Send, entry.Timings.Wait, entry.Timings.Receive} {
+		if phase > 0 {
+			entry.Time += phase
+		}
+	}
+	return entry
+}
+
+// timings splits the exchange into HAR timing phases the same way Chrome
+// DevTools does when exporting a HAR.
+func (r *harRecord) timings() HARTimings {
+	t := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if r.response == nil {
+		if r.finishedAt > r.issued {
+			t.Wait = toMillis(r.finishedAt - r.issued)
+		}
+		return t
+	}
+
+	timing := r.response.Timing
+	if timing == nil {
+		// Served without touching the network, e.g. from cache
+		t.Blocked = 0
+		t.Wait = math.Max(toMillis(r.respondedAt-r.issued), 0)
+		if r.finishedAt > r.respondedAt {
+			t.Receive = toMillis(r.finishedAt - r.respondedAt)
+		}
+		return t
+	}
+
+	// Time queued before the request started, plus time stalled before the
+	// first network phase
+	t.Blocked = math.Max(toMillis(timing.RequestTime-r.issued), 0)
+	blockedStart := leastNonNegative(timing.DNSStart, timing.ConnectStart, timing.SendStart)
+	if blockedStart > 0 {
+		t.Blocked += blockedStart
+	}
+	if blockedStart < 0 {
+		blockedStart = 0
+	}
+
+	dnsEnd := -1.0
+	if timing.DNSEnd >= 0 {
+		dnsEnd = timing.DNSEnd
+		t.DNS = timing.DNSEnd - blockedStart
+	}
+	sslEnd := -1.0
+	if timing.SSLEnd > 0 {
+		sslEnd = timing.SSLEnd
+		t.SSL = timing.SSLEnd - timing.SSLStart
+	}
+	connectEnd := -1.0
+	if timing.ConnectEnd >= 0 {
+		connectEnd = timing.ConnectEnd
+		start := leastNonNegative(dnsEnd, blockedStart)
+		if start < 0 {
+			start = 0
+		}
+		t.Connect = timing.ConnectEnd - start
+	}
+	sendEnd := 0.0
+	if timing.SendEnd >= 0 {
+		sendEnd = timing.SendEnd
+		t.Send = math.Max(timing.SendEnd-math.Max(math.Max(connectEnd, dnsEnd), blockedStart), 0)
+	}
+
+	highest := math.Max(math.Max(math.Max(sendEnd, connectEnd), math.Max(sslEnd, dnsEnd)), math.Max(blockedStart, 0))
+	waitEnd := timing.ReceiveHeadersEnd
+	if waitEnd <= 0 {
+		waitEnd = toMillis(r.respondedAt - timing.RequestTime)
+	}
+	t.Wait = math.Max(waitEnd-highest, 0)
+	if r.finishedAt > 0 {
+		t.Receive = math.Max(toMillis(r.finishedAt-timing.RequestTime)-waitEnd, 0)
+	}
+	return t
+}
+
+// toMillis converts a CDP duration in seconds to milliseconds, rounded to
+// the microsecond.
+func toMillis(seconds float64) float64 {
+	return math.Round(seconds*1e6) / 1e3
+}
+
+// leastNonNegative returns the smallest of values that is not negative, or -1.
+func leastNonNegative(values ...float64) float64 {
+	least := -1.0
+	for _, v := range values {
+		if v >= 0 && (least < 0 || v < least) {
+			least = v
+		}
+	}
+	return least
+}
+
+// harHTTPVersion converts a CDP protocol name to a HAR httpVersion.
+func harHTTPVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "h2":
+		return "HTTP/2"
+	case "h3", "http/2+quic/43":
+		return "HTTP/3"
+	case "":
+		return "HTTP/1.1"
+	default:
+		return strings.ToUpper(protocol)
+	}
+}
+
+// harHeaders converts CDP headers to HAR headers. Chrome joins repeated
+// headers, such as Set-Cookie, with newlines; they are split back apart.
+func harHeaders(headers map[string]string) []HARHeader {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]HARHeader, 0, len(headers))
+	for _, name := range names {
+		for _, value := range strings.Split(headers[name], "\n") {
+			result = append(result, HARHeader{Name: name, Value: value})
+		}
+	}
+	return result
+}
+
+// headerValue returns the first value of a header, ignoring case.
+func headerValue(headers map[string]string, name string) string {
+	value, _ := lookupHeader(headers, name)
+	if i := strings.IndexByte(value, '\n'); i >= 0 {
+		value = value[:i]
+	}
+	return value
+}
+
+// harQueryString parses the query parameters of a URL.
+func harQueryString(rawURL string) []HARQuery {
+	result := []HARQuery{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return result
+	}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		result = append(result, HARQuery{Name: name, Value: value})
+	}
+	return result
+}
+
+// requestCookies parses the Cookie header of a request.
+func requestCookies(headers map[string]string) []HARCookie {
+	result := []HARCookie{}
+	for _, line := range strings.Split(headerValue(headers, "Cookie"), ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		result = append(result, HARCookie{Name: name, Value: value})
+	}
+	return result
+}
+
+// responseCookies parses the Set-Cookie headers of a response.
+func responseCookies(headers map[string]string) []HARCookie {
+	result := []HARCookie{}
+	setCookie, ok := lookupHeader(headers, "Set-Cookie")
+	if !ok {
+		return result
+	}
+	for _, line := range strings.Split(setCookie, "\n") {
+		cookie, err := http.ParseSetCookie(line)
+		if err != nil {
+			continue
+		}
+		hc := HARCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			hc.Expires = cookie.Expires.UTC().Format(time.RFC3339)
+		}
+		result = append(result, hc)
+	}
+	return result
+}
+
+// capturedBody is a response body as returned by Network.getResponseBody.
+type capturedBody struct {
+	data     []byte
+	text     string
+	encoding string
+}
+
+// responseBody fetches the body of a captured response.
+func (c *Client) responseBody(ctx context.Context, sessionID, requestID string) (*capturedBody, error) {
+	result, err := c.CallSession(ctx, sessionID, "Network.getResponseBody", map[string]interface{}{
+		"requestId": requestID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ResponseBodyResult
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Base64Encoded {
+		data, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &capturedBody{data: data, text: resp.Body, encoding: "base64"}, nil
+	}
+	return &capturedBody{data: []byte(resp.Body), text: resp.Body}, nil
+}
+
+// requestPostData fetches a request body too large to be sent with
+// Network.requestWillBeSent.
+func (c *Client) requestPostData(ctx context.Context, sessionID, requestID string) (string, error) {
+	result, err := c.CallSession(ctx, sessionID, "Network.getRequestPostData", map[string]interface{}{
+		"requestId": requestID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		PostData string `json:"postData"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return "", err
+	}
+	return resp.PostData, nil
+}
+
+// harArchive looks up recorded entries for paused requests. Requests made
+// more than once are answered with successive matching entries, repeating
+// the last one when the recording runs out.
+type harArchive struct {
+	match   string
+	entries []HAREntry
+	byKey   map[string][]int
+	served  map[string]int
+}
+
+func newHARArchive(har *HARLog, match string) *harArchive {
+	a := &harArchive{
+		match:   match,
+		entries: har.Log.Entries,
+		byKey:   make(map[string][]int),
+		served:  make(map[string]int),
+	}
+	for i, entry := range har.Log.Entries {
+		var body string
+		if entry.Request.PostData != nil {
+			body = entry.Request.PostData.Text
+		}
+		key := a.key(entry.Request.Method, entry.Request.URL, body)
+		a.byKey[key] = append(a.byKey[key], i)
+	}
+	return a
+}
+
+// key builds the lookup key for a request according to the match strictness.
+func (a *harArchive) key(method, url, body string) string {
+	if i := strings.IndexByte(url, '#'); i >= 0 {
+		url = url[:i]
+	}
+	if a.match == "path" {
+		if i := strings.IndexByte(url, '?'); i >= 0 {
+			url = url[:i]
+		}
+	}
+	key := strings.ToUpper(method) + " " + url
+	if a.match == "strict" {
+		key += "\n" + body
+	}
+	return key
+}
+
+// lookup returns the index of the entry to replay for a request, or -1.
+func (a *harArchive) lookup(method, url, body string) int {
+	key := a.key(method, url, body)
+	indexes := a.byKey[key]
+	if len(indexes) == 0 {
+		return -1
+	}
+	n := a.served[key]
+	a.served[key] = n + 1
+	if n >= len(indexes) {
+		n = len(indexes) - 1
+	}
+	return indexes[n]
+}
+
+// ReplayHAR answers the target's requests from a recorded HAR log without
+// contacting the network. Requests not found in the archive are failed or
+// passed through according to opts.NotFound.
+// Returns a channel that receives a HARReplayEvent for every request and a
+// stop function. The stop function MUST be called when done to release
+// resources and stop interception. Requests wait while the channel is full,
+// so it should be read until then.
+func (c *Client) ReplayHAR(ctx context.Context, targetID string, har *HARLog, opts HARReplayOptions) (<-chan HARReplayEvent, func(), error) {
+	if opts.Match == "" {
+		opts.Match = "strict"
+	}
+	if opts.Match != "strict" && opts.Match != "url" && opts.Match != "path" {
+		return nil, nil, fmt.Errorf("invalid match %q (must be strict, url or path)", opts.Match)
+	}
+	if opts.NotFound == "" {
+		opts.NotFound = "abort"
+	}
+	if opts.NotFound != "abort" && opts.NotFound != "passthrough" {
+		return nil, nil, fmt.Errorf("invalid not-found policy %q (must be abort or passthrough)", opts.NotFound)
+	}
+
+	archive := newHARArchive(har, opts.Match)
+
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Subscribe before enabling so no paused request is missed
+	eventCh := c.subscribeEvent(sessionID, "Fetch.requestPaused")
+
+	_, err = c.CallSession(ctx, sessionID, "Fetch.enable", map[string]interface{}{
+		"patterns": []map[string]interface{}{
+			{"urlPattern": "*", "requestStage": "Request"},
+		},
+	})
+	if err != nil {
+		c.unsubscribeEvent(sessionID, "Fetch.requestPaused", eventCh)
+		return nil, nil, fmt.Errorf("enabling fetch: %w", err)
+	}
+
+	output := make(chan HARReplayEvent, 100)
+	done := make(chan struct{})
+	var stopOnce sync.Once
+
+	stop := func() {
+		stopOnce.Do(func() {
+			close(done)
+			c.unsubscribeEvent(sessionID, "Fetch.requestPaused", eventCh)
+			disableCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			c.CallSession(disableCtx, sessionID, "Fetch.disable", nil)
+		})
+	}
+
+	go func() {
+		defer close(output)
+		for {
+			select {
+			case params, ok := <-eventCh:
+				if !ok {
+					return
+				}
+				var paused pausedRequest
+				if err := json.Unmarshal(params, &paused); err != nil {
+					continue
+				}
+				ev := c.replayRequest(context.Background(), sessionID, &paused, archive, opts.NotFound)
+				select {
+				case output <- ev:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			case <-c.closeCh:
+				return
+			}
+		}
+	}()
+
+	return output, stop, nil
+}
+
+// replayRequest answers a single paused request from the archive.
+func (c *Client) replayRequest(ctx context.Context, sessionID string, p *pausedRequest, archive *harArchive, notFound string) HARReplayEvent {
+	ev := HARReplayEvent{URL: p.Request.URL, Method: p.Request.Method}
+
+	var err error
+	index := archive.lookup(p.Request.Method, p.Request.URL, p.body())
+	switch {
+	case index < 0 && notFound == "passthrough":
+		ev.Action = "passthrough"
+		err = c.continueRequest(ctx, sessionID, p.RequestID)
+
+	case index < 0:
+		ev.Action = "abort"
+		err = c.failRequest(ctx, sessionID, p.RequestID, "Failed")
+
+	default:
+		entry := archive.entries[index]
+		ev.Action = "replay"
+		ev.Entry = &index
+		ev.Status = entry.Response.Status
+		if ev.Status == 0 {
+			// The recorded request never got a response
+			err = c.failRequest(ctx, sessionID, p.RequestID, "Failed")
+			break
+		}
+		var body []byte
+		body, err = harContentBody(entry.Response.Content)
+		if err != nil {
+			c.failRequest(ctx, sessionID, p.RequestID, "Failed")
+			break
+		}
+		err = c.fulfillRequest(ctx, sessionID, p.RequestID, ev.Status, harResponseHeaders(entry.Response.Headers), body)
+	}
+
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev
+}
+
+// harContentBody decodes the recorded body of a response.
+func harContentBody(content HARContent) ([]byte, error) {
+	if content.Encoding == "base64" {
+		body, err := base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			return nil, fmt.Errorf("decoding recorded body: %w", err)
+		}
+		return body, nil
+	}
+	return []byte(content.Text), nil
+}
+
+// harResponseHeaders converts recorded headers for Fetch.fulfillRequest,
+// dropping HTTP/2 pseudo-headers and those describing the encoded body.
+// Values Chrome joined with newlines are split back into separate headers.
+func harResponseHeaders(headers []HARHeader) []headerEntry {
+	entries := make([]headerEntry, 0, len(headers))
+	for _, h := range headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		for _, value := range strings.Split(h.Value, "\n") {
+			entries = append(entries, headerEntry{Name: h.Name, Value: value})
+		}
+	}
+	return mergeHeaders(entries, nil)
+}
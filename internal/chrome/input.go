@@ -4,33 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
-var keyCodeMap = map[string]int{
-	"Enter":      13,
-	"Tab":        9,
-	"Escape":     27,
-	"Backspace":  8,
-	"Delete":     46,
-	"ArrowUp":    38,
-	"ArrowDown":  40,
-	"ArrowLeft":  37,
-	"ArrowRight": 39,
-	"Home":       36,
-	"End":        35,
-	"PageUp":     33,
-	"PageDown":   34,
-	"Space":      32,
-}
-
-// Click clicks on the first element matching a CSS selector.
+// Click clicks on the first element matching a selector.
 func (c *Client) Click(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	x, y, err := c.resolveElementCenter(ctx, sessionID, selector)
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, clickChecks)
 	if err != nil {
 		return err
 	}
@@ -50,18 +34,18 @@ func (c *Client) ClickAt(ctx context.Context, targetID string, x, y float64) err
 
 // DoubleClick double-clicks on an element specified by selector.
 func (c *Client) DoubleClick(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	x, y, err := c.resolveElementCenter(ctx, sessionID, selector)
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, clickChecks)
 	if err != nil {
 		return err
 	}
 
 	// Double-click: move, press(1), release(1), press(2), release(2)
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type": "mouseMoved",
 		"x":    x,
 		"y":    y,
@@ -71,7 +55,7 @@ func (c *Client) DoubleClick(ctx context.Context, targetID string, selector stri
 	}
 
 	for _, clickCount := range []int{1, 2} {
-		_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+		_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 			"type":       "mousePressed",
 			"x":          x,
 			"y":          y,
@@ -82,7 +66,7 @@ func (c *Client) DoubleClick(ctx context.Context, targetID string, selector stri
 			return fmt.Errorf("dispatching mousePressed (%d): %w", clickCount, err)
 		}
 
-		_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+		_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 			"type":       "mouseReleased",
 			"x":          x,
 			"y":          y,
@@ -99,12 +83,12 @@ func (c *Client) DoubleClick(ctx context.Context, targetID string, selector stri
 
 // RightClick right-clicks on an element specified by selector.
 func (c *Client) RightClick(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	x, y, err := c.resolveElementCenter(ctx, sessionID, selector)
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, clickChecks)
 	if err != nil {
 		return err
 	}
@@ -115,18 +99,18 @@ func (c *Client) RightClick(ctx context.Context, targetID string, selector strin
 // TripleClick triple-clicks on an element specified by selector.
 // This is typically used to select an entire paragraph.
 func (c *Client) TripleClick(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	x, y, err := c.resolveElementCenter(ctx, sessionID, selector)
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, clickChecks)
 	if err != nil {
 		return err
 	}
 
 	// Move mouse to element
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type": "mouseMoved",
 		"x":    x,
 		"y":    y,
@@ -136,7 +120,7 @@ func (c *Client) TripleClick(ctx context.Context, targetID string, selector stri
 	}
 
 	for _, clickCount := range []int{1, 2, 3} {
-		_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+		_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 			"type":       "mousePressed",
 			"x":          x,
 			"y":          y,
@@ -147,7 +131,7 @@ func (c *Client) TripleClick(ctx context.Context, targetID string, selector stri
 			return fmt.Errorf("dispatching mousePressed (%d): %w", clickCount, err)
 		}
 
-		_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+		_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 			"type":       "mouseReleased",
 			"x":          x,
 			"y":          y,
@@ -164,17 +148,17 @@ func (c *Client) TripleClick(ctx context.Context, targetID string, selector stri
 
 // Tap performs a touch tap on an element (like a finger tap on mobile).
 func (c *Client) Tap(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	x, y, err := c.resolveElementCenter(ctx, sessionID, selector)
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, clickChecks)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 		"type": "touchStart",
 		"touchPoints": []map[string]interface{}{
 			{"x": x, "y": y},
@@ -184,7 +168,7 @@ func (c *Client) Tap(ctx context.Context, targetID string, selector string) erro
 		return fmt.Errorf("dispatching touchStart: %w", err)
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 		"type":        "touchEnd",
 		"touchPoints": []map[string]interface{}{},
 	})
@@ -197,72 +181,18 @@ func (c *Client) Tap(ctx context.Context, targetID string, selector string) erro
 
 // Drag performs a drag from one element to another.
 func (c *Client) Drag(ctx context.Context, targetID string, sourceSelector, destSelector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
-	if err != nil {
-		return err
-	}
-
-	srcX, srcY, err := c.resolveElementCenter(ctx, sessionID, sourceSelector)
-	if err != nil {
-		return err
-	}
-	dstX, dstY, err := c.resolveElementCenter(ctx, sessionID, destSelector)
-	if err != nil {
-		return err
-	}
-
-	// Perform drag: move to source, press, move to dest, release
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
-		"type": "mouseMoved",
-		"x":    srcX,
-		"y":    srcY,
-	})
-	if err != nil {
-		return fmt.Errorf("moving to source: %w", err)
-	}
-
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
-		"type":       "mousePressed",
-		"x":          srcX,
-		"y":          srcY,
-		"button":     "left",
-		"clickCount": 1,
-	})
-	if err != nil {
-		return fmt.Errorf("pressing at source: %w", err)
-	}
-
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
-		"type": "mouseMoved",
-		"x":    dstX,
-		"y":    dstY,
-	})
-	if err != nil {
-		return fmt.Errorf("moving to destination: %w", err)
-	}
-
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
-		"type":       "mouseReleased",
-		"x":          dstX,
-		"y":          dstY,
-		"button":     "left",
-		"clickCount": 1,
-	})
-	if err != nil {
-		return fmt.Errorf("releasing at destination: %w", err)
-	}
-
-	return nil
+	_, err := c.DragWithOptions(ctx, targetID, sourceSelector, destSelector, DragOptions{})
+	return err
 }
 
 // Fill fills an input element with text.
 func (c *Client) Fill(ctx context.Context, targetID string, selector string, text string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	nodeID, err := c.resolveNodeID(ctx, sessionID, selector)
+	nodeID, _, _, err := c.waitActionable(ctx, sessionID, selector, fillChecks)
 	if err != nil {
 		return err
 	}
@@ -282,15 +212,13 @@ func (c *Client) Fill(ctx context.Context, targetID string, selector string, tex
 	}
 
 	// Clear the input value using JavaScript
-	_, err = c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression": fmt.Sprintf(`document.querySelector(%q).value = ''`, selector),
-	})
+	_, err = c.callOnSelector(ctx, sessionID, selector, `el => { el.value = ''; }`)
 	if err != nil {
 		return fmt.Errorf("clearing input value: %w", err)
 	}
 
 	// Insert the text
-	_, err = c.CallSession(ctx, sessionID, "Input.insertText", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.insertText", map[string]interface{}{
 		"text": text,
 	})
 	if err != nil {
@@ -309,13 +237,13 @@ func (c *Client) Clear(ctx context.Context, targetID string, selector string) er
 	}
 
 	// Select all and delete
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
 	// Ctrl+A to select all
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
 		"type":                  "keyDown",
 		"key":                   "a",
 		"modifiers":             2, // Ctrl
@@ -325,7 +253,7 @@ func (c *Client) Clear(ctx context.Context, targetID string, selector string) er
 		return fmt.Errorf("selecting all: %w", err)
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
 		"type":                  "keyUp",
 		"key":                   "a",
 		"modifiers":             2,
@@ -336,7 +264,7 @@ func (c *Client) Clear(ctx context.Context, targetID string, selector string) er
 	}
 
 	// Delete key to clear
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
 		"type":                  "keyDown",
 		"key":                   "Delete",
 		"windowsVirtualKeyCode": 46,
@@ -345,7 +273,7 @@ func (c *Client) Clear(ctx context.Context, targetID string, selector string) er
 		return fmt.Errorf("deleting: %w", err)
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
 		"type":                  "keyUp",
 		"key":                   "Delete",
 		"windowsVirtualKeyCode": 46,
@@ -357,165 +285,160 @@ func (c *Client) Clear(ctx context.Context, targetID string, selector string) er
 	return nil
 }
 
-// Type sends individual key events for each character in the text.
-// This is useful for inputs that need realistic typing (autocomplete, etc.).
+// Type sends key events for each character in the text, as typed on a US
+// keyboard. This is useful for inputs that need realistic typing
+// (autocomplete, etc.).
 func (c *Client) Type(ctx context.Context, targetID string, text string) error {
+	return c.TypeWithOptions(ctx, targetID, text, TypeOptions{})
+}
+
+// TypeWithOptions types text with a delay between characters or, with
+// opts.Insert, inserts it as an input method would, without key events.
+// In the text, \n is Enter, \t is Tab and \\ a backslash. Characters not on
+// a US keyboard, such as accented letters and emoji, are always inserted.
+func (c *Client) TypeWithOptions(ctx context.Context, targetID string, text string, opts TypeOptions) error {
 	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
 	// Enable Input domain
-	_, err = c.CallSession(ctx, sessionID, "Input.enable", nil)
+	_, err = c.callInput(ctx, sessionID, "Input.enable", nil)
 	if err != nil {
 		// Input.enable might not exist in all Chrome versions, continue anyway
 	}
 
-	// Process text with escape sequence support
-	i := 0
-	for i < len(text) {
-		if text[i] == '\\' && i+1 < len(text) {
-			next := text[i+1]
-			switch next {
-			case 'n':
-				// \n → Enter key
-				if err := c.typeSpecialKey(ctx, sessionID, "Enter", "\r", 13); err != nil {
-					return err
-				}
-				i += 2
-				continue
-			case 't':
-				// \t → Tab key
-				if err := c.typeSpecialKey(ctx, sessionID, "Tab", "", 9); err != nil {
-					return err
-				}
-				i += 2
-				continue
-			case '\\':
-				// \\ → literal backslash
-				if err := c.typeChar(ctx, sessionID, `\`); err != nil {
-					return err
-				}
-				i += 2
-				continue
+	chars := typedChars(text)
+	if opts.Insert && opts.Delay <= 0 {
+		return c.insertText(ctx, sessionID, strings.Join(chars, ""))
+	}
+
+	for i, char := range chars {
+		if i > 0 {
+			if err := sleepContext(ctx, opts.Delay); err != nil {
+				return err
 			}
 		}
 
-		// Regular character
-		charStr := string(text[i])
-		if err := c.typeChar(ctx, sessionID, charStr); err != nil {
+		k := typedKey(char)
+		if opts.Insert || k.Code == "" {
+			err = c.insertText(ctx, sessionID, char)
+		} else {
+			err = c.pressKeys(ctx, sessionID, []keyDefinition{k}, 0)
+		}
+		if err != nil {
 			return err
 		}
-		i++
 	}
 
 	return nil
 }
 
-// typeChar dispatches key events for a regular character.
-func (c *Client) typeChar(ctx context.Context, sessionID string, char string) error {
-	_, err := c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
-		"type": "keyDown",
-		"text": char,
-		"key":  char,
-	})
-	if err != nil {
-		return fmt.Errorf("keyDown for %q: %w", char, err)
-	}
-
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
-		"type": "keyUp",
-		"key":  char,
-	})
-	if err != nil {
-		return fmt.Errorf("keyUp for %q: %w", char, err)
+// typedChars splits text to type into characters, resolving the escape
+// sequences \n, \t and \\.
+func typedChars(text string) []string {
+	var chars []string
+	escapes := map[rune]string{'n': "\n", 't': "\t", '\\': `\`}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) {
+			if char, ok := escapes[runes[i+1]]; ok {
+				chars = append(chars, char)
+				i++
+				continue
+			}
+		}
+		chars = append(chars, string(runes[i]))
 	}
-	return nil
+	return chars
 }
 
-// typeSpecialKey dispatches key events for a special key (Enter, Tab, etc).
-func (c *Client) typeSpecialKey(ctx context.Context, sessionID string, key string, text string, keyCode int) error {
-	params := map[string]interface{}{
-		"type":                  "keyDown",
-		"key":                   key,
-		"windowsVirtualKeyCode": keyCode,
-		"nativeVirtualKeyCode":  keyCode,
-	}
-	if text != "" {
-		params["text"] = text
-	}
-	_, err := c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", params)
-	if err != nil {
-		return fmt.Errorf("keyDown for %q: %w", key, err)
-	}
+// typedKey returns the key that types a character.
+func typedKey(char string) keyDefinition {
+	switch char {
+	case "\n", "\r":
+		return keyDefinitions["Enter"]
+	case "\t":
+		return keyDefinitions["Tab"]
+	}
+	// A single character is always found
+	k, _ := lookupKey(char)
+	return k
+}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", map[string]interface{}{
-		"type":                  "keyUp",
-		"key":                   key,
-		"windowsVirtualKeyCode": keyCode,
-		"nativeVirtualKeyCode":  keyCode,
+// insertText inserts text at the focus without key events.
+func (c *Client) insertText(ctx context.Context, sessionID string, text string) error {
+	_, err := c.callInput(ctx, sessionID, "Input.insertText", map[string]interface{}{
+		"text": text,
 	})
 	if err != nil {
-		return fmt.Errorf("keyUp for %q: %w", key, err)
+		return fmt.Errorf("inserting %q: %w", text, err)
 	}
 	return nil
 }
 
-// PressKey presses a special key (Enter, Tab, Escape, etc.).
+// PressKey presses a key or a chord of keys such as "Control+Shift+K",
+// holding each key down in turn and letting them go in reverse order.
 func (c *Client) PressKey(ctx context.Context, targetID string, key string) error {
-	return c.PressKeyWithModifiers(ctx, targetID, key, KeyModifiers{})
+	return c.PressKeys(ctx, targetID, []string{key})
 }
 
-// PressKeyWithModifiers presses a key with modifier keys (Ctrl, Alt, Shift, Meta).
-func (c *Client) PressKeyWithModifiers(ctx context.Context, targetID string, key string, mods KeyModifiers) error {
+// PressKeys presses keys or chords one after the other. Keys are named by
+// their KeyboardEvent.key or KeyboardEvent.code values, such as "a",
+// "Enter", "F5", "KeyA" or "Numpad5"; all are checked before any is
+// pressed.
+func (c *Client) PressKeys(ctx context.Context, targetID string, chords []string) error {
+	parsed := make([][]keyDefinition, len(chords))
+	for i, chord := range chords {
+		keys, err := parseChord(chord)
+		if err != nil {
+			return err
+		}
+		parsed[i] = keys
+	}
+
 	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	// Get key code if available
-	keyCode, hasKeyCode := keyCodeMap[key]
-	modMask := mods.modifierBitmask()
-
-	params := map[string]interface{}{
-		"type":      "keyDown",
-		"key":       key,
-		"modifiers": modMask,
-	}
-	if hasKeyCode {
-		params["windowsVirtualKeyCode"] = keyCode
-		params["nativeVirtualKeyCode"] = keyCode
+	for _, keys := range parsed {
+		if err := c.pressKeys(ctx, sessionID, keys, 0); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// keyDown
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", params)
+// PressKeyWithModifiers presses a key with modifier keys (Ctrl, Alt, Shift, Meta)
+// held, without key events for the modifiers themselves.
+func (c *Client) PressKeyWithModifiers(ctx context.Context, targetID string, key string, mods KeyModifiers) error {
+	k, err := lookupKey(key)
 	if err != nil {
-		return fmt.Errorf("keyDown for %q: %w", key, err)
+		return err
 	}
 
-	// keyUp
-	params["type"] = "keyUp"
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchKeyEvent", params)
+	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
-		return fmt.Errorf("keyUp for %q: %w", key, err)
+		return err
 	}
 
-	return nil
+	return c.pressKeys(ctx, sessionID, []keyDefinition{k}, mods.modifierBitmask())
 }
 
 // Hover moves the mouse over an element specified by selector.
 func (c *Client) Hover(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	x, y, err := c.resolveElementCenter(ctx, sessionID, selector)
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, hoverChecks)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type": "mouseMoved",
 		"x":    x,
 		"y":    y,
@@ -529,7 +452,7 @@ func (c *Client) Hover(ctx context.Context, targetID string, selector string) er
 
 // Focus focuses on an element specified by selector.
 func (c *Client) Focus(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
@@ -539,7 +462,7 @@ func (c *Client) Focus(ctx context.Context, targetID string, selector string) er
 		return fmt.Errorf("enabling Runtime domain: %w", err)
 	}
 
-	nodeID, err := c.resolveNodeID(ctx, sessionID, selector)
+	nodeID, _, _, err := c.waitActionable(ctx, sessionID, selector, fillChecks)
 	if err != nil {
 		return err
 	}
@@ -556,12 +479,12 @@ func (c *Client) Focus(ctx context.Context, targetID string, selector string) er
 
 // Swipe performs a touch swipe gesture on an element.
 func (c *Client) Swipe(ctx context.Context, targetID string, selector string, direction string) (*SwipeResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	cx, cy, err := c.resolveElementCenter(ctx, sessionID, selector)
+	cx, cy, err := c.resolveElementCenter(ctx, sessionID, selector, hoverChecks)
 	if err != nil {
 		return nil, err
 	}
@@ -583,7 +506,7 @@ func (c *Client) Swipe(ctx context.Context, targetID string, selector string, di
 	}
 
 	// touchStart at center
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 		"type": "touchStart",
 		"touchPoints": []map[string]interface{}{
 			{"x": cx, "y": cy},
@@ -597,7 +520,7 @@ func (c *Client) Swipe(ctx context.Context, targetID string, selector string, di
 	steps := 5
 	for i := 1; i <= steps; i++ {
 		frac := float64(i) / float64(steps)
-		_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+		_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 			"type": "touchMove",
 			"touchPoints": []map[string]interface{}{
 				{"x": cx + dx*frac, "y": cy + dy*frac},
@@ -609,7 +532,7 @@ func (c *Client) Swipe(ctx context.Context, targetID string, selector string, di
 	}
 
 	// touchEnd
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 		"type":        "touchEnd",
 		"touchPoints": []map[string]interface{}{},
 	})
@@ -626,12 +549,12 @@ func (c *Client) Swipe(ctx context.Context, targetID string, selector string, di
 
 // Pinch performs a two-finger pinch gesture on an element.
 func (c *Client) Pinch(ctx context.Context, targetID string, selector string, direction string) (*PinchResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	cx, cy, err := c.resolveElementCenter(ctx, sessionID, selector)
+	cx, cy, err := c.resolveElementCenter(ctx, sessionID, selector, hoverChecks)
 	if err != nil {
 		return nil, err
 	}
@@ -648,7 +571,7 @@ func (c *Client) Pinch(ctx context.Context, targetID string, selector string, di
 	}
 
 	// touchStart with two fingers
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 		"type": "touchStart",
 		"touchPoints": []map[string]interface{}{
 			{"x": cx - startOffset, "y": cy},
@@ -664,7 +587,7 @@ func (c *Client) Pinch(ctx context.Context, targetID string, selector string, di
 	for i := 1; i <= steps; i++ {
 		frac := float64(i) / float64(steps)
 		offset := startOffset + (endOffset-startOffset)*frac
-		_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+		_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 			"type": "touchMove",
 			"touchPoints": []map[string]interface{}{
 				{"x": cx - offset, "y": cy},
@@ -677,7 +600,7 @@ func (c *Client) Pinch(ctx context.Context, targetID string, selector string, di
 	}
 
 	// touchEnd
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchTouchEvent", map[string]interface{}{
 		"type":        "touchEnd",
 		"touchPoints": []map[string]interface{}{},
 	})
@@ -699,7 +622,7 @@ func (c *Client) MouseMove(ctx context.Context, targetID string, x, y float64) (
 		return nil, err
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
+	_, err = c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", map[string]interface{}{
 		"type": "mouseMoved",
 		"x":    x,
 		"y":    y,
@@ -713,60 +636,69 @@ func (c *Client) MouseMove(ctx context.Context, targetID string, x, y float64) (
 
 // SelectOption selects an option in a <select> element by value.
 func (c *Client) SelectOption(ctx context.Context, targetID string, selector string, value string) error {
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el, value) {
 			if (!el) throw new Error('Element not found');
 			if (el.tagName !== 'SELECT') throw new Error('Element is not a select');
-			el.value = %q;
+			el.value = value;
 			el.dispatchEvent(new Event('change', { bubbles: true }));
 			return el.value;
-		})()
-	`, selector, value)
+		}
+	`
+
+	if err := c.waitEnabled(ctx, targetID, selector); err != nil {
+		return err
+	}
 
-	_, err := c.Eval(ctx, targetID, js)
+	_, err := c.evalOnSelector(ctx, targetID, selector, js, value)
 	return err
 }
 
 // Check checks a checkbox or radio button.
 func (c *Client) Check(ctx context.Context, targetID string, selector string) error {
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el) {
 			if (!el) throw new Error('Element not found');
 			if (!el.checked) {
 				el.checked = true;
 				el.dispatchEvent(new Event('change', { bubbles: true }));
 			}
 			return el.checked;
-		})()
-	`, selector)
+		}
+	`
 
-	_, err := c.Eval(ctx, targetID, js)
+	if err := c.waitEnabled(ctx, targetID, selector); err != nil {
+		return err
+	}
+
+	_, err := c.evalOnSelector(ctx, targetID, selector, js)
 	return err
 }
 
 // Uncheck unchecks a checkbox.
 func (c *Client) Uncheck(ctx context.Context, targetID string, selector string) error {
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el) {
 			if (!el) throw new Error('Element not found');
 			if (el.checked) {
 				el.checked = false;
 				el.dispatchEvent(new Event('change', { bubbles: true }));
 			}
 			return !el.checked;
-		})()
-	`, selector)
+		}
+	`
+
+	if err := c.waitEnabled(ctx, targetID, selector); err != nil {
+		return err
+	}
 
-	_, err := c.Eval(ctx, targetID, js)
+	_, err := c.evalOnSelector(ctx, targetID, selector, js)
 	return err
 }
 
 // UploadFile sets files for a file input element.
 func (c *Client) UploadFile(ctx context.Context, targetID string, selector string, files []string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
@@ -787,29 +719,75 @@ func (c *Client) UploadFile(ctx context.Context, targetID string, selector strin
 	return nil
 }
 
+// UploadOnChooser uploads files through a file chooser rather than a file
+// input found by selector, for upload widgets that open a hidden input's
+// chooser themselves. File choosers are intercepted while trigger runs, so
+// that none is shown, and the first to open is given the files. Fails if
+// trigger fails, or with ctx's error if no chooser opens before it is done.
+func (c *Client) UploadOnChooser(ctx context.Context, targetID string, files []string, trigger func() error) (*FileChooserResult, error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.CallSession(ctx, sessionID, "Page.enable", nil); err != nil {
+		return nil, fmt.Errorf("enabling page events: %w", err)
+	}
+
+	// Subscribe before intercepting so no chooser is missed
+	opened := c.subscribeEvent(sessionID, "Page.fileChooserOpened")
+	defer c.unsubscribeEvent(sessionID, "Page.fileChooserOpened", opened)
+	if _, err := c.CallSession(ctx, sessionID, "Page.setInterceptFileChooserDialog", map[string]interface{}{"enabled": true}); err != nil {
+		return nil, fmt.Errorf("intercepting file choosers: %w", err)
+	}
+	defer c.CallSession(context.WithoutCancel(ctx), sessionID, "Page.setInterceptFileChooserDialog", map[string]interface{}{"enabled": false})
+
+	if err := trigger(); err != nil {
+		return nil, err
+	}
+
+	var chooser FileChooserResult
+	select {
+	case params := <-opened:
+		if err := json.Unmarshal(params, &chooser); err != nil {
+			return nil, fmt.Errorf("parsing file chooser: %w", err)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if chooser.Mode == "selectSingle" && len(files) > 1 {
+		return nil, fmt.Errorf("the file chooser takes a single file, got %d", len(files))
+	}
+	_, err = c.CallSession(ctx, sessionID, "DOM.setFileInputFiles", map[string]interface{}{
+		"backendNodeId": chooser.BackendNodeID,
+		"files":         files,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("setting files: %w", err)
+	}
+	return &chooser, nil
+}
+
 // DispatchEvent dispatches a custom event on an element.
 func (c *Client) DispatchEvent(ctx context.Context, targetID string, selector string, eventType string) (*DispatchEventResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	jsExpr := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	jsFunc := `
+		function(el, eventType) {
 			if (!el) {
 				return {error: 'element not found'};
 			}
-			const event = new Event(%q, {bubbles: true, cancelable: true});
+			const event = new Event(eventType, {bubbles: true, cancelable: true});
 			el.dispatchEvent(event);
 			return {dispatched: true};
-		})()
-	`, selector, eventType)
+		}
+	`
 
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression":    jsExpr,
-		"returnByValue": true,
-	})
+	result, err := c.callOnSelector(ctx, sessionID, selector, jsFunc, eventType)
 	if err != nil {
 		return nil, fmt.Errorf("dispatching event: %w", err)
 	}
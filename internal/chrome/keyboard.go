@@ -0,0 +1,321 @@
+package chrome
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Modifier bits of Input.dispatchKeyEvent and Input.dispatchMouseEvent.
+const (
+	modifierAlt   = 1
+	modifierCtrl  = 2
+	modifierMeta  = 4
+	modifierShift = 8
+)
+
+// keyLocation values of KeyboardEvent.location.
+const (
+	locationStandard = 0
+	locationLeft     = 1
+	locationRight    = 2
+	locationNumpad   = 3
+)
+
+// keyDefinition describes a key of a US keyboard as the DOM reports it.
+type keyDefinition struct {
+	Key       string // KeyboardEvent.key
+	Code      string // KeyboardEvent.code
+	KeyCode   int    // Windows virtual key code, KeyboardEvent.keyCode
+	Text      string // Text the key types, if any
+	ShiftKey  string // KeyboardEvent.key with Shift held, for keys it changes
+	Location  int
+	Modifier  int // Modifier bit, for modifier keys
+	Printable bool
+}
+
+// keyAliases are other names accepted for keys.
+var keyAliases = map[string]string{
+	"ctrl":    "Control",
+	"cmd":     "Meta",
+	"command": "Meta",
+	"option":  "Alt",
+	"esc":     "Escape",
+	"return":  "Enter",
+	"space":   " ",
+	"del":     "Delete",
+	"up":      "ArrowUp",
+	"down":    "ArrowDown",
+	"left":    "ArrowLeft",
+	"right":   "ArrowRight",
+	"plus":    "+",
+}
+
+// keyDefinitions are the keys of a US keyboard, by KeyboardEvent.key and
+// KeyboardEvent.code, as the DOM's UI Events KeyboardEvent code and key
+// values specifications name them.
+var keyDefinitions = buildKeyDefinitions()
+
+// keyNames are keyDefinitions and keyAliases by lowercase name, for names
+// of more than a character.
+var keyNames = buildKeyNames()
+
+func buildKeyDefinitions() map[string]keyDefinition {
+	var keys []keyDefinition
+
+	// Printable keys and what Shift makes of them
+	for i := 0; i < 26; i++ {
+		lower := string(rune('a' + i))
+		keys = append(keys, keyDefinition{Key: lower, Code: "Key" + strings.ToUpper(lower), KeyCode: 'A' + i, ShiftKey: strings.ToUpper(lower)})
+	}
+	for i, shifted := range ")!@#$%^&*(" {
+		keys = append(keys, keyDefinition{Key: string(rune('0' + i)), Code: fmt.Sprintf("Digit%d", i), KeyCode: '0' + i, ShiftKey: string(shifted)})
+	}
+	for _, k := range []keyDefinition{
+		{Key: ";", Code: "Semicolon", KeyCode: 186, ShiftKey: ":"},
+		{Key: "=", Code: "Equal", KeyCode: 187, ShiftKey: "+"},
+		{Key: ",", Code: "Comma", KeyCode: 188, ShiftKey: "<"},
+		{Key: "-", Code: "Minus", KeyCode: 189, ShiftKey: "_"},
+		{Key: ".", Code: "Period", KeyCode: 190, ShiftKey: ">"},
+		{Key: "/", Code: "Slash", KeyCode: 191, ShiftKey: "?"},
+		{Key: "`", Code: "Backquote", KeyCode: 192, ShiftKey: "~"},
+		{Key: "[", Code: "BracketLeft", KeyCode: 219, ShiftKey: "{"},
+		{Key: `\`, Code: "Backslash", KeyCode: 220, ShiftKey: "|"},
+		{Key: "]", Code: "BracketRight", KeyCode: 221, ShiftKey: "}"},
+		{Key: "'", Code: "Quote", KeyCode: 222, ShiftKey: `"`},
+		{Key: " ", Code: "Space", KeyCode: 32},
+	} {
+		keys = append(keys, k)
+	}
+	for i := range keys {
+		keys[i].Printable = true
+	}
+
+	// Editing, whitespace and navigation
+	keys = append(keys,
+		keyDefinition{Key: "Enter", Code: "Enter", KeyCode: 13, Text: "\r"},
+		keyDefinition{Key: "Tab", Code: "Tab", KeyCode: 9},
+		keyDefinition{Key: "Backspace", Code: "Backspace", KeyCode: 8},
+		keyDefinition{Key: "Delete", Code: "Delete", KeyCode: 46},
+		keyDefinition{Key: "Insert", Code: "Insert", KeyCode: 45},
+		keyDefinition{Key: "Escape", Code: "Escape", KeyCode: 27},
+		keyDefinition{Key: "ArrowUp", Code: "ArrowUp", KeyCode: 38},
+		keyDefinition{Key: "ArrowDown", Code: "ArrowDown", KeyCode: 40},
+		keyDefinition{Key: "ArrowLeft", Code: "ArrowLeft", KeyCode: 37},
+		keyDefinition{Key: "ArrowRight", Code: "ArrowRight", KeyCode: 39},
+		keyDefinition{Key: "Home", Code: "Home", KeyCode: 36},
+		keyDefinition{Key: "End", Code: "End", KeyCode: 35},
+		keyDefinition{Key: "PageUp", Code: "PageUp", KeyCode: 33},
+		keyDefinition{Key: "PageDown", Code: "PageDown", KeyCode: 34},
+		keyDefinition{Key: "CapsLock", Code: "CapsLock", KeyCode: 20},
+		keyDefinition{Key: "NumLock", Code: "NumLock", KeyCode: 144},
+		keyDefinition{Key: "ScrollLock", Code: "ScrollLock", KeyCode: 145},
+		keyDefinition{Key: "Pause", Code: "Pause", KeyCode: 19},
+		keyDefinition{Key: "PrintScreen", Code: "PrintScreen", KeyCode: 44},
+		keyDefinition{Key: "ContextMenu", Code: "ContextMenu", KeyCode: 93},
+	)
+	for i := 1; i <= 24; i++ {
+		keys = append(keys, keyDefinition{Key: fmt.Sprintf("F%d", i), Code: fmt.Sprintf("F%d", i), KeyCode: 111 + i})
+	}
+
+	// Modifiers, the left ones first so that they are what their names
+	// press
+	keys = append(keys,
+		keyDefinition{Key: "Shift", Code: "ShiftLeft", KeyCode: 16, Location: locationLeft, Modifier: modifierShift},
+		keyDefinition{Key: "Control", Code: "ControlLeft", KeyCode: 17, Location: locationLeft, Modifier: modifierCtrl},
+		keyDefinition{Key: "Alt", Code: "AltLeft", KeyCode: 18, Location: locationLeft, Modifier: modifierAlt},
+		keyDefinition{Key: "Meta", Code: "MetaLeft", KeyCode: 91, Location: locationLeft, Modifier: modifierMeta},
+		keyDefinition{Key: "Shift", Code: "ShiftRight", KeyCode: 16, Location: locationRight, Modifier: modifierShift},
+		keyDefinition{Key: "Control", Code: "ControlRight", KeyCode: 17, Location: locationRight, Modifier: modifierCtrl},
+		keyDefinition{Key: "Alt", Code: "AltRight", KeyCode: 18, Location: locationRight, Modifier: modifierAlt},
+		keyDefinition{Key: "Meta", Code: "MetaRight", KeyCode: 92, Location: locationRight, Modifier: modifierMeta},
+	)
+
+	// The numeric keypad, with Num Lock on
+	for i := 0; i < 10; i++ {
+		keys = append(keys, keyDefinition{Key: fmt.Sprint(i), Code: fmt.Sprintf("Numpad%d", i), KeyCode: 96 + i, Location: locationNumpad, Printable: true})
+	}
+	keys = append(keys,
+		keyDefinition{Key: "*", Code: "NumpadMultiply", KeyCode: 106, Location: locationNumpad, Printable: true},
+		keyDefinition{Key: "+", Code: "NumpadAdd", KeyCode: 107, Location: locationNumpad, Printable: true},
+		keyDefinition{Key: "-", Code: "NumpadSubtract", KeyCode: 109, Location: locationNumpad, Printable: true},
+		keyDefinition{Key: ".", Code: "NumpadDecimal", KeyCode: 110, Location: locationNumpad, Printable: true},
+		keyDefinition{Key: "/", Code: "NumpadDivide", KeyCode: 111, Location: locationNumpad, Printable: true},
+		keyDefinition{Key: "Enter", Code: "NumpadEnter", KeyCode: 13, Text: "\r", Location: locationNumpad},
+	)
+
+	// Media and volume keys
+	keys = append(keys,
+		keyDefinition{Key: "AudioVolumeMute", Code: "AudioVolumeMute", KeyCode: 173},
+		keyDefinition{Key: "AudioVolumeDown", Code: "AudioVolumeDown", KeyCode: 174},
+		keyDefinition{Key: "AudioVolumeUp", Code: "AudioVolumeUp", KeyCode: 175},
+		keyDefinition{Key: "MediaTrackNext", Code: "MediaTrackNext", KeyCode: 176},
+		keyDefinition{Key: "MediaTrackPrevious", Code: "MediaTrackPrevious", KeyCode: 177},
+		keyDefinition{Key: "MediaStop", Code: "MediaStop", KeyCode: 178},
+		keyDefinition{Key: "MediaPlayPause", Code: "MediaPlayPause", KeyCode: 179},
+	)
+
+	defs := make(map[string]keyDefinition)
+	for _, k := range keys {
+		if k.Printable {
+			k.Text = k.Key
+		}
+		if _, ok := defs[k.Code]; !ok {
+			defs[k.Code] = k
+		}
+		// Names go to the first key with them, so digits and Enter are
+		// those of the main keyboard rather than the keypad
+		if _, ok := defs[k.Key]; !ok {
+			defs[k.Key] = k
+		}
+		if k.ShiftKey != "" {
+			if _, ok := defs[k.ShiftKey]; !ok {
+				shifted := k
+				shifted.Key, shifted.Text = k.ShiftKey, k.ShiftKey
+				defs[k.ShiftKey] = shifted
+			}
+		}
+	}
+	return defs
+}
+
+func buildKeyNames() map[string]string {
+	names := make(map[string]string)
+	for name := range keyDefinitions {
+		if utf8.RuneCountInString(name) > 1 {
+			names[strings.ToLower(name)] = name
+		}
+	}
+	for alias, name := range keyAliases {
+		names[alias] = name
+	}
+	return names
+}
+
+// lookupKey returns the definition of a key by its KeyboardEvent.key or
+// KeyboardEvent.code value or an alias, such as "a", "Enter", "F5",
+// "KeyA", "Numpad5" or "Ctrl". Names of more than a character are matched
+// regardless of case. Characters not on a US keyboard type themselves.
+func lookupKey(name string) (keyDefinition, error) {
+	if k, ok := keyDefinitions[name]; ok {
+		return k, nil
+	}
+	if utf8.RuneCountInString(name) > 1 {
+		if canonical, ok := keyNames[strings.ToLower(name)]; ok {
+			return keyDefinitions[canonical], nil
+		}
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		return keyDefinition{Key: name, Text: name}, nil
+	}
+	return keyDefinition{}, fmt.Errorf("unknown key %q", name)
+}
+
+// parseChord splits a chord such as "Control+Shift+K" into the keys to
+// hold down in turn. A "+" on its own or after another "+" is the plus key,
+// as in "Control++".
+func parseChord(chord string) ([]keyDefinition, error) {
+	if chord == "" {
+		return nil, fmt.Errorf("empty key")
+	}
+	var names []string
+	for rest := chord; rest != ""; {
+		i := strings.Index(rest[1:], "+")
+		if i < 0 {
+			names = append(names, rest)
+			break
+		}
+		names = append(names, rest[:i+1])
+		rest = rest[i+2:]
+		if rest == "" {
+			return nil, fmt.Errorf("invalid key %q: missing key after +", chord)
+		}
+	}
+
+	keys := make([]keyDefinition, len(names))
+	for i, name := range names {
+		k, err := lookupKey(name)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// keyEvent returns the Input.dispatchKeyEvent parameters for a key going
+// down or up with the given modifiers held.
+func keyEvent(k keyDefinition, down bool, modifiers int) map[string]interface{} {
+	key, text := k.Key, k.Text
+	if modifiers&modifierShift != 0 && k.ShiftKey != "" {
+		key, text = k.ShiftKey, k.ShiftKey
+	}
+	// Shortcuts with Control, Alt or Meta type nothing
+	if modifiers&^modifierShift != 0 {
+		text = ""
+	}
+
+	params := map[string]interface{}{
+		"type":      "keyUp",
+		"key":       key,
+		"modifiers": modifiers,
+		"location":  k.Location,
+	}
+	if k.Code != "" {
+		params["code"] = k.Code
+	}
+	if k.KeyCode != 0 {
+		params["windowsVirtualKeyCode"] = k.KeyCode
+		params["nativeVirtualKeyCode"] = k.KeyCode
+	}
+	if down {
+		params["type"] = "rawKeyDown"
+		if text != "" {
+			params["type"] = "keyDown"
+			params["text"] = text
+			params["unmodifiedText"] = text
+		}
+	}
+	return params
+}
+
+// pressKeys holds down each key in turn, with the modifiers before it and
+// those given, then lets them go in reverse order.
+func (c *Client) pressKeys(ctx context.Context, sessionID string, keys []keyDefinition, modifiers int) error {
+	held := modifiers
+	for i, k := range keys {
+		held |= k.Modifier
+		if _, err := c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", keyEvent(k, true, held)); err != nil {
+			// Let go of the keys already down
+			for j := i - 1; j >= 0; j-- {
+				held &^= keys[j].Modifier
+				c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", keyEvent(keys[j], false, held))
+			}
+			return fmt.Errorf("keyDown for %q: %w", k.Key, err)
+		}
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		held &^= keys[i].Modifier
+		if _, err := c.callInput(ctx, sessionID, "Input.dispatchKeyEvent", keyEvent(keys[i], false, held)); err != nil {
+			return fmt.Errorf("keyUp for %q: %w", keys[i].Key, err)
+		}
+	}
+	return nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package chrome
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLookupKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		code     string
+		keyCode  int
+		text     string
+		location int
+	}{
+		{"a", "a", "KeyA", 65, "a", 0},
+		{"A", "A", "KeyA", 65, "A", 0},
+		{"KeyA", "a", "KeyA", 65, "a", 0},
+		{"!", "!", "Digit1", 49, "!", 0},
+		{"7", "7", "Digit7", 55, "7", 0},
+		{"Numpad7", "7", "Numpad7", 103, "7", locationNumpad},
+		{"+", "+", "Equal", 187, "+", 0},
+		{" ", " ", "Space", 32, " ", 0},
+		{"Space", " ", "Space", 32, " ", 0},
+		{"Enter", "Enter", "Enter", 13, "\r", 0},
+		{"NumpadEnter", "Enter", "NumpadEnter", 13, "\r", locationNumpad},
+		{"enter", "Enter", "Enter", 13, "\r", 0},
+		{"Esc", "Escape", "Escape", 27, "", 0},
+		{"F5", "F5", "F5", 116, "", 0},
+		{"F24", "F24", "F24", 135, "", 0},
+		{"pageup", "PageUp", "PageUp", 33, "", 0},
+		{"Ctrl", "Control", "ControlLeft", 17, "", locationLeft},
+		{"Cmd", "Meta", "MetaLeft", 91, "", locationLeft},
+		{"ShiftRight", "Shift", "ShiftRight", 16, "", locationRight},
+		{"MediaPlayPause", "MediaPlayPause", "MediaPlayPause", 179, "", 0},
+		{"é", "é", "", 0, "é", 0},
+	}
+	for _, tt := range tests {
+		k, err := lookupKey(tt.name)
+		if err != nil {
+			t.Errorf("lookupKey(%q): %v", tt.name, err)
+			continue
+		}
+		if k.Key != tt.key || k.Code != tt.code || k.KeyCode != tt.keyCode || k.Text != tt.text || k.Location != tt.location {
+			t.Errorf("lookupKey(%q) = %+v", tt.name, k)
+		}
+	}
+
+	if _, err := lookupKey("Hyper"); err == nil || err.Error() != `unknown key "Hyper"` {
+		t.Errorf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestParseChord(t *testing.T) {
+	tests := []struct {
+		chord string
+		want  []string
+	}{
+		{"K", []string{"K"}},
+		{"Control+Shift+K", []string{"Control", "Shift", "K"}},
+		{"ctrl+a", []string{"Control", "a"}},
+		{"Meta+A", []string{"Meta", "A"}},
+		{"+", []string{"+"}},
+		{"Control++", []string{"Control", "+"}},
+		{"Shift+F10", []string{"Shift", "F10"}},
+	}
+	for _, tt := range tests {
+		keys, err := parseChord(tt.chord)
+		if err != nil {
+			t.Errorf("parseChord(%q): %v", tt.chord, err)
+			continue
+		}
+		var got []string
+		for _, k := range keys {
+			got = append(got, k.Key)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseChord(%q) = %q, want %q", tt.chord, got, tt.want)
+		}
+	}
+
+	for _, chord := range []string{"", "Control+", "Control+Hyper"} {
+		if _, err := parseChord(chord); err == nil {
+			t.Errorf("parseChord(%q): expected an error", chord)
+		}
+	}
+}
+
+func TestKeyEvent(t *testing.T) {
+	a := keyDefinitions["a"]
+	tests := []struct {
+		name      string
+		key       keyDefinition
+		down      bool
+		modifiers int
+		want      map[string]interface{}
+	}{
+		{"typing", a, true, 0, map[string]interface{}{
+			"type": "keyDown", "key": "a", "code": "KeyA", "text": "a", "unmodifiedText": "a",
+			"windowsVirtualKeyCode": 65, "nativeVirtualKeyCode": 65, "modifiers": 0, "location": 0,
+		}},
+		{"shifted", a, true, modifierShift, map[string]interface{}{
+			"type": "keyDown", "key": "A", "code": "KeyA", "text": "A", "unmodifiedText": "A",
+			"windowsVirtualKeyCode": 65, "nativeVirtualKeyCode": 65, "modifiers": modifierShift, "location": 0,
+		}},
+		{"shortcut", a, true, modifierCtrl | modifierShift, map[string]interface{}{
+			"type": "rawKeyDown", "key": "A", "code": "KeyA",
+			"windowsVirtualKeyCode": 65, "nativeVirtualKeyCode": 65, "modifiers": modifierCtrl | modifierShift, "location": 0,
+		}},
+		{"release", a, false, modifierMeta, map[string]interface{}{
+			"type": "keyUp", "key": "a", "code": "KeyA",
+			"windowsVirtualKeyCode": 65, "nativeVirtualKeyCode": 65, "modifiers": modifierMeta, "location": 0,
+		}},
+		{"unmapped", keyDefinition{Key: "é", Text: "é"}, true, 0, map[string]interface{}{
+			"type": "keyDown", "key": "é", "text": "é", "unmodifiedText": "é", "modifiers": 0, "location": 0,
+		}},
+	}
+	for _, tt := range tests {
+		if got := keyEvent(tt.key, tt.down, tt.modifiers); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keyEvent() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTypedChars(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"ab", []string{"a", "b"}},
+		{`a\nb`, []string{"a", "\n", "b"}},
+		{`\t\\x`, []string{"\t", `\`, "x"}},
+		{`\q`, []string{`\`, "q"}},
+		{"é👍", []string{"é", "👍"}},
+	}
+	for _, tt := range tests {
+		if got := typedChars(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("typedChars(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestKeyModifiers_Bitmask(t *testing.T) {
+	mods := KeyModifiers{Ctrl: true, Shift: true}
+	if got := mods.modifierBitmask(); got != 10 {
+		t.Errorf("expected Control and Shift to be 2|8, got %d", got)
+	}
+	if got := (KeyModifiers{Alt: true, Meta: true}).modifierBitmask(); got != 5 {
+		t.Errorf("expected Alt and Meta to be 1|4, got %d", got)
+	}
+}
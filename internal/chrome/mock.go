@@ -0,0 +1,270 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compiledMockRule is a MockRule with its URL matcher prepared.
+type compiledMockRule struct {
+	MockRule
+	name string
+	url  *regexp.Regexp
+}
+
+// mockAction describes what a rule does to a matching request.
+func (r *compiledMockRule) action() string {
+	switch {
+	case r.Abort != "":
+		return "abort"
+	case r.Body != nil:
+		return "fulfill"
+	case r.Status != 0 || len(r.Headers) > 0:
+		return "modify"
+	default:
+		return "continue"
+	}
+}
+
+func (r *compiledMockRule) matches(p *pausedRequest) bool {
+	if r.url != nil && !r.url.MatchString(p.Request.URL) {
+		return false
+	}
+	if r.Method != "" && !strings.EqualFold(r.Method, p.Request.Method) {
+		return false
+	}
+	if r.ResourceType != "" && !strings.EqualFold(r.ResourceType, p.ResourceType) {
+		return false
+	}
+	if r.BodyContains != "" && !strings.Contains(p.body(), r.BodyContains) {
+		return false
+	}
+	return true
+}
+
+// compileMockRules validates rules and prepares their URL matchers.
+func compileMockRules(rules []MockRule) ([]*compiledMockRule, error) {
+	compiled := make([]*compiledMockRule, 0, len(rules))
+	for i, rule := range rules {
+		cr := &compiledMockRule{MockRule: rule, name: rule.Name}
+		if cr.name == "" {
+			cr.name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if rule.URL != "" && rule.URLRegex != "" {
+			return nil, fmt.Errorf("rule %s: url and urlRegex are mutually exclusive", cr.name)
+		}
+		if rule.URL != "" {
+			cr.url = globRegexp(rule.URL)
+		}
+		if rule.URLRegex != "" {
+			re, err := regexp.Compile(rule.URLRegex)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid urlRegex: %w", cr.name, err)
+			}
+			cr.url = re
+		}
+		if rule.Abort != "" && !fetchErrorReasons[rule.Abort] {
+			return nil, fmt.Errorf("rule %s: unknown abort reason %q", cr.name, rule.Abort)
+		}
+		if rule.Status != 0 && (rule.Status < 100 || rule.Status > 599) {
+			return nil, fmt.Errorf("rule %s: invalid status %d", cr.name, rule.Status)
+		}
+
+		compiled = append(compiled, cr)
+	}
+	return compiled, nil
+}
+
+// Mock intercepts the target's requests and answers those matching a rule.
+// The first matching rule wins; requests matching no rule proceed normally.
+// Rules with a body answer at the request stage without contacting the
+// server, while rules that only set a status or headers modify the real
+// response, keeping its body and any headers the rule does not override.
+// Those modify rules are tried again, on their own, once the response
+// arrives, so a request delayed by an earlier rule can still be modified.
+// Returns a channel that receives a MockEvent for every matched request and a
+// stop function. The stop function MUST be called when done to release
+// resources and stop interception. Matched requests wait while the channel
+// is full, so it should be read until then.
+func (c *Client) Mock(ctx context.Context, targetID string, rules []MockRule) (<-chan MockEvent, func(), error) {
+	compiled, err := compileMockRules(rules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patterns := []map[string]interface{}{
+		{"urlPattern": "*", "requestStage": "Request"},
+	}
+	for _, rule := range compiled {
+		if rule.action() == "modify" {
+			patterns = append(patterns, map[string]interface{}{"urlPattern": "*", "requestStage": "Response"})
+			break
+		}
+	}
+
+	// Subscribe before enabling so no paused request is missed
+	eventCh := c.subscribeEvent(sessionID, "Fetch.requestPaused")
+
+	_, err = c.CallSession(ctx, sessionID, "Fetch.enable", map[string]interface{}{
+		"patterns": patterns,
+	})
+	if err != nil {
+		c.unsubscribeEvent(sessionID, "Fetch.requestPaused", eventCh)
+		return nil, nil, fmt.Errorf("enabling fetch: %w", err)
+	}
+
+	output := make(chan MockEvent, 100)
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	var handlers sync.WaitGroup
+
+	// Requests are answered on a context that outlives ctx, so that
+	// requests paused just before stopping are still released
+	handleCtx, cancelHandlers := context.WithCancel(context.Background())
+
+	stop := func() {
+		stopOnce.Do(func() {
+			close(done)
+			c.unsubscribeEvent(sessionID, "Fetch.requestPaused", eventCh)
+			disableCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			c.CallSession(disableCtx, sessionID, "Fetch.disable", nil)
+			cancelHandlers()
+		})
+	}
+
+	emit := func(ev MockEvent) {
+		select {
+		case output <- ev:
+		case <-done:
+		}
+	}
+
+	go func() {
+		defer func() {
+			handlers.Wait()
+			close(output)
+		}()
+		for {
+			select {
+			case params, ok := <-eventCh:
+				if !ok {
+					return
+				}
+				var paused pausedRequest
+				if err := json.Unmarshal(params, &paused); err != nil {
+					continue
+				}
+				handlers.Add(1)
+				go func() {
+					defer handlers.Done()
+					if ev, ok := c.handleMockRequest(handleCtx, sessionID, &paused, compiled); ok {
+						emit(ev)
+					}
+				}()
+			case <-done:
+				return
+			case <-c.closeCh:
+				return
+			}
+		}
+	}()
+
+	return output, stop, nil
+}
+
+// handleMockRequest answers a single paused request. It reports false when no
+// rule matched or the request only paused on its way to the response stage.
+func (c *Client) handleMockRequest(ctx context.Context, sessionID string, p *pausedRequest, rules []*compiledMockRule) (MockEvent, bool) {
+	// Requests pause at the response stage only for modify rules, having
+	// already passed every other rule at the request stage
+	atResponse := p.atResponse()
+	var rule *compiledMockRule
+	for _, r := range rules {
+		if atResponse && r.action() != "modify" {
+			continue
+		}
+		if r.matches(p) {
+			rule = r
+			break
+		}
+	}
+	if rule == nil {
+		c.continueRequest(ctx, sessionID, p.RequestID)
+		return MockEvent{}, false
+	}
+
+	action := rule.action()
+	if action == "modify" && !atResponse {
+		// Let the request reach the server, the rule applies to the response
+		c.continueRequest(ctx, sessionID, p.RequestID)
+		return MockEvent{}, false
+	}
+
+	if rule.Delay > 0 {
+		select {
+		case <-time.After(rule.Delay):
+		case <-ctx.Done():
+			return MockEvent{}, false
+		}
+	}
+
+	ev := MockEvent{
+		Rule:         rule.name,
+		URL:          p.Request.URL,
+		Method:       p.Request.Method,
+		ResourceType: p.ResourceType,
+		Action:       action,
+	}
+
+	var err error
+	switch action {
+	case "abort":
+		ev.Reason = rule.Abort
+		err = c.failRequest(ctx, sessionID, p.RequestID, rule.Abort)
+
+	case "fulfill":
+		ev.Status = rule.Status
+		if ev.Status == 0 {
+			ev.Status = 200
+		}
+		err = c.fulfillRequest(ctx, sessionID, p.RequestID, ev.Status, mergeHeaders(nil, rule.Headers), rule.Body)
+
+	case "modify":
+		if p.ResponseErrorReason != "" {
+			err = c.continueRequest(ctx, sessionID, p.RequestID)
+			ev.Error = p.ResponseErrorReason
+			break
+		}
+		ev.Status = p.ResponseStatusCode
+		if rule.Status != 0 {
+			ev.Status = rule.Status
+		}
+		var body []byte
+		body, err = c.getPausedResponseBody(ctx, sessionID, p.RequestID)
+		if err != nil {
+			c.continueRequest(ctx, sessionID, p.RequestID)
+			break
+		}
+		err = c.fulfillRequest(ctx, sessionID, p.RequestID, ev.Status, mergeHeaders(p.ResponseHeaders, rule.Headers), body)
+
+	default:
+		err = c.continueRequest(ctx, sessionID, p.RequestID)
+	}
+
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	return ev, true
+}
@@ -0,0 +1,352 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Kinds of drag told apart by DragWithOptions.
+const (
+	DragHTML5   = "html5"   // The page started an HTML5 drag, finished with drag events
+	DragPointer = "pointer" // The page followed the mouse events themselves
+)
+
+// mouseButtons are the bits of MouseEvent.buttons, by button.
+var mouseButtons = map[string]int{
+	"left":    1,
+	"right":   2,
+	"middle":  4,
+	"back":    8,
+	"forward": 16,
+}
+
+// MouseButtonMask returns the bit of a mouse button in MouseEvent.buttons.
+func MouseButtonMask(button string) (int, error) {
+	if button == "" {
+		button = "left"
+	}
+	mask, ok := mouseButtons[button]
+	if !ok {
+		return 0, fmt.Errorf("invalid mouse button %q (use left, right, middle, back or forward)", button)
+	}
+	return mask, nil
+}
+
+// ParseKeyModifiers parses a list of modifier keys separated by commas or
+// +, such as "Shift,Control" or "Ctrl+Alt".
+func ParseKeyModifiers(s string) (KeyModifiers, error) {
+	var mods KeyModifiers
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '+' }) {
+		k, err := lookupKey(strings.TrimSpace(name))
+		if err != nil || k.Modifier == 0 {
+			return mods, fmt.Errorf("invalid modifier %q (use Shift, Control, Alt or Meta)", name)
+		}
+		switch k.Modifier {
+		case modifierAlt:
+			mods.Alt = true
+		case modifierCtrl:
+			mods.Ctrl = true
+		case modifierMeta:
+			mods.Meta = true
+		case modifierShift:
+			mods.Shift = true
+		}
+	}
+	return mods, nil
+}
+
+// MouseOptions configures a mouse event.
+type MouseOptions struct {
+	Button     string       // Button pressed or released: left (the default), right, middle, back or forward
+	ClickCount int          // Presses in quick succession, 2 for the second of a double click; 1 by default
+	Buttons    int          // Buttons held down before the event, as MouseEvent.buttons
+	Modifiers  KeyModifiers // Modifier keys held
+}
+
+// DragOptions configures a drag.
+type DragOptions struct {
+	Steps int // Mouse moves from the source to the destination; 1 by default
+}
+
+// mouseEvent returns the Input.dispatchMouseEvent parameters of a mouse
+// event at a point.
+func mouseEvent(eventType string, x, y float64, opts MouseOptions) map[string]interface{} {
+	params := map[string]interface{}{
+		"type":      eventType,
+		"x":         x,
+		"y":         y,
+		"buttons":   opts.Buttons,
+		"modifiers": opts.Modifiers.modifierBitmask(),
+	}
+	if eventType == "mousePressed" || eventType == "mouseReleased" {
+		button := opts.Button
+		if button == "" {
+			button = "left"
+		}
+		clickCount := opts.ClickCount
+		if clickCount < 1 {
+			clickCount = 1
+		}
+		params["button"] = button
+		params["clickCount"] = clickCount
+	}
+	return params
+}
+
+// interpolate returns the points of a move from one point to another in
+// steps, ending at the destination.
+func interpolate(fromX, fromY, x, y float64, steps int) [][2]float64 {
+	if steps < 1 {
+		steps = 1
+	}
+	points := make([][2]float64, steps)
+	for i := 1; i <= steps; i++ {
+		frac := float64(i) / float64(steps)
+		points[i-1] = [2]float64{fromX + (x-fromX)*frac, fromY + (y-fromY)*frac}
+	}
+	return points
+}
+
+// MouseDown presses a mouse button at a point in the page's viewport.
+func (c *Client) MouseDown(ctx context.Context, targetID string, x, y float64, opts MouseOptions) error {
+	mask, err := MouseButtonMask(opts.Button)
+	if err != nil {
+		return err
+	}
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mouseMoved", x, y, opts)); err != nil {
+		return fmt.Errorf("dispatching mouseMoved: %w", err)
+	}
+	opts.Buttons |= mask
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mousePressed", x, y, opts)); err != nil {
+		return fmt.Errorf("dispatching mousePressed: %w", err)
+	}
+	return nil
+}
+
+// MouseUp releases a mouse button at a point in the page's viewport.
+func (c *Client) MouseUp(ctx context.Context, targetID string, x, y float64, opts MouseOptions) error {
+	mask, err := MouseButtonMask(opts.Button)
+	if err != nil {
+		return err
+	}
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	opts.Buttons &^= mask
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mouseReleased", x, y, opts)); err != nil {
+		return fmt.Errorf("dispatching mouseReleased: %w", err)
+	}
+	return nil
+}
+
+// MouseMoveSteps moves the mouse from one point in the page's viewport to
+// another in steps, with opts.Buttons held.
+func (c *Client) MouseMoveSteps(ctx context.Context, targetID string, fromX, fromY, x, y float64, steps int, opts MouseOptions) error {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range interpolate(fromX, fromY, x, y, steps) {
+		if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mouseMoved", p[0], p[1], opts)); err != nil {
+			return fmt.Errorf("moving mouse: %w", err)
+		}
+	}
+	return nil
+}
+
+// MouseWheel scrolls the mouse wheel at a point in the page's viewport by
+// deltaX and deltaY CSS pixels.
+func (c *Client) MouseWheel(ctx context.Context, targetID string, x, y, deltaX, deltaY float64, opts MouseOptions) error {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	params := mouseEvent("mouseWheel", x, y, opts)
+	params["deltaX"] = deltaX
+	params["deltaY"] = deltaY
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", params); err != nil {
+		return fmt.Errorf("dispatching mouseWheel: %w", err)
+	}
+	return nil
+}
+
+// DragWithOptions drags one element onto another, pressing the left button
+// at the source's center, moving to the destination's in steps and
+// releasing it there. If the page starts an HTML5 drag, which Chrome does
+// not finish for synthetic mouse events, the drag is intercepted and
+// finished with drag events, so that libraries using either HTML5 drag and
+// drop or pointer events see a drag. Returns DragHTML5 or DragPointer.
+func (c *Client) DragWithOptions(ctx context.Context, targetID string, sourceSelector, destSelector string, opts DragOptions) (string, error) {
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return "", err
+	}
+
+	srcX, srcY, err := c.resolveElementCenter(ctx, sessionID, sourceSelector, hoverChecks)
+	if err != nil {
+		return "", err
+	}
+	dstX, dstY, err := c.resolveElementCenter(ctx, sessionID, destSelector, hoverChecks)
+	if err != nil {
+		return "", err
+	}
+
+	// Drags are intercepted on the page, even for a frame set by SetFrame
+	pageSessionID := sessionID
+	if scope := c.scopeOf(sessionID); scope != nil {
+		pageSessionID = scope.PageSessionID
+	}
+	intercepted := c.subscribeEvent(pageSessionID, "Input.dragIntercepted")
+	defer c.unsubscribeEvent(pageSessionID, "Input.dragIntercepted", intercepted)
+	if _, err := c.CallSession(ctx, pageSessionID, "Input.setInterceptDrags", map[string]interface{}{"enabled": true}); err != nil {
+		return "", fmt.Errorf("intercepting drags: %w", err)
+	}
+	defer c.CallSession(context.WithoutCancel(ctx), pageSessionID, "Input.setInterceptDrags", map[string]interface{}{"enabled": false})
+
+	held := MouseOptions{Buttons: mouseButtons["left"]}
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mouseMoved", srcX, srcY, MouseOptions{})); err != nil {
+		return "", fmt.Errorf("moving to source: %w", err)
+	}
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mousePressed", srcX, srcY, held)); err != nil {
+		return "", fmt.Errorf("pressing at source: %w", err)
+	}
+
+	var dragData json.RawMessage
+	points := interpolate(srcX, srcY, dstX, dstY, opts.Steps)
+	for _, p := range points {
+		if dragData != nil {
+			if err := c.dispatchDragEvent(ctx, sessionID, "dragOver", p[0], p[1], dragData); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mouseMoved", p[0], p[1], held)); err != nil {
+			return "", fmt.Errorf("moving to destination: %w", err)
+		}
+
+		// A drag the page starts is reported before the next frame. Pages
+		// may wait for the pointer to move some distance before starting one
+		if dragData, err = c.awaitDragIntercepted(ctx, sessionID, intercepted); err != nil {
+			return "", err
+		}
+		if dragData != nil {
+			if err := c.dispatchDragEvent(ctx, sessionID, "dragEnter", p[0], p[1], dragData); err != nil {
+				return "", err
+			}
+			if err := c.dispatchDragEvent(ctx, sessionID, "dragOver", p[0], p[1], dragData); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	kind := DragPointer
+	if dragData != nil {
+		if err := c.dispatchDragEvent(ctx, sessionID, "drop", dstX, dstY, dragData); err != nil {
+			return "", err
+		}
+		kind = DragHTML5
+	}
+
+	// The button is released after a drop too, so the page sees the end of
+	// the press that started the drag
+	if _, err := c.callInput(ctx, sessionID, "Input.dispatchMouseEvent", mouseEvent("mouseReleased", dstX, dstY, MouseOptions{})); err != nil {
+		return "", fmt.Errorf("releasing at destination: %w", err)
+	}
+	return kind, nil
+}
+
+// awaitDragIntercepted waits for the page to render a frame, then returns
+// the data of the drag it started, or nil if it did not start one.
+func (c *Client) awaitDragIntercepted(ctx context.Context, sessionID string, intercepted chan json.RawMessage) (json.RawMessage, error) {
+	_, err := c.evaluate(ctx, sessionID, map[string]interface{}{
+		"expression":   "new Promise(r => requestAnimationFrame(() => setTimeout(r, 0)))",
+		"awaitPromise": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("waiting for a frame: %w", err)
+	}
+
+	select {
+	case params := <-intercepted:
+		var event struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(params, &event); err != nil {
+			return nil, fmt.Errorf("parsing intercepted drag: %w", err)
+		}
+		return event.Data, nil
+	default:
+		return nil, nil
+	}
+}
+
+// dispatchDragEvent sends an intercepted drag's event at a point.
+func (c *Client) dispatchDragEvent(ctx context.Context, sessionID string, eventType string, x, y float64, data json.RawMessage) error {
+	_, err := c.callInput(ctx, sessionID, "Input.dispatchDragEvent", map[string]interface{}{
+		"type": eventType,
+		"x":    x,
+		"y":    y,
+		"data": data,
+	})
+	if err != nil {
+		return fmt.Errorf("dispatching %s: %w", eventType, err)
+	}
+	return nil
+}
+
+// DropFiles drops files onto an element as if dragged there from the
+// operating system, with dragenter, dragover and drop events at its center
+// whose dataTransfer holds the files. Paths must be absolute.
+func (c *Client) DropFiles(ctx context.Context, targetID string, selector string, files []string) error {
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	x, y, err := c.resolveElementCenter(ctx, sessionID, selector, hoverChecks)
+	if err != nil {
+		return err
+	}
+
+	// Any drop effect the page asks for is allowed: copy, link or move
+	data, err := json.Marshal(map[string]interface{}{
+		"items":              []interface{}{},
+		"files":              files,
+		"dragOperationsMask": 1 | 2 | 16,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling drag data: %w", err)
+	}
+	for _, eventType := range []string{"dragEnter", "dragOver", "drop"} {
+		if err := c.dispatchDragEvent(ctx, sessionID, eventType, x, y, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HeldMouseButtons returns the names of the buttons in a MouseEvent.buttons
+// mask, in the order of their bits.
+func HeldMouseButtons(mask int) []string {
+	var names []string
+	for name, bit := range mouseButtons {
+		if mask&bit != 0 {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int { return mouseButtons[a] - mouseButtons[b] })
+	return names
+}
@@ -0,0 +1,88 @@
+package chrome
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMouseButtonMask(t *testing.T) {
+	tests := []struct {
+		button string
+		want   int
+	}{
+		{"", 1},
+		{"left", 1},
+		{"right", 2},
+		{"middle", 4},
+		{"back", 8},
+		{"forward", 16},
+	}
+	for _, tt := range tests {
+		got, err := MouseButtonMask(tt.button)
+		if err != nil || got != tt.want {
+			t.Errorf("MouseButtonMask(%q) = %d, %v, want %d", tt.button, got, err, tt.want)
+		}
+	}
+	if _, err := MouseButtonMask("thumb"); err == nil {
+		t.Error("expected an error for an unknown button")
+	}
+}
+
+func TestHeldMouseButtons(t *testing.T) {
+	if got := HeldMouseButtons(0); got != nil {
+		t.Errorf("HeldMouseButtons(0) = %v, want none", got)
+	}
+	if got, want := HeldMouseButtons(1|4|16), []string{"left", "middle", "forward"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HeldMouseButtons(21) = %v, want %v", got, want)
+	}
+}
+
+func TestParseKeyModifiers(t *testing.T) {
+	tests := []struct {
+		s    string
+		want KeyModifiers
+	}{
+		{"", KeyModifiers{}},
+		{"Shift", KeyModifiers{Shift: true}},
+		{"Shift,Control", KeyModifiers{Shift: true, Ctrl: true}},
+		{"ctrl+alt", KeyModifiers{Ctrl: true, Alt: true}},
+		{"Cmd", KeyModifiers{Meta: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeyModifiers(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseKeyModifiers(%q) = %+v, %v, want %+v", tt.s, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"Shift,a", "Hyper"} {
+		if _, err := ParseKeyModifiers(s); err == nil {
+			t.Errorf("ParseKeyModifiers(%q): expected an error", s)
+		}
+	}
+}
+
+func TestMouseEvent(t *testing.T) {
+	got := mouseEvent("mousePressed", 10, 20, MouseOptions{Button: "right", Buttons: 2, Modifiers: KeyModifiers{Shift: true}})
+	want := map[string]interface{}{
+		"type": "mousePressed", "x": 10.0, "y": 20.0, "buttons": 2, "modifiers": modifierShift,
+		"button": "right", "clickCount": 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mouseEvent() = %v, want %v", got, want)
+	}
+
+	got = mouseEvent("mouseMoved", 1, 2, MouseOptions{Button: "left", Buttons: 1})
+	want = map[string]interface{}{"type": "mouseMoved", "x": 1.0, "y": 2.0, "buttons": 1, "modifiers": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mouseEvent() = %v, want %v", got, want)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	if got, want := interpolate(0, 0, 30, -60, 3), [][2]float64{{10, -20}, {20, -40}, {30, -60}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("interpolate() = %v, want %v", got, want)
+	}
+	if got, want := interpolate(5, 5, 9, 9, 0), [][2]float64{{9, 9}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("interpolate() with no steps = %v, want %v", got, want)
+	}
+}
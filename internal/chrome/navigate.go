@@ -40,12 +40,7 @@ func (c *Client) Targets(ctx context.Context) ([]TargetInfo, error) {
 	}
 
 	var resp struct {
-		TargetInfos []struct {
-			TargetID string `json:"targetId"`
-			Type     string `json:"type"`
-			Title    string `json:"title"`
-			URL      string `json:"url"`
-		} `json:"targetInfos"`
+		TargetInfos []targetInfo `json:"targetInfos"`
 	}
 	if err := json.Unmarshal(result, &resp); err != nil {
 		return nil, fmt.Errorf("unmarshaling targets: %w", err)
@@ -53,17 +48,34 @@ func (c *Client) Targets(ctx context.Context) ([]TargetInfo, error) {
 
 	targets := make([]TargetInfo, 0, len(resp.TargetInfos))
 	for _, t := range resp.TargetInfos {
-		targets = append(targets, TargetInfo{
-			ID:    t.TargetID,
-			Type:  t.Type,
-			Title: t.Title,
-			URL:   t.URL,
-		})
+		targets = append(targets, t.info())
 	}
 
 	return targets, nil
 }
 
+// targetInfo is a target as the Target domain describes it.
+type targetInfo struct {
+	TargetID string `json:"targetId"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	OpenerID string `json:"openerId"`
+
+	BrowserContextID string `json:"browserContextId"`
+}
+
+func (t targetInfo) info() TargetInfo {
+	return TargetInfo{
+		ID:               t.TargetID,
+		Type:             t.Type,
+		Title:            t.Title,
+		URL:              t.URL,
+		OpenerID:         t.OpenerID,
+		BrowserContextID: t.BrowserContextID,
+	}
+}
+
 // Pages returns only page targets (tabs).
 func (c *Client) Pages(ctx context.Context) ([]TargetInfo, error) {
 	targets, err := c.Targets(ctx)
@@ -306,15 +318,20 @@ func (c *Client) Reload(ctx context.Context, targetID string, ignoreCache bool)
 	return nil
 }
 
-// NewTab creates a new browser tab and returns its target ID.
+// NewTab creates a new browser tab, in the browser context set by
+// SetBrowserContext, and returns its target ID.
 func (c *Client) NewTab(ctx context.Context, url string) (string, error) {
 	if url == "" {
 		url = "about:blank"
 	}
 
-	result, err := c.Call(ctx, "Target.createTarget", map[string]interface{}{
+	params := map[string]interface{}{
 		"url": url,
-	})
+	}
+	if c.browserContext != "" {
+		params["browserContextId"] = c.browserContext
+	}
+	result, err := c.Call(ctx, "Target.createTarget", params)
 	if err != nil {
 		return "", fmt.Errorf("creating target: %w", err)
 	}
@@ -329,6 +346,96 @@ func (c *Client) NewTab(ctx context.Context, url string) (string, error) {
 	return resp.TargetID, nil
 }
 
+// popupURLWait is how long WaitForPopup waits for a popup opened on
+// about:blank to start loading its page.
+const popupURLWait = 2 * time.Second
+
+// WaitForPopup runs trigger, if not nil, then waits for the target to open a
+// page, such as with window.open or a link with target=_blank. Popups are
+// often opened on about:blank and then navigated, so it waits briefly for
+// the popup to have a URL. It returns an error if trigger does.
+func (c *Client) WaitForPopup(ctx context.Context, targetID string, trigger func() error) (*TargetInfo, error) {
+	createdCh := c.subscribeEvent("", "Target.targetCreated")
+	defer c.unsubscribeEvent("", "Target.targetCreated", createdCh)
+	changedCh := c.subscribeEvent("", "Target.targetInfoChanged")
+	defer c.unsubscribeEvent("", "Target.targetInfoChanged", changedCh)
+
+	// Discovery is left on, as turning it off would stop it for the other
+	// clients of a daemon too. Chrome only ends it when the browser
+	// connection closes, which for a daemon is when the daemon stops
+	if _, err := c.Call(ctx, "Target.setDiscoverTargets", map[string]interface{}{"discover": true}); err != nil {
+		return nil, fmt.Errorf("discovering targets: %w", err)
+	}
+
+	// Turning discovery on reports the targets that are already open, and
+	// an earlier popup of the same page is not the one being waited for
+	targets, err := c.Targets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting targets: %w", err)
+	}
+	existing := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		existing[t.ID] = true
+	}
+
+	if trigger != nil {
+		if err := trigger(); err != nil {
+			return nil, err
+		}
+	}
+
+	var popup *TargetInfo
+	// Latest info of targets that changed before the popup was seen
+	changed := map[string]TargetInfo{}
+	var urlWait <-chan time.Time
+	for {
+		if popup != nil && popup.URL != "" && popup.URL != "about:blank" {
+			return popup, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-urlWait:
+			return popup, nil
+		case params, ok := <-createdCh:
+			if !ok {
+				return nil, fmt.Errorf("event channel closed")
+			}
+			var event struct {
+				TargetInfo targetInfo `json:"targetInfo"`
+			}
+			if popup != nil || json.Unmarshal(params, &event) != nil {
+				continue
+			}
+			if event.TargetInfo.Type != "page" || event.TargetInfo.OpenerID != targetID || existing[event.TargetInfo.TargetID] {
+				continue
+			}
+			info := event.TargetInfo.info()
+			if latest, ok := changed[info.ID]; ok {
+				info = latest
+			}
+			popup = &info
+			urlWait = time.After(popupURLWait)
+		case params, ok := <-changedCh:
+			if !ok {
+				return nil, fmt.Errorf("event channel closed")
+			}
+			var event struct {
+				TargetInfo targetInfo `json:"targetInfo"`
+			}
+			if json.Unmarshal(params, &event) != nil {
+				continue
+			}
+			info := event.TargetInfo.info()
+			if popup != nil && info.ID == popup.ID {
+				popup = &info
+			} else if popup == nil {
+				changed[info.ID] = info
+			}
+		}
+	}
+}
+
 // CloseTab closes a browser tab by its target ID.
 func (c *Client) CloseTab(ctx context.Context, targetID string) error {
 	// Remove session from cache before closing
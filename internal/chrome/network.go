@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -289,180 +290,6 @@ func (c *Client) CaptureNetwork(ctx context.Context, targetID string) (<-chan Ne
 	return output, stop, nil
 }
 
-// CaptureHAR captures network activity and returns it as a HAR log.
-func (c *Client) CaptureHAR(ctx context.Context, targetID string, duration time.Duration) (*HARLog, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Enable Network domain
-	_, err = c.CallSession(ctx, sessionID, "Network.enable", nil)
-	if err != nil {
-		return nil, fmt.Errorf("enabling Network domain: %w", err)
-	}
-
-	// Subscribe to network events
-	requestCh := c.subscribeEvent(sessionID, "Network.requestWillBeSent")
-	responseCh := c.subscribeEvent(sessionID, "Network.responseReceived")
-	loadingFinishedCh := c.subscribeEvent(sessionID, "Network.loadingFinished")
-
-	defer func() {
-		c.unsubscribeEvent(sessionID, "Network.requestWillBeSent", requestCh)
-		c.unsubscribeEvent(sessionID, "Network.responseReceived", responseCh)
-		c.unsubscribeEvent(sessionID, "Network.loadingFinished", loadingFinishedCh)
-		disableCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		c.CallSession(disableCtx, sessionID, "Network.disable", nil)
-	}()
-
-	// Track requests and responses
-	type requestInfo struct {
-		startTime time.Time
-		method    string
-		url       string
-		headers   map[string]string
-	}
-	type responseInfo struct {
-		status   int
-		mimeType string
-		headers  map[string]string
-	}
-	requests := make(map[string]*requestInfo)
-	responses := make(map[string]*responseInfo)
-	timings := make(map[string]float64) // requestID -> duration in ms
-
-	timeout := time.After(duration)
-
-	for {
-		select {
-		case params, ok := <-requestCh:
-			if !ok {
-				goto done
-			}
-			var event struct {
-				RequestID string `json:"requestId"`
-				Timestamp float64 `json:"timestamp"`
-				Request   struct {
-					URL     string            `json:"url"`
-					Method  string            `json:"method"`
-					Headers map[string]string `json:"headers"`
-				} `json:"request"`
-			}
-			if err := json.Unmarshal(params, &event); err != nil {
-				continue
-			}
-			requests[event.RequestID] = &requestInfo{
-				startTime: time.Now(),
-				method:    event.Request.Method,
-				url:       event.Request.URL,
-				headers:   event.Request.Headers,
-			}
-
-		case params, ok := <-responseCh:
-			if !ok {
-				goto done
-			}
-			var event struct {
-				RequestID string `json:"requestId"`
-				Response  struct {
-					Status   int               `json:"status"`
-					MimeType string            `json:"mimeType"`
-					Headers  map[string]string `json:"headers"`
-				} `json:"response"`
-			}
-			if err := json.Unmarshal(params, &event); err != nil {
-				continue
-			}
-			responses[event.RequestID] = &responseInfo{
-				status:   event.Response.Status,
-				mimeType: event.Response.MimeType,
-				headers:  event.Response.Headers,
-			}
-
-		case params, ok := <-loadingFinishedCh:
-			if !ok {
-				goto done
-			}
-			var event struct {
-				RequestID string  `json:"requestId"`
-				Timestamp float64 `json:"timestamp"`
-			}
-			if err := json.Unmarshal(params, &event); err != nil {
-				continue
-			}
-			if req, ok := requests[event.RequestID]; ok {
-				timings[event.RequestID] = float64(time.Since(req.startTime).Milliseconds())
-			}
-
-		case <-timeout:
-			goto done
-
-		case <-ctx.Done():
-			return nil, ctx.Err()
-
-		case <-c.closeCh:
-			goto done
-		}
-	}
-
-done:
-	// Build HAR log
-	har := &HARLog{}
-	har.Log.Version = "1.2"
-	har.Log.Creator = HARCreator{Name: "hubcap", Version: "1.0"}
-	har.Log.Entries = make([]HAREntry, 0)
-
-	for requestID, req := range requests {
-		entry := HAREntry{
-			StartedDateTime: req.startTime.Format(time.RFC3339Nano),
-			Time:            timings[requestID],
-			Request: HARRequest{
-				Method:      req.method,
-				URL:         req.url,
-				HTTPVersion: "HTTP/1.1",
-				Headers:     make([]HARHeader, 0),
-				QueryString: make([]HARQuery, 0),
-				HeadersSize: -1,
-				BodySize:    -1,
-			},
-			Response: HARResponse{
-				Status:      0,
-				StatusText:  "",
-				HTTPVersion: "HTTP/1.1",
-				Headers:     make([]HARHeader, 0),
-				Content:     HARContent{Size: -1, MimeType: ""},
-				RedirectURL: "",
-				HeadersSize: -1,
-				BodySize:    -1,
-			},
-			Timings: HARTimings{
-				Send:    -1,
-				Wait:    -1,
-				Receive: -1,
-			},
-		}
-
-		// Add request headers
-		for name, value := range req.headers {
-			entry.Request.Headers = append(entry.Request.Headers, HARHeader{Name: name, Value: value})
-		}
-
-		// Add response if available
-		if resp, ok := responses[requestID]; ok {
-			entry.Response.Status = resp.status
-			entry.Response.Content.MimeType = resp.mimeType
-			for name, value := range resp.headers {
-				entry.Response.Headers = append(entry.Response.Headers, HARHeader{Name: name, Value: value})
-			}
-		}
-
-		har.Log.Entries = append(har.Log.Entries, entry)
-	}
-
-	return har, nil
-}
-
 // GetCoverage returns JavaScript code coverage data.
 func (c *Client) GetCoverage(ctx context.Context, targetID string) (*CoverageResult, error) {
 	sessionID, err := c.attachToTarget(ctx, targetID)
@@ -723,8 +550,8 @@ func (c *Client) EnableIntercept(ctx context.Context, targetID string, config In
 				Request           struct {
 					URL string `json:"url"`
 				} `json:"request"`
-				ResponseStatusCode int               `json:"responseStatusCode"`
-				ResponseHeaders    []json.RawMessage `json:"responseHeaders"`
+				ResponseStatusCode int           `json:"responseStatusCode"`
+				ResponseHeaders    []headerEntry `json:"responseHeaders"`
 			}
 			if err := json.Unmarshal(params, &event); err != nil {
 				continue
@@ -780,19 +607,8 @@ func (c *Client) EnableIntercept(ctx context.Context, targetID string, config In
 					statusCode = config.StatusCode
 				}
 
-				// Build response headers
-				responseHeaders := []map[string]string{}
-				// Keep original headers (simplified - in real impl would parse responseHeaders)
-				responseHeaders = append(responseHeaders, map[string]string{
-					"name":  "Content-Type",
-					"value": "text/html; charset=utf-8",
-				})
-				for name, value := range config.Headers {
-					responseHeaders = append(responseHeaders, map[string]string{
-						"name":  name,
-						"value": value,
-					})
-				}
+				// Keep original headers, replacing any that are overridden
+				responseHeaders := mergeHeaders(event.ResponseHeaders, config.Headers)
 
 				// Fulfill with modified response
 				c.CallSession(ctx, sessionID, "Fetch.fulfillRequest", map[string]interface{}{
@@ -962,6 +778,38 @@ func (c *Client) WaitForRequest(ctx context.Context, targetID string, pattern st
 
 // WaitForResponse waits for a network response with a URL containing the pattern.
 func (c *Client) WaitForResponse(ctx context.Context, targetID string, pattern string, timeout time.Duration) (*WaitResponseResult, error) {
+	match := func(url string, status int) bool { return strings.Contains(url, pattern) }
+	return c.waitResponse(ctx, targetID, match, fmt.Sprintf("response matching %q", pattern), timeout)
+}
+
+// WaitForStatus waits for a network response with a status matching the
+// pattern, either a code such as 404 or a class such as 5xx.
+func (c *Client) WaitForStatus(ctx context.Context, targetID string, pattern string, timeout time.Duration) (*WaitResponseResult, error) {
+	matchStatus, err := StatusMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+	match := func(url string, status int) bool { return matchStatus(status) }
+	return c.waitResponse(ctx, targetID, match, fmt.Sprintf("response with status %s", pattern), timeout)
+}
+
+// StatusMatcher returns a function reporting whether an HTTP status matches
+// the pattern, either a code such as 404 or a class such as 5xx.
+func StatusMatcher(pattern string) (func(status int) bool, error) {
+	if len(pattern) == 3 && pattern[0] >= '1' && pattern[0] <= '5' && strings.EqualFold(pattern[1:], "xx") {
+		class := int(pattern[0] - '0')
+		return func(status int) bool { return status/100 == class }, nil
+	}
+	code, err := strconv.Atoi(pattern)
+	if err != nil || code < 100 || code > 599 {
+		return nil, fmt.Errorf("invalid status %q (use a code such as 404 or a class such as 5xx)", pattern)
+	}
+	return func(status int) bool { return status == code }, nil
+}
+
+// waitResponse waits for a network response that match accepts. what
+// describes the response in the timeout error.
+func (c *Client) waitResponse(ctx context.Context, targetID string, match func(url string, status int) bool, what string, timeout time.Duration) (*WaitResponseResult, error) {
 	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
 		return nil, err
@@ -985,7 +833,7 @@ func (c *Client) WaitForResponse(ctx context.Context, targetID string, pattern s
 		select {
 		case <-timeoutCtx.Done():
 			if timeoutCtx.Err() == context.DeadlineExceeded {
-				return nil, fmt.Errorf("timeout waiting for response matching %q", pattern)
+				return nil, fmt.Errorf("timeout waiting for %s", what)
 			}
 			return nil, timeoutCtx.Err()
 		case params, ok := <-responseCh:
@@ -1003,8 +851,7 @@ func (c *Client) WaitForResponse(ctx context.Context, targetID string, pattern s
 			if err := json.Unmarshal(params, &event); err != nil {
 				continue
 			}
-			// Check if URL contains the pattern
-			if strings.Contains(event.Response.URL, pattern) {
+			if match(event.Response.URL, event.Response.Status) {
 				return &WaitResponseResult{
 					Found:     true,
 					URL:       event.Response.URL,
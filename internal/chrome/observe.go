@@ -0,0 +1,255 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxOutlineName is the length at which names in a page outline are cut.
+const maxOutlineName = 80
+
+// interactiveRoles are the roles of the elements to act on in a page
+// outline.
+var interactiveRoles = map[string]bool{
+	"link": true, "button": true, "textbox": true, "searchbox": true, "combobox": true,
+	"listbox": true, "option": true, "checkbox": true, "radio": true, "switch": true,
+	"slider": true, "spinbutton": true, "tab": true, "treeitem": true,
+	"menuitem": true, "menuitemcheckbox": true, "menuitemradio": true,
+}
+
+// containerRoles are the landmark and dialog roles structuring a page
+// outline. Other than dialogs, they are left out when they contain nothing
+// else of the outline.
+var containerRoles = map[string]bool{
+	"banner": true, "navigation": true, "main": true, "complementary": true,
+	"contentinfo": true, "region": true, "search": true, "form": true,
+	"dialog": true, "alertdialog": true,
+}
+
+// outlineStates maps accessibility properties to the states shown in a
+// page outline, by the property's value.
+var outlineStates = map[string]map[string]string{
+	"checked":  {"true": "checked", "mixed": "mixed"},
+	"pressed":  {"true": "pressed", "mixed": "mixed"},
+	"selected": {"true": "selected"},
+	"expanded": {"true": "expanded", "false": "collapsed"},
+	"disabled": {"true": "disabled"},
+	"required": {"true": "required"},
+	"readonly": {"true": "readonly"},
+	"invalid":  {"true": "invalid", "grammar": "invalid", "spelling": "invalid"},
+	"focused":  {"true": "focused"},
+	"modal":    {"true": "modal"},
+}
+
+// axNode is a node of the accessibility tree as returned by
+// Accessibility.getFullAXTree.
+type axNode struct {
+	NodeID  string `json:"nodeId"`
+	Ignored bool   `json:"ignored"`
+	Role    struct {
+		Value string `json:"value"`
+	} `json:"role"`
+	Name struct {
+		Value string `json:"value"`
+	} `json:"name"`
+	Value struct {
+		Value interface{} `json:"value"`
+	} `json:"value"`
+	Properties []struct {
+		Name  string `json:"name"`
+		Value struct {
+			Value interface{} `json:"value"`
+		} `json:"value"`
+	} `json:"properties"`
+	ChildIDs         []string `json:"childIds"`
+	BackendDOMNodeID int64    `json:"backendDOMNodeId"`
+}
+
+// Observe returns a compact outline of the page: its landmarks, headings,
+// dialogs, links, buttons and form controls, nested as on the page, leaving
+// out elements that are hidden or, unless opts.FullPage is set, outside the
+// viewport. The outline is of the main frame, whatever frame SetFrame set.
+func (c *Client) Observe(ctx context.Context, targetID string, opts ObserveOptions) ([]OutlineNode, error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.CallSession(ctx, sessionID, "Accessibility.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling accessibility: %w", err)
+	}
+	result, err := c.CallSession(ctx, sessionID, "Accessibility.getFullAXTree", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting accessibility tree: %w", err)
+	}
+	var tree struct {
+		Nodes []axNode `json:"nodes"`
+	}
+	if err := json.Unmarshal(result, &tree); err != nil {
+		return nil, fmt.Errorf("parsing accessibility tree: %w", err)
+	}
+
+	boxes, err := c.layoutBoxes(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var viewport *BoundingBox
+	if !opts.FullPage {
+		if viewport, err = c.layoutViewport(ctx, sessionID); err != nil {
+			return nil, err
+		}
+	}
+
+	return buildOutline(tree.Nodes, boxes, viewport), nil
+}
+
+// layoutBoxes returns the boxes of the rendered nodes of the page's
+// document, by backend node ID, in document coordinates.
+func (c *Client) layoutBoxes(ctx context.Context, sessionID string) (map[int64]BoundingBox, error) {
+	result, err := c.CallSession(ctx, sessionID, "DOMSnapshot.captureSnapshot", map[string]interface{}{
+		"computedStyles": []string{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("capturing DOM snapshot: %w", err)
+	}
+
+	var resp struct {
+		Documents []struct {
+			Nodes struct {
+				BackendNodeID []int64 `json:"backendNodeId"`
+			} `json:"nodes"`
+			Layout struct {
+				NodeIndex []int       `json:"nodeIndex"`
+				Bounds    [][]float64 `json:"bounds"`
+			} `json:"layout"`
+		} `json:"documents"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing snapshot response: %w", err)
+	}
+
+	boxes := make(map[int64]BoundingBox)
+	if len(resp.Documents) == 0 {
+		return boxes, nil
+	}
+	// The first document is the page's; frames have their own
+	doc := resp.Documents[0]
+	for i, index := range doc.Layout.NodeIndex {
+		if index >= len(doc.Nodes.BackendNodeID) || i >= len(doc.Layout.Bounds) || len(doc.Layout.Bounds[i]) < 4 {
+			continue
+		}
+		b := doc.Layout.Bounds[i]
+		box := BoundingBox{X: b[0], Y: b[1], Width: b[2], Height: b[3]}
+		// An element can have several layout objects, such as for its
+		// text; its own comes first
+		if _, ok := boxes[doc.Nodes.BackendNodeID[index]]; !ok {
+			boxes[doc.Nodes.BackendNodeID[index]] = box
+		}
+	}
+	return boxes, nil
+}
+
+// layoutViewport returns the visible part of the page, in document
+// coordinates.
+func (c *Client) layoutViewport(ctx context.Context, sessionID string) (*BoundingBox, error) {
+	result, err := c.CallSession(ctx, sessionID, "Page.getLayoutMetrics", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting layout metrics: %w", err)
+	}
+
+	var resp struct {
+		CSSLayoutViewport struct {
+			PageX        float64 `json:"pageX"`
+			PageY        float64 `json:"pageY"`
+			ClientWidth  float64 `json:"clientWidth"`
+			ClientHeight float64 `json:"clientHeight"`
+		} `json:"cssLayoutViewport"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing layout metrics: %w", err)
+	}
+
+	v := resp.CSSLayoutViewport
+	return &BoundingBox{X: v.PageX, Y: v.PageY, Width: v.ClientWidth, Height: v.ClientHeight}, nil
+}
+
+// buildOutline builds a page outline from the accessibility tree. Nodes
+// of the outline's roles are kept if they have a box and, when viewport
+// is given, the box is in it; the nodes kept below a node that is not
+// become children of its nearest kept ancestor.
+func buildOutline(nodes []axNode, boxes map[int64]BoundingBox, viewport *BoundingBox) []OutlineNode {
+	if len(nodes) == 0 {
+		return []OutlineNode{}
+	}
+	byID := make(map[string]*axNode, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].NodeID] = &nodes[i]
+	}
+
+	var walk func(id string) []OutlineNode
+	walk = func(id string) []OutlineNode {
+		n, ok := byID[id]
+		if !ok {
+			return nil
+		}
+		var children []OutlineNode
+		for _, childID := range n.ChildIDs {
+			children = append(children, walk(childID)...)
+		}
+
+		role := n.Role.Value
+		if n.Ignored || !(role == "heading" || interactiveRoles[role] || containerRoles[role]) {
+			return children
+		}
+		box, ok := boxes[n.BackendDOMNodeID]
+		if !ok || box.Width <= 0 || box.Height <= 0 || (viewport != nil && !box.intersects(*viewport)) {
+			return children
+		}
+		if containerRoles[role] && role != "dialog" && role != "alertdialog" && len(children) == 0 {
+			return nil
+		}
+
+		node := OutlineNode{
+			Role:          role,
+			Name:          cutName(n.Name.Value),
+			BackendNodeID: n.BackendDOMNodeID,
+			Children:      children,
+		}
+		if v, ok := n.Value.Value.(string); ok && interactiveRoles[role] {
+			node.Value = cutName(v)
+		}
+		for _, p := range n.Properties {
+			value := fmt.Sprint(p.Value.Value)
+			if p.Name == "level" && role == "heading" {
+				fmt.Sscan(value, &node.Level)
+			} else if state, ok := outlineStates[p.Name][value]; ok {
+				node.States = append(node.States, state)
+			}
+		}
+		return []OutlineNode{node}
+	}
+
+	outline := walk(nodes[0].NodeID)
+	if outline == nil {
+		return []OutlineNode{}
+	}
+	return outline
+}
+
+// intersects reports whether two boxes overlap.
+func (b BoundingBox) intersects(o BoundingBox) bool {
+	return b.X < o.X+o.Width && o.X < b.X+b.Width && b.Y < o.Y+o.Height && o.Y < b.Y+b.Height
+}
+
+// cutName shortens a name for an outline, collapsing its whitespace.
+func cutName(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxOutlineName {
+		return string(r[:maxOutlineName-1]) + "…"
+	}
+	return s
+}
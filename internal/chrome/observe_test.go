@@ -0,0 +1,112 @@
+package chrome
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// outlineTree is an accessibility tree of a page with a navigation bar, a
+// heading, a form, a hidden button and a link below the fold.
+const outlineTree = `[
+	{"nodeId": "1", "role": {"value": "RootWebArea"}, "name": {"value": "Shop"}, "childIds": ["2", "5", "13"], "backendDOMNodeId": 1},
+	{"nodeId": "2", "role": {"value": "navigation"}, "childIds": ["3", "4"], "backendDOMNodeId": 2},
+	{"nodeId": "3", "role": {"value": "link"}, "name": {"value": "Home"}, "childIds": ["20"], "backendDOMNodeId": 3},
+	{"nodeId": "20", "role": {"value": "StaticText"}, "name": {"value": "Home"}, "backendDOMNodeId": 20},
+	{"nodeId": "4", "role": {"value": "link"}, "name": {"value": "Basket"}, "properties": [{"name": "focused", "value": {"type": "booleanOrUndefined", "value": true}}], "backendDOMNodeId": 4},
+	{"nodeId": "5", "role": {"value": "main"}, "childIds": ["6", "7", "12"], "backendDOMNodeId": 5},
+	{"nodeId": "6", "role": {"value": "heading"}, "name": {"value": "  Checkout\n  now "}, "properties": [{"name": "level", "value": {"type": "integer", "value": 1}}], "backendDOMNodeId": 6},
+	{"nodeId": "7", "role": {"value": "generic"}, "ignored": true, "childIds": ["8", "9", "10", "11"], "backendDOMNodeId": 7},
+	{"nodeId": "8", "role": {"value": "textbox"}, "name": {"value": "Email"}, "value": {"type": "string", "value": "a@b.test"}, "properties": [{"name": "required", "value": {"type": "boolean", "value": true}}], "backendDOMNodeId": 8},
+	{"nodeId": "9", "role": {"value": "checkbox"}, "name": {"value": "Gift wrap"}, "properties": [{"name": "checked", "value": {"type": "tristate", "value": "false"}}, {"name": "disabled", "value": {"type": "boolean", "value": true}}], "backendDOMNodeId": 9},
+	{"nodeId": "10", "role": {"value": "button"}, "name": {"value": "Hidden"}, "backendDOMNodeId": 10},
+	{"nodeId": "11", "role": {"value": "button"}, "name": {"value": "Pay"}, "properties": [{"name": "expanded", "value": {"type": "booleanOrUndefined", "value": false}}], "backendDOMNodeId": 11},
+	{"nodeId": "12", "role": {"value": "link"}, "name": {"value": "Terms"}, "backendDOMNodeId": 12},
+	{"nodeId": "13", "role": {"value": "contentinfo"}, "childIds": ["14"], "backendDOMNodeId": 13},
+	{"nodeId": "14", "role": {"value": "paragraph"}, "backendDOMNodeId": 14}
+]`
+
+var outlineBoxes = map[int64]BoundingBox{
+	1:  {X: 0, Y: 0, Width: 800, Height: 2000},
+	2:  {X: 0, Y: 0, Width: 800, Height: 40},
+	3:  {X: 10, Y: 10, Width: 50, Height: 20},
+	4:  {X: 70, Y: 10, Width: 50, Height: 20},
+	5:  {X: 0, Y: 40, Width: 800, Height: 1900},
+	6:  {X: 10, Y: 50, Width: 300, Height: 40},
+	7:  {X: 0, Y: 100, Width: 800, Height: 200},
+	8:  {X: 10, Y: 110, Width: 200, Height: 20},
+	9:  {X: 10, Y: 140, Width: 20, Height: 20},
+	10: {X: 10, Y: 170, Width: 0, Height: 0},
+	11: {X: 10, Y: 200, Width: 80, Height: 30},
+	12: {X: 10, Y: 1500, Width: 50, Height: 20},
+	13: {X: 0, Y: 1940, Width: 800, Height: 60},
+	14: {X: 10, Y: 1950, Width: 300, Height: 20},
+}
+
+func parseOutlineTree(t *testing.T) []axNode {
+	t.Helper()
+	var nodes []axNode
+	if err := json.Unmarshal([]byte(outlineTree), &nodes); err != nil {
+		t.Fatalf("parsing tree: %v", err)
+	}
+	return nodes
+}
+
+func TestBuildOutline_Viewport(t *testing.T) {
+	viewport := &BoundingBox{X: 0, Y: 0, Width: 800, Height: 600}
+	got := buildOutline(parseOutlineTree(t), outlineBoxes, viewport)
+
+	want := []OutlineNode{
+		{Role: "navigation", BackendNodeID: 2, Children: []OutlineNode{
+			{Role: "link", Name: "Home", BackendNodeID: 3},
+			{Role: "link", Name: "Basket", States: []string{"focused"}, BackendNodeID: 4},
+		}},
+		{Role: "main", BackendNodeID: 5, Children: []OutlineNode{
+			{Role: "heading", Name: "Checkout now", Level: 1, BackendNodeID: 6},
+			{Role: "textbox", Name: "Email", Value: "a@b.test", States: []string{"required"}, BackendNodeID: 8},
+			{Role: "checkbox", Name: "Gift wrap", States: []string{"disabled"}, BackendNodeID: 9},
+			{Role: "button", Name: "Pay", States: []string{"collapsed"}, BackendNodeID: 11},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildOutline() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestBuildOutline_FullPage(t *testing.T) {
+	got := buildOutline(parseOutlineTree(t), outlineBoxes, nil)
+
+	if len(got) != 2 {
+		t.Fatalf("expected navigation and main, got %+v", got)
+	}
+	main := got[1].Children
+	if last := main[len(main)-1]; last.Name != "Terms" {
+		t.Errorf("expected the link below the fold, got %+v", last)
+	}
+}
+
+func TestBuildOutline_Empty(t *testing.T) {
+	if got := buildOutline(nil, nil, nil); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty outline, got %#v", got)
+	}
+}
+
+func TestCutName(t *testing.T) {
+	long := ""
+	for range 20 {
+		long += "word "
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Save", "Save"},
+		{"  Save \n changes ", "Save changes"},
+		{long, long[:79] + "…"},
+	}
+	for _, tt := range tests {
+		if got := cutName(tt.name); got != tt.want {
+			t.Errorf("cutName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -99,6 +99,34 @@ func (c *Client) ScreenshotElement(ctx context.Context, targetID string, selecto
 	return data, bounds, nil
 }
 
+// DevicePixelRatio returns the number of screenshot pixels to a CSS pixel
+// of the page.
+func (c *Client) DevicePixelRatio(ctx context.Context, targetID string) (float64, error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return 0, err
+	}
+
+	// The page's own, rather than that of a frame set by SetFrame
+	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+		"expression":    "window.devicePixelRatio",
+		"returnByValue": true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("getting device pixel ratio: %w", err)
+	}
+	eval, err := parseEvalResult(result)
+	if err != nil {
+		return 0, err
+	}
+
+	ratio, ok := eval.Value.(float64)
+	if !ok || ratio <= 0 {
+		return 1, nil
+	}
+	return ratio, nil
+}
+
 // PrintToPDF generates a PDF of the page.
 func (c *Client) PrintToPDF(ctx context.Context, targetID string, opts PDFOptions) ([]byte, error) {
 	sessionID, err := c.attachToTarget(ctx, targetID)
@@ -252,12 +280,12 @@ func (c *Client) GetPageInfo(ctx context.Context, targetID string) (*PageInfo, e
 
 // GetForms returns information about all forms on the page.
 func (c *Client) GetForms(ctx context.Context, targetID string) ([]FormInfo, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+	result, err := c.evaluate(ctx, sessionID, map[string]interface{}{
 		"expression": `(function() {
 			const forms = [];
 			document.querySelectorAll('form').forEach(form => {
@@ -302,12 +330,12 @@ func (c *Client) GetForms(ctx context.Context, targetID string) ([]FormInfo, err
 
 // GetImages returns all images on the page.
 func (c *Client) GetImages(ctx context.Context, targetID string) ([]ImageInfo, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+	result, err := c.evaluate(ctx, sessionID, map[string]interface{}{
 		"expression": `(function() {
 			const images = [];
 			document.querySelectorAll('img').forEach(img => {
@@ -387,7 +415,7 @@ func (c *Client) GetFrames(ctx context.Context, targetID string) ([]FrameInfo, e
 
 // Eval evaluates a JavaScript expression in a target's page context.
 func (c *Client) Eval(ctx context.Context, targetID string, expression string) (*EvalResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
@@ -399,7 +427,7 @@ func (c *Client) Eval(ctx context.Context, targetID string, expression string) (
 	}
 
 	// Evaluate expression
-	evalResult, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+	evalResult, err := c.evaluate(ctx, sessionID, map[string]interface{}{
 		"expression":    expression,
 		"returnByValue": true,
 	})
@@ -407,27 +435,7 @@ func (c *Client) Eval(ctx context.Context, targetID string, expression string) (
 		return nil, fmt.Errorf("evaluating expression: %w", err)
 	}
 
-	var evalResp struct {
-		Result struct {
-			Type  string      `json:"type"`
-			Value interface{} `json:"value"`
-		} `json:"result"`
-		ExceptionDetails *struct {
-			Text string `json:"text"`
-		} `json:"exceptionDetails"`
-	}
-	if err := json.Unmarshal(evalResult, &evalResp); err != nil {
-		return nil, fmt.Errorf("parsing eval response: %w", err)
-	}
-
-	if evalResp.ExceptionDetails != nil {
-		return nil, fmt.Errorf("JS exception: %s", evalResp.ExceptionDetails.Text)
-	}
-
-	return &EvalResult{
-		Value: evalResp.Result.Value,
-		Type:  evalResp.Result.Type,
-	}, nil
+	return parseEvalResult(evalResult)
 }
 
 // EvalInFrame evaluates JavaScript in a specific frame.
@@ -490,7 +498,8 @@ func (c *Client) EvalInFrame(ctx context.Context, targetID string, frameID strin
 	}, nil
 }
 
-// GetAccessibilityTree returns the accessibility tree for the page.
+// GetAccessibilityTree returns the accessibility tree for the page's main
+// frame, whatever frame SetFrame set.
 func (c *Client) GetAccessibilityTree(ctx context.Context, targetID string) ([]AccessibilityNode, error) {
 	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
@@ -528,7 +537,8 @@ func (c *Client) GetAccessibilityTree(ctx context.Context, targetID string) ([]A
 				Name  string      `json:"name"`
 				Value interface{} `json:"value"`
 			} `json:"properties"`
-			ChildIds []string `json:"childIds"`
+			ChildIds         []string `json:"childIds"`
+			BackendDOMNodeID int64    `json:"backendDOMNodeId"`
 		} `json:"nodes"`
 	}
 	if err := json.Unmarshal(result, &treeResult); err != nil {
@@ -544,11 +554,12 @@ func (c *Client) GetAccessibilityTree(ctx context.Context, targetID string) ([]A
 		}
 
 		node := AccessibilityNode{
-			NodeID:      n.NodeID,
-			Role:        n.Role.Value,
-			Name:        n.Name.Value,
-			Description: n.Description.Value,
-			Value:       n.Value.Value,
+			NodeID:        n.NodeID,
+			BackendNodeID: n.BackendDOMNodeID,
+			Role:          n.Role.Value,
+			Name:          n.Name.Value,
+			Description:   n.Description.Value,
+			Value:         n.Value.Value,
 		}
 
 		if len(n.Properties) > 0 {
@@ -695,12 +706,12 @@ func (c *Client) GetDOMSnapshot(ctx context.Context, targetID string) (*DOMSnaps
 
 // ScrollToBottom scrolls to the bottom of the page.
 func (c *Client) ScrollToBottom(ctx context.Context, targetID string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+	_, err = c.evaluate(ctx, sessionID, map[string]interface{}{
 		"expression": `window.scrollTo(0, document.body.scrollHeight)`,
 	})
 	if err != nil {
@@ -712,12 +723,12 @@ func (c *Client) ScrollToBottom(ctx context.Context, targetID string) error {
 
 // ScrollToTop scrolls to the top of the page.
 func (c *Client) ScrollToTop(ctx context.Context, targetID string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+	_, err = c.evaluate(ctx, sessionID, map[string]interface{}{
 		"expression": `window.scrollTo(0, 0)`,
 	})
 	if err != nil {
@@ -729,16 +740,15 @@ func (c *Client) ScrollToTop(ctx context.Context, targetID string) error {
 
 // ScrollIntoView scrolls an element into view.
 func (c *Client) ScrollIntoView(ctx context.Context, targetID string, selector string) error {
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el) {
 			if (!el) throw new Error('Element not found');
 			el.scrollIntoView({ behavior: 'instant', block: 'center' });
 			return true;
-		})()
-	`, selector)
+		}
+	`
 
-	_, err := c.Eval(ctx, targetID, js)
+	_, err := c.evalOnSelector(ctx, targetID, selector, js)
 	return err
 }
 
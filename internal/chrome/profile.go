@@ -4,30 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
-	"sync"
+	"io"
+	"sort"
 	"time"
+
+	"github.com/tomyan/hubcap/internal/pprof"
 )
 
-// TakeHeapSnapshot captures a V8 heap snapshot and writes it to a file.
-func (c *Client) TakeHeapSnapshot(ctx context.Context, targetID string) ([]byte, error) {
+// TakeHeapSnapshot captures a V8 heap snapshot, writing its chunks to w as
+// they arrive so the snapshot is never held in memory. Returns the number
+// of bytes written.
+func (c *Client) TakeHeapSnapshot(ctx context.Context, targetID string, w io.Writer) (int64, error) {
 	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
 	// Enable HeapProfiler
 	_, err = c.CallSession(ctx, sessionID, "HeapProfiler.enable", nil)
 	if err != nil {
-		return nil, fmt.Errorf("enabling HeapProfiler: %w", err)
+		return 0, fmt.Errorf("enabling HeapProfiler: %w", err)
 	}
 
-	// Subscribe to chunk events before taking snapshot
-	chunkCh := c.subscribeEvent(sessionID, "HeapProfiler.addHeapSnapshotChunk")
+	// Subscribe to chunk events before taking snapshot. A snapshot missing
+	// any chunk is unreadable, so none may be dropped
+	chunkCh := c.subscribeEventLossless(sessionID, "HeapProfiler.addHeapSnapshotChunk")
 
-	// Collect chunks in a goroutine
-	var chunks []string
-	var chunksMu sync.Mutex
+	// Write chunks in a goroutine, draining the channel even after a write
+	// error so that the chunks queued for it are let go
+	var written int64
+	var writeErr error
 	chunksDone := make(chan struct{})
 
 	go func() {
@@ -36,11 +42,12 @@ func (c *Client) TakeHeapSnapshot(ctx context.Context, targetID string) ([]byte,
 			var chunk struct {
 				Chunk string `json:"chunk"`
 			}
-			if err := json.Unmarshal(params, &chunk); err == nil {
-				chunksMu.Lock()
-				chunks = append(chunks, chunk.Chunk)
-				chunksMu.Unlock()
+			if err := json.Unmarshal(params, &chunk); err != nil || writeErr != nil {
+				continue
 			}
+			n, err := io.WriteString(w, chunk.Chunk)
+			written += int64(n)
+			writeErr = err
 		}
 	}()
 
@@ -49,7 +56,8 @@ func (c *Client) TakeHeapSnapshot(ctx context.Context, targetID string) ([]byte,
 		"reportProgress": false,
 	})
 
-	// Unsubscribe closes the channel, which signals the goroutine to finish
+	// Unsubscribe closes the channel once the queued chunks are received,
+	// which signals the goroutine to finish
 	c.unsubscribeEvent(sessionID, "HeapProfiler.addHeapSnapshotChunk", chunkCh)
 	<-chunksDone
 
@@ -57,96 +65,131 @@ func (c *Client) TakeHeapSnapshot(ctx context.Context, targetID string) ([]byte,
 	c.CallSession(ctx, sessionID, "HeapProfiler.disable", nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("taking heap snapshot: %w", err)
+		return written, fmt.Errorf("taking heap snapshot: %w", err)
+	}
+	if writeErr != nil {
+		return written, fmt.Errorf("writing heap snapshot: %w", writeErr)
 	}
 
-	// Assemble chunks
-	chunksMu.Lock()
-	defer chunksMu.Unlock()
+	return written, nil
+}
 
-	var buf strings.Builder
-	for _, chunk := range chunks {
-		buf.WriteString(chunk)
+// CollectGarbage forces a full garbage collection in the target.
+func (c *Client) CollectGarbage(ctx context.Context, targetID string) error {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
 	}
 
-	return []byte(buf.String()), nil
+	_, err = c.CallSession(ctx, sessionID, "HeapProfiler.collectGarbage", nil)
+	if err != nil {
+		return fmt.Errorf("collecting garbage: %w", err)
+	}
+	return nil
 }
 
-// CaptureTrace captures a Chrome performance trace for the given duration.
-func (c *Client) CaptureTrace(ctx context.Context, targetID string, duration time.Duration) ([]byte, error) {
+// DefaultTraceCategories are the trace categories recorded when none are
+// given: those the DevTools Performance panel needs for its main-thread,
+// frame and user timing views.
+const DefaultTraceCategories = "-*,devtools.timeline,v8.execute,disabled-by-default-devtools.timeline,disabled-by-default-devtools.timeline.frame,toplevel,blink.user_timing"
+
+// CaptureTrace captures a Chrome performance trace until opts.Until is met
+// and returns its events as a JSON array.
+func (c *Client) CaptureTrace(ctx context.Context, targetID string, opts TraceOptions) ([]byte, error) {
 	sessionID, err := c.attachToTarget(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Subscribe to tracing events
-	dataCh := c.subscribeEvent(sessionID, "Tracing.dataCollected")
-	defer c.unsubscribeEvent(sessionID, "Tracing.dataCollected", dataCh)
-
-	completeCh := c.subscribeEvent(sessionID, "Tracing.tracingComplete")
-	defer c.unsubscribeEvent(sessionID, "Tracing.tracingComplete", completeCh)
-
-	// Collect trace data chunks
-	var traceEvents []json.RawMessage
-	var traceEventsMu sync.Mutex
-	collectDone := make(chan struct{})
+	categories := opts.Categories
+	if categories == "" {
+		categories = DefaultTraceCategories
+	}
 
-	go func() {
-		for {
-			select {
-			case params, ok := <-dataCh:
-				if !ok {
-					return
-				}
-				var data struct {
-					Value []json.RawMessage `json:"value"`
-				}
-				if err := json.Unmarshal(params, &data); err == nil {
-					traceEventsMu.Lock()
-					traceEvents = append(traceEvents, data.Value...)
-					traceEventsMu.Unlock()
-				}
-			case <-collectDone:
-				return
-			}
-		}
-	}()
+	// Trace data is read from a single tap so that every chunk is seen
+	// before the tracingComplete event that follows it
+	events := c.tapEvents()
+	defer c.untapEvents(events)
 
 	// Start tracing
 	_, err = c.CallSession(ctx, sessionID, "Tracing.start", map[string]interface{}{
-		"categories": "-*,devtools.timeline,v8.execute,disabled-by-default-devtools.timeline",
+		"categories": categories,
 	})
 	if err != nil {
-		close(collectDone)
 		return nil, fmt.Errorf("starting trace: %w", err)
 	}
 
-	// Wait for the specified duration
-	time.Sleep(duration)
+	var traceEvents []json.RawMessage
+	collect := func(ev cdpEvent) bool {
+		if ev.SessionID != sessionID {
+			return false
+		}
+		switch ev.Method {
+		case "Tracing.dataCollected":
+			var data struct {
+				Value []json.RawMessage `json:"value"`
+			}
+			if err := json.Unmarshal(ev.Params, &data); err == nil {
+				traceEvents = append(traceEvents, data.Value...)
+			}
+		case "Tracing.tracingComplete":
+			return true
+		}
+		return false
+	}
+
+	// Wait for the stop condition, collecting any data sent meanwhile
+	stopCh := make(chan error, 1)
+	stopCtx, cancelStop := context.WithCancel(ctx)
+	defer cancelStop()
+	go func() {
+		stopCh <- c.waitStop(stopCtx, targetID, opts.Until)
+	}()
+
+	stopped := false
+	for !stopped {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil, ErrConnectionClosed
+			}
+			collect(ev)
+		case err := <-stopCh:
+			if err != nil {
+				endCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				c.CallSession(endCtx, sessionID, "Tracing.end", nil)
+				cancel()
+				return nil, err
+			}
+			stopped = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 
 	// End tracing
 	_, err = c.CallSession(ctx, sessionID, "Tracing.end", nil)
 	if err != nil {
-		close(collectDone)
 		return nil, fmt.Errorf("ending trace: %w", err)
 	}
 
 	// Wait for tracing complete event
-	select {
-	case <-completeCh:
-	case <-time.After(10 * time.Second):
-	case <-ctx.Done():
-		close(collectDone)
-		return nil, ctx.Err()
+	timeout := time.After(10 * time.Second)
+	for complete := false; !complete; {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil, ErrConnectionClosed
+			}
+			complete = collect(ev)
+		case <-timeout:
+			complete = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
-	close(collectDone)
-	time.Sleep(100 * time.Millisecond)
-
 	// Build JSON array from collected events
-	traceEventsMu.Lock()
-	defer traceEventsMu.Unlock()
-
 	traceData, err := json.Marshal(traceEvents)
 	if err != nil {
 		return nil, fmt.Errorf("marshaling trace data: %w", err)
@@ -154,3 +197,167 @@ func (c *Client) CaptureTrace(ctx context.Context, targetID string, duration tim
 
 	return traceData, nil
 }
+
+// CaptureCPUProfile records a V8 CPU profile of the target for the given
+// duration, sampling every interval (0 = V8's default of 1ms).
+func (c *Client) CaptureCPUProfile(ctx context.Context, targetID string, duration, interval time.Duration) (*CPUProfile, error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enable Profiler
+	_, err = c.CallSession(ctx, sessionID, "Profiler.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling Profiler: %w", err)
+	}
+	defer func() {
+		disableCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		c.CallSession(disableCtx, sessionID, "Profiler.disable", nil)
+	}()
+
+	if interval > 0 {
+		_, err = c.CallSession(ctx, sessionID, "Profiler.setSamplingInterval", map[string]interface{}{
+			"interval": interval.Microseconds(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("setting sampling interval: %w", err)
+		}
+	}
+
+	_, err = c.CallSession(ctx, sessionID, "Profiler.start", nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting profiler: %w", err)
+	}
+
+	// Wait for the specified duration
+	select {
+	case <-time.After(duration):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "Profiler.stop", nil)
+	if err != nil {
+		return nil, fmt.Errorf("stopping profiler: %w", err)
+	}
+
+	var resp struct {
+		Profile CPUProfile `json:"profile"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing profile: %w", err)
+	}
+	return &resp.Profile, nil
+}
+
+// Pprof converts the profile to pprof format with "samples/count" and
+// "cpu/nanoseconds" values. Each node becomes a location whose stack is the
+// path from the root of the call tree; the synthetic (root) and (idle) nodes
+// are left out, so idle time does not dominate flame graphs. started is the
+// wall-clock time the profile began.
+func (p *CPUProfile) Pprof(started time.Time) *pprof.Profile {
+	parents := make(map[int]int, len(p.Nodes))
+	nodes := make(map[int]*CPUProfileNode, len(p.Nodes))
+	for i := range p.Nodes {
+		node := &p.Nodes[i]
+		nodes[node.ID] = node
+		for _, child := range node.Children {
+			parents[child] = node.ID
+		}
+	}
+
+	// Attribute to each sample the time until the next one
+	counts := make(map[int]int64)
+	durations := make(map[int]int64)
+	timestamp := p.StartTime
+	for i, nodeID := range p.Samples {
+		if i < len(p.TimeDeltas) {
+			timestamp += float64(p.TimeDeltas[i])
+		}
+		next := p.EndTime
+		if i+1 < len(p.TimeDeltas) && i+1 < len(p.Samples) {
+			next = timestamp + float64(p.TimeDeltas[i+1])
+		}
+		counts[nodeID]++
+		if next > timestamp {
+			durations[nodeID] += int64((next - timestamp) * 1000)
+		}
+	}
+
+	prof := &pprof.Profile{
+		SampleTypes: []pprof.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		TimeNanos:     started.UnixNano(),
+		DurationNanos: int64((p.EndTime - p.StartTime) * 1000),
+		PeriodType:    pprof.ValueType{Type: "cpu", Unit: "nanoseconds"},
+	}
+	if len(p.Samples) > 0 {
+		prof.Period = prof.DurationNanos / int64(len(p.Samples))
+	}
+
+	skipped := func(node *CPUProfileNode) bool {
+		name := node.CallFrame.FunctionName
+		return name == "(root)" || name == "(idle)"
+	}
+
+	// One function per distinct call frame, one location per node
+	functionIDs := make(map[CPUProfileCallFrame]uint64)
+	for i := range p.Nodes {
+		node := &p.Nodes[i]
+		if skipped(node) {
+			continue
+		}
+		frame := node.CallFrame
+		frame.ScriptID = ""
+		fnID, ok := functionIDs[frame]
+		if !ok {
+			fnID = uint64(len(prof.Functions) + 1)
+			functionIDs[frame] = fnID
+			name := frame.FunctionName
+			if name == "" {
+				name = "(anonymous)"
+			}
+			prof.Functions = append(prof.Functions, pprof.Function{
+				ID:        fnID,
+				Name:      name,
+				Filename:  frame.URL,
+				StartLine: int64(frame.LineNumber + 1),
+			})
+		}
+		prof.Locations = append(prof.Locations, pprof.Location{
+			ID:         uint64(node.ID),
+			FunctionID: fnID,
+			Line:       int64(frame.LineNumber + 1),
+			Column:     int64(frame.ColumnNumber + 1),
+		})
+	}
+
+	sampled := make([]int, 0, len(counts))
+	for nodeID := range counts {
+		sampled = append(sampled, nodeID)
+	}
+	sort.Ints(sampled)
+
+	for _, nodeID := range sampled {
+		node, ok := nodes[nodeID]
+		if !ok || skipped(node) {
+			continue
+		}
+		var stack []uint64
+		for id, ok := nodeID, true; ok; id, ok = parents[id] {
+			if n := nodes[id]; n != nil && !skipped(n) {
+				stack = append(stack, uint64(id))
+			}
+		}
+		prof.Samples = append(prof.Samples, pprof.Sample{
+			LocationIDs: stack,
+			Values:      []int64{counts[nodeID], durations[nodeID]},
+		})
+	}
+
+	return prof
+}
@@ -3,62 +3,32 @@ package chrome
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
-// Query finds the first DOM element matching a CSS selector.
+// Query finds the first DOM element matching a selector.
 func (c *Client) Query(ctx context.Context, targetID string, selector string) (*QueryResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	nodeID, err := c.queryNodeID(ctx, sessionID, selector)
 	if err != nil {
-		return nil, fmt.Errorf("enabling DOM domain: %w", err)
-	}
-
-	// Get document root
-	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-	if err != nil {
-		return nil, fmt.Errorf("getting document: %w", err)
-	}
-
-	var docResp struct {
-		Root struct {
-			NodeID int `json:"nodeId"`
-		} `json:"root"`
-	}
-	if err := json.Unmarshal(docResult, &docResp); err != nil {
-		return nil, fmt.Errorf("parsing document response: %w", err)
-	}
-
-	// Query selector
-	queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": selector,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("querying selector: %w", err)
-	}
-
-	var queryResp struct {
-		NodeID int `json:"nodeId"`
-	}
-	if err := json.Unmarshal(queryResult, &queryResp); err != nil {
-		return nil, fmt.Errorf("parsing query response: %w", err)
+		return nil, err
 	}
 
 	// If not found, return empty result
-	if queryResp.NodeID == 0 {
+	if nodeID == 0 {
 		return &QueryResult{NodeID: 0}, nil
 	}
 
 	// Describe the node to get tag name and attributes
 	descResult, err := c.CallSession(ctx, sessionID, "DOM.describeNode", map[string]interface{}{
-		"nodeId": queryResp.NodeID,
+		"nodeId": nodeID,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("describing node: %w", err)
@@ -66,8 +36,9 @@ func (c *Client) Query(ctx context.Context, targetID string, selector string) (*
 
 	var descResp struct {
 		Node struct {
-			NodeName   string   `json:"nodeName"`
-			Attributes []string `json:"attributes"`
+			BackendNodeID int64    `json:"backendNodeId"`
+			NodeName      string   `json:"nodeName"`
+			Attributes    []string `json:"attributes"`
 		} `json:"node"`
 	}
 	if err := json.Unmarshal(descResult, &descResp); err != nil {
@@ -81,65 +52,199 @@ func (c *Client) Query(ctx context.Context, targetID string, selector string) (*
 	}
 
 	return &QueryResult{
-		NodeID:     queryResp.NodeID,
-		TagName:    descResp.Node.NodeName,
-		Attributes: attrs,
+		NodeID:        int(nodeID),
+		BackendNodeID: descResp.Node.BackendNodeID,
+		TagName:       descResp.Node.NodeName,
+		Attributes:    attrs,
 	}, nil
 }
 
-// QueryShadow finds an element inside a shadow DOM.
-// hostSelector is the CSS selector for the shadow host element.
-// innerSelector is the CSS selector to query within the shadow root.
-func (c *Client) QueryShadow(ctx context.Context, targetID string, hostSelector string, innerSelector string) (*QueryResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+// maxElementText is the length of the text reported by QueryAll for each
+// element.
+const maxElementText = 200
+
+// maxDescribing is the number of elements QueryAll describes at once.
+const maxDescribing = 8
+
+// maxElementStates is the number of elements QueryAll gets the text,
+// visibility and box of in one call.
+const maxElementStates = 500
+
+// QueryAll describes every element matching a selector, in document order.
+func (c *Client) QueryAll(ctx context.Context, targetID string, selector string) ([]ElementInfo, error) {
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	nodeIDs, err := c.queryNodeIDs(ctx, sessionID, selector)
 	if err != nil {
-		return nil, fmt.Errorf("enabling DOM domain: %w", err)
+		return nil, err
+	}
+	if len(nodeIDs) == 0 {
+		return []ElementInfo{}, nil
+	}
+
+	// Describe the nodes a few at a time rather than a round trip at a
+	// time, without flooding the connection when there are many
+	elements := make([]ElementInfo, len(nodeIDs))
+	objectIDs := make([]string, len(nodeIDs))
+	errs := make([]error, len(nodeIDs))
+	describing := make(chan struct{}, maxDescribing)
+	var wg sync.WaitGroup
+	for i, nodeID := range nodeIDs {
+		wg.Add(1)
+		describing <- struct{}{}
+		go func() {
+			defer func() {
+				<-describing
+				wg.Done()
+			}()
+			elements[i], errs[i] = c.describeElementNode(ctx, sessionID, nodeID)
+			if errs[i] == nil {
+				objectIDs[i], errs[i] = c.resolveObjectID(ctx, sessionID, map[string]interface{}{"nodeId": nodeID})
+			}
+			elements[i].Index = i
+		}()
+	}
+	wg.Wait()
+	defer c.releaseObjects(sessionID, objectIDs...)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// The text, visibility and box of the elements come from a call for
+	// each batch of them, as a function can take only so many arguments
+	originX, originY := c.frameOrigin(targetID)
+	for start := 0; start < len(objectIDs); start += maxElementStates {
+		batch := objectIDs[start:min(start+maxElementStates, len(objectIDs))]
+		states, err := c.elementStates(ctx, sessionID, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		// Within a frame, boxes are reported in the page's viewport
+		for i, state := range states {
+			if i == len(batch) {
+				break
+			}
+			element := &elements[start+i]
+			element.Text = state.Text
+			element.Visible = state.Visible
+			element.Bounds = state.Bounds
+			element.Bounds.X += originX
+			element.Bounds.Y += originY
+		}
 	}
+	return elements, nil
+}
 
-	// Get document root
-	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
+// elementState is the text, visibility and box of an element.
+type elementState struct {
+	Text    string      `json:"text"`
+	Visible bool        `json:"visible"`
+	Bounds  BoundingBox `json:"bounds"`
+}
+
+// elementStates returns the state of each of the elements objectIDs refer to.
+func (c *Client) elementStates(ctx context.Context, sessionID string, objectIDs []string) ([]elementState, error) {
+	args := make([]map[string]interface{}, len(objectIDs)+1)
+	args[0] = map[string]interface{}{"value": maxElementText}
+	for i, id := range objectIDs {
+		args[i+1] = map[string]interface{}{"objectId": id}
+	}
+	result, err := c.CallSession(ctx, sessionID, "Runtime.callFunctionOn", map[string]interface{}{
+		"objectId": objectIDs[0],
+		"functionDeclaration": `function(max, ...els) {
+			return els.map(el => {
+				const style = window.getComputedStyle(el);
+				const rect = el.getBoundingClientRect();
+				let text = (el.innerText ?? el.textContent).replace(/\s+/g, ' ').trim();
+				if (text.length > max) text = text.slice(0, max) + '…';
+				return {
+					text,
+					visible: style.display !== 'none' && style.visibility !== 'hidden' &&
+						style.opacity !== '0' && rect.width > 0 && rect.height > 0,
+					bounds: { x: rect.x, y: rect.y, width: rect.width, height: rect.height },
+				};
+			});
+		}`,
+		"arguments":     args,
+		"returnByValue": true,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("getting document: %w", err)
+		return nil, fmt.Errorf("getting element state: %w", err)
 	}
 
-	var docResp struct {
-		Root struct {
-			NodeID int `json:"nodeId"`
-		} `json:"root"`
+	var resp struct {
+		Result struct {
+			Value []elementState `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
 	}
-	if err := json.Unmarshal(docResult, &docResp); err != nil {
-		return nil, fmt.Errorf("parsing document response: %w", err)
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing element state: %w", err)
 	}
+	if resp.ExceptionDetails != nil {
+		return nil, fmt.Errorf("getting element state: %w", resp.ExceptionDetails)
+	}
+	return resp.Result.Value, nil
+}
 
-	// Find the shadow host element
-	queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": hostSelector,
+// describeElementNode returns the tag name and attributes of a node.
+func (c *Client) describeElementNode(ctx context.Context, sessionID string, nodeID int64) (ElementInfo, error) {
+	result, err := c.CallSession(ctx, sessionID, "DOM.describeNode", map[string]interface{}{
+		"nodeId": nodeID,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("querying host selector: %w", err)
+		return ElementInfo{}, fmt.Errorf("describing node: %w", err)
 	}
 
-	var hostResp struct {
-		NodeID int `json:"nodeId"`
+	var descResp struct {
+		Node struct {
+			BackendNodeID int64    `json:"backendNodeId"`
+			NodeName      string   `json:"nodeName"`
+			Attributes    []string `json:"attributes"`
+		} `json:"node"`
+	}
+	if err := json.Unmarshal(result, &descResp); err != nil {
+		return ElementInfo{}, fmt.Errorf("parsing describe response: %w", err)
+	}
+
+	attrs := make(map[string]string)
+	for i := 0; i+1 < len(descResp.Node.Attributes); i += 2 {
+		attrs[descResp.Node.Attributes[i]] = descResp.Node.Attributes[i+1]
 	}
-	if err := json.Unmarshal(queryResult, &hostResp); err != nil {
-		return nil, fmt.Errorf("parsing host query response: %w", err)
+	return ElementInfo{
+		NodeID:        int(nodeID),
+		BackendNodeID: descResp.Node.BackendNodeID,
+		TagName:       descResp.Node.NodeName,
+		Attributes:    attrs,
+	}, nil
+}
+
+// QueryShadow finds an element inside a shadow DOM.
+// hostSelector is a selector for the shadow host element.
+// innerSelector is the CSS selector to query within the shadow root.
+func (c *Client) QueryShadow(ctx context.Context, targetID string, hostSelector string, innerSelector string) (*QueryResult, error) {
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return nil, err
 	}
 
-	if hostResp.NodeID == 0 {
+	// Find the shadow host element
+	hostID, err := c.queryNodeID(ctx, sessionID, hostSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostID == 0 {
 		return nil, fmt.Errorf("shadow host not found: %s", hostSelector)
 	}
 
 	// Describe the host node to get its shadow root
 	descResult, err := c.CallSession(ctx, sessionID, "DOM.describeNode", map[string]interface{}{
-		"nodeId": hostResp.NodeID,
+		"nodeId": hostID,
 		"depth":  1,
 		"pierce": true,
 	})
@@ -219,55 +324,19 @@ func (c *Client) QueryShadow(ctx context.Context, targetID string, hostSelector
 
 // GetHTML returns the outer HTML of an element matching the selector.
 func (c *Client) GetHTML(ctx context.Context, targetID string, selector string) (string, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return "", err
 	}
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	nodeID, err := c.resolveNodeID(ctx, sessionID, selector)
 	if err != nil {
-		return "", fmt.Errorf("enabling DOM domain: %w", err)
-	}
-
-	// Get document root
-	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-	if err != nil {
-		return "", fmt.Errorf("getting document: %w", err)
-	}
-
-	var docResp struct {
-		Root struct {
-			NodeID int `json:"nodeId"`
-		} `json:"root"`
-	}
-	if err := json.Unmarshal(docResult, &docResp); err != nil {
-		return "", fmt.Errorf("parsing document response: %w", err)
-	}
-
-	// Query selector
-	queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": selector,
-	})
-	if err != nil {
-		return "", fmt.Errorf("querying selector: %w", err)
-	}
-
-	var queryResp struct {
-		NodeID int `json:"nodeId"`
-	}
-	if err := json.Unmarshal(queryResult, &queryResp); err != nil {
-		return "", fmt.Errorf("parsing query response: %w", err)
-	}
-
-	if queryResp.NodeID == 0 {
-		return "", fmt.Errorf("element not found: %s", selector)
+		return "", err
 	}
 
 	// Get outer HTML
 	htmlResult, err := c.CallSession(ctx, sessionID, "DOM.getOuterHTML", map[string]interface{}{
-		"nodeId": queryResp.NodeID,
+		"nodeId": nodeID,
 	})
 	if err != nil {
 		return "", fmt.Errorf("getting outer HTML: %w", err)
@@ -285,7 +354,7 @@ func (c *Client) GetHTML(ctx context.Context, targetID string, selector string)
 
 // GetText returns the text content of an element.
 func (c *Client) GetText(ctx context.Context, targetID string, selector string) (string, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return "", err
 	}
@@ -297,10 +366,7 @@ func (c *Client) GetText(ctx context.Context, targetID string, selector string)
 	}
 
 	// Use JavaScript to get innerText (handles whitespace better than textContent)
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression":    fmt.Sprintf(`document.querySelector(%q)?.innerText || ''`, selector),
-		"returnByValue": true,
-	})
+	result, err := c.callOnSelector(ctx, sessionID, selector, `el => el?.innerText || ''`)
 	if err != nil {
 		return "", fmt.Errorf("evaluating expression: %w", err)
 	}
@@ -319,55 +385,19 @@ func (c *Client) GetText(ctx context.Context, targetID string, selector string)
 
 // GetAttribute returns the value of an attribute for an element.
 func (c *Client) GetAttribute(ctx context.Context, targetID string, selector string, name string) (string, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return "", err
 	}
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
-	if err != nil {
-		return "", fmt.Errorf("enabling DOM domain: %w", err)
-	}
-
-	// Get document root
-	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-	if err != nil {
-		return "", fmt.Errorf("getting document: %w", err)
-	}
-
-	var docResp struct {
-		Root struct {
-			NodeID int64 `json:"nodeId"`
-		} `json:"root"`
-	}
-	if err := json.Unmarshal(docResult, &docResp); err != nil {
-		return "", fmt.Errorf("parsing document response: %w", err)
-	}
-
-	// Query for element
-	queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": selector,
-	})
+	nodeID, err := c.resolveNodeID(ctx, sessionID, selector)
 	if err != nil {
-		return "", fmt.Errorf("querying selector: %w", err)
-	}
-
-	var queryResp struct {
-		NodeID int64 `json:"nodeId"`
-	}
-	if err := json.Unmarshal(queryResult, &queryResp); err != nil {
-		return "", fmt.Errorf("parsing query response: %w", err)
-	}
-
-	if queryResp.NodeID == 0 {
-		return "", fmt.Errorf("element not found: %s", selector)
+		return "", err
 	}
 
 	// Get attributes using DOM.getAttributes
 	attrResult, err := c.CallSession(ctx, sessionID, "DOM.getAttributes", map[string]interface{}{
-		"nodeId": queryResp.NodeID,
+		"nodeId": nodeID,
 	})
 	if err != nil {
 		return "", fmt.Errorf("getting attributes: %w", err)
@@ -392,7 +422,7 @@ func (c *Client) GetAttribute(ctx context.Context, targetID string, selector str
 
 // Exists checks if an element matching the selector exists.
 func (c *Client) Exists(ctx context.Context, targetID string, selector string) (bool, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return false, err
 	}
@@ -404,10 +434,7 @@ func (c *Client) Exists(ctx context.Context, targetID string, selector string) (
 	}
 
 	// Use JavaScript to check if element exists
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression":    fmt.Sprintf(`document.querySelector(%q) !== null`, selector),
-		"returnByValue": true,
-	})
+	result, err := c.callOnSelector(ctx, sessionID, selector, `el => el !== null`)
 	if err != nil {
 		return false, fmt.Errorf("evaluating: %w", err)
 	}
@@ -426,8 +453,16 @@ func (c *Client) Exists(ctx context.Context, targetID string, selector string) (
 
 // CountElements returns the number of elements matching the selector.
 func (c *Client) CountElements(ctx context.Context, targetID string, selector string) (int, error) {
-	js := fmt.Sprintf(`document.querySelectorAll(%q).length`, selector)
-	result, err := c.Eval(ctx, targetID, js)
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := c.callOnSelectorAll(ctx, sessionID, selector, `els => els.length`)
+	if err != nil {
+		return 0, fmt.Errorf("evaluating expression: %w", err)
+	}
+	result, err := parseEvalResult(raw)
 	if err != nil {
 		return 0, err
 	}
@@ -443,9 +478,8 @@ func (c *Client) CountElements(ctx context.Context, targetID string, selector st
 
 // IsVisible checks if an element is visible.
 func (c *Client) IsVisible(ctx context.Context, targetID string, selector string) (bool, error) {
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el) {
 			if (!el) return false;
 			const style = window.getComputedStyle(el);
 			const rect = el.getBoundingClientRect();
@@ -453,10 +487,10 @@ func (c *Client) IsVisible(ctx context.Context, targetID string, selector string
 			       style.visibility !== 'hidden' &&
 			       style.opacity !== '0' &&
 			       rect.width > 0 && rect.height > 0;
-		})()
-	`, selector)
+		}
+	`
 
-	result, err := c.Eval(ctx, targetID, js)
+	result, err := c.evalOnSelector(ctx, targetID, selector, js)
 	if err != nil {
 		return false, err
 	}
@@ -468,16 +502,15 @@ func (c *Client) IsVisible(ctx context.Context, targetID string, selector string
 
 // GetBoundingBox returns the bounding box of an element.
 func (c *Client) GetBoundingBox(ctx context.Context, targetID string, selector string) (*BoundingBox, error) {
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el) {
 			if (!el) return null;
 			const rect = el.getBoundingClientRect();
 			return { x: rect.x, y: rect.y, width: rect.width, height: rect.height };
-		})()
-	`, selector)
+		}
+	`
 
-	result, err := c.Eval(ctx, targetID, js)
+	result, err := c.evalOnSelector(ctx, targetID, selector, js)
 	if err != nil {
 		return nil, err
 	}
@@ -491,9 +524,11 @@ func (c *Client) GetBoundingBox(ctx context.Context, targetID string, selector s
 		return nil, fmt.Errorf("unexpected result type")
 	}
 
+	// Within a frame, the box is reported in the page's viewport
+	originX, originY := c.frameOrigin(targetID)
 	return &BoundingBox{
-		X:      m["x"].(float64),
-		Y:      m["y"].(float64),
+		X:      m["x"].(float64) + originX,
+		Y:      m["y"].(float64) + originY,
 		Width:  m["width"].(float64),
 		Height: m["height"].(float64),
 	}, nil
@@ -501,27 +536,23 @@ func (c *Client) GetBoundingBox(ctx context.Context, targetID string, selector s
 
 // GetComputedStyle returns the computed style value for a CSS property of an element.
 func (c *Client) GetComputedStyle(ctx context.Context, targetID string, selector string, property string) (*ComputedStyleResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Use JavaScript to get the computed style
-	jsExpr := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	jsFunc := `
+		function(el, property) {
 			if (!el) {
 				return {error: 'element not found'};
 			}
 			const style = window.getComputedStyle(el);
-			return {value: style.getPropertyValue(%q)};
-		})()
-	`, selector, property)
+			return {value: style.getPropertyValue(property)};
+		}
+	`
 
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression":    jsExpr,
-		"returnByValue": true,
-	})
+	result, err := c.callOnSelector(ctx, sessionID, selector, jsFunc, property)
 	if err != nil {
 		return nil, fmt.Errorf("evaluating computed style: %w", err)
 	}
@@ -550,19 +581,16 @@ func (c *Client) GetComputedStyle(ctx context.Context, targetID string, selector
 
 // GetComputedStyles returns computed CSS styles for an element.
 func (c *Client) GetComputedStyles(ctx context.Context, targetID string, selector string, properties []string) (map[string]string, error) {
-	// Build JS to get computed styles
-	propsJS := "null"
+	// Nil properties are passed as null, for the common properties
+	var props interface{}
 	if len(properties) > 0 {
-		propsJSON, _ := json.Marshal(properties)
-		propsJS = string(propsJSON)
+		props = properties
 	}
 
-	js := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	js := `
+		function(el, props) {
 			if (!el) return null;
 			const computed = window.getComputedStyle(el);
-			const props = %s;
 			const result = {};
 			if (props) {
 				for (const p of props) {
@@ -587,10 +615,10 @@ func (c *Client) GetComputedStyles(ctx context.Context, targetID string, selecto
 				}
 			}
 			return result;
-		})()
-	`, selector, propsJS)
+		}
+	`
 
-	result, err := c.Eval(ctx, targetID, js)
+	result, err := c.evalOnSelector(ctx, targetID, selector, js, props)
 	if err != nil {
 		return nil, err
 	}
@@ -616,8 +644,8 @@ func (c *Client) GetComputedStyles(ctx context.Context, targetID string, selecto
 
 // GetElementLayout returns comprehensive layout info for an element and its children.
 func (c *Client) GetElementLayout(ctx context.Context, targetID string, selector string, depth int) (*ElementLayout, error) {
-	js := fmt.Sprintf(`
-		(function() {
+	js := `
+		function(el, maxDepth) {
 			function getLayout(el, currentDepth, maxDepth) {
 				if (!el) return null;
 				const rect = el.getBoundingClientRect();
@@ -652,12 +680,11 @@ func (c *Client) GetElementLayout(ctx context.Context, targetID string, selector
 				return layout;
 			}
 
-			const el = document.querySelector(%q);
-			return getLayout(el, 0, %d);
-		})()
-	`, selector, depth)
+			return getLayout(el, 0, maxDepth);
+		}
+	`
 
-	result, err := c.Eval(ctx, targetID, js)
+	result, err := c.evalOnSelector(ctx, targetID, selector, js, depth)
 	if err != nil {
 		return nil, err
 	}
@@ -683,20 +710,16 @@ func (c *Client) GetElementLayout(ctx context.Context, targetID string, selector
 
 // GetValue retrieves the value of an input, textarea, or select element.
 func (c *Client) GetValue(ctx context.Context, targetID string, selector string) (string, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return "", err
 	}
 
 	// Use JavaScript to get the value
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression": fmt.Sprintf(`(function() {
-			const el = document.querySelector(%q);
+	result, err := c.callOnSelector(ctx, sessionID, selector, `function(el) {
 			if (!el) return {error: 'element not found'};
 			return {value: el.value || ''};
-		})()`, selector),
-		"returnByValue": true,
-	})
+		}`)
 	if err != nil {
 		return "", fmt.Errorf("getting value: %w", err)
 	}
@@ -722,22 +745,17 @@ func (c *Client) GetValue(ctx context.Context, targetID string, selector string)
 
 // SetValue directly sets the value of an input/textarea element.
 func (c *Client) SetValue(ctx context.Context, targetID string, selector string, value string) (*SetValueResult, error) {
-	escapedSelector := strings.ReplaceAll(selector, "'", "\\'")
-	escapedValue := strings.ReplaceAll(value, "'", "\\'")
-	escapedValue = strings.ReplaceAll(escapedValue, "\n", "\\n")
-
-	result, err := c.Eval(ctx, targetID, fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector('%s');
+	result, err := c.evalOnSelector(ctx, targetID, selector, `
+		function(el, selector, value) {
 			if (!el) {
-				return { error: 'Element not found: %s' };
+				return { error: 'Element not found: ' + selector };
 			}
-			el.value = '%s';
+			el.value = value;
 			el.dispatchEvent(new Event('input', { bubbles: true }));
 			el.dispatchEvent(new Event('change', { bubbles: true }));
-			return { selector: '%s', value: el.value };
-		})()
-	`, escapedSelector, escapedSelector, escapedValue, escapedSelector))
+			return { selector: selector, value: el.value };
+		}
+	`, selector, value)
 	if err != nil {
 		return nil, fmt.Errorf("setting value: %w", err)
 	}
@@ -759,12 +777,12 @@ func (c *Client) SetValue(ctx context.Context, targetID string, selector string,
 
 // GetSelection returns the currently selected text on the page.
 func (c *Client) GetSelection(ctx context.Context, targetID string) (*SelectionResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+	result, err := c.evaluate(ctx, sessionID, map[string]interface{}{
 		"expression":    "window.getSelection().toString()",
 		"returnByValue": true,
 	})
@@ -788,15 +806,14 @@ func (c *Client) GetSelection(ctx context.Context, targetID string) (*SelectionR
 
 // GetCaretPosition returns the caret (cursor) position in an input or textarea element.
 func (c *Client) GetCaretPosition(ctx context.Context, targetID string, selector string) (*CaretPositionResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Use JavaScript to get caret position
-	jsExpr := fmt.Sprintf(`
-		(function() {
-			const el = document.querySelector(%q);
+	jsFunc := `
+		function(el) {
 			if (!el) {
 				return {error: 'element not found'};
 			}
@@ -804,13 +821,10 @@ func (c *Client) GetCaretPosition(ctx context.Context, targetID string, selector
 				return {error: 'element does not support selection'};
 			}
 			return {start: el.selectionStart, end: el.selectionEnd};
-		})()
-	`, selector)
+		}
+	`
 
-	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-		"expression":    jsExpr,
-		"returnByValue": true,
-	})
+	result, err := c.callOnSelector(ctx, sessionID, selector, jsFunc)
 	if err != nil {
 		return nil, fmt.Errorf("getting caret position: %w", err)
 	}
@@ -880,48 +894,16 @@ func (c *Client) FindText(ctx context.Context, targetID string, text string) (*F
 
 // Highlight adds a visual highlight to an element for debugging.
 func (c *Client) Highlight(ctx context.Context, targetID string, selector string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
-	if err != nil {
-		return fmt.Errorf("enabling DOM: %w", err)
-	}
-
-	// Get document root
-	result, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-	if err != nil {
-		return fmt.Errorf("getting document: %w", err)
-	}
-
-	var docResp struct {
-		Root struct {
-			NodeID int64 `json:"nodeId"`
-		} `json:"root"`
-	}
-	if err := json.Unmarshal(result, &docResp); err != nil {
-		return fmt.Errorf("parsing document: %w", err)
-	}
-
-	// Query selector
-	result, err = c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": selector,
-	})
+	nodeID, err := c.queryNodeID(ctx, sessionID, selector)
 	if err != nil {
-		return fmt.Errorf("querying selector: %w", err)
-	}
-
-	var queryResp struct {
-		NodeID int64 `json:"nodeId"`
-	}
-	if err := json.Unmarshal(result, &queryResp); err != nil {
-		return fmt.Errorf("parsing query response: %w", err)
+		return err
 	}
-	if queryResp.NodeID == 0 {
+	if nodeID == 0 {
 		return fmt.Errorf("selector %q: element not found", selector)
 	}
 
@@ -932,7 +914,7 @@ func (c *Client) Highlight(ctx context.Context, targetID string, selector string
 	}
 
 	_, err = c.CallSession(ctx, sessionID, "Overlay.highlightNode", map[string]interface{}{
-		"nodeId": queryResp.NodeID,
+		"nodeId": nodeID,
 		"highlightConfig": map[string]interface{}{
 			"showInfo":       true,
 			"showExtensions": true,
@@ -951,7 +933,7 @@ func (c *Client) Highlight(ctx context.Context, targetID string, selector string
 
 // HideHighlight removes any element highlight.
 func (c *Client) HideHighlight(ctx context.Context, targetID string) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
@@ -966,54 +948,19 @@ func (c *Client) HideHighlight(ctx context.Context, targetID string) error {
 
 // GetEventListeners returns the event listeners attached to a DOM element.
 func (c *Client) GetEventListeners(ctx context.Context, targetID string, selector string) (*EventListenersResult, error) {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	nodeID, err := c.resolveNodeID(ctx, sessionID, selector)
 	if err != nil {
-		return nil, fmt.Errorf("enabling DOM domain: %w", err)
-	}
-
-	// Get document root
-	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-	if err != nil {
-		return nil, fmt.Errorf("getting document: %w", err)
-	}
-
-	var docResp struct {
-		Root struct {
-			NodeID int `json:"nodeId"`
-		} `json:"root"`
-	}
-	if err := json.Unmarshal(docResult, &docResp); err != nil {
-		return nil, fmt.Errorf("parsing document response: %w", err)
-	}
-
-	// Query for element
-	queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-		"nodeId":   docResp.Root.NodeID,
-		"selector": selector,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("querying selector: %w", err)
-	}
-
-	var queryResp struct {
-		NodeID int `json:"nodeId"`
-	}
-	if err := json.Unmarshal(queryResult, &queryResp); err != nil {
-		return nil, fmt.Errorf("parsing query response: %w", err)
-	}
-	if queryResp.NodeID == 0 {
-		return nil, fmt.Errorf("element not found: %s", selector)
+		return nil, err
 	}
 
 	// Resolve node to get remote object ID
 	resolveResult, err := c.CallSession(ctx, sessionID, "DOM.resolveNode", map[string]interface{}{
-		"nodeId": queryResp.NodeID,
+		"nodeId": nodeID,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("resolving node: %w", err)
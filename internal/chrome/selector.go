@@ -0,0 +1,1105 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Selectors
+//
+// Every selector-taking method accepts, besides plain CSS:
+//
+//	xpath=//form//button      XPath; a leading // or .. also selects XPath
+//	text="Sign in"            Element whose whitespace-normalized text is exactly "Sign in"
+//	text=sign in              Element whose text contains "sign in", ignoring case
+//	role=button[name="Save"]  Element with the ARIA role and accessible name, from the accessibility tree
+//	data-testid=submit        Element with data-testid="submit"
+//	css=.nav a                Explicit CSS
+//
+// Parts joined with >> are chained: each part is matched within the
+// elements matched by the part before it, e.g. `#login >> text=Submit`.
+// Joined with >>> instead, the part is also matched inside the open shadow
+// roots below those elements, however deeply nested, and a selector that
+// starts with >>> searches every shadow root in the document, e.g.
+// `>>> text=Pay`. role= selectors always see inside shadow roots, as the
+// accessibility tree does. Plain CSS selectors take the DOM.querySelector
+// fast path.
+//
+// An element ref such as @e12, handed out by hubcap for an element it
+// listed, selects that element for as long as it stays in the same
+// document of the same frame, and can start a chain, e.g.
+// `@e12 >> text=Save`. Refs are set with SetRefs.
+//
+// A part ending in :nth=N keeps only the Nth of its matches, counting from
+// 0 in document order, e.g. `li:nth=2 >> a`. Methods acting on one element
+// take the first match, or with SetStrict fail with an
+// *AmbiguousSelectorError when there is more than one.
+
+// selectorEngines are the known selector prefixes, without the "=".
+var selectorEngines = []string{"css", "xpath", "text", "role", "data-testid"}
+
+// nthSuffix matches the :nth=N that ends a selector part.
+var nthSuffix = regexp.MustCompile(`:nth=(\d+)$`)
+
+// elementRef matches an element ref such as @e12.
+var elementRef = regexp.MustCompile(`^@(e\d+)$`)
+
+// StaleElementError is returned for an element ref whose element is no
+// longer in the page.
+type StaleElementError struct {
+	Ref string `json:"ref"`
+}
+
+func (e *StaleElementError) Error() string {
+	return fmt.Sprintf("stale element @%s: it is no longer in the page, list the elements again for new refs", e.Ref)
+}
+
+// maxCandidates is the number of matches listed by an AmbiguousSelectorError.
+const maxCandidates = 10
+
+// AmbiguousSelectorError is returned in strict mode when a selector for a
+// single element matches more than one.
+type AmbiguousSelectorError struct {
+	Selector   string   `json:"selector"`
+	Count      int      `json:"count"`
+	Candidates []string `json:"candidates"` // The first matches, such as <button id="save">
+}
+
+func (e *AmbiguousSelectorError) Error() string {
+	msg := fmt.Sprintf("selector %s matches %d elements: %s", e.Selector, e.Count, strings.Join(e.Candidates, ", "))
+	if more := e.Count - len(e.Candidates); more > 0 {
+		msg += fmt.Sprintf(" and %d more", more)
+	}
+	return msg + " (add :nth=N to pick one)"
+}
+
+// ElementRef is the element an element ref selects. Backend node IDs are
+// only unique within a renderer process, so a ref also holds the frame
+// selectors ran in when it was handed out and the loader ID of the frame's
+// document, see RefDocument.
+type ElementRef struct {
+	BackendNodeID int64  `json:"node"`
+	FrameID       string `json:"frame"`
+	LoaderID      string `json:"loader"`
+}
+
+// SetRefs sets the element refs selectors may use, from ref names such as
+// "e12".
+func (c *Client) SetRefs(refs map[string]ElementRef) {
+	c.refs = refs
+}
+
+// RefDocument returns the frame selectors for a target run in, the one set
+// by SetFrame or the page's main frame, and the loader ID of its current
+// document, for the refs of elements listed there.
+func (c *Client) RefDocument(ctx context.Context, targetID string) (frameID, loaderID string, err error) {
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return "", "", err
+	}
+	return c.sessionDocument(ctx, sessionID)
+}
+
+// PageDocument returns a target's main frame and the loader ID of its
+// current document, for the refs of elements listed from the whole page
+// whatever frame SetFrame set.
+func (c *Client) PageDocument(ctx context.Context, targetID string) (frameID, loaderID string, err error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return "", "", err
+	}
+	return c.frameDocument(ctx, sessionID, "")
+}
+
+// sessionDocument returns the frame selectors in a session run in and the
+// loader ID of its current document, or "" if the frame has gone.
+func (c *Client) sessionDocument(ctx context.Context, sessionID string) (frameID, loaderID string, err error) {
+	if scope := c.scopeOf(sessionID); scope != nil {
+		return c.frameDocument(ctx, sessionID, scope.FrameID)
+	}
+	return c.frameDocument(ctx, sessionID, "")
+}
+
+// frameDocument returns a frame of a session, or the session's main frame
+// if frameID is "", and the loader ID of its current document, or "" if
+// the frame has gone.
+func (c *Client) frameDocument(ctx context.Context, sessionID, frameID string) (string, string, error) {
+	if _, err := c.CallSession(ctx, sessionID, "Page.enable", nil); err != nil {
+		return "", "", fmt.Errorf("enabling Page domain: %w", err)
+	}
+	result, err := c.CallSession(ctx, sessionID, "Page.getFrameTree", nil)
+	if err != nil {
+		return "", "", fmt.Errorf("getting frame tree: %w", err)
+	}
+	var resp struct {
+		FrameTree frameTreeNode `json:"frameTree"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return "", "", fmt.Errorf("parsing frame tree: %w", err)
+	}
+
+	if frameID == "" {
+		frameID = resp.FrameTree.Frame.ID
+	}
+	var find func(node frameTreeNode) string
+	find = func(node frameTreeNode) string {
+		if node.Frame.ID == frameID {
+			return node.Frame.LoaderID
+		}
+		for _, child := range node.ChildFrames {
+			if loaderID := find(child); loaderID != "" {
+				return loaderID
+			}
+		}
+		return ""
+	}
+	return frameID, find(resp.FrameTree), nil
+}
+
+// lookupRef returns the element of an element ref, or a *StaleElementError
+// if the session's frame or document is not the one it was handed out in.
+func (c *Client) lookupRef(ctx context.Context, sessionID string, ref string) (int64, error) {
+	element, ok := c.refs[ref]
+	if !ok {
+		return 0, fmt.Errorf("unknown element ref @%s", ref)
+	}
+	frameID, loaderID, err := c.sessionDocument(ctx, sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if frameID != element.FrameID || loaderID != element.LoaderID {
+		return 0, &StaleElementError{Ref: ref}
+	}
+	return element.BackendNodeID, nil
+}
+
+// SetStrict sets whether selectors for a single element must match only
+// one, rather than taking the first match.
+func (c *Client) SetStrict(strict bool) {
+	c.strict = strict
+}
+
+// SetIndex sets the match, counting from 0, taken by selectors for a
+// single element that have no :nth of their own. A negative index takes
+// the first match, subject to SetStrict.
+func (c *Client) SetIndex(index int) {
+	c.index = index
+}
+
+// selectorPart is one part of a chained selector.
+type selectorPart struct {
+	Engine string `json:"engine"`
+	Body   string `json:"body"`
+	Deep   bool   `json:"deep,omitempty"` // Joined to the part before by >>>
+}
+
+// parseSelector splits a selector into its chained parts.
+func parseSelector(selector string) ([]selectorPart, error) {
+	var parts []selectorPart
+	chain, deep := splitSelectorChain(selector)
+	for i, s := range chain {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			// A leading >>> searches from the document
+			if i == 0 && len(chain) > 1 && deep[1] {
+				continue
+			}
+			return nil, fmt.Errorf("invalid selector %q: empty part", selector)
+		}
+		nth := ""
+		if m := nthSuffix.FindStringSubmatch(s); m != nil {
+			s, nth = strings.TrimSpace(s[:len(s)-len(m[0])]), m[1]
+			if s == "" {
+				return nil, fmt.Errorf("invalid selector %q: :nth=%s must follow a selector", selector, nth)
+			}
+		}
+		if m := elementRef.FindStringSubmatch(s); m != nil {
+			if len(parts) > 0 || deep[i] {
+				return nil, fmt.Errorf("invalid selector %q: element ref %s must start the selector", selector, s)
+			}
+			parts = append(parts, selectorPart{Engine: "ref", Body: m[1]})
+			if nth != "" {
+				parts = append(parts, selectorPart{Engine: "nth", Body: nth})
+			}
+			continue
+		}
+		part := selectorPart{Engine: "css", Body: s, Deep: deep[i]}
+		for _, engine := range selectorEngines {
+			if body, ok := strings.CutPrefix(s, engine+"="); ok {
+				part.Engine = engine
+				part.Body = strings.TrimSpace(body)
+				break
+			}
+		}
+		if part.Engine == "css" && (strings.HasPrefix(s, "//") || strings.HasPrefix(s, "..")) {
+			part.Engine = "xpath"
+		}
+		if part.Body == "" {
+			return nil, fmt.Errorf("invalid selector %q: empty %s selector", selector, part.Engine)
+		}
+		if part.Engine == "role" {
+			if _, err := parseRoleSelector(part.Body); err != nil {
+				return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+			}
+		}
+		parts = append(parts, part)
+		if nth != "" {
+			parts = append(parts, selectorPart{Engine: "nth", Body: nth})
+		}
+	}
+	return parts, nil
+}
+
+// hasNth reports whether a selector picks one of its matches with :nth.
+func hasNth(selector string) bool {
+	parts, err := parseSelector(selector)
+	return err == nil && slices.ContainsFunc(parts, func(p selectorPart) bool { return p.Engine == "nth" })
+}
+
+// singleSelector returns the selector used to find a single element: with
+// the index set by SetIndex unless it has an :nth.
+func (c *Client) singleSelector(selector string) string {
+	if c.index < 0 || hasNth(selector) {
+		return selector
+	}
+	return fmt.Sprintf("%s:nth=%d", selector, c.index)
+}
+
+// splitSelectorChain splits a selector on the >> and >>> that are not
+// inside quotes or brackets, reporting for each part whether it followed
+// a >>>.
+func splitSelectorChain(selector string) (parts []string, deep []bool) {
+	deep = []bool{false}
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(selector); i++ {
+		ch := selector[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '(':
+			depth++
+		case ch == ']' || ch == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(selector[i:], ">>"):
+			parts = append(parts, selector[start:i])
+			combinator := ">>"
+			if strings.HasPrefix(selector[i:], ">>>") {
+				combinator = ">>>"
+			}
+			deep = append(deep, combinator == ">>>")
+			start = i + len(combinator)
+			i = start - 1
+		}
+	}
+	return append(parts, selector[start:]), deep
+}
+
+// cssSelector returns the CSS of a selector that is a single CSS part,
+// which can be passed straight to querySelector.
+func cssSelector(selector string) (string, bool) {
+	parts, err := parseSelector(selector)
+	if err != nil || len(parts) != 1 || parts[0].Engine != "css" || parts[0].Deep {
+		return "", false
+	}
+	return parts[0].Body, true
+}
+
+// roleSelector is the body of a role= selector: role[name="..."].
+type roleSelector struct {
+	Role  string
+	Name  string
+	Exact bool // Name was quoted: match it exactly rather than as a substring
+	Named bool // A name was given
+}
+
+// parseRoleSelector parses the body of a role= selector.
+func parseRoleSelector(body string) (roleSelector, error) {
+	role, attrs, _ := strings.Cut(body, "[")
+	rs := roleSelector{Role: strings.TrimSpace(role)}
+	if rs.Role == "" {
+		return rs, fmt.Errorf("role selector %q: missing role", body)
+	}
+	if attrs == "" {
+		return rs, nil
+	}
+	attrs, ok := strings.CutSuffix(strings.TrimSpace(attrs), "]")
+	if !ok {
+		return rs, fmt.Errorf("role selector %q: missing ]", body)
+	}
+	key, value, ok := strings.Cut(attrs, "=")
+	if !ok || strings.TrimSpace(key) != "name" {
+		return rs, fmt.Errorf("role selector %q: only [name=...] is supported", body)
+	}
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+		rs.Exact = true
+	}
+	rs.Name = value
+	rs.Named = true
+	return rs, nil
+}
+
+// matches reports whether an accessible name matches the selector's name.
+func (rs roleSelector) matches(name string) bool {
+	if !rs.Named {
+		return true
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	want := strings.Join(strings.Fields(rs.Name), " ")
+	if rs.Exact {
+		return name == want
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(want))
+}
+
+// selectorEngine is a JavaScript function returning the elements matched
+// by a chain of non-role selector parts within roots, without duplicates
+// and in document order, with the contents of a shadow root following its
+// host.
+const selectorEngine = `function(parts, roots) {
+	const normalize = s => s.replace(/\s+/g, ' ').trim();
+	const skip = new Set(['SCRIPT', 'STYLE', 'NOSCRIPT', 'TEMPLATE', 'HEAD']);
+	const texts = new Map();
+	const text = el => {
+		if (texts.has(el)) return texts.get(el);
+		let s = '';
+		if (el.tagName === 'INPUT' && ['button', 'submit', 'reset'].includes(el.type)) {
+			s = el.value;
+		} else {
+			for (const n of el.childNodes) {
+				if (n.nodeType === 3) s += n.nodeValue;
+				else if (n.nodeType === 1 && !skip.has(n.tagName)) s += text(n);
+			}
+		}
+		texts.set(el, s);
+		return s;
+	};
+	const textMatcher = body => {
+		const quoted = body.match(/^(["'])([\s\S]*)\1$/);
+		if (quoted) {
+			const want = normalize(quoted[2]);
+			return el => normalize(text(el)) === want;
+		}
+		const want = normalize(body).toLowerCase();
+		return el => normalize(text(el)).toLowerCase().includes(want);
+	};
+	const engines = {
+		'css': (root, body) => Array.from(root.querySelectorAll(body)),
+		'data-testid': (root, body) => Array.from(root.querySelectorAll('[data-testid="' + CSS.escape(body) + '"]')),
+		'xpath': (root, body) => {
+			if (root.nodeType !== 9 && body.startsWith('/')) body = '.' + body;
+			const doc = root.ownerDocument || root;
+			const found = doc.evaluate(body, root, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+			const els = [];
+			for (let i = 0; i < found.snapshotLength; i++) {
+				if (found.snapshotItem(i).nodeType === 1) els.push(found.snapshotItem(i));
+			}
+			return els;
+		},
+		'text': (root, body) => {
+			const match = textMatcher(body);
+			const candidates = (root.nodeType === 1 ? [root] : []).concat(Array.from(root.querySelectorAll('*')));
+			const matched = new Set(candidates.filter(el => !skip.has(el.tagName) && match(el)));
+			// A child holding the text is a better match than its parent
+			return Array.from(matched).filter(el => !Array.from(el.children).some(child => matched.has(child)));
+		},
+	};
+	const withShadowRoots = root => {
+		const found = [root];
+		for (let i = 0; i < found.length; i++) {
+			if (found[i].shadowRoot) found.push(found[i].shadowRoot);
+			for (const el of found[i].querySelectorAll('*')) {
+				if (el.shadowRoot) found.push(el.shadowRoot);
+			}
+		}
+		return found;
+	};
+	const hosts = n => {
+		const chain = [n];
+		for (let root = n.getRootNode(); root.host; root = root.host.getRootNode()) chain.push(root.host);
+		return chain;
+	};
+	const order = (a, b) => {
+		if (a === b) return 0;
+		const ca = hosts(a), cb = hosts(b);
+		for (const x of ca) {
+			for (const y of cb) {
+				// One is inside the other's shadow root
+				if (x === y) return x === a ? -1 : 1;
+				if (x.getRootNode() === y.getRootNode()) {
+					return x.compareDocumentPosition(y) & Node.DOCUMENT_POSITION_FOLLOWING ? -1 : 1;
+				}
+			}
+		}
+		return 0;
+	};
+	let current = roots;
+	for (const part of parts) {
+		if (part.engine === 'nth') {
+			const sorted = Array.from(new Set(current)).sort(order);
+			const n = Number(part.body);
+			current = n < sorted.length ? [sorted[n]] : [];
+			continue;
+		}
+		const found = new Set();
+		for (const root of current) {
+			for (const scope of part.deep ? withShadowRoots(root) : [root]) {
+				for (const el of engines[part.engine](scope, part.body)) found.add(el);
+			}
+		}
+		current = Array.from(found);
+	}
+	return Array.from(new Set(current)).sort(order);
+}`
+
+// remoteObject is the part of a Runtime.RemoteObject used by selectors.
+type remoteObject struct {
+	Type     string `json:"type"`
+	ObjectID string `json:"objectId"`
+}
+
+// exceptionDetails is a JavaScript exception from Runtime.evaluate or
+// Runtime.callFunctionOn.
+type exceptionDetails struct {
+	Text      string `json:"text"`
+	Exception *struct {
+		Description string `json:"description"`
+	} `json:"exception"`
+}
+
+func (e *exceptionDetails) Error() string {
+	if e.Exception != nil && e.Exception.Description != "" {
+		// The first line of the stack trace is the message
+		msg, _, _ := strings.Cut(e.Exception.Description, "\n")
+		return msg
+	}
+	return e.Text
+}
+
+// queryElements returns the remote object IDs of the elements matching
+// selector, in document order.
+func (c *Client) queryElements(ctx context.Context, sessionID string, selector string) ([]string, error) {
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []string // nil for the document
+	for len(parts) > 0 {
+		// Each step's elements are done with once the next has run
+		prev := roots
+		if parts[0].Engine == "ref" {
+			roots, err = c.resolveRef(ctx, sessionID, parts[0].Body)
+			if err != nil {
+				return nil, err
+			}
+			parts = parts[1:]
+		} else if parts[0].Engine == "role" {
+			roots, err = c.queryRole(ctx, sessionID, roots, parts[0].Body)
+			parts = parts[1:]
+		} else {
+			n := 1
+			for n < len(parts) && parts[n].Engine != "role" {
+				n++
+			}
+			roots, err = c.queryJS(ctx, sessionID, roots, parts[:n])
+			parts = parts[n:]
+		}
+		c.releaseObjects(sessionID, prev...)
+		if err != nil {
+			return nil, fmt.Errorf("querying selector %s: %w", selector, err)
+		}
+		if len(roots) == 0 {
+			return nil, nil
+		}
+	}
+	return roots, nil
+}
+
+// resolveRef returns the element of an element ref, or a
+// *StaleElementError if it is no longer in the page.
+func (c *Client) resolveRef(ctx context.Context, sessionID string, ref string) ([]string, error) {
+	backendNodeID, err := c.lookupRef(ctx, sessionID, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	objectID, err := c.resolveObjectID(ctx, sessionID, map[string]interface{}{"backendNodeId": backendNodeID})
+	if err != nil {
+		return nil, &StaleElementError{Ref: ref}
+	}
+
+	// A removed element lives on while something refers to it
+	result, err := c.CallSession(ctx, sessionID, "Runtime.callFunctionOn", map[string]interface{}{
+		"objectId":            objectID,
+		"functionDeclaration": `function() { return this.isConnected; }`,
+		"returnByValue":       true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("checking element ref: %w", err)
+	}
+	var resp struct {
+		Result struct {
+			Value bool `json:"value"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing element ref: %w", err)
+	}
+	if !resp.Result.Value {
+		return nil, &StaleElementError{Ref: ref}
+	}
+	return []string{objectID}, nil
+}
+
+// queryJS runs selectorEngine within roots, or the document if roots is
+// nil, and returns the matching elements.
+func (c *Client) queryJS(ctx context.Context, sessionID string, roots []string, parts []selectorPart) ([]string, error) {
+	var result json.RawMessage
+	var err error
+	if roots == nil {
+		partsJSON, _ := json.Marshal(parts)
+		result, err = c.evaluate(ctx, sessionID, map[string]interface{}{
+			"expression": fmt.Sprintf("(%s)(%s, [document])", selectorEngine, partsJSON),
+		})
+	} else {
+		if parts == nil {
+			parts = []selectorPart{}
+		}
+		args := []map[string]interface{}{{"value": parts}}
+		for _, id := range roots {
+			args = append(args, map[string]interface{}{"objectId": id})
+		}
+		result, err = c.CallSession(ctx, sessionID, "Runtime.callFunctionOn", map[string]interface{}{
+			"objectId":            roots[0],
+			"functionDeclaration": fmt.Sprintf("function(parts, ...roots) { return (%s)(parts, roots); }", selectorEngine),
+			"arguments":           args,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.arrayElements(ctx, sessionID, result)
+}
+
+// queryRole finds the elements with a role and accessible name in the
+// accessibility tree below each of roots, or the document if roots is nil.
+func (c *Client) queryRole(ctx context.Context, sessionID string, roots []string, body string) ([]string, error) {
+	rs, err := parseRoleSelector(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if roots == nil {
+		doc, err := c.documentObjectID(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		defer c.releaseObjects(sessionID, doc)
+		roots = []string{doc}
+	}
+
+	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling DOM domain: %w", err)
+	}
+	_, err = c.CallSession(ctx, sessionID, "Accessibility.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling Accessibility domain: %w", err)
+	}
+
+	seen := make(map[int64]bool)
+	var elements []string
+	for _, root := range roots {
+		result, err := c.CallSession(ctx, sessionID, "Accessibility.queryAXTree", map[string]interface{}{
+			"objectId": root,
+			"role":     rs.Role,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("querying accessibility tree: %w", err)
+		}
+
+		var resp struct {
+			Nodes []struct {
+				Ignored bool `json:"ignored"`
+				Name    *struct {
+					Value string `json:"value"`
+				} `json:"name"`
+				BackendDOMNodeID int64 `json:"backendDOMNodeId"`
+			} `json:"nodes"`
+		}
+		if err := json.Unmarshal(result, &resp); err != nil {
+			return nil, fmt.Errorf("parsing accessibility tree: %w", err)
+		}
+
+		for _, node := range resp.Nodes {
+			name := ""
+			if node.Name != nil {
+				name = node.Name.Value
+			}
+			if node.Ignored || node.BackendDOMNodeID == 0 || seen[node.BackendDOMNodeID] || !rs.matches(name) {
+				continue
+			}
+			seen[node.BackendDOMNodeID] = true
+
+			resolved, err := c.CallSession(ctx, sessionID, "DOM.resolveNode", map[string]interface{}{
+				"backendNodeId": node.BackendDOMNodeID,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("resolving node: %w", err)
+			}
+			var resolveResp struct {
+				Object remoteObject `json:"object"`
+			}
+			if err := json.Unmarshal(resolved, &resolveResp); err != nil {
+				return nil, fmt.Errorf("parsing resolve response: %w", err)
+			}
+			elements = append(elements, resolveResp.Object.ObjectID)
+		}
+	}
+
+	if len(roots) == 1 || len(elements) < 2 {
+		return elements, nil
+	}
+	// Matches below different roots may interleave
+	defer c.releaseObjects(sessionID, elements...)
+	return c.queryJS(ctx, sessionID, elements, nil)
+}
+
+// documentObjectID returns a remote object ID for the document.
+func (c *Client) documentObjectID(ctx context.Context, sessionID string) (string, error) {
+	result, err := c.evaluate(ctx, sessionID, map[string]interface{}{
+		"expression": "document",
+	})
+	if err != nil {
+		return "", fmt.Errorf("getting document: %w", err)
+	}
+	var resp struct {
+		Result remoteObject `json:"result"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return "", fmt.Errorf("parsing document: %w", err)
+	}
+	return resp.Result.ObjectID, nil
+}
+
+// arrayElements returns the object IDs of the elements of an array
+// returned by Runtime.evaluate or Runtime.callFunctionOn.
+func (c *Client) arrayElements(ctx context.Context, sessionID string, result json.RawMessage) ([]string, error) {
+	var resp struct {
+		Result           remoteObject      `json:"result"`
+		ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("parsing query response: %w", err)
+	}
+	if resp.ExceptionDetails != nil {
+		return nil, resp.ExceptionDetails
+	}
+
+	props, err := c.CallSession(ctx, sessionID, "Runtime.getProperties", map[string]interface{}{
+		"objectId":      resp.Result.ObjectID,
+		"ownProperties": true,
+	})
+	c.releaseObjects(sessionID, resp.Result.ObjectID)
+	if err != nil {
+		return nil, fmt.Errorf("getting elements: %w", err)
+	}
+
+	var propsResp struct {
+		Result []struct {
+			Name  string        `json:"name"`
+			Value *remoteObject `json:"value"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(props, &propsResp); err != nil {
+		return nil, fmt.Errorf("parsing elements: %w", err)
+	}
+
+	type indexed struct {
+		index int
+		id    string
+	}
+	var elements []indexed
+	for _, p := range propsResp.Result {
+		var i int
+		if _, err := fmt.Sscan(p.Name, &i); err != nil || p.Value == nil || p.Value.ObjectID == "" {
+			continue
+		}
+		elements = append(elements, indexed{i, p.Value.ObjectID})
+	}
+	sort.Slice(elements, func(a, b int) bool { return elements[a].index < elements[b].index })
+
+	ids := make([]string, len(elements))
+	for i, e := range elements {
+		ids[i] = e.id
+	}
+	return ids, nil
+}
+
+// queryNodeID returns the DOM node ID of the first element matching
+// selector, or 0 if there is none.
+func (c *Client) queryNodeID(ctx context.Context, sessionID string, selector string) (int64, error) {
+	selector = c.singleSelector(selector)
+	if err := c.checkStrict(ctx, sessionID, selector); err != nil {
+		return 0, err
+	}
+
+	_, err := c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	if err != nil {
+		return 0, fmt.Errorf("enabling DOM domain: %w", err)
+	}
+
+	// Node IDs are only assigned once the document has been requested
+	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
+	if err != nil {
+		return 0, fmt.Errorf("getting document: %w", err)
+	}
+
+	if parts, err := parseSelector(selector); err == nil && len(parts) == 1 && parts[0].Engine == "ref" {
+		return c.pushRef(ctx, sessionID, parts[0].Body)
+	}
+
+	// In a frame of the page's process, the document is not the frame's
+	scope := c.scopeOf(sessionID)
+	if css, ok := cssSelector(selector); ok && (scope == nil || scope.ContextID == 0) {
+		var docResp struct {
+			Root struct {
+				NodeID int64 `json:"nodeId"`
+			} `json:"root"`
+		}
+		if err := json.Unmarshal(docResult, &docResp); err != nil {
+			return 0, fmt.Errorf("parsing document response: %w", err)
+		}
+
+		queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
+			"nodeId":   docResp.Root.NodeID,
+			"selector": css,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("querying selector: %w", err)
+		}
+
+		var queryResp struct {
+			NodeID int64 `json:"nodeId"`
+		}
+		if err := json.Unmarshal(queryResult, &queryResp); err != nil {
+			return 0, fmt.Errorf("parsing query response: %w", err)
+		}
+		return queryResp.NodeID, nil
+	}
+
+	elements, err := c.queryElements(ctx, sessionID, selector)
+	if err != nil || len(elements) == 0 {
+		return 0, err
+	}
+	defer c.releaseObjects(sessionID, elements...)
+	return c.requestNodeID(ctx, sessionID, elements[0])
+}
+
+// pushRef returns the DOM node ID of the element of an element ref, or a
+// *StaleElementError if it is no longer in the document.
+func (c *Client) pushRef(ctx context.Context, sessionID string, ref string) (int64, error) {
+	backendNodeID, err := c.lookupRef(ctx, sessionID, ref)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "DOM.pushNodesByBackendIdsToFrontend", map[string]interface{}{
+		"backendNodeIds": []int64{backendNodeID},
+	})
+	if err != nil {
+		return 0, &StaleElementError{Ref: ref}
+	}
+	var resp struct {
+		NodeIDs []int64 `json:"nodeIds"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return 0, fmt.Errorf("parsing node response: %w", err)
+	}
+	// Nodes outside the document have no node ID
+	if len(resp.NodeIDs) == 0 || resp.NodeIDs[0] == 0 {
+		return 0, &StaleElementError{Ref: ref}
+	}
+	return resp.NodeIDs[0], nil
+}
+
+// queryNodeIDs returns the DOM node IDs of every element matching
+// selector, in document order.
+func (c *Client) queryNodeIDs(ctx context.Context, sessionID string, selector string) ([]int64, error) {
+	_, err := c.CallSession(ctx, sessionID, "DOM.enable", nil)
+	if err != nil {
+		return nil, fmt.Errorf("enabling DOM domain: %w", err)
+	}
+
+	docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting document: %w", err)
+	}
+
+	scope := c.scopeOf(sessionID)
+	if css, ok := cssSelector(selector); ok && (scope == nil || scope.ContextID == 0) {
+		var docResp struct {
+			Root struct {
+				NodeID int64 `json:"nodeId"`
+			} `json:"root"`
+		}
+		if err := json.Unmarshal(docResult, &docResp); err != nil {
+			return nil, fmt.Errorf("parsing document response: %w", err)
+		}
+
+		queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelectorAll", map[string]interface{}{
+			"nodeId":   docResp.Root.NodeID,
+			"selector": css,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("querying selector: %w", err)
+		}
+
+		var queryResp struct {
+			NodeIDs []int64 `json:"nodeIds"`
+		}
+		if err := json.Unmarshal(queryResult, &queryResp); err != nil {
+			return nil, fmt.Errorf("parsing query response: %w", err)
+		}
+		return queryResp.NodeIDs, nil
+	}
+
+	elements, err := c.queryElements(ctx, sessionID, selector)
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseObjects(sessionID, elements...)
+	nodeIDs := make([]int64, len(elements))
+	for i, objectID := range elements {
+		if nodeIDs[i], err = c.requestNodeID(ctx, sessionID, objectID); err != nil {
+			return nil, err
+		}
+	}
+	return nodeIDs, nil
+}
+
+// requestNodeID returns the DOM node ID of a remote object.
+func (c *Client) requestNodeID(ctx context.Context, sessionID string, objectID string) (int64, error) {
+	result, err := c.CallSession(ctx, sessionID, "DOM.requestNode", map[string]interface{}{
+		"objectId": objectID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("requesting node: %w", err)
+	}
+	var resp struct {
+		NodeID int64 `json:"nodeId"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return 0, fmt.Errorf("parsing node response: %w", err)
+	}
+	return resp.NodeID, nil
+}
+
+// checkStrict returns an *AmbiguousSelectorError if strict mode is set and
+// selector matches more than one element.
+func (c *Client) checkStrict(ctx context.Context, sessionID string, selector string) error {
+	if !c.strict {
+		return nil
+	}
+
+	result, err := c.callOnSelectorAll(ctx, sessionID, selector, `(els, max) => ({
+		count: els.length,
+		candidates: els.slice(0, max).map(el => [el.localName, el.id, el.getAttribute('class') || '']),
+	})`, maxCandidates)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Result struct {
+			Value struct {
+				Count      int         `json:"count"`
+				Candidates [][3]string `json:"candidates"`
+			} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return fmt.Errorf("parsing matches: %w", err)
+	}
+	if resp.ExceptionDetails != nil {
+		return fmt.Errorf("querying selector %s: %w", selector, resp.ExceptionDetails)
+	}
+
+	matches := resp.Result.Value
+	if matches.Count <= 1 {
+		return nil
+	}
+	ambiguous := &AmbiguousSelectorError{Selector: selector, Count: matches.Count}
+	for _, el := range matches.Candidates {
+		var attrs []string
+		if el[1] != "" {
+			attrs = append(attrs, "id", el[1])
+		}
+		if el[2] != "" {
+			attrs = append(attrs, "class", el[2])
+		}
+		ambiguous.Candidates = append(ambiguous.Candidates, describeElement(el[0], attrs))
+	}
+	return ambiguous
+}
+
+// callOnSelector calls fn, a JavaScript function declaration, with the
+// first element matching selector, or null if there is none, followed by
+// args. It returns the raw Runtime.evaluate-style response, with the
+// result returned by value.
+func (c *Client) callOnSelector(ctx context.Context, sessionID string, selector string, fn string, args ...interface{}) (json.RawMessage, error) {
+	return c.callOnElements(ctx, sessionID, selector, false, fn, args)
+}
+
+// callOnSelectorAll is callOnSelector with an array of every matching
+// element in place of the first.
+func (c *Client) callOnSelectorAll(ctx context.Context, sessionID string, selector string, fn string, args ...interface{}) (json.RawMessage, error) {
+	return c.callOnElements(ctx, sessionID, selector, true, fn, args)
+}
+
+func (c *Client) callOnElements(ctx context.Context, sessionID string, selector string, all bool, fn string, args []interface{}) (json.RawMessage, error) {
+	if !all {
+		selector = c.singleSelector(selector)
+		if err := c.checkStrict(ctx, sessionID, selector); err != nil {
+			return nil, err
+		}
+	}
+
+	parts, err := parseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	// Selectors the engine can run in the page are evaluated in one call
+	if runsInPage(parts) {
+		expr := fmt.Sprintf("(%s)(%s", fn, pageElements(selector, parts, all))
+		for _, arg := range args {
+			argJSON, err := json.Marshal(arg)
+			if err != nil {
+				return nil, err
+			}
+			expr += ", " + string(argJSON)
+		}
+		expr += ")"
+
+		return c.evaluate(ctx, sessionID, map[string]interface{}{
+			"expression":    expr,
+			"returnByValue": true,
+		})
+	}
+
+	elements, err := c.queryElements(ctx, sessionID, selector)
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseObjects(sessionID, elements...)
+	if !all && len(elements) > 1 {
+		elements = elements[:1]
+	}
+	doc, err := c.documentObjectID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseObjects(sessionID, doc)
+
+	callArgs := []map[string]interface{}{{"value": len(elements)}}
+	for _, id := range elements {
+		callArgs = append(callArgs, map[string]interface{}{"objectId": id})
+	}
+	for _, arg := range args {
+		callArgs = append(callArgs, map[string]interface{}{"value": arg})
+	}
+	first := "els"
+	if !all {
+		first = "els[0] || null"
+	}
+	return c.CallSession(ctx, sessionID, "Runtime.callFunctionOn", map[string]interface{}{
+		"objectId":            doc,
+		"functionDeclaration": fmt.Sprintf("function(n, ...rest) { const els = rest.slice(0, n); return (%s)(%s, ...rest.slice(n)); }", fn, first),
+		"arguments":           callArgs,
+		"returnByValue":       true,
+	})
+}
+
+// runsInPage reports whether the selector engine can match a selector's
+// parts in the page; roles and element refs are resolved through the
+// protocol.
+func runsInPage(parts []selectorPart) bool {
+	return !slices.ContainsFunc(parts, func(p selectorPart) bool { return p.Engine == "role" || p.Engine == "ref" })
+}
+
+// pageElements returns a JavaScript expression for the first element
+// matching a selector that runs in the page, or null, or with all an array
+// of every match.
+func pageElements(selector string, parts []selectorPart, all bool) string {
+	if css, ok := cssSelector(selector); ok {
+		sel, _ := json.Marshal(css)
+		if all {
+			return fmt.Sprintf("Array.from(document.querySelectorAll(%s))", sel)
+		}
+		return fmt.Sprintf("document.querySelector(%s)", sel)
+	}
+	partsJSON, _ := json.Marshal(parts)
+	elements := fmt.Sprintf("(%s)(%s, [document])", selectorEngine, partsJSON)
+	if !all {
+		elements += "[0] || null"
+	}
+	return elements
+}
+
+// evalOnSelector is callOnSelector for a target, returning the result as
+// Eval does.
+func (c *Client) evalOnSelector(ctx context.Context, targetID string, selector string, fn string, args ...interface{}) (*EvalResult, error) {
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.callOnSelector(ctx, sessionID, selector, fn, args...)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating expression: %w", err)
+	}
+	return parseEvalResult(result)
+}
+
+// parseEvalResult parses a Runtime.evaluate or Runtime.callFunctionOn
+// response returned by value.
+func parseEvalResult(result json.RawMessage) (*EvalResult, error) {
+	var evalResp struct {
+		Result struct {
+			Type  string      `json:"type"`
+			Value interface{} `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(result, &evalResp); err != nil {
+		return nil, fmt.Errorf("parsing eval response: %w", err)
+	}
+
+	if evalResp.ExceptionDetails != nil {
+		return nil, fmt.Errorf("JS exception: %s", evalResp.ExceptionDetails.Text)
+	}
+
+	return &EvalResult{
+		Value: evalResp.Result.Value,
+		Type:  evalResp.Result.Type,
+	}, nil
+}
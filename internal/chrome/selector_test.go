@@ -0,0 +1,154 @@
+package chrome
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []selectorPart
+	}{
+		{"#main .item", []selectorPart{{Engine: "css", Body: "#main .item"}}},
+		{`a[title="x >> y"]`, []selectorPart{{Engine: "css", Body: `a[title="x >> y"]`}}},
+		{"css=div > p", []selectorPart{{Engine: "css", Body: "div > p"}}},
+		{"xpath=//button[@type='submit']", []selectorPart{{Engine: "xpath", Body: "//button[@type='submit']"}}},
+		{"//div[contains(., '>>')]", []selectorPart{{Engine: "xpath", Body: "//div[contains(., '>>')]"}}},
+		{`text="Sign in"`, []selectorPart{{Engine: "text", Body: `"Sign in"`}}},
+		{`role=button[name="Save"]`, []selectorPart{{Engine: "role", Body: `button[name="Save"]`}}},
+		{"data-testid=submit", []selectorPart{{Engine: "data-testid", Body: "submit"}}},
+		{`#login >> text=Submit >> xpath=..`, []selectorPart{{Engine: "css", Body: "#login"}, {Engine: "text", Body: "Submit"}, {Engine: "xpath", Body: ".."}}},
+		{"pay-form >>> button", []selectorPart{{Engine: "css", Body: "pay-form"}, {Engine: "css", Body: "button", Deep: true}}},
+		{">>> text=Pay >> span", []selectorPart{{Engine: "text", Body: "Pay", Deep: true}, {Engine: "css", Body: "span"}}},
+		{"li:nth=2", []selectorPart{{Engine: "css", Body: "li"}, {Engine: "nth", Body: "2"}}},
+		{"role=row :nth=0 >> text=Edit", []selectorPart{{Engine: "role", Body: "row"}, {Engine: "nth", Body: "0"}, {Engine: "text", Body: "Edit"}}},
+		{"li:nth-child(2)", []selectorPart{{Engine: "css", Body: "li:nth-child(2)"}}},
+		{`text="a:nth=1"`, []selectorPart{{Engine: "text", Body: `"a:nth=1"`}}},
+		{"@e12", []selectorPart{{Engine: "ref", Body: "e12"}}},
+		{"@e3 >> text=Save", []selectorPart{{Engine: "ref", Body: "e3"}, {Engine: "text", Body: "Save"}}},
+		{"@e3:nth=0", []selectorPart{{Engine: "ref", Body: "e3"}, {Engine: "nth", Body: "0"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSelector(tt.selector)
+		if err != nil {
+			t.Errorf("parseSelector(%q): %v", tt.selector, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelector(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelector_Errors(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+	}{
+		{"#a >> ", "empty part"},
+		{"#a >>> ", "empty part"},
+		{">> #a", "empty part"},
+		{"text=", "empty text selector"},
+		{"role=[name=x]", "missing role"},
+		{"role=button[level=2]", "only [name=...] is supported"},
+		{`role=button[name="Save"`, "missing ]"},
+		{":nth=1", "must follow a selector"},
+		{"ul >> :nth=1", "must follow a selector"},
+		{"form >> @e1", "must start the selector"},
+		{">>> @e1", "must start the selector"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseSelector(tt.selector)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseSelector(%q) error = %v, want containing %q", tt.selector, err, tt.want)
+		}
+	}
+}
+
+func TestCSSSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+		ok       bool
+	}{
+		{"button.primary", "button.primary", true},
+		{"css=button", "button", true},
+		{`[data-testid=save]`, `[data-testid=save]`, true},
+		{"text=Save", "", false},
+		{"form >> button", "", false},
+		{">>> button", "", false},
+		{"//button", "", false},
+		{"data-testid=save", "", false},
+		{"button:nth=1", "", false},
+		{"@e1", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := cssSelector(tt.selector)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("cssSelector(%q) = %q, %v, want %q, %v", tt.selector, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHasNth(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"li", false},
+		{"li:nth=1", true},
+		{"ul:nth=0 >> li", true},
+		{"li:nth-of-type(1)", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasNth(tt.selector); got != tt.want {
+			t.Errorf("hasNth(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestAmbiguousSelectorError(t *testing.T) {
+	err := &AmbiguousSelectorError{Selector: "button", Count: 2, Candidates: []string{`<button id="save">`, "<button>"}}
+	want := `selector button matches 2 elements: <button id="save">, <button> (add :nth=N to pick one)`
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	err = &AmbiguousSelectorError{Selector: "li", Count: 12, Candidates: []string{"<li>", "<li>"}}
+	if got := err.Error(); !strings.Contains(got, "<li>, <li> and 10 more") {
+		t.Errorf("Error() = %q, want the number not listed", got)
+	}
+}
+
+func TestRoleSelector_Matches(t *testing.T) {
+	tests := []struct {
+		body string
+		name string
+		want bool
+	}{
+		{"button", "anything", true},
+		{`button[name="Save"]`, "Save", true},
+		{`button[name="Save"]`, " Save\n", true},
+		{`button[name="Save"]`, "Save draft", false},
+		{`button[name='Save']`, "save", false},
+		{"button[name=save]", "Save draft", true},
+		{"button[name=save  draft]", "SAVE draft", true},
+		{"button[name=save]", "Cancel", false},
+	}
+
+	for _, tt := range tests {
+		rs, err := parseRoleSelector(tt.body)
+		if err != nil {
+			t.Fatalf("parseRoleSelector(%q): %v", tt.body, err)
+		}
+		if got := rs.matches(tt.name); got != tt.want {
+			t.Errorf("%s matches %q = %v, want %v", tt.body, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// cdpEvent is a protocol event as relayed to daemon clients.
+type cdpEvent struct {
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+}
+
+// socketRequest is a protocol command received from a daemon client.
+type socketRequest struct {
+	ID        int64           `json:"id"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params,omitempty"`
+}
+
+// socketResponse is the reply to a socketRequest.
+type socketResponse struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *ProtocolError  `json:"error,omitempty"`
+}
+
+// socketMessage is an event or response waiting to be written to a daemon
+// client, with what to do once it has been.
+type socketMessage struct {
+	Message interface{}
+	Then    func()
+}
+
+// socketConn carries newline-delimited JSON messages over a stream connection.
+type socketConn struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+	mu   sync.Mutex
+}
+
+func newSocketConn(conn net.Conn) *socketConn {
+	return &socketConn{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		dec:  json.NewDecoder(conn),
+	}
+}
+
+func (s *socketConn) ReadJSON(v interface{}) error {
+	return s.dec.Decode(v)
+}
+
+func (s *socketConn) WriteJSON(v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(v)
+}
+
+func (s *socketConn) Close() error {
+	return s.conn.Close()
+}
+
+// ConnectSocket connects to a hubcap daemon listening on the Unix socket at path.
+// The returned client behaves exactly like one returned by Connect, but shares
+// the daemon's browser connection and session cache.
+func ConnectSocket(ctx context.Context, path string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to daemon: %w", err)
+	}
+	return newClient(newSocketConn(conn), "unix://"+path), nil
+}
+
+// DaemonStatus reports the state of a daemon's shared browser connection.
+func (c *Client) DaemonStatus(ctx context.Context) (*DaemonStatus, error) {
+	result, err := c.Call(ctx, "Hubcap.status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting daemon status: %w", err)
+	}
+
+	var status DaemonStatus
+	if err := json.Unmarshal(result, &status); err != nil {
+		return nil, fmt.Errorf("parsing daemon status: %w", err)
+	}
+	return &status, nil
+}
+
+// ShutdownDaemon asks the daemon at the other end of the connection to stop serving.
+func (c *Client) ShutdownDaemon(ctx context.Context) error {
+	// The daemon may drop the connection as soon as it has replied
+	if _, err := c.Call(ctx, "Hubcap.shutdown", nil); err != nil && !errors.Is(err, ErrConnectionClosed) {
+		return fmt.Errorf("stopping daemon: %w", err)
+	}
+	return nil
+}
+
+// Serve accepts connections on ln and relays the protocol messages of each one
+// over this client's browser connection, so that many short-lived processes can
+// share one connection and its session cache. Sessions are never detached on
+// behalf of socket clients, which keeps session-scoped state such as emulation
+// alive between commands. Serve returns nil once a client asks it to shut down,
+// and ErrConnectionClosed if the browser connection drops.
+func (c *Client) Serve(ln net.Listener) error {
+	var (
+		shutdownOnce sync.Once
+		shutdown     = make(chan struct{})
+		connsMu      sync.Mutex
+		conns        = make(map[*socketConn]bool)
+		clients      sync.WaitGroup
+	)
+	stop := func() {
+		shutdownOnce.Do(func() {
+			close(shutdown)
+			ln.Close()
+		})
+	}
+
+	go func() {
+		select {
+		case <-c.closeCh:
+			ln.Close()
+		case <-shutdown:
+		}
+	}()
+
+	defer func() {
+		connsMu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		connsMu.Unlock()
+		clients.Wait()
+	}()
+
+	count := func() int {
+		connsMu.Lock()
+		defer connsMu.Unlock()
+		return len(conns)
+	}
+
+	for {
+		netConn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-shutdown:
+				return nil
+			default:
+			}
+			if c.closed.Load() {
+				return ErrConnectionClosed
+			}
+			return err
+		}
+
+		conn := newSocketConn(netConn)
+		connsMu.Lock()
+		conns[conn] = true
+		connsMu.Unlock()
+
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			c.serveConn(conn, count, stop)
+
+			connsMu.Lock()
+			delete(conns, conn)
+			connsMu.Unlock()
+		}()
+	}
+}
+
+// serveConn relays requests from one socket client until it disconnects.
+func (c *Client) serveConn(conn *socketConn, clients func() int, shutdown func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Events and responses are written in the order Chrome sent them by one
+	// writer, so that a client sees the events that came before a response
+	// first. Reading from Chrome waits while the client is behind, rather
+	// than dropping events, until the client goes away.
+	out := make(chan socketMessage, 1000)
+	gone := make(chan struct{})
+	send := func(msg socketMessage) {
+		select {
+		case out <- msg:
+		case <-gone:
+		}
+	}
+	go func() {
+		for {
+			select {
+			case msg := <-out:
+				if conn.WriteJSON(msg.Message) != nil {
+					// Reading fails next, and the client is let go
+					conn.Close()
+					continue
+				}
+				if msg.Then != nil {
+					msg.Then()
+				}
+			case <-gone:
+				return
+			}
+		}
+	}()
+	events := c.addTap(func(ev cdpEvent) { send(socketMessage{Message: ev}) })
+
+	// Sessions on which this client enabled request interception. They are
+	// released when the client goes away, otherwise paused requests would
+	// wait forever for a handler that no longer exists.
+	var fetchMu sync.Mutex
+	fetchSessions := make(map[string]bool)
+
+	// Browser contexts whose download behavior this client changed. Chrome
+	// would undo the change when a connection of the client's own closed,
+	// so the daemon does the same.
+	var downloadsMu sync.Mutex
+	downloadContexts := make(map[string]bool)
+
+	defer func() {
+		cancel()
+		c.removeTap(events)
+		conn.Close()
+
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cleanupCancel()
+		fetchMu.Lock()
+		for sessionID := range fetchSessions {
+			c.CallSession(cleanupCtx, sessionID, "Fetch.disable", nil)
+		}
+		fetchMu.Unlock()
+		downloadsMu.Lock()
+		for browserContextID := range downloadContexts {
+			c.restoreDownloadBehavior(cleanupCtx, browserContextID)
+		}
+		downloadsMu.Unlock()
+	}()
+
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+	defer close(gone)
+
+	for {
+		var req socketRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+
+			result, err := c.relay(ctx, req, clients)
+			if err == nil && req.Method == "Fetch.enable" {
+				fetchMu.Lock()
+				fetchSessions[req.SessionID] = true
+				fetchMu.Unlock()
+			}
+			if err == nil && req.Method == "Browser.setDownloadBehavior" {
+				var download struct {
+					BrowserContextID string `json:"browserContextId"`
+				}
+				json.Unmarshal(req.Params, &download)
+				downloadsMu.Lock()
+				downloadContexts[download.BrowserContextID] = true
+				downloadsMu.Unlock()
+			}
+
+			resp := socketResponse{ID: req.ID, Result: result}
+			if err != nil {
+				var perr *ProtocolError
+				if !errors.As(err, &perr) {
+					perr = &ProtocolError{Code: -32000, Message: err.Error()}
+				}
+				resp.Result = nil
+				resp.Error = perr
+			} else if len(resp.Result) == 0 {
+				resp.Result = json.RawMessage("{}")
+			}
+			msg := socketMessage{Message: resp}
+			if err == nil && req.Method == "Hubcap.shutdown" {
+				// Once the reply has been written
+				msg.Then = shutdown
+			}
+			send(msg)
+		}()
+	}
+}
+
+// relay executes a single socket client request against the browser connection.
+func (c *Client) relay(ctx context.Context, req socketRequest, clients func() int) (json.RawMessage, error) {
+	var params interface{}
+	if len(req.Params) > 0 {
+		params = req.Params
+	}
+
+	switch req.Method {
+	case "Hubcap.status":
+		c.sessionsMu.Lock()
+		sessions := len(c.sessions)
+		c.sessionsMu.Unlock()
+		return json.Marshal(DaemonStatus{
+			BrowserURL: c.wsURL,
+			Sessions:   sessions,
+			Clients:    clients(),
+		})
+
+	case "Hubcap.shutdown":
+		// Handled by serveConn once the reply has been written
+		return nil, nil
+
+	case "Target.attachToTarget":
+		if req.SessionID != "" {
+			break
+		}
+		var attach struct {
+			TargetID string `json:"targetId"`
+		}
+		if err := json.Unmarshal(req.Params, &attach); err != nil {
+			return nil, fmt.Errorf("parsing attach params: %w", err)
+		}
+		sessionID, err := c.attachToTarget(ctx, attach.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"sessionId": sessionID})
+
+	case "Target.detachFromTarget":
+		// Keep the session cached for the next client
+		if req.SessionID == "" {
+			return nil, nil
+		}
+
+	case "Browser.setDownloadBehavior":
+		// A client that only turns on download events, leaving downloads
+		// to Chrome, keeps the behavior the daemon set for the context
+		var download struct {
+			Behavior         string `json:"behavior"`
+			EventsEnabled    bool   `json:"eventsEnabled"`
+			BrowserContextID string `json:"browserContextId"`
+		}
+		if json.Unmarshal(req.Params, &download) != nil || download.Behavior != DownloadDefault || !download.EventsEnabled {
+			break
+		}
+		c.downloadsMu.Lock()
+		own := c.downloads
+		c.downloadsMu.Unlock()
+		if id, _ := own["browserContextId"].(string); own != nil && id == download.BrowserContextID {
+			params = own
+		}
+	}
+
+	if req.SessionID != "" {
+		return c.CallSession(ctx, req.SessionID, req.Method, params)
+	}
+	return c.Call(ctx, req.Method, params)
+}
+
+// eventTap receives every protocol event, either on a channel that drops
+// events while it is full, or by a function that reading waits for.
+type eventTap struct {
+	ch      chan cdpEvent
+	deliver func(cdpEvent)
+}
+
+// tapEvents returns a channel that receives every protocol event.
+func (c *Client) tapEvents() chan cdpEvent {
+	ch := make(chan cdpEvent, 1000)
+
+	c.tapsMu.Lock()
+	c.taps = append(c.taps, &eventTap{ch: ch})
+	c.tapsMu.Unlock()
+
+	return ch
+}
+
+// untapEvents removes and closes a channel returned by tapEvents.
+func (c *Client) untapEvents(ch chan cdpEvent) {
+	c.tapsMu.Lock()
+	defer c.tapsMu.Unlock()
+
+	for i, t := range c.taps {
+		if t.ch == ch {
+			c.taps = append(c.taps[:i], c.taps[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// addTap calls deliver with every protocol event, in the order they arrive.
+// Messages are not read from the browser until deliver returns, so it must
+// not wait on a call to the browser. It may still be called for an event
+// that was being delivered when the tap is removed.
+func (c *Client) addTap(deliver func(cdpEvent)) *eventTap {
+	t := &eventTap{deliver: deliver}
+
+	c.tapsMu.Lock()
+	c.taps = append(c.taps, t)
+	c.tapsMu.Unlock()
+
+	return t
+}
+
+// removeTap removes a tap returned by addTap.
+func (c *Client) removeTap(tap *eventTap) {
+	c.tapsMu.Lock()
+	defer c.tapsMu.Unlock()
+
+	for i, t := range c.taps {
+		if t == tap {
+			c.taps = append(c.taps[:i], c.taps[i+1:]...)
+			return
+		}
+	}
+}
+
+// tapEvent delivers an event to every tap.
+func (c *Client) tapEvent(ev cdpEvent) {
+	var deliver []func(cdpEvent)
+
+	c.tapsMu.Lock()
+	for _, t := range c.taps {
+		if t.deliver != nil {
+			deliver = append(deliver, t.deliver)
+			continue
+		}
+		select {
+		case t.ch <- ev:
+		default:
+			// Drop if channel is full
+		}
+	}
+	c.tapsMu.Unlock()
+
+	// Outside the lock, so that a tap waiting on its client does not hold
+	// up taps being added or removed
+	for _, d := range deliver {
+		d(ev)
+	}
+}
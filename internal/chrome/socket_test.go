@@ -0,0 +1,134 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// floodConn is a browser connection that answers every call with a flood
+// of events followed by the reply.
+type floodConn struct {
+	events   int
+	messages chan []byte
+	closed   chan struct{}
+}
+
+func (f *floodConn) ReadJSON(v interface{}) error {
+	select {
+	case msg := <-f.messages:
+		return json.Unmarshal(msg, v)
+	case <-f.closed:
+		return errors.New("closed")
+	}
+}
+
+func (f *floodConn) WriteJSON(v interface{}) error {
+	req := v.(cdpRequest)
+	go func() {
+		for i := 0; i < f.events; i++ {
+			msg, _ := json.Marshal(map[string]interface{}{"method": "Test.event", "params": map[string]int{"n": i}})
+			select {
+			case f.messages <- msg:
+			case <-f.closed:
+				return
+			}
+		}
+		msg, _ := json.Marshal(map[string]interface{}{"id": req.ID, "result": map[string]bool{"done": true}})
+		select {
+		case f.messages <- msg:
+		case <-f.closed:
+		}
+	}()
+	return nil
+}
+
+func (f *floodConn) Close() error {
+	select {
+	case <-f.closed:
+	default:
+		close(f.closed)
+	}
+	return nil
+}
+
+func TestServe_EventsInOrderBeforeReply(t *testing.T) {
+	browser := &floodConn{events: 5000, messages: make(chan []byte), closed: make(chan struct{})}
+	c := newClient(browser, "ws://test")
+	defer c.Close()
+
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "d.sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go c.Serve(ln)
+	defer ln.Close()
+
+	conn, err := net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(socketRequest{ID: 1, Method: "Test.flood"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// More events than any buffer holds arrive, all before the reply
+	dec := json.NewDecoder(conn)
+	for n := 0; ; n++ {
+		var msg struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+			Params struct {
+				N int `json:"n"`
+			} `json:"params"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if msg.ID == 1 {
+			if n != browser.events {
+				t.Fatalf("got %d events before the reply, want %d", n, browser.events)
+			}
+			return
+		}
+		if msg.Method != "Test.event" || msg.Params.N != n {
+			t.Fatalf("message %d = %+v, want event %d", n, msg, n)
+		}
+	}
+}
+
+func TestSubscribeEventLossless_DoesNotHoldUpReading(t *testing.T) {
+	browser := &floodConn{events: 5000, messages: make(chan []byte), closed: make(chan struct{})}
+	c := newClient(browser, "ws://test")
+	defer c.Close()
+
+	ch := c.subscribeEventLossless("", "Test.event")
+
+	// The reply comes after more events than the channel holds, none of
+	// them received yet
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.Call(ctx, "Test.flood", nil); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	c.unsubscribeEvent("", "Test.event", ch)
+
+	n := 0
+	for params := range ch {
+		var event struct {
+			N int `json:"n"`
+		}
+		if err := json.Unmarshal(params, &event); err != nil || event.N != n {
+			t.Fatalf("event %d = %s, want n %d", n, params, n)
+		}
+		n++
+	}
+	if n != browser.events {
+		t.Errorf("got %d events, want %d", n, browser.events)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 )
 
 // --- Errors ---
@@ -40,10 +41,19 @@ type VersionInfo struct {
 
 // TargetInfo contains information about a browser target (tab/page).
 type TargetInfo struct {
-	ID    string `json:"id"`
-	Type  string `json:"type"`
-	Title string `json:"title"`
-	URL   string `json:"url"`
+	ID               string `json:"id"`
+	Type             string `json:"type"`
+	Title            string `json:"title"`
+	URL              string `json:"url"`
+	OpenerID         string `json:"openerId,omitempty"`         // Page that opened this one, for popups
+	BrowserContextID string `json:"browserContextId,omitempty"` // Browser context the target is in
+}
+
+// BrowserContextOptions configures a browser context made by
+// CreateBrowserContext.
+type BrowserContextOptions struct {
+	ProxyServer     string // Such as "http://proxy.test:8080" or "socks5://proxy.test:1080"
+	ProxyBypassList string // Comma-separated hosts to reach without the proxy
 }
 
 // PageInfo represents combined information about the current page.
@@ -211,6 +221,146 @@ type InterceptConfig struct {
 	Headers           map[string]string // Override/add headers
 }
 
+// --- Mocking ---
+
+// MockRule describes which requests to answer and how. All match fields are
+// optional; an empty rule matches every request.
+type MockRule struct {
+	Name         string            // Label reported in MockEvent (defaults to the rule's position)
+	URL          string            // URL glob (* matches any run of characters, ? a single one)
+	URLRegex     string            // URL regular expression (exclusive with URL)
+	Method       string            // HTTP method, case-insensitive
+	ResourceType string            // CDP resource type (Document, XHR, Fetch, Script, ...)
+	BodyContains string            // Substring the request body must contain
+	Status       int               // Response status (0 = 200 for fulfilled, original for modified)
+	Headers      map[string]string // Response headers, replacing originals of the same name
+	Body         []byte            // Response body (nil = keep the real response)
+	Delay        time.Duration     // Delay before answering
+	Abort        string            // Fail the request with this network error reason
+}
+
+// MockEvent reports a request answered by a mock rule.
+type MockEvent struct {
+	Rule         string `json:"rule"`
+	URL          string `json:"url"`
+	Method       string `json:"method"`
+	ResourceType string `json:"resourceType,omitempty"`
+	Action       string `json:"action"`           // "fulfill", "modify", "abort" or "continue"
+	Status       int    `json:"status,omitempty"` // Status sent to the page
+	Reason       string `json:"reason,omitempty"` // Network error reason (abort only)
+	Error        string `json:"error,omitempty"`
+}
+
+// --- Stop Conditions ---
+
+// StopCondition describes when a capture ends. The capture stops as soon as
+// any of the set conditions is met.
+type StopCondition struct {
+	Duration time.Duration // Fixed capture length
+	Idle     time.Duration // No network requests in flight for this long
+	Selector string        // An element matching this selector exists
+}
+
+// --- Wait Conditions ---
+
+// WaitCondition is one of the conditions of a composite wait. Kind is one of
+// WaitConditionKinds; Value is its selector, text, pattern or expression.
+type WaitCondition struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+}
+
+// ConditionResult describes a condition of a composite wait that was met.
+type ConditionResult struct {
+	Condition string      `json:"condition"`        // The condition as given, kind:value
+	Index     int         `json:"index"`            // Position among the conditions
+	ElapsedMs int64       `json:"elapsedMs"`        // Time from the start of the wait
+	Result    interface{} `json:"result,omitempty"` // What matched, for conditions that report it
+}
+
+// DialogInfo describes a JavaScript dialog that opened.
+type DialogInfo struct {
+	Type          string `json:"type"` // "alert", "confirm", "prompt" or "beforeunload"
+	Message       string `json:"message"`
+	URL           string `json:"url"`
+	DefaultPrompt string `json:"defaultPrompt,omitempty"`
+}
+
+// DownloadInfo describes a download that a page started.
+type DownloadInfo struct {
+	GUID              string `json:"guid"`
+	URL               string `json:"url"`
+	SuggestedFilename string `json:"suggestedFilename"`
+}
+
+// DownloadResult describes a download that has finished.
+type DownloadResult struct {
+	DownloadInfo
+	Path string `json:"path"` // Where Chrome saved the file
+	Size int64  `json:"size"` // Bytes received
+}
+
+// --- Tracing ---
+
+// TraceOptions configures trace capture.
+type TraceOptions struct {
+	Categories string        // Comma-separated trace categories; DefaultTraceCategories if empty
+	Until      StopCondition // When to stop tracing
+}
+
+// --- Web Vitals ---
+
+// Vitals are the Core Web Vitals and loading metrics of a page. Times are
+// in milliseconds from the start of navigation. Metrics the page did not
+// produce, such as INP before any interaction, are nil.
+type Vitals struct {
+	URL  string       `json:"url"`
+	LCP  *LCPMetric   `json:"lcp"`
+	CLS  *CLSMetric   `json:"cls"`
+	INP  *INPMetric   `json:"inp"`
+	FCP  *VitalMetric `json:"fcp"`
+	TTFB *VitalMetric `json:"ttfb"`
+}
+
+// VitalMetric is a metric value with its rating: "good",
+// "needs-improvement" or "poor".
+type VitalMetric struct {
+	Value  float64 `json:"value"`
+	Rating string  `json:"rating"`
+}
+
+// LCPMetric is Largest Contentful Paint, with the element painted.
+type LCPMetric struct {
+	VitalMetric
+	Element string  `json:"element"`       // CSS selector of the element
+	URL     string  `json:"url,omitempty"` // Image URL, for image elements
+	Size    float64 `json:"size"`          // Painted area in CSS pixels
+}
+
+// CLSMetric is Cumulative Layout Shift: the largest session window of
+// layout shifts, with the shifts in it.
+type CLSMetric struct {
+	VitalMetric
+	Shifts []LayoutShift `json:"shifts"`
+}
+
+// LayoutShift is a layout shift not caused by user input.
+type LayoutShift struct {
+	Start   float64  `json:"start"`
+	Value   float64  `json:"value"`
+	Sources []string `json:"sources"` // CSS selectors of the nodes that moved
+}
+
+// INPMetric is Interaction to Next Paint: the slowest interaction,
+// ignoring one outlier for every 50 interactions.
+type INPMetric struct {
+	VitalMetric
+	Event        string  `json:"event"`  // e.g. "click" or "keydown"
+	Target       string  `json:"target"` // CSS selector of the event target
+	Start        float64 `json:"start"`
+	Interactions int     `json:"interactions"` // Interactions observed
+}
+
 // --- HAR (HTTP Archive) ---
 
 // HARLog represents an HTTP Archive log.
@@ -231,22 +381,32 @@ type HARCreator struct {
 // HAREntry represents a single HTTP transaction.
 type HAREntry struct {
 	StartedDateTime string      `json:"startedDateTime"`
-	Time            float64     `json:"time"`
+	Time            float64     `json:"time"` // Total of the non-negative timings, in milliseconds
 	Request         HARRequest  `json:"request"`
 	Response        HARResponse `json:"response"`
 	Cache           struct{}    `json:"cache"`
 	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
 }
 
 // HARRequest represents an HTTP request.
 type HARRequest struct {
-	Method      string      `json:"method"`
-	URL         string      `json:"url"`
-	HTTPVersion string      `json:"httpVersion"`
-	Headers     []HARHeader `json:"headers"`
-	QueryString []HARQuery  `json:"queryString"`
-	HeadersSize int         `json:"headersSize"`
-	BodySize    int         `json:"bodySize"`
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []HARCookie  `json:"cookies"`
+	Headers     []HARHeader  `json:"headers"`
+	QueryString []HARQuery   `json:"queryString"`
+	PostData    *HARPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+// HARPostData represents the body of a request.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
 }
 
 // HARResponse represents an HTTP response.
@@ -254,6 +414,7 @@ type HARResponse struct {
 	Status      int         `json:"status"`
 	StatusText  string      `json:"statusText"`
 	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []HARCookie `json:"cookies"`
 	Headers     []HARHeader `json:"headers"`
 	Content     HARContent  `json:"content"`
 	RedirectURL string      `json:"redirectURL"`
@@ -267,6 +428,17 @@ type HARHeader struct {
 	Value string `json:"value"`
 }
 
+// HARCookie represents a cookie sent with a request or set by a response.
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
 // HARQuery represents a query string parameter.
 type HARQuery struct {
 	Name  string `json:"name"`
@@ -277,22 +449,67 @@ type HARQuery struct {
 type HARContent struct {
 	Size     int    `json:"size"`
 	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"` // "base64" if Text is base64-encoded
+	File     string `json:"_file,omitempty"`    // Body stored in a file next to the HAR instead of Text
 }
 
-// HARTimings represents the timing information.
+// HARTimings represents the timing phases of a request in milliseconds.
+// Blocked, DNS, Connect and SSL are -1 when they do not apply.
 type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"` // Includes SSL
 	Send    float64 `json:"send"`
 	Wait    float64 `json:"wait"`
 	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARCaptureOptions configures HAR capture.
+type HARCaptureOptions struct {
+	Until  StopCondition // When to stop capturing
+	Bodies bool          // Embed response bodies in content.text
+}
+
+// HARReplayOptions configures how requests are answered from a HAR log.
+type HARReplayOptions struct {
+	Match    string // "strict" (method, URL and body), "url" (method and URL) or "path" (method and URL without query)
+	NotFound string // "abort" or "passthrough" for requests not in the archive
+}
+
+// HARReplayEvent reports how a request was handled during HAR replay.
+type HARReplayEvent struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Action string `json:"action"`          // "replay", "abort" or "passthrough"
+	Entry  *int   `json:"entry,omitempty"` // Index of the archive entry replayed
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
 }
 
 // --- DOM & Elements ---
 
 // QueryResult contains the result of querying for a DOM element.
 type QueryResult struct {
-	NodeID     int               `json:"nodeId"`
-	TagName    string            `json:"tagName,omitempty"`
-	Attributes map[string]string `json:"attributes,omitempty"`
+	NodeID        int               `json:"nodeId"`
+	BackendNodeID int64             `json:"backendNodeId,omitempty"`
+	Ref           string            `json:"ref,omitempty"` // Element ref such as @e12, set by the CLI
+	TagName       string            `json:"tagName,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+}
+
+// ElementInfo describes one of the elements matching a selector.
+type ElementInfo struct {
+	Index         int               `json:"index"` // Selects the element with :nth=
+	NodeID        int               `json:"nodeId"`
+	BackendNodeID int64             `json:"backendNodeId"`
+	Ref           string            `json:"ref,omitempty"` // Element ref such as @e12, set by the CLI
+	TagName       string            `json:"tagName"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Text          string            `json:"text"`
+	Visible       bool              `json:"visible"`
+	Bounds        BoundingBox       `json:"bounds"`
 }
 
 // BoundingBox represents an element's position and size.
@@ -344,21 +561,27 @@ type KeyModifiers struct {
 // modifierBitmask returns the protocol modifier bitmask.
 func (m KeyModifiers) modifierBitmask() int {
 	mask := 0
-	if m.Shift {
-		mask |= 1
+	if m.Alt {
+		mask |= modifierAlt
 	}
 	if m.Ctrl {
-		mask |= 2
-	}
-	if m.Alt {
-		mask |= 4
+		mask |= modifierCtrl
 	}
 	if m.Meta {
-		mask |= 8
+		mask |= modifierMeta
+	}
+	if m.Shift {
+		mask |= modifierShift
 	}
 	return mask
 }
 
+// TypeOptions configures typing.
+type TypeOptions struct {
+	Delay  time.Duration // Pause between characters
+	Insert bool          // Insert the text as an input method would, without key events
+}
+
 // SetValueResult represents the result of setting an input value.
 type SetValueResult struct {
 	Selector string `json:"selector"`
@@ -371,6 +594,12 @@ type MouseMoveResult struct {
 	Y float64 `json:"y"`
 }
 
+// FileChooserResult describes the file chooser given files by UploadOnChooser.
+type FileChooserResult struct {
+	Mode          string `json:"mode"`          // selectSingle or selectMultiple
+	BackendNodeID int64  `json:"backendNodeId"` // The file input that opened it
+}
+
 // DispatchEventResult contains the result of dispatching a custom event.
 type DispatchEventResult struct {
 	Dispatched bool   `json:"dispatched"`
@@ -606,6 +835,7 @@ type frameTreeNode struct {
 		ParentID string `json:"parentId"`
 		Name     string `json:"name"`
 		URL      string `json:"url"`
+		LoaderID string `json:"loaderId"`
 	} `json:"frame"`
 	ChildFrames []frameTreeNode `json:"childFrames"`
 }
@@ -614,13 +844,15 @@ type frameTreeNode struct {
 
 // AccessibilityNode represents a node in the accessibility tree.
 type AccessibilityNode struct {
-	NodeID      string                 `json:"nodeId"`
-	Role        string                 `json:"role"`
-	Name        string                 `json:"name,omitempty"`
-	Description string                 `json:"description,omitempty"`
-	Value       string                 `json:"value,omitempty"`
-	Properties  map[string]interface{} `json:"properties,omitempty"`
-	Children    []AccessibilityNode    `json:"children,omitempty"`
+	NodeID        string                 `json:"nodeId"`
+	BackendNodeID int64                  `json:"backendNodeId,omitempty"` // The DOM node, if any
+	Ref           string                 `json:"ref,omitempty"`           // Element ref such as @e12, set by the CLI
+	Role          string                 `json:"role"`
+	Name          string                 `json:"name,omitempty"`
+	Description   string                 `json:"description,omitempty"`
+	Value         string                 `json:"value,omitempty"`
+	Properties    map[string]interface{} `json:"properties,omitempty"`
+	Children      []AccessibilityNode    `json:"children,omitempty"`
 }
 
 // --- Event Listeners ---
@@ -655,6 +887,76 @@ type TraceResult struct {
 	Size int    `json:"size"`
 }
 
+// CPUProfile is a V8 CPU profile as returned by Profiler.stop. Marshaled to
+// JSON it is the .cpuprofile format read by Chrome DevTools.
+type CPUProfile struct {
+	Nodes      []CPUProfileNode `json:"nodes"`
+	StartTime  float64          `json:"startTime"`  // Microseconds
+	EndTime    float64          `json:"endTime"`    // Microseconds
+	Samples    []int            `json:"samples"`    // Node ID of each sample
+	TimeDeltas []int            `json:"timeDeltas"` // Microseconds since the previous sample
+}
+
+// CPUProfileNode is a call tree node of a CPUProfile.
+type CPUProfileNode struct {
+	ID            int                      `json:"id"`
+	CallFrame     CPUProfileCallFrame      `json:"callFrame"`
+	HitCount      int                      `json:"hitCount,omitempty"`
+	Children      []int                    `json:"children,omitempty"`
+	DeoptReason   string                   `json:"deoptReason,omitempty"`
+	PositionTicks []CPUProfilePositionTick `json:"positionTicks,omitempty"`
+}
+
+// CPUProfileCallFrame identifies the function of a CPUProfileNode.
+type CPUProfileCallFrame struct {
+	FunctionName string `json:"functionName"`
+	ScriptID     string `json:"scriptId"`
+	URL          string `json:"url"`
+	LineNumber   int    `json:"lineNumber"`   // 0-based
+	ColumnNumber int    `json:"columnNumber"` // 0-based
+}
+
+// CPUProfilePositionTick counts the samples taken on a source line.
+type CPUProfilePositionTick struct {
+	Line  int `json:"line"`
+	Ticks int `json:"ticks"`
+}
+
+// --- Daemon ---
+
+// DaemonStatus describes the browser connection shared by a hubcap daemon.
+type DaemonStatus struct {
+	BrowserURL string `json:"browserUrl"`
+	Sessions   int    `json:"sessions"`
+	Clients    int    `json:"clients"`
+}
+
+// --- Observe ---
+
+// ObserveOptions configures a page outline.
+type ObserveOptions struct {
+	FullPage bool // Include elements outside the viewport
+}
+
+// OutlineNode is an item of a page outline: a landmark, heading, dialog,
+// form control or other interactive element.
+type OutlineNode struct {
+	Role          string        `json:"role"`
+	Name          string        `json:"name,omitempty"`
+	Value         string        `json:"value,omitempty"`
+	Level         int           `json:"level,omitempty"` // Heading level
+	States        []string      `json:"states,omitempty"`
+	BackendNodeID int64         `json:"backendNodeId,omitempty"`
+	Ref           string        `json:"ref,omitempty"` // Element ref such as @e12, set by the CLI
+	Children      []OutlineNode `json:"children,omitempty"`
+}
+
+// Interactive reports whether the node is an element to act on, rather
+// than one structuring the page.
+func (n *OutlineNode) Interactive() bool {
+	return interactiveRoles[n.Role]
+}
+
 // --- Helper Functions ---
 
 // isTruthy checks if a value is truthy in JavaScript terms.
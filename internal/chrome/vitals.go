@@ -0,0 +1,226 @@
+package chrome
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// vitalsScript records Web Vitals in the top-level document with
+// PerformanceObserver, the way the web-vitals library does, and defines
+// window.__hubcapVitals to report them. It is installed before any page
+// script runs so that no entries are missed.
+const vitalsScript = `(() => {
+	if (window !== window.top || window.__hubcapVitals) return;
+
+	const selector = (node) => {
+		let el = node && node.nodeType !== 1 ? node.parentElement : node;
+		const parts = [];
+		for (; el && el.nodeType === 1; el = el.parentElement) {
+			if (el.id) {
+				parts.unshift('#' + CSS.escape(el.id));
+				break;
+			}
+			let part = el.localName;
+			const parent = el.parentElement;
+			if (parent) {
+				const same = Array.from(parent.children).filter(c => c.localName === el.localName);
+				if (same.length > 1) part += ':nth-of-type(' + (same.indexOf(el) + 1) + ')';
+			}
+			parts.unshift(part);
+			if (el.localName === 'body') break;
+		}
+		return parts.join(' > ');
+	};
+
+	let lcp = null;
+	const shifts = [];
+	const interactions = new Map();
+	const observers = [];
+	const observe = (type, handle, opts) => {
+		try {
+			const o = new PerformanceObserver(list => list.getEntries().forEach(handle));
+			o.observe(Object.assign({type: type, buffered: true}, opts));
+			observers.push({o: o, handle: handle});
+		} catch (e) {}
+	};
+	const interaction = (e) => {
+		if (!e.interactionId) return;
+		const prev = interactions.get(e.interactionId);
+		if (!prev || e.duration > prev.duration) {
+			interactions.set(e.interactionId, {duration: e.duration, event: e.name, target: selector(e.target), start: e.startTime});
+		}
+	};
+
+	observe('largest-contentful-paint', e => {
+		lcp = {value: e.startTime, element: selector(e.element), url: e.url || '', size: e.size};
+	});
+	observe('layout-shift', e => {
+		if (e.hadRecentInput) return;
+		shifts.push({start: e.startTime, value: e.value, sources: (e.sources || []).map(s => selector(s.node)).filter(Boolean)});
+	});
+	observe('event', interaction, {durationThreshold: 16});
+	observe('first-input', interaction);
+
+	window.__hubcapVitals = () => {
+		observers.forEach(({o, handle}) => o.takeRecords().forEach(handle));
+
+		const nav = performance.getEntriesByType('navigation')[0];
+		const activation = (nav && nav.activationStart) || 0;
+		const fcp = performance.getEntriesByName('first-contentful-paint')[0];
+
+		// Session windows end after a 1s gap or at 5s long
+		let cls = {value: 0, shifts: []};
+		let session = cls;
+		for (const s of shifts) {
+			const first = session.shifts[0];
+			const last = session.shifts[session.shifts.length - 1];
+			if (last && (s.start - last.start > 1000 || s.start - first.start > 5000)) {
+				session = {value: 0, shifts: []};
+			}
+			session.value += s.value;
+			session.shifts.push(s);
+			if (session.value > cls.value) cls = session;
+		}
+
+		const slowest = Array.from(interactions.values()).sort((a, b) => b.duration - a.duration);
+		const inp = slowest[Math.min(slowest.length - 1, Math.floor(slowest.length / 50))];
+
+		return {
+			url: location.href,
+			lcp: lcp && Object.assign({}, lcp, {value: Math.max(lcp.value - activation, 0)}),
+			cls: cls,
+			inp: inp ? {value: inp.duration, event: inp.event, target: inp.target, start: inp.start, interactions: slowest.length} : null,
+			fcp: fcp ? {value: Math.max(fcp.startTime - activation, 0)} : null,
+			ttfb: nav ? {value: Math.max(nav.responseStart - activation, 0)} : null,
+		};
+	};
+})()`
+
+// StartVitals installs Web Vitals recording in every document the target
+// loads from now on. It returns an identifier to pass to StopVitals. Only
+// documents loaded after StartVitals are measured.
+func (c *Client) StartVitals(ctx context.Context, targetID string) (string, error) {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = c.CallSession(ctx, sessionID, "Page.enable", nil)
+	if err != nil {
+		return "", fmt.Errorf("enabling Page domain: %w", err)
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "Page.addScriptToEvaluateOnNewDocument", map[string]interface{}{
+		"source": vitalsScript,
+	})
+	if err != nil {
+		return "", fmt.Errorf("installing vitals script: %w", err)
+	}
+
+	var resp struct {
+		Identifier string `json:"identifier"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return "", fmt.Errorf("parsing response: %w", err)
+	}
+	return resp.Identifier, nil
+}
+
+// StopVitals removes the recording installed by StartVitals.
+func (c *Client) StopVitals(ctx context.Context, targetID string, identifier string) error {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.CallSession(ctx, sessionID, "Page.removeScriptToEvaluateOnNewDocument", map[string]interface{}{
+		"identifier": identifier,
+	})
+	if err != nil {
+		return fmt.Errorf("removing vitals script: %w", err)
+	}
+	return nil
+}
+
+// CollectVitals waits for the stop condition, giving late layout shifts and
+// LCP candidates time to arrive, then reads the vitals of the current
+// document. StartVitals must have been called before the document loaded.
+func (c *Client) CollectVitals(ctx context.Context, targetID string, until StopCondition) (*Vitals, error) {
+	if until != (StopCondition{}) {
+		if err := c.waitStop(ctx, targetID, until); err != nil {
+			return nil, err
+		}
+	}
+
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
+		"expression":    "window.__hubcapVitals ? window.__hubcapVitals() : null",
+		"returnByValue": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading vitals: %w", err)
+	}
+
+	var evalResp struct {
+		Result struct {
+			Value *Vitals `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text string `json:"text"`
+		} `json:"exceptionDetails"`
+	}
+	if err := json.Unmarshal(result, &evalResp); err != nil {
+		return nil, fmt.Errorf("parsing vitals: %w", err)
+	}
+	if evalResp.ExceptionDetails != nil {
+		return nil, fmt.Errorf("reading vitals: JS exception: %s", evalResp.ExceptionDetails.Text)
+	}
+
+	v := evalResp.Result.Value
+	if v == nil {
+		return nil, fmt.Errorf("no vitals recorded: the page has not loaded since recording started")
+	}
+	v.rate()
+	return v, nil
+}
+
+// rate sets the rating of each metric from the thresholds published at
+// web.dev/vitals.
+func (v *Vitals) rate() {
+	if v.LCP != nil {
+		v.LCP.Rating = rating(v.LCP.Value, 2500, 4000)
+	}
+	if v.CLS != nil {
+		v.CLS.Rating = rating(v.CLS.Value, 0.1, 0.25)
+		if v.CLS.Shifts == nil {
+			v.CLS.Shifts = []LayoutShift{}
+		}
+	}
+	if v.INP != nil {
+		v.INP.Rating = rating(v.INP.Value, 200, 500)
+	}
+	if v.FCP != nil {
+		v.FCP.Rating = rating(v.FCP.Value, 1800, 3000)
+	}
+	if v.TTFB != nil {
+		v.TTFB.Rating = rating(v.TTFB.Value, 800, 1800)
+	}
+}
+
+// rating returns "good" up to and including good, "poor" above poor and
+// "needs-improvement" in between.
+func rating(value, good, poor float64) string {
+	switch {
+	case value <= good:
+		return "good"
+	case value <= poor:
+		return "needs-improvement"
+	default:
+		return "poor"
+	}
+}
@@ -3,201 +3,302 @@ package chrome
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
 
-// WaitFor waits for an element matching the selector to appear.
-func (c *Client) WaitFor(ctx context.Context, targetID string, selector string, timeout time.Duration) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
-	if err != nil {
-		return err
-	}
+// Element states that WaitForState waits for.
+const (
+	StateAttached = "attached" // In the document
+	StateDetached = "detached" // Not in the document
+	StateVisible  = "visible"  // In the document with a box, and not visibility: hidden
+	StateHidden   = "hidden"   // Detached or not visible
+	StateEnabled  = "enabled"  // Visible and not disabled
+)
 
-	// Enable DOM domain
-	_, err = c.CallSession(ctx, sessionID, "DOM.enable", nil)
-	if err != nil {
-		return fmt.Errorf("enabling DOM domain: %w", err)
+// ElementStates are the states WaitForState accepts.
+var ElementStates = []string{StateAttached, StateDetached, StateVisible, StateHidden, StateEnabled}
+
+// waitPollInterval is how often conditions that cannot be watched in the
+// page are checked.
+const waitPollInterval = 100 * time.Millisecond
+
+// elementStateScript reports whether an element, or null, is in a state,
+// judging visible and enabled as the actionability checks do.
+const elementStateScript = `function(el, state) {
+	const attached = !!el && el.isConnected;
+	const visible = attached && (() => {
+		const rect = el.getBoundingClientRect();
+		return rect.width > 0 && rect.height > 0 && getComputedStyle(el).visibility !== 'hidden';
+	})();
+	switch (state) {
+	case 'attached': return attached;
+	case 'detached': return !attached;
+	case 'visible': return visible;
+	case 'hidden': return !visible;
+	case 'enabled': return visible && !el.matches(':disabled') && !el.closest('[aria-disabled="true"]');
 	}
+	throw new Error('unknown element state: ' + state);
+}`
+
+// waitScript returns a promise of whether check returns a truthy value
+// within timeout milliseconds. The check is made at once, then after every
+// DOM mutation and animation frame, so the promise settles within a frame
+// of the check passing. It rejects if the check throws.
+const waitScript = `function(check, timeout) {
+	return new Promise((resolve, reject) => {
+		let done = false;
+		const cleanups = [];
+		const finish = (settle, value) => {
+			if (done) return;
+			done = true;
+			cleanups.forEach(cleanup => cleanup());
+			settle(value);
+		};
+		const test = () => {
+			if (done) return;
+			try {
+				if (check()) finish(resolve, true);
+			} catch (e) {
+				finish(reject, e);
+			}
+		};
+		test();
+		if (done) return;
+
+		const observer = new MutationObserver(test);
+		observer.observe(document, {childList: true, subtree: true, attributes: true, characterData: true});
+		cleanups.push(() => observer.disconnect());
+		// Style and layout changes are not mutations
+		let frame = requestAnimationFrame(function onFrame() {
+			test();
+			frame = requestAnimationFrame(onFrame);
+		});
+		cleanups.push(() => cancelAnimationFrame(frame));
+		// Pages that are not being rendered, such as background tabs, have no animation frames
+		const poll = setInterval(test, 100);
+		cleanups.push(() => clearInterval(poll));
+		const timer = setTimeout(() => finish(resolve, false), timeout);
+		cleanups.push(() => clearTimeout(timer));
+	});
+}`
+
+// contextLost reports whether an evaluation failed because the page
+// navigated away or reloaded while it ran, so it can be made again in the
+// new document.
+func contextLost(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Execution context was destroyed") ||
+		strings.Contains(msg, "Cannot find context with specified id") ||
+		strings.Contains(msg, "Promise was collected") ||
+		strings.Contains(msg, "Inspected target navigated or closed")
+}
 
-	deadline := time.Now().Add(timeout)
-	pollInterval := 100 * time.Millisecond
-
+// waitInPage waits until check, a JavaScript expression, is truthy, with
+// the check watched in the page by waitScript. Returns whether it was
+// before the deadline, or ctx's error. A check that throws fails the wait,
+// with the error a *exceptionDetails.
+func (c *Client) waitInPage(ctx context.Context, sessionID string, check string, deadline time.Time) (bool, error) {
 	for {
-		// Check if context is done
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false, nil
 		}
 
-		// Check timeout
-		if time.Now().After(deadline) {
-			return fmt.Errorf("timeout waiting for selector: %s", selector)
+		var resp struct {
+			Result struct {
+				Value bool `json:"value"`
+			} `json:"result"`
+			ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
 		}
-
-		// Get document root
-		docResult, err := c.CallSession(ctx, sessionID, "DOM.getDocument", nil)
-		if err != nil {
-			return fmt.Errorf("getting document: %w", err)
+		result, err := c.evaluate(ctx, sessionID, map[string]interface{}{
+			"expression":    fmt.Sprintf("(%s)(() => (%s), %d)", waitScript, check, remaining.Milliseconds()),
+			"awaitPromise":  true,
+			"returnByValue": true,
+		})
+		if err == nil {
+			if err = json.Unmarshal(result, &resp); err != nil {
+				return false, fmt.Errorf("parsing response: %w", err)
+			}
+			if resp.ExceptionDetails != nil {
+				err = resp.ExceptionDetails
+			}
+		}
+		if err == nil {
+			return resp.Result.Value, nil
 		}
 
-		var docResp struct {
-			Root struct {
-				NodeID int `json:"nodeId"`
-			} `json:"root"`
+		if ctx.Err() != nil {
+			return false, ctx.Err()
 		}
-		if err := json.Unmarshal(docResult, &docResp); err != nil {
-			return fmt.Errorf("parsing document response: %w", err)
+		if !contextLost(err) {
+			return false, err
 		}
-
-		// Query selector
-		queryResult, err := c.CallSession(ctx, sessionID, "DOM.querySelector", map[string]interface{}{
-			"nodeId":   docResp.Root.NodeID,
-			"selector": selector,
-		})
-		if err != nil {
-			return fmt.Errorf("querying selector: %w", err)
+		// The new document may not have its context yet
+		if err := sleepContext(ctx, waitPollInterval); err != nil {
+			return false, err
 		}
+	}
+}
 
-		var queryResp struct {
-			NodeID int `json:"nodeId"`
+// poll calls check every waitPollInterval until it reports true, returning
+// whether it did before the deadline, or ctx's error. It is the fallback
+// for conditions that cannot be watched in the page.
+func poll(ctx context.Context, deadline time.Time, check func() (bool, error)) (bool, error) {
+	for {
+		ok, err := check()
+		if err != nil || ok {
+			return ok, err
 		}
-		if err := json.Unmarshal(queryResult, &queryResp); err != nil {
-			return fmt.Errorf("parsing query response: %w", err)
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false, nil
 		}
-
-		// Found!
-		if queryResp.NodeID != 0 {
-			return nil
+		if err := sleepContext(ctx, min(waitPollInterval, remaining)); err != nil {
+			return false, err
 		}
-
-		// Wait before polling again
-		time.Sleep(pollInterval)
 	}
 }
 
+// WaitFor waits for an element matching the selector to appear.
+func (c *Client) WaitFor(ctx context.Context, targetID string, selector string, timeout time.Duration) error {
+	return c.WaitForState(ctx, targetID, selector, StateAttached, timeout)
+}
+
 // WaitForGone waits for an element to be removed from the DOM.
 func (c *Client) WaitForGone(ctx context.Context, targetID string, selector string, timeout time.Duration) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	return c.WaitForState(ctx, targetID, selector, StateDetached, timeout)
+}
+
+// WaitForState waits for the element matching the selector to be in a
+// state, one of ElementStates. Selectors the engine runs in the page are
+// watched there, so the wait ends within a frame of the element reaching
+// the state; role= selectors and element refs are checked every 100ms.
+func (c *Client) WaitForState(ctx context.Context, targetID string, selector string, state string, timeout time.Duration) error {
+	if !slices.Contains(ElementStates, state) {
+		return fmt.Errorf("invalid state %q (use %s)", state, strings.Join(ElementStates, ", "))
+	}
+	parts, err := parseSelector(selector)
 	if err != nil {
 		return err
 	}
 
-	deadline := time.Now().Add(timeout)
-	pollInterval := 50 * time.Millisecond
-
-	for {
-		if time.Now().After(deadline) {
-			return fmt.Errorf("timeout waiting for %q to be removed", selector)
-		}
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return err
+	}
 
-		// Check if element exists
-		result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-			"expression":    fmt.Sprintf(`document.querySelector(%q) === null`, selector),
-			"returnByValue": true,
-		})
+	deadline := time.Now().Add(timeout)
+	var met bool
+	if single := c.singleSelector(selector); runsInPage(parts) {
+		singleParts, err := parseSelector(single)
 		if err != nil {
-			return fmt.Errorf("checking selector: %w", err)
-		}
-
-		var evalResp struct {
-			Result struct {
-				Value bool `json:"value"`
-			} `json:"result"`
+			return err
 		}
-		if err := json.Unmarshal(result, &evalResp); err != nil {
-			return fmt.Errorf("parsing response: %w", err)
+		stateJSON, _ := json.Marshal(state)
+		check := fmt.Sprintf("(%s)(%s, %s)", elementStateScript, pageElements(single, singleParts, false), stateJSON)
+		met, err = c.waitInPage(ctx, sessionID, check, deadline)
+		if err != nil {
+			return fmt.Errorf("waiting for selector %s: %w", selector, err)
 		}
-
-		// Element is gone
-		if evalResp.Result.Value {
-			return nil
+	} else {
+		met, err = poll(ctx, deadline, func() (bool, error) {
+			result, err := c.callOnSelector(ctx, sessionID, selector, elementStateScript, state)
+			if err != nil {
+				var stale *StaleElementError
+				if errors.As(err, &stale) {
+					// A ref's element is gone for good
+					return state == StateDetached || state == StateHidden, nil
+				}
+				return false, err
+			}
+			ev, err := parseEvalResult(result)
+			if err != nil {
+				return false, err
+			}
+			return ev.Value == true, nil
+		})
+		if err != nil {
+			return err
 		}
+	}
+	if met {
+		return nil
+	}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(pollInterval):
-			// Continue polling
-		}
+	switch state {
+	case StateAttached:
+		return fmt.Errorf("timeout waiting for selector: %s", selector)
+	case StateDetached:
+		return fmt.Errorf("timeout waiting for %q to be removed", selector)
 	}
+	return fmt.Errorf("timeout waiting for %q to be %s", selector, state)
 }
 
 // WaitForText waits for text to appear on the page.
 func (c *Client) WaitForText(ctx context.Context, targetID string, text string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	pollInterval := 100 * time.Millisecond
-
-	escapedText := strings.ReplaceAll(text, "'", "\\'")
-
-	for time.Now().Before(deadline) {
-		result, err := c.Eval(ctx, targetID, fmt.Sprintf(
-			"document.body && document.body.innerText.includes('%s')", escapedText))
-		if err != nil {
-			return err
-		}
-
-		if result.Value == true {
-			return nil
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(pollInterval):
-			// Continue polling
-		}
+	sessionID, err := c.attachToFrame(ctx, targetID)
+	if err != nil {
+		return err
 	}
 
-	return fmt.Errorf("timeout waiting for text %q", text)
+	textJSON, _ := json.Marshal(text)
+	check := fmt.Sprintf("document.body && document.body.innerText.includes(%s)", textJSON)
+	met, err := c.waitInPage(ctx, sessionID, check, time.Now().Add(timeout))
+	if err != nil {
+		return err
+	}
+	if !met {
+		return fmt.Errorf("timeout waiting for text %q", text)
+	}
+	return nil
 }
 
-// WaitForFunction waits until a JavaScript expression evaluates to a truthy value.
+// WaitForFunction waits until a JavaScript expression evaluates to a truthy
+// value. The expression is watched in the page, or, if it is a script that
+// cannot be wrapped in a function, such as one declaring variables,
+// evaluated every 100ms.
 func (c *Client) WaitForFunction(ctx context.Context, targetID string, expression string, timeout time.Duration) error {
-	sessionID, err := c.attachToTarget(ctx, targetID)
+	sessionID, err := c.attachToFrame(ctx, targetID)
 	if err != nil {
 		return err
 	}
 
+	// As when polling, an expression that throws is not yet true
 	deadline := time.Now().Add(timeout)
-	pollInterval := 50 * time.Millisecond
-
-	for {
-		if time.Now().After(deadline) {
-			return fmt.Errorf("timeout waiting for function")
-		}
-
-		result, err := c.CallSession(ctx, sessionID, "Runtime.evaluate", map[string]interface{}{
-			"expression":    expression,
-			"returnByValue": true,
+	check := fmt.Sprintf("(() => { try { return (%s); } catch (e) { return false; } })()", expression)
+	met, err := c.waitInPage(ctx, sessionID, check, deadline)
+	var exception *exceptionDetails
+	if errors.As(err, &exception) && strings.HasPrefix(exception.Error(), "SyntaxError") {
+		met, err = poll(ctx, deadline, func() (bool, error) {
+			result, err := c.evaluate(ctx, sessionID, map[string]interface{}{
+				"expression":    expression,
+				"returnByValue": true,
+			})
+			if err != nil {
+				return false, fmt.Errorf("evaluating expression: %w", err)
+			}
+			var evalResp struct {
+				Result struct {
+					Value interface{} `json:"value"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(result, &evalResp); err != nil {
+				return false, fmt.Errorf("parsing response: %w", err)
+			}
+			return isTruthy(evalResp.Result.Value), nil
 		})
-		if err != nil {
-			return fmt.Errorf("evaluating expression: %w", err)
-		}
-
-		var evalResp struct {
-			Result struct {
-				Value interface{} `json:"value"`
-			} `json:"result"`
-		}
-		if err := json.Unmarshal(result, &evalResp); err != nil {
-			return fmt.Errorf("parsing response: %w", err)
-		}
-
-		// Check if value is truthy
-		if isTruthy(evalResp.Result.Value) {
-			return nil
-		}
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(pollInterval):
-			// Continue polling
-		}
 	}
+	if err != nil {
+		return fmt.Errorf("evaluating expression: %w", err)
+	}
+	if !met {
+		return fmt.Errorf("timeout waiting for function")
+	}
+	return nil
 }
 
 // WaitForNavigation waits for a navigation to complete.
@@ -240,73 +341,57 @@ func (c *Client) WaitForNetworkIdle(ctx context.Context, targetID string, idleTi
 		return err
 	}
 
+	// Read network events from a single tap so that a request's start and
+	// end are seen in order, and start before enabling so none are missed
+	events := c.tapEvents()
+	defer c.untapEvents(events)
+
 	// Enable Network domain
 	_, err = c.CallSession(ctx, sessionID, "Network.enable", nil)
 	if err != nil {
 		return fmt.Errorf("enabling network: %w", err)
 	}
 
-	// Subscribe to network events
-	requestCh := c.subscribeEvent(sessionID, "Network.requestWillBeSent")
-	responseCh := c.subscribeEvent(sessionID, "Network.loadingFinished")
-	failedCh := c.subscribeEvent(sessionID, "Network.loadingFailed")
-
-	defer c.unsubscribeEvent(sessionID, "Network.requestWillBeSent", requestCh)
-	defer c.unsubscribeEvent(sessionID, "Network.loadingFinished", responseCh)
-	defer c.unsubscribeEvent(sessionID, "Network.loadingFailed", failedCh)
-
 	pendingRequests := make(map[string]bool)
 	idleTimer := time.NewTimer(idleTime)
+	defer idleTimer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case params := <-requestCh:
-			var event struct {
-				RequestID string `json:"requestId"`
+		case ev, ok := <-events:
+			if !ok {
+				return ErrConnectionClosed
 			}
-			if err := json.Unmarshal(params, &event); err == nil {
-				pendingRequests[event.RequestID] = true
-				// Reset idle timer when new request starts
-				if !idleTimer.Stop() {
-					select {
-					case <-idleTimer.C:
-					default:
-					}
-				}
-				idleTimer.Reset(idleTime)
+			if ev.SessionID != sessionID {
+				continue
 			}
-		case params := <-responseCh:
 			var event struct {
 				RequestID string `json:"requestId"`
 			}
-			if err := json.Unmarshal(params, &event); err == nil {
-				delete(pendingRequests, event.RequestID)
-				// Reset idle timer when request finishes
-				if !idleTimer.Stop() {
-					select {
-					case <-idleTimer.C:
-					default:
-					}
+			switch ev.Method {
+			case "Network.requestWillBeSent":
+				if err := json.Unmarshal(ev.Params, &event); err != nil {
+					continue
+				}
+				pendingRequests[event.RequestID] = true
+			case "Network.loadingFinished", "Network.loadingFailed":
+				if err := json.Unmarshal(ev.Params, &event); err != nil {
+					continue
 				}
-				idleTimer.Reset(idleTime)
-			}
-		case params := <-failedCh:
-			var event struct {
-				RequestID string `json:"requestId"`
-			}
-			if err := json.Unmarshal(params, &event); err == nil {
 				delete(pendingRequests, event.RequestID)
-				// Reset idle timer when request fails
-				if !idleTimer.Stop() {
-					select {
-					case <-idleTimer.C:
-					default:
-					}
+			default:
+				continue
+			}
+			// Reset idle timer when a request starts or ends
+			if !idleTimer.Stop() {
+				select {
+				case <-idleTimer.C:
+				default:
 				}
-				idleTimer.Reset(idleTime)
 			}
+			idleTimer.Reset(idleTime)
 		case <-idleTimer.C:
 			// No network activity for idleTime
 			if len(pendingRequests) == 0 {
@@ -358,3 +443,349 @@ func (c *Client) WaitForLoad(ctx context.Context, targetID string) error {
 	_, err := c.Eval(ctx, targetID, js)
 	return err
 }
+
+// waitStop blocks until the first condition in cond is met. It returns an
+// error if ctx is done first or a condition cannot be evaluated.
+func (c *Client) waitStop(ctx context.Context, targetID string, cond StopCondition) error {
+	if cond == (StopCondition{}) {
+		return fmt.Errorf("no stop condition given")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	met := make(chan error, 3)
+	if cond.Duration > 0 {
+		go func() {
+			select {
+			case <-time.After(cond.Duration):
+				met <- nil
+			case <-ctx.Done():
+			}
+		}()
+	}
+	if cond.Idle > 0 {
+		go func() {
+			met <- c.WaitForNetworkIdle(ctx, targetID, cond.Idle)
+		}()
+	}
+	if cond.Selector != "" {
+		go func() {
+			timeout := 24 * time.Hour
+			if deadline, ok := ctx.Deadline(); ok {
+				timeout = time.Until(deadline)
+			}
+			met <- c.WaitFor(ctx, targetID, cond.Selector, timeout)
+		}()
+	}
+
+	select {
+	case err := <-met:
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WaitForConsole waits for a console message containing the pattern, or any
+// message if the pattern is empty.
+func (c *Client) WaitForConsole(ctx context.Context, targetID string, pattern string, timeout time.Duration) (*ConsoleMessage, error) {
+	messages, stop, err := c.CaptureConsole(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			if timeoutCtx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("timeout waiting for console message matching %q", pattern)
+			}
+			return nil, timeoutCtx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil, fmt.Errorf("event channel closed")
+			}
+			if strings.Contains(msg.Text, pattern) {
+				return &msg, nil
+			}
+		}
+	}
+}
+
+// WaitForDialog waits for a JavaScript dialog with a message containing the
+// pattern, or any dialog if the pattern is empty. The dialog is left open.
+func (c *Client) WaitForDialog(ctx context.Context, targetID string, pattern string, timeout time.Duration) (*DialogInfo, error) {
+	var dialog DialogInfo
+	match := func() bool { return strings.Contains(dialog.Message, pattern) }
+	what := fmt.Sprintf("dialog matching %q", pattern)
+	if err := c.waitPageEvent(ctx, targetID, "Page.javascriptDialogOpening", &dialog, match, what, timeout); err != nil {
+		return nil, err
+	}
+	return &dialog, nil
+}
+
+// WaitForDownload waits for the page to start a download with a URL or
+// suggested filename containing the pattern, or any download if the pattern
+// is empty.
+func (c *Client) WaitForDownload(ctx context.Context, targetID string, pattern string, timeout time.Duration) (*DownloadInfo, error) {
+	// Download events come from the browser rather than the page
+	beginCh := c.subscribeEvent("", "Browser.downloadWillBegin")
+	defer c.unsubscribeEvent("", "Browser.downloadWillBegin", beginCh)
+
+	if err := c.enableDownloadEvents(ctx); err != nil {
+		return nil, err
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			if timeoutCtx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("timeout waiting for download matching %q", pattern)
+			}
+			return nil, timeoutCtx.Err()
+		case params, ok := <-beginCh:
+			if !ok {
+				return nil, fmt.Errorf("event channel closed")
+			}
+			var event struct {
+				FrameID string `json:"frameId"`
+				DownloadInfo
+			}
+			if json.Unmarshal(params, &event) != nil {
+				continue
+			}
+			if !strings.Contains(event.URL, pattern) && !strings.Contains(event.SuggestedFilename, pattern) {
+				continue
+			}
+			fromTarget, err := c.hasFrame(timeoutCtx, targetID, event.FrameID)
+			if err != nil {
+				return nil, err
+			}
+			if fromTarget {
+				return &event.DownloadInfo, nil
+			}
+		}
+	}
+}
+
+// waitPageEvent enables the Page domain and waits for a method event whose
+// params, decoded into v, match accepts. what describes the event in the
+// timeout error.
+func (c *Client) waitPageEvent(ctx context.Context, targetID string, method string, v interface{}, match func() bool, what string, timeout time.Duration) error {
+	sessionID, err := c.attachToTarget(ctx, targetID)
+	if err != nil {
+		return err
+	}
+
+	eventCh := c.subscribeEvent(sessionID, method)
+	defer c.unsubscribeEvent(sessionID, method, eventCh)
+
+	if _, err := c.CallSession(ctx, sessionID, "Page.enable", nil); err != nil {
+		return fmt.Errorf("enabling Page domain: %w", err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		select {
+		case <-timeoutCtx.Done():
+			if timeoutCtx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("timeout waiting for %s", what)
+			}
+			return timeoutCtx.Err()
+		case params, ok := <-eventCh:
+			if !ok {
+				return fmt.Errorf("event channel closed")
+			}
+			if err := json.Unmarshal(params, v); err != nil {
+				continue
+			}
+			if match() {
+				return nil
+			}
+		}
+	}
+}
+
+// WaitConditionKinds are the kinds of WaitCondition.
+var WaitConditionKinds = []string{"selector", "text", "url", "fn", "request", "response", "status", "console", "dialog", "download"}
+
+// ParseWaitCondition parses a condition of the form kind:value. The value is
+// optional for console, dialog and download, which match any message, dialog
+// or download without one.
+func ParseWaitCondition(spec string) (WaitCondition, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	if !slices.Contains(WaitConditionKinds, kind) {
+		return WaitCondition{}, fmt.Errorf("invalid condition %q (use kind:value, where kind is one of %s)", spec, strings.Join(WaitConditionKinds, ", "))
+	}
+	switch kind {
+	case "console", "dialog", "download":
+	case "status":
+		if _, err := StatusMatcher(value); err != nil {
+			return WaitCondition{}, err
+		}
+	default:
+		if value == "" {
+			return WaitCondition{}, fmt.Errorf("condition %q needs a value, as in %s:<value>", spec, kind)
+		}
+	}
+	return WaitCondition{Kind: kind, Value: value}, nil
+}
+
+// String returns the condition in the form ParseWaitCondition accepts.
+func (w WaitCondition) String() string {
+	if w.Value == "" {
+		return w.Kind
+	}
+	return w.Kind + ":" + w.Value
+}
+
+// waitCondition waits for a single condition and returns what matched, for
+// the conditions that report it.
+func (c *Client) waitCondition(ctx context.Context, targetID string, cond WaitCondition, timeout time.Duration) (interface{}, error) {
+	switch cond.Kind {
+	case "selector":
+		return nil, c.WaitFor(ctx, targetID, cond.Value, timeout)
+	case "text":
+		return nil, c.WaitForText(ctx, targetID, cond.Value, timeout)
+	case "url":
+		url, err := c.WaitForURL(ctx, targetID, cond.Value, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"url": url}, nil
+	case "fn":
+		return nil, c.WaitForFunction(ctx, targetID, cond.Value, timeout)
+	case "request":
+		return c.WaitForRequest(ctx, targetID, cond.Value, timeout)
+	case "response":
+		return c.WaitForResponse(ctx, targetID, cond.Value, timeout)
+	case "status":
+		return c.WaitForStatus(ctx, targetID, cond.Value, timeout)
+	case "console":
+		return c.WaitForConsole(ctx, targetID, cond.Value, timeout)
+	case "dialog":
+		return c.WaitForDialog(ctx, targetID, cond.Value, timeout)
+	case "download":
+		return c.WaitForDownload(ctx, targetID, cond.Value, timeout)
+	}
+	return nil, fmt.Errorf("invalid condition kind %q", cond.Kind)
+}
+
+// conditionOutcome is how the wait for one of several conditions ended.
+type conditionOutcome struct {
+	index  int
+	result interface{}
+	err    error
+}
+
+// startConditions waits for each of the conditions at once, and returns a
+// channel that receives the outcome of each wait as it ends.
+func (c *Client) startConditions(ctx context.Context, targetID string, conds []WaitCondition, timeout time.Duration) (<-chan conditionOutcome, error) {
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("no conditions given")
+	}
+	// Attach before starting so that the waits share a session
+	if _, err := c.attachToTarget(ctx, targetID); err != nil {
+		return nil, err
+	}
+
+	outcomes := make(chan conditionOutcome, len(conds))
+	for i, cond := range conds {
+		go func() {
+			result, err := c.waitCondition(ctx, targetID, cond, timeout)
+			outcomes <- conditionOutcome{index: i, result: result, err: err}
+		}()
+	}
+	return outcomes, nil
+}
+
+// WaitForAny waits for all of the conditions at once and returns the first to
+// be met. It fails as soon as a condition cannot be waited for, such as an
+// expression that does not compile.
+func (c *Client) WaitForAny(ctx context.Context, targetID string, conds []WaitCondition, timeout time.Duration) (*ConditionResult, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	outcomes, err := c.startConditions(ctx, targetID, conds, timeout)
+	if err != nil {
+		return nil, err
+	}
+	for range conds {
+		o := <-outcomes
+		if o.err == nil {
+			return &ConditionResult{
+				Condition: conds[o.index].String(),
+				Index:     o.index,
+				ElapsedMs: time.Since(start).Milliseconds(),
+				Result:    o.result,
+			}, nil
+		}
+		if ctx.Err() == nil {
+			return nil, fmt.Errorf("%s: %w", conds[o.index], o.err)
+		}
+	}
+	if ctx.Err() == context.Canceled {
+		return nil, ctx.Err()
+	}
+	return nil, fmt.Errorf("timeout waiting for any of %d conditions", len(conds))
+}
+
+// WaitForAll waits for all of the conditions at once until each has been met,
+// and returns them in the order given. It fails as soon as a condition cannot
+// be waited for.
+func (c *Client) WaitForAll(ctx context.Context, targetID string, conds []WaitCondition, timeout time.Duration) ([]ConditionResult, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	outcomes, err := c.startConditions(ctx, targetID, conds, timeout)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ConditionResult, len(conds))
+	met := make([]bool, len(conds))
+	for range conds {
+		o := <-outcomes
+		if o.err != nil {
+			if ctx.Err() == nil {
+				return nil, fmt.Errorf("%s: %w", conds[o.index], o.err)
+			}
+			continue
+		}
+		met[o.index] = true
+		results[o.index] = ConditionResult{
+			Condition: conds[o.index].String(),
+			Index:     o.index,
+			ElapsedMs: time.Since(start).Milliseconds(),
+			Result:    o.result,
+		}
+	}
+
+	var pending []string
+	for i, ok := range met {
+		if !ok {
+			pending = append(pending, conds[i].String())
+		}
+	}
+	if len(pending) > 0 {
+		if ctx.Err() == context.Canceled {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("timeout waiting for %s", strings.Join(pending, ", "))
+	}
+	return results, nil
+}
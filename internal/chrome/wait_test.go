@@ -0,0 +1,75 @@
+package chrome
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatusMatcher(t *testing.T) {
+	tests := []struct {
+		pattern string
+		status  int
+		want    bool
+	}{
+		{"5xx", 503, true},
+		{"5XX", 500, true},
+		{"5xx", 404, false},
+		{"404", 404, true},
+		{"404", 405, false},
+		{"2xx", 204, true},
+	}
+	for _, tt := range tests {
+		match, err := StatusMatcher(tt.pattern)
+		if err != nil {
+			t.Errorf("StatusMatcher(%q): %v", tt.pattern, err)
+			continue
+		}
+		if got := match(tt.status); got != tt.want {
+			t.Errorf("StatusMatcher(%q)(%d) = %v, want %v", tt.pattern, tt.status, got, tt.want)
+		}
+	}
+	for _, pattern := range []string{"", "6xx", "5x", "99", "600", "ok"} {
+		if _, err := StatusMatcher(pattern); err == nil {
+			t.Errorf("StatusMatcher(%q): expected an error", pattern)
+		}
+	}
+}
+
+func TestParseWaitCondition(t *testing.T) {
+	tests := []struct {
+		spec string
+		want WaitCondition
+	}{
+		{"selector:#success", WaitCondition{Kind: "selector", Value: "#success"}},
+		{"selector:a:hover", WaitCondition{Kind: "selector", Value: "a:hover"}},
+		{"url:https://x.test/error", WaitCondition{Kind: "url", Value: "https://x.test/error"}},
+		{"status:5xx", WaitCondition{Kind: "status", Value: "5xx"}},
+		{"dialog", WaitCondition{Kind: "dialog"}},
+		{"download:.csv", WaitCondition{Kind: "download", Value: ".csv"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseWaitCondition(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseWaitCondition(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+		if got.String() != tt.spec {
+			t.Errorf("%+v.String() = %q, want %q", got, got.String(), tt.spec)
+		}
+	}
+
+	errTests := []struct {
+		spec string
+		want string
+	}{
+		{"#success", "invalid condition"},
+		{"cookie:x", "invalid condition"},
+		{"selector", "needs a value"},
+		{"fn:", "needs a value"},
+		{"status:teapot", "invalid status"},
+	}
+	for _, tt := range errTests {
+		if _, err := ParseWaitCondition(tt.spec); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseWaitCondition(%q) error = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
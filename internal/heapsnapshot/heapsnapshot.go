@@ -0,0 +1,359 @@
+// Package heapsnapshot reads V8 heap snapshots (.heapsnapshot files) and
+// summarizes them the way the Chrome DevTools Memory panel does.
+//
+// A snapshot stores its object graph as flat integer arrays: every node is
+// a run of meta.node_fields values and every edge a run of meta.edge_fields
+// values, with the edges of each node stored consecutively in node order.
+package heapsnapshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Snapshot is a parsed heap snapshot.
+type Snapshot struct {
+	nodes   []int
+	edges   []int
+	strings []string
+
+	nodeFieldCount int
+	nodeType       int
+	nodeName       int
+	nodeID         int
+	nodeSelfSize   int
+	nodeEdgeCount  int
+	nodeDetached   int // -1 if the snapshot does not record detachedness
+
+	edgeFieldCount int
+	edgeType       int
+	edgeName       int
+	edgeToNode     int
+
+	nodeTypes []string
+	edgeTypes []string
+
+	firstEdge []int // Offset into edges of each node's first edge
+}
+
+// meta describes the layout of the nodes and edges arrays.
+type meta struct {
+	NodeFields []string          `json:"node_fields"`
+	NodeTypes  []json.RawMessage `json:"node_types"`
+	EdgeFields []string          `json:"edge_fields"`
+	EdgeTypes  []json.RawMessage `json:"edge_types"`
+}
+
+// Parse reads a heap snapshot. Only the snapshot metadata and the nodes,
+// edges and strings arrays are kept.
+func Parse(r io.Reader) (*Snapshot, error) {
+	dec := json.NewDecoder(bufio.NewReaderSize(r, 1<<20))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("parsing heap snapshot: expected object")
+	}
+
+	var header struct {
+		Meta meta `json:"meta"`
+	}
+	s := &Snapshot{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("parsing heap snapshot: %w", err)
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "snapshot":
+			err = dec.Decode(&header)
+		case "nodes":
+			err = dec.Decode(&s.nodes)
+		case "edges":
+			err = dec.Decode(&s.edges)
+		case "strings":
+			err = dec.Decode(&s.strings)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing heap snapshot %s: %w", key, err)
+		}
+	}
+
+	if err := s.init(header.Meta); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// init resolves field offsets from the metadata and indexes the edges.
+func (s *Snapshot) init(m meta) error {
+	field := func(fields []string, name string) int {
+		for i, f := range fields {
+			if f == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	s.nodeFieldCount = len(m.NodeFields)
+	s.nodeType = field(m.NodeFields, "type")
+	s.nodeName = field(m.NodeFields, "name")
+	s.nodeID = field(m.NodeFields, "id")
+	s.nodeSelfSize = field(m.NodeFields, "self_size")
+	s.nodeEdgeCount = field(m.NodeFields, "edge_count")
+	s.nodeDetached = field(m.NodeFields, "detachedness")
+	s.edgeFieldCount = len(m.EdgeFields)
+	s.edgeType = field(m.EdgeFields, "type")
+	s.edgeName = field(m.EdgeFields, "name_or_index")
+	s.edgeToNode = field(m.EdgeFields, "to_node")
+
+	if s.nodeType < 0 || s.nodeName < 0 || s.nodeID < 0 || s.nodeSelfSize < 0 || s.nodeEdgeCount < 0 || s.edgeType < 0 || s.edgeName < 0 || s.edgeToNode < 0 {
+		return fmt.Errorf("parsing heap snapshot: missing node or edge fields in metadata")
+	}
+	if len(m.NodeTypes) == 0 || json.Unmarshal(m.NodeTypes[0], &s.nodeTypes) != nil {
+		return fmt.Errorf("parsing heap snapshot: invalid node types")
+	}
+	if len(m.EdgeTypes) == 0 || json.Unmarshal(m.EdgeTypes[0], &s.edgeTypes) != nil {
+		return fmt.Errorf("parsing heap snapshot: invalid edge types")
+	}
+	if len(s.nodes)%s.nodeFieldCount != 0 || len(s.edges)%s.edgeFieldCount != 0 {
+		return fmt.Errorf("parsing heap snapshot: truncated nodes or edges")
+	}
+
+	n := s.NodeCount()
+	s.firstEdge = make([]int, n+1)
+	for i := 0; i < n; i++ {
+		s.firstEdge[i+1] = s.firstEdge[i] + s.nodes[i*s.nodeFieldCount+s.nodeEdgeCount]*s.edgeFieldCount
+	}
+	if s.firstEdge[n] != len(s.edges) {
+		return fmt.Errorf("parsing heap snapshot: edge counts do not match edges")
+	}
+	for i := s.edgeToNode; i < len(s.edges); i += s.edgeFieldCount {
+		if to := s.edges[i]; to < 0 || to >= len(s.nodes) || to%s.nodeFieldCount != 0 {
+			return fmt.Errorf("parsing heap snapshot: edge to invalid node %d", to)
+		}
+	}
+	return nil
+}
+
+// NodeCount returns the number of nodes (heap objects) in the snapshot.
+func (s *Snapshot) NodeCount() int {
+	if s.nodeFieldCount == 0 {
+		return 0
+	}
+	return len(s.nodes) / s.nodeFieldCount
+}
+
+func (s *Snapshot) node(i, field int) int {
+	return s.nodes[i*s.nodeFieldCount+field]
+}
+
+func (s *Snapshot) str(i int) string {
+	if i < 0 || i >= len(s.strings) {
+		return ""
+	}
+	return s.strings[i]
+}
+
+func (s *Snapshot) typeName(i int) string {
+	t := s.node(i, s.nodeType)
+	if t < 0 || t >= len(s.nodeTypes) {
+		return ""
+	}
+	return s.nodeTypes[t]
+}
+
+// ID returns the heap object ID of node i, which is stable across
+// snapshots taken in the same session.
+func (s *Snapshot) ID(i int) int {
+	return s.node(i, s.nodeID)
+}
+
+// SelfSize returns the size of node i itself, in bytes.
+func (s *Snapshot) SelfSize(i int) int {
+	return s.node(i, s.nodeSelfSize)
+}
+
+// Detached reports whether node i is a DOM node no longer in a document.
+func (s *Snapshot) Detached(i int) bool {
+	if s.nodeDetached >= 0 && s.node(i, s.nodeDetached) == 2 {
+		return true
+	}
+	return strings.HasPrefix(s.str(s.node(i, s.nodeName)), "Detached ")
+}
+
+// ClassName returns the constructor name DevTools groups node i under:
+// the name of objects, or the node type in parentheses for everything else.
+func (s *Snapshot) ClassName(i int) string {
+	switch t := s.typeName(i); t {
+	case "object", "native":
+		return strings.TrimPrefix(s.str(s.node(i, s.nodeName)), "Detached ")
+	case "hidden":
+		return "(system)"
+	case "code":
+		return "(compiled code)"
+	default:
+		return "(" + t + ")"
+	}
+}
+
+// essentialEdge reports whether edge e (an offset into edges) of node i
+// keeps its target alive. Weak references never do, and shortcuts only
+// from the root, matching DevTools.
+func (s *Snapshot) essentialEdge(i, e int) bool {
+	t := s.edges[e+s.edgeType]
+	if t < 0 || t >= len(s.edgeTypes) {
+		return true
+	}
+	switch s.edgeTypes[t] {
+	case "weak":
+		return false
+	case "shortcut":
+		return i == 0
+	}
+	return true
+}
+
+// RetainedSizes returns, for each node, its self size plus the self sizes
+// of every node it dominates: the memory that would be freed if it were
+// collected. Nodes unreachable from the root retain only themselves.
+func (s *Snapshot) RetainedSizes() []int64 {
+	return s.retainedSizes(s.dominators())
+}
+
+func (s *Snapshot) retainedSizes(dom dominatorTree) []int64 {
+	retained := make([]int64, len(dom.idom))
+	for i := range retained {
+		retained[i] = int64(s.SelfSize(i))
+	}
+	// Post order visits every node before its dominator
+	for _, i := range dom.postOrder {
+		if d := dom.idom[i]; d >= 0 && d != i {
+			retained[d] += retained[i]
+		}
+	}
+	return retained
+}
+
+// dominatorTree is the immediate dominator of each node, -1 where the node
+// is unreachable, and the reachable nodes in DFS post order (root last).
+type dominatorTree struct {
+	idom      []int
+	postOrder []int
+}
+
+// dominators computes the dominator tree rooted at node 0 with the
+// iterative algorithm of Cooper, Harvey and Kennedy.
+func (s *Snapshot) dominators() dominatorTree {
+	n := s.NodeCount()
+	tree := dominatorTree{idom: make([]int, n)}
+	for i := range tree.idom {
+		tree.idom[i] = -1
+	}
+	if n == 0 {
+		return tree
+	}
+
+	// Depth-first post order over essential edges
+	order := make([]int, n) // Post order index of each node, -1 if unvisited
+	for i := range order {
+		order[i] = -1
+	}
+	visited := make([]bool, n)
+	type frame struct{ node, edge int }
+	stack := []frame{{0, s.firstEdge[0]}}
+	visited[0] = true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.edge < s.firstEdge[top.node+1] {
+			e := top.edge
+			top.edge += s.edgeFieldCount
+			to := s.edges[e+s.edgeToNode] / s.nodeFieldCount
+			if !visited[to] && s.essentialEdge(top.node, e) {
+				visited[to] = true
+				stack = append(stack, frame{to, s.firstEdge[to]})
+			}
+			continue
+		}
+		order[top.node] = len(tree.postOrder)
+		tree.postOrder = append(tree.postOrder, top.node)
+		stack = stack[:len(stack)-1]
+	}
+
+	// Predecessors of each reachable node, by post order index
+	count := make([]int, len(tree.postOrder)+1)
+	s.eachEssentialEdge(order, func(from, to int) { count[order[to]+1]++ })
+	for i := 1; i < len(count); i++ {
+		count[i] += count[i-1]
+	}
+	preds := make([]int, count[len(count)-1])
+	next := append([]int(nil), count[:len(count)-1]...)
+	s.eachEssentialEdge(order, func(from, to int) {
+		preds[next[order[to]]] = order[from]
+		next[order[to]]++
+	})
+
+	root := len(tree.postOrder) - 1
+	doms := make([]int, len(tree.postOrder))
+	for i := range doms {
+		doms[i] = -1
+	}
+	doms[root] = root
+	intersect := func(a, b int) int {
+		for a != b {
+			for a < b {
+				a = doms[a]
+			}
+			for b < a {
+				b = doms[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for b := root - 1; b >= 0; b-- {
+			newIdom := -1
+			for _, p := range preds[count[b]:count[b+1]] {
+				if doms[p] < 0 {
+					continue
+				}
+				if newIdom < 0 {
+					newIdom = p
+				} else {
+					newIdom = intersect(p, newIdom)
+				}
+			}
+			if newIdom >= 0 && doms[b] != newIdom {
+				doms[b] = newIdom
+				changed = true
+			}
+		}
+	}
+
+	for i, node := range tree.postOrder {
+		tree.idom[node] = tree.postOrder[doms[i]]
+	}
+	return tree
+}
+
+// eachEssentialEdge calls fn for every essential edge between reachable
+// nodes.
+func (s *Snapshot) eachEssentialEdge(order []int, fn func(from, to int)) {
+	for from := 0; from < s.NodeCount(); from++ {
+		if order[from] < 0 {
+			continue
+		}
+		for e := s.firstEdge[from]; e < s.firstEdge[from+1]; e += s.edgeFieldCount {
+			to := s.edges[e+s.edgeToNode] / s.nodeFieldCount
+			if s.essentialEdge(from, e) && order[to] >= 0 {
+				fn(from, to)
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package heapsnapshot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testSnapshot builds a snapshot in the V8 format from nodes given as
+// {type, name, id, selfSize} and edges as {from, type, to}.
+func testSnapshot(t *testing.T, nodes [][4]interface{}, edges [][3]interface{}) *Snapshot {
+	t.Helper()
+	strs := []string{""}
+	str := func(s string) int {
+		for i, existing := range strs {
+			if existing == s {
+				return i
+			}
+		}
+		strs = append(strs, s)
+		return len(strs) - 1
+	}
+	nodeTypes := []string{"hidden", "array", "string", "object", "code", "closure", "regexp", "number", "native", "synthetic"}
+	edgeTypes := []string{"context", "element", "property", "internal", "hidden", "shortcut", "weak"}
+	index := func(types []string, name string) int {
+		for i, t := range types {
+			if t == name {
+				return i
+			}
+		}
+		t.Fatalf("unknown type %q", name)
+		return 0
+	}
+
+	counts := make([]int, len(nodes))
+	for _, e := range edges {
+		counts[e[0].(int)]++
+	}
+	var nodeValues []string
+	for i, n := range nodes {
+		nodeValues = append(nodeValues, fmt.Sprintf("%d,%d,%d,%d,%d,0,0",
+			index(nodeTypes, n[0].(string)), str(n[1].(string)), n[2].(int), n[3].(int), counts[i]))
+	}
+	var edgeValues []string
+	for from := range nodes {
+		for _, e := range edges {
+			if e[0].(int) == from {
+				edgeValues = append(edgeValues, fmt.Sprintf("%d,%d,%d", index(edgeTypes, e[1].(string)), str("ref"), e[2].(int)*7))
+			}
+		}
+	}
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+
+	data := `{"snapshot":{"meta":{` +
+		`"node_fields":["type","name","id","self_size","edge_count","trace_node_id","detachedness"],` +
+		`"node_types":[["` + strings.Join(nodeTypes, `","`) + `"],"string","number","number","number","number","number"],` +
+		`"edge_fields":["type","name_or_index","to_node"],` +
+		`"edge_types":[["` + strings.Join(edgeTypes, `","`) + `"],"string_or_number","node"]},` +
+		fmt.Sprintf(`"node_count":%d,"edge_count":%d},`, len(nodes), len(edges)) +
+		`"nodes":[` + strings.Join(nodeValues, ",") + `],` +
+		`"edges":[` + strings.Join(edgeValues, ",") + `],` +
+		`"trace_function_infos":[],"trace_tree":[],"samples":[],"locations":[],` +
+		`"strings":[` + strings.Join(quoted, ",") + `]}`
+
+	s, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return s
+}
+
+// sampleSnapshot is a page whose Foo retains another Foo, a detached div
+// and, shared with the window, a Bar; a Baz is only weakly referenced.
+func sampleSnapshot(t *testing.T) *Snapshot {
+	return testSnapshot(t,
+		[][4]interface{}{
+			{"synthetic", "", 1, 0},
+			{"object", "Window", 3, 10},
+			{"object", "Foo", 5, 100},
+			{"object", "Foo", 7, 50},
+			{"object", "Bar", 9, 30},
+			{"native", "Detached HTMLDivElement", 11, 20},
+			{"object", "Baz", 13, 7},
+		},
+		[][3]interface{}{
+			{0, "element", 1},
+			{1, "property", 2},
+			{1, "property", 4},
+			{1, "weak", 6},
+			{2, "property", 3},
+			{2, "property", 4},
+			{2, "property", 5},
+		})
+}
+
+func TestRetainedSizes(t *testing.T) {
+	s := sampleSnapshot(t)
+	want := []int64{210, 210, 170, 50, 30, 20, 7}
+	got := s.RetainedSizes()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("retained size of node %d = %d, want %d", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	sum := sampleSnapshot(t).Summarize()
+
+	if sum.NodeCount != 7 || sum.TotalSize != 217 {
+		t.Errorf("NodeCount = %d, TotalSize = %d, want 7, 217", sum.NodeCount, sum.TotalSize)
+	}
+
+	classes := make(map[string]Class)
+	for _, c := range sum.Constructors {
+		classes[c.Name] = c
+	}
+	// The inner Foo is retained by the outer one and only counted once
+	if foo := classes["Foo"]; foo.Count != 2 || foo.SelfSize != 150 || foo.RetainedSize != 170 {
+		t.Errorf("Foo = %+v, want count 2, self 150, retained 170", foo)
+	}
+	if baz := classes["Baz"]; baz.RetainedSize != 7 {
+		t.Errorf("Baz = %+v, want retained 7", baz)
+	}
+	if sum.Constructors[0].Name != "Window" || sum.Constructors[1].Name != "Foo" {
+		t.Errorf("expected constructors by retained size, got %+v", sum.Constructors)
+	}
+
+	if sum.DetachedCount != 1 || len(sum.Detached) != 1 || sum.Detached[0].Name != "HTMLDivElement" || sum.Detached[0].RetainedSize != 20 {
+		t.Errorf("unexpected detached nodes: %d %+v", sum.DetachedCount, sum.Detached)
+	}
+}
+
+func TestClassCounts(t *testing.T) {
+	counts := sampleSnapshot(t).ClassCounts()
+	if counts["Foo"] != 2 || counts["Window"] != 1 {
+		t.Errorf("ClassCounts() = %v, want 2 Foo and 1 Window", counts)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := sampleSnapshot(t)
+	after := testSnapshot(t,
+		[][4]interface{}{
+			{"synthetic", "", 1, 0},
+			{"object", "Window", 3, 10},
+			{"object", "Foo", 5, 100},
+			{"object", "Foo", 15, 60},
+			{"object", "Foo", 17, 60},
+			{"object", "Bar", 9, 30},
+		},
+		[][3]interface{}{
+			{0, "element", 1},
+			{1, "property", 2},
+			{1, "property", 5},
+			{2, "property", 3},
+			{2, "property", 4},
+		})
+
+	d := Compare(before, after)
+	if d.NodeCountDelta != -1 || d.TotalSizeDelta != 43 {
+		t.Errorf("NodeCountDelta = %d, TotalSizeDelta = %d, want -1, 43", d.NodeCountDelta, d.TotalSizeDelta)
+	}
+	if len(d.Constructors) != 1 {
+		t.Fatalf("expected only Foo to grow, got %+v", d.Constructors)
+	}
+	foo := d.Constructors[0]
+	if foo.Name != "Foo" || foo.CountBefore != 2 || foo.CountAfter != 3 || foo.CountDelta != 1 || foo.SizeDelta != 70 {
+		t.Errorf("unexpected Foo diff: %+v", foo)
+	}
+	if foo.New != 2 || foo.Deleted != 1 {
+		t.Errorf("Foo new = %d, deleted = %d, want 2, 1", foo.New, foo.Deleted)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not an object", `[]`},
+		{"missing meta", `{"nodes":[],"edges":[],"strings":[]}`},
+		{"truncated", `{"snapshot":{"meta":{"node_fields":["type","name","id","self_size","edge_count"],"node_types":[["object"]],"edge_fields":["type","name_or_index","to_node"],"edge_types":[["property"]]}},"nodes":[0,0,1],"edges":[],"strings":[""]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(tt.data)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRetainers_Path(t *testing.T) {
+	s := sampleSnapshot(t)
+	r := s.Retainers()
+
+	tests := []struct {
+		node int
+		want string
+	}{
+		{1, "Window"},
+		{3, "Window.ref.ref → Foo"},
+		{4, "Window.ref → Bar"},
+		{5, "Window.ref.ref → HTMLDivElement"},
+		{6, ""}, // Only weakly held
+	}
+	for _, tt := range tests {
+		if got := r.Path(tt.node); got != tt.want {
+			t.Errorf("Path(%d) = %q, want %q", tt.node, got, tt.want)
+		}
+	}
+}
+
+func TestNewObjects(t *testing.T) {
+	before := sampleSnapshot(t)
+	after := testSnapshot(t,
+		[][4]interface{}{
+			{"synthetic", "", 1, 0},
+			{"object", "Foo", 5, 100},
+			{"object", "Foo", 15, 60},
+			{"object", "Bar", 17, 60},
+		},
+		[][3]interface{}{
+			{0, "element", 1},
+			{1, "property", 2},
+			{1, "property", 3},
+		})
+
+	got := NewObjects(before, after, "Foo")
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("NewObjects = %v, want [2]", got)
+	}
+}
@@ -0,0 +1,116 @@
+package heapsnapshot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Retainers records, for every node reachable from the root, the edge by
+// which it is first reached in a breadth-first walk: the last step of its
+// shortest retaining path.
+type Retainers struct {
+	s    *Snapshot
+	from []int // Retaining node, -1 for the root and unreachable nodes
+	edge []int // Offset into edges of the retaining edge
+}
+
+// Retainers walks the snapshot from the root over the edges that keep
+// objects alive.
+func (s *Snapshot) Retainers() *Retainers {
+	n := s.NodeCount()
+	r := &Retainers{s: s, from: make([]int, n), edge: make([]int, n)}
+	for i := range r.from {
+		r.from[i] = -1
+	}
+	if n == 0 {
+		return r
+	}
+
+	visited := make([]bool, n)
+	visited[0] = true
+	queue := []int{0}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for e := s.firstEdge[i]; e < s.firstEdge[i+1]; e += s.edgeFieldCount {
+			to := s.edges[e+s.edgeToNode] / s.nodeFieldCount
+			if visited[to] || !s.essentialEdge(i, e) {
+				continue
+			}
+			visited[to] = true
+			r.from[to] = i
+			r.edge[to] = e
+			queue = append(queue, to)
+		}
+	}
+	return r
+}
+
+// Path describes the shortest path from the root to node i as property
+// accesses from the first object below the synthetic roots, followed by
+// the node's constructor, such as "Window.app.listeners[3] → Listener".
+// Returns "" if the node is unreachable.
+func (r *Retainers) Path(i int) string {
+	if i != 0 && r.from[i] < 0 {
+		return ""
+	}
+
+	// Walk up to the first synthetic node, collecting edges leaf first
+	var edges []int
+	node := i
+	for r.from[node] >= 0 && r.s.typeName(r.from[node]) != "synthetic" {
+		edges = append(edges, r.edge[node])
+		node = r.from[node]
+	}
+	if len(edges) == 0 {
+		return r.s.ClassName(i)
+	}
+
+	var b strings.Builder
+	b.WriteString(r.s.ClassName(node))
+	for j := len(edges) - 1; j >= 0; j-- {
+		b.WriteString(r.s.edgeLabel(edges[j]))
+	}
+	b.WriteString(" → ")
+	b.WriteString(r.s.ClassName(i))
+	return b.String()
+}
+
+// edgeLabel formats the edge at offset e as a property access.
+func (s *Snapshot) edgeLabel(e int) string {
+	name := s.edges[e+s.edgeName]
+	t := s.edges[e+s.edgeType]
+	if t >= 0 && t < len(s.edgeTypes) {
+		switch s.edgeTypes[t] {
+		case "element", "hidden":
+			return "[" + strconv.Itoa(name) + "]"
+		}
+	}
+	return "." + s.str(name)
+}
+
+// NewObjects returns the nodes of after with the given constructor whose
+// heap object IDs are not in before, in node order.
+func NewObjects(before, after *Snapshot, class string) []int {
+	var ids []int
+	for i := 0; i < before.NodeCount(); i++ {
+		if before.ClassName(i) == class {
+			ids = append(ids, before.ID(i))
+		}
+	}
+	sort.Ints(ids)
+
+	var nodes []int
+	for i := 0; i < after.NodeCount(); i++ {
+		if after.ClassName(i) != class {
+			continue
+		}
+		id := after.ID(i)
+		if j := sort.SearchInts(ids, id); j < len(ids) && ids[j] == id {
+			continue
+		}
+		nodes = append(nodes, i)
+	}
+	return nodes
+}
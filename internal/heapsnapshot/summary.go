@@ -0,0 +1,229 @@
+package heapsnapshot
+
+import "sort"
+
+// Class aggregates the nodes of one constructor.
+type Class struct {
+	Name         string `json:"name"`
+	Count        int    `json:"count"`
+	SelfSize     int64  `json:"selfSize"`
+	RetainedSize int64  `json:"retainedSize"`
+}
+
+// Summary is a heap snapshot grouped by constructor.
+type Summary struct {
+	NodeCount     int     `json:"nodeCount"`
+	TotalSize     int64   `json:"totalSize"`
+	Constructors  []Class `json:"constructors"`
+	DetachedCount int     `json:"detachedCount"`
+	Detached      []Class `json:"detached"`
+}
+
+// Summarize groups the snapshot's nodes by constructor, largest retained
+// size first. The retained size of a constructor counts each object once:
+// objects dominated by another object of the same constructor are already
+// included in its retained size. Synthetic nodes are left out, and detached
+// DOM nodes are also grouped separately.
+func (s *Snapshot) Summarize() *Summary {
+	n := s.NodeCount()
+	dom := s.dominators()
+	retained := s.retainedSizes(dom)
+
+	classIDs := make(map[string]int)
+	var classes []Class
+	nodeClass := make([]int, n)
+	detachedIDs := make(map[string]int)
+	var detached []Class
+
+	sum := &Summary{NodeCount: n}
+	for i := 0; i < n; i++ {
+		name := s.ClassName(i)
+		id, ok := classIDs[name]
+		if !ok {
+			id = len(classes)
+			classIDs[name] = id
+			classes = append(classes, Class{Name: name})
+		}
+		nodeClass[i] = id
+		classes[id].Count++
+		classes[id].SelfSize += int64(s.SelfSize(i))
+		sum.TotalSize += int64(s.SelfSize(i))
+
+		if s.Detached(i) {
+			sum.DetachedCount++
+			d, ok := detachedIDs[name]
+			if !ok {
+				d = len(detached)
+				detachedIDs[name] = d
+				detached = append(detached, Class{Name: name})
+			}
+			detached[d].Count++
+			detached[d].SelfSize += int64(s.SelfSize(i))
+			detached[d].RetainedSize += retained[i]
+		}
+	}
+
+	// Walk the dominator tree, adding each object's retained size unless an
+	// object of the same constructor dominates it
+	children := make([][]int, n)
+	for _, i := range dom.postOrder {
+		if d := dom.idom[i]; d != i {
+			children[d] = append(children[d], i)
+		}
+	}
+	onPath := make([]int, len(classes))
+	type frame struct {
+		node  int
+		child int
+	}
+	if n > 0 {
+		stack := []frame{{node: 0}}
+		onPath[nodeClass[0]]++
+		classes[nodeClass[0]].RetainedSize += retained[0]
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.child < len(children[top.node]) {
+				c := children[top.node][top.child]
+				top.child++
+				if onPath[nodeClass[c]] == 0 {
+					classes[nodeClass[c]].RetainedSize += retained[c]
+				}
+				onPath[nodeClass[c]]++
+				stack = append(stack, frame{node: c})
+				continue
+			}
+			onPath[nodeClass[top.node]]--
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	// Unreachable nodes are not in the tree and retain only themselves
+	for i := 0; i < n; i++ {
+		if dom.idom[i] < 0 {
+			classes[nodeClass[i]].RetainedSize += retained[i]
+		}
+	}
+
+	// Synthetic nodes such as the GC roots are not objects
+	sum.Constructors = []Class{}
+	for _, c := range classes {
+		if c.Name != "(synthetic)" {
+			sum.Constructors = append(sum.Constructors, c)
+		}
+	}
+	sortClasses(sum.Constructors)
+	sortClasses(detached)
+	sum.Detached = detached
+	if sum.Detached == nil {
+		sum.Detached = []Class{}
+	}
+	return sum
+}
+
+// ClassCounts returns the number of objects of each constructor, named as
+// by ClassName.
+func (s *Snapshot) ClassCounts() map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < s.NodeCount(); i++ {
+		counts[s.ClassName(i)]++
+	}
+	return counts
+}
+
+func sortClasses(classes []Class) {
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].RetainedSize != classes[j].RetainedSize {
+			return classes[i].RetainedSize > classes[j].RetainedSize
+		}
+		return classes[i].Name < classes[j].Name
+	})
+}
+
+// ClassDiff is the change in one constructor between two snapshots.
+type ClassDiff struct {
+	Name        string `json:"name"`
+	CountBefore int    `json:"countBefore"`
+	CountAfter  int    `json:"countAfter"`
+	CountDelta  int    `json:"countDelta"`
+	New         int    `json:"new"`     // Objects only in the second snapshot
+	Deleted     int    `json:"deleted"` // Objects only in the first snapshot
+	SizeDelta   int64  `json:"sizeDelta"`
+}
+
+// Diff is the change between two snapshots of the same page.
+type Diff struct {
+	NodeCountDelta int         `json:"nodeCountDelta"`
+	TotalSizeDelta int64       `json:"totalSizeDelta"`
+	Constructors   []ClassDiff `json:"constructors"`
+}
+
+// Compare reports the constructors that grew from before to after, in
+// count or self size, largest size growth first. Objects are matched by
+// heap object ID, so both snapshots must come from the same page session
+// for the new and deleted counts to be meaningful.
+func Compare(before, after *Snapshot) *Diff {
+	type classIDs struct{ before, after []int }
+	ids := make(map[string]*classIDs)
+	diffs := make(map[string]*ClassDiff)
+	get := func(name string) (*classIDs, *ClassDiff) {
+		if _, ok := ids[name]; !ok {
+			ids[name] = &classIDs{}
+			diffs[name] = &ClassDiff{Name: name}
+		}
+		return ids[name], diffs[name]
+	}
+
+	d := &Diff{NodeCountDelta: after.NodeCount() - before.NodeCount()}
+	for i := 0; i < before.NodeCount(); i++ {
+		c, cd := get(before.ClassName(i))
+		c.before = append(c.before, before.ID(i))
+		cd.CountBefore++
+		cd.SizeDelta -= int64(before.SelfSize(i))
+		d.TotalSizeDelta -= int64(before.SelfSize(i))
+	}
+	for i := 0; i < after.NodeCount(); i++ {
+		c, cd := get(after.ClassName(i))
+		c.after = append(c.after, after.ID(i))
+		cd.CountAfter++
+		cd.SizeDelta += int64(after.SelfSize(i))
+		d.TotalSizeDelta += int64(after.SelfSize(i))
+	}
+
+	d.Constructors = []ClassDiff{}
+	for name, cd := range diffs {
+		cd.CountDelta = cd.CountAfter - cd.CountBefore
+		if cd.CountDelta <= 0 && cd.SizeDelta <= 0 {
+			continue
+		}
+		c := ids[name]
+		sort.Ints(c.before)
+		sort.Ints(c.after)
+		i, j := 0, 0
+		for i < len(c.before) || j < len(c.after) {
+			switch {
+			case j == len(c.after) || (i < len(c.before) && c.before[i] < c.after[j]):
+				cd.Deleted++
+				i++
+			case i == len(c.before) || c.after[j] < c.before[i]:
+				cd.New++
+				j++
+			default:
+				i++
+				j++
+			}
+		}
+		d.Constructors = append(d.Constructors, *cd)
+	}
+
+	sort.Slice(d.Constructors, func(i, j int) bool {
+		a, b := d.Constructors[i], d.Constructors[j]
+		if a.SizeDelta != b.SizeDelta {
+			return a.SizeDelta > b.SizeDelta
+		}
+		if a.CountDelta != b.CountDelta {
+			return a.CountDelta > b.CountDelta
+		}
+		return a.Name < b.Name
+	})
+	return d
+}
@@ -0,0 +1,249 @@
+// Package pprof writes profiles in the gzipped protocol buffer format read
+// by `go tool pprof` and other pprof-compatible tools.
+//
+// Only the subset of profile.proto needed to describe sampled call stacks is
+// supported. See https://github.com/google/pprof/blob/main/proto/profile.proto.
+package pprof
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// ValueType describes the type and unit of a sample value, e.g. "cpu" in
+// "nanoseconds".
+type ValueType struct {
+	Type string
+	Unit string
+}
+
+// Sample is a set of values recorded for a call stack.
+type Sample struct {
+	LocationIDs []uint64 // Leaf first
+	Values      []int64  // One per Profile.SampleTypes
+}
+
+// Location is a point in the program, here always a single source line.
+type Location struct {
+	ID         uint64
+	FunctionID uint64
+	Line       int64
+	Column     int64
+}
+
+// Function is a function referenced by locations.
+type Function struct {
+	ID        uint64
+	Name      string
+	Filename  string
+	StartLine int64
+}
+
+// Profile is a pprof profile.
+type Profile struct {
+	SampleTypes   []ValueType
+	Samples       []Sample
+	Locations     []Location
+	Functions     []Function
+	TimeNanos     int64 // Wall-clock start of the profile
+	DurationNanos int64
+	PeriodType    ValueType
+	Period        int64
+}
+
+// Write encodes the profile and writes it gzipped to w.
+func (p *Profile) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p.encode()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Field numbers from profile.proto.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+	lineColumn     = 3
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// encode returns the uncompressed protocol buffer encoding of the profile.
+func (p *Profile) encode() []byte {
+	strings := newStringTable()
+	var b buffer
+
+	valueType := func(field int, vt ValueType) {
+		var m buffer
+		m.int64(valueTypeType, strings.index(vt.Type))
+		m.int64(valueTypeUnit, strings.index(vt.Unit))
+		b.message(field, m)
+	}
+
+	for _, st := range p.SampleTypes {
+		valueType(profileSampleType, st)
+	}
+
+	for _, s := range p.Samples {
+		var m buffer
+		m.packedUint64(sampleLocationID, s.LocationIDs)
+		m.packedInt64(sampleValue, s.Values)
+		b.message(profileSample, m)
+	}
+
+	for _, loc := range p.Locations {
+		var line buffer
+		line.uint64(lineFunctionID, loc.FunctionID)
+		line.int64(lineLine, loc.Line)
+		line.int64(lineColumn, loc.Column)
+
+		var m buffer
+		m.uint64(locationID, loc.ID)
+		m.message(locationLine, line)
+		b.message(profileLocation, m)
+	}
+
+	for _, fn := range p.Functions {
+		var m buffer
+		m.uint64(functionID, fn.ID)
+		m.int64(functionName, strings.index(fn.Name))
+		m.int64(functionSystemName, strings.index(fn.Name))
+		m.int64(functionFilename, strings.index(fn.Filename))
+		m.int64(functionStartLine, fn.StartLine)
+		b.message(profileFunction, m)
+	}
+
+	b.int64(profileTimeNanos, p.TimeNanos)
+	b.int64(profileDurationNanos, p.DurationNanos)
+	valueType(profilePeriodType, p.PeriodType)
+	b.int64(profilePeriod, p.Period)
+
+	// The string table is referenced by index from everything above, so it
+	// is written last, once complete
+	for _, s := range strings.strings {
+		b.string(profileStringTable, s)
+	}
+
+	return b.data
+}
+
+// stringTable assigns indexes to strings. Index 0 is always "".
+type stringTable struct {
+	strings []string
+	indexes map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{
+		strings: []string{""},
+		indexes: map[string]int64{"": 0},
+	}
+}
+
+func (t *stringTable) index(s string) int64 {
+	if i, ok := t.indexes[s]; ok {
+		return i
+	}
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.indexes[s] = i
+	return i
+}
+
+// buffer accumulates protocol buffer fields.
+type buffer struct {
+	data []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *buffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.data = append(b.data, byte(v)|0x80)
+		v >>= 7
+	}
+	b.data = append(b.data, byte(v))
+}
+
+func (b *buffer) key(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// uint64 writes a varint field, omitting the zero value.
+func (b *buffer) uint64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(v)
+}
+
+// int64 writes a varint field, omitting the zero value.
+func (b *buffer) int64(field int, v int64) {
+	b.uint64(field, uint64(v))
+}
+
+func (b *buffer) bytes(field int, data []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// string writes a string field. Unlike other scalars, empty strings are
+// written, as string table entries are positional.
+func (b *buffer) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *buffer) message(field int, m buffer) {
+	b.bytes(field, m.data)
+}
+
+func (b *buffer) packedUint64(field int, values []uint64) {
+	if len(values) == 0 {
+		return
+	}
+	var packed buffer
+	for _, v := range values {
+		packed.varint(v)
+	}
+	b.bytes(field, packed.data)
+}
+
+func (b *buffer) packedInt64(field int, values []int64) {
+	if len(values) == 0 {
+		return
+	}
+	var packed buffer
+	for _, v := range values {
+		packed.varint(uint64(v))
+	}
+	b.bytes(field, packed.data)
+}
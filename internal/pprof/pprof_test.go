@@ -0,0 +1,153 @@
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+// field is a decoded protocol buffer field.
+type field struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+func readVarint(t *testing.T, data []byte) (uint64, []byte) {
+	t.Helper()
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		if len(data) == 0 {
+			t.Fatal("truncated varint")
+		}
+		b := data[0]
+		data = data[1:]
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, data
+		}
+	}
+}
+
+func decodeFields(t *testing.T, data []byte) []field {
+	t.Helper()
+	var fields []field
+	for len(data) > 0 {
+		var key uint64
+		key, data = readVarint(t, data)
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.varint, data = readVarint(t, data)
+		case wireBytes:
+			var n uint64
+			n, data = readVarint(t, data)
+			f.bytes, data = data[:n], data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func decodePacked(t *testing.T, data []byte) []uint64 {
+	t.Helper()
+	var values []uint64
+	for len(data) > 0 {
+		var v uint64
+		v, data = readVarint(t, data)
+		values = append(values, v)
+	}
+	return values
+}
+
+func TestProfile_Write(t *testing.T) {
+	p := &Profile{
+		SampleTypes: []ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		Samples: []Sample{
+			{LocationIDs: []uint64{2, 1}, Values: []int64{3, 3000000}},
+		},
+		Locations: []Location{
+			{ID: 1, FunctionID: 1, Line: 10, Column: 5},
+			{ID: 2, FunctionID: 2, Line: 20},
+		},
+		Functions: []Function{
+			{ID: 1, Name: "main", Filename: "app.js", StartLine: 10},
+			{ID: 2, Name: "work", Filename: "app.js", StartLine: 20},
+		},
+		TimeNanos:     1700000000000000000,
+		DurationNanos: 5000000000,
+		PeriodType:    ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        1000000,
+	}
+
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("output is not gzipped: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading gzip: %v", err)
+	}
+
+	fields := decodeFields(t, data)
+	var strs []string
+	counts := make(map[int]int)
+	var sample, function []field
+	var timeNanos, period uint64
+	for _, f := range fields {
+		counts[f.num]++
+		switch f.num {
+		case profileStringTable:
+			strs = append(strs, string(f.bytes))
+		case profileSample:
+			sample = decodeFields(t, f.bytes)
+		case profileFunction:
+			if function == nil {
+				function = decodeFields(t, f.bytes)
+			}
+		case profileTimeNanos:
+			timeNanos = f.varint
+		case profilePeriod:
+			period = f.varint
+		}
+	}
+
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table must start with \"\", got %q", strs)
+	}
+	if counts[profileSampleType] != 2 || counts[profileLocation] != 2 || counts[profileFunction] != 2 {
+		t.Errorf("unexpected field counts: %v", counts)
+	}
+	if timeNanos != uint64(p.TimeNanos) || period != 1000000 {
+		t.Errorf("timeNanos = %d, period = %d", timeNanos, period)
+	}
+
+	for _, f := range sample {
+		switch f.num {
+		case sampleLocationID:
+			if got := decodePacked(t, f.bytes); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+				t.Errorf("location ids = %v, want [2 1]", got)
+			}
+		case sampleValue:
+			if got := decodePacked(t, f.bytes); len(got) != 2 || got[0] != 3 || got[1] != 3000000 {
+				t.Errorf("values = %v, want [3 3000000]", got)
+			}
+		}
+	}
+
+	for _, f := range function {
+		if f.num == functionName && strs[f.varint] != "main" {
+			t.Errorf("function name = %q, want main", strs[f.varint])
+		}
+		if f.num == functionFilename && strs[f.varint] != "app.js" {
+			t.Errorf("function filename = %q, want app.js", strs[f.varint])
+		}
+	}
+}
@@ -0,0 +1,561 @@
+// Package trace summarizes Chrome performance traces in the Trace Event
+// Format: where the renderer's main thread spent its time, which tasks
+// were long, and which layouts were forced by script.
+//
+// Events on the main thread nest by time. Each event's self time (its
+// duration less that of the events inside it) is attributed to a category
+// by event name; events without a category of their own take that of the
+// event they are nested in.
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LongTaskThreshold is the duration above which a main-thread task is
+// reported as long, in microseconds.
+const LongTaskThreshold = 50000
+
+// event is a trace event. Times are in microseconds.
+type event struct {
+	Name string    `json:"name"`
+	Ph   string    `json:"ph"`
+	Ts   float64   `json:"ts"`
+	Dur  float64   `json:"dur"`
+	Pid  int       `json:"pid"`
+	Tid  int       `json:"tid"`
+	Args eventArgs `json:"args"`
+}
+
+// eventArgs holds the event arguments used in summaries.
+type eventArgs struct {
+	Name string     `json:"name"` // Thread name of thread_name metadata
+	Data *eventData `json:"data"`
+
+	BeginData *struct {
+		URL string `json:"url"`
+	} `json:"beginData"`
+}
+
+type eventData struct {
+	FunctionName string `json:"functionName"`
+	URL          string `json:"url"`
+	LineNumber   int    `json:"lineNumber"`
+	Type         string `json:"type"`
+	Frames       []struct {
+		ProcessID int    `json:"processId"`
+		Parent    string `json:"parent"`
+	} `json:"frames"`
+}
+
+// Trace is a parsed trace.
+type Trace struct {
+	events []event
+}
+
+// Parse reads a trace saved either as a JSON array of events or as an
+// object with a traceEvents array, as DevTools saves them.
+func Parse(r io.Reader) (*Trace, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Trace{}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var obj struct {
+			TraceEvents []event `json:"traceEvents"`
+		}
+		err = json.Unmarshal(data, &obj)
+		t.events = obj.TraceEvents
+	} else {
+		err = json.Unmarshal(data, &t.events)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing trace: %w", err)
+	}
+	return t, nil
+}
+
+// Category names used in breakdowns.
+const (
+	scripting = "scripting"
+	layout    = "layout"
+	paint     = "paint"
+	gc        = "gc"
+	other     = "other"
+)
+
+// categories maps event names to categories, after the DevTools
+// Performance panel.
+var categories = map[string]string{
+	"FunctionCall":        scripting,
+	"EvaluateScript":      scripting,
+	"v8.evaluateModule":   scripting,
+	"v8.compile":          scripting,
+	"v8.compileModule":    scripting,
+	"v8.run":              scripting,
+	"v8.callFunction":     scripting,
+	"V8.Execute":          scripting,
+	"TimerFire":           scripting,
+	"EventDispatch":       scripting,
+	"FireAnimationFrame":  scripting,
+	"FireIdleCallback":    scripting,
+	"RunMicrotasks":       scripting,
+	"XHRReadyStateChange": scripting,
+	"XHRLoad":             scripting,
+
+	"Layout":               layout,
+	"UpdateLayoutTree":     layout,
+	"RecalculateStyles":    layout,
+	"UpdateLayerTree":      layout,
+	"UpdateLayer":          layout,
+	"HitTest":              layout,
+	"PrePaint":             layout,
+	"Layerize":             layout,
+	"ComputeIntersections": layout,
+
+	"Paint":           paint,
+	"PaintImage":      paint,
+	"PaintSetup":      paint,
+	"CompositeLayers": paint,
+	"Commit":          paint,
+	"DecodeImage":     paint,
+	"Decode Image":    paint,
+
+	"MajorGC": gc,
+	"MinorGC": gc,
+	"GCEvent": gc,
+}
+
+func category(name string) string {
+	if c, ok := categories[name]; ok {
+		return c
+	}
+	for _, prefix := range []string{"V8.GC", "BlinkGC.", "CppGC."} {
+		if strings.HasPrefix(name, prefix) {
+			return gc
+		}
+	}
+	return ""
+}
+
+// Breakdown is time in milliseconds by category.
+type Breakdown struct {
+	Scripting float64 `json:"scripting"`
+	Layout    float64 `json:"layout"`
+	Paint     float64 `json:"paint"`
+	GC        float64 `json:"gc"`
+	Other     float64 `json:"other"`
+	Idle      float64 `json:"idle,omitempty"`
+}
+
+func (b *Breakdown) add(cat string, us float64) {
+	switch cat {
+	case scripting:
+		b.Scripting += us
+	case layout:
+		b.Layout += us
+	case paint:
+		b.Paint += us
+	case gc:
+		b.GC += us
+	default:
+		b.Other += us
+	}
+}
+
+func (b *Breakdown) busy() float64 {
+	return b.Scripting + b.Layout + b.Paint + b.GC + b.Other
+}
+
+// millis converts a breakdown accumulated in microseconds to milliseconds.
+func (b Breakdown) millis() Breakdown {
+	return Breakdown{
+		Scripting: ms(b.Scripting),
+		Layout:    ms(b.Layout),
+		Paint:     ms(b.Paint),
+		GC:        ms(b.GC),
+		Other:     ms(b.Other),
+		Idle:      ms(b.Idle),
+	}
+}
+
+// ms converts microseconds to milliseconds, rounded to the microsecond.
+func ms(us float64) float64 {
+	return math.Round(us) / 1000
+}
+
+// Attribution identifies what a long task or forced reflow was doing.
+type Attribution struct {
+	Event    string `json:"event"`              // Outermost event inside the task
+	Detail   string `json:"detail,omitempty"`   // e.g. the DOM event type
+	URL      string `json:"url,omitempty"`      // Script responsible
+	Function string `json:"function,omitempty"` // Function responsible
+	Line     int    `json:"line,omitempty"`
+}
+
+// LongTask is a main-thread task longer than LongTaskThreshold.
+type LongTask struct {
+	Start       float64     `json:"start"` // Milliseconds since the start of the trace
+	Duration    float64     `json:"duration"`
+	Attribution Attribution `json:"attribution"`
+	Breakdown   Breakdown   `json:"breakdown"`
+}
+
+// ForcedReflow is a style or layout calculation forced by script reading
+// layout before the next frame.
+type ForcedReflow struct {
+	Start       float64     `json:"start"`
+	Duration    float64     `json:"duration"`
+	Event       string      `json:"event"` // Layout or UpdateLayoutTree
+	Attribution Attribution `json:"attribution"`
+}
+
+// Frame is the main thread's time between two frames.
+type Frame struct {
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	Breakdown
+}
+
+// Summary is a summary of the renderer main thread in a trace. Times are
+// in milliseconds.
+type Summary struct {
+	Duration      float64        `json:"duration"`
+	Totals        Breakdown      `json:"totals"`
+	Tasks         int            `json:"tasks"`
+	LongTasks     []LongTask     `json:"longTasks"`
+	ForcedReflows []ForcedReflow `json:"forcedReflows"`
+	Frames        []Frame        `json:"frames"`
+}
+
+// node is a main-thread event placed in the nesting tree.
+type node struct {
+	ev         *event
+	start, end float64
+	cat        string // Own category, or inherited from the parent
+	parent     int    // -1 for tasks
+	children   []int
+}
+
+// segment is a stretch of self time of one category.
+type segment struct {
+	start, end float64
+	cat        string
+}
+
+// Summarize summarizes the main thread of the page's renderer.
+func (t *Trace) Summarize() (*Summary, error) {
+	pid, tid, ok := t.mainThread()
+	if !ok {
+		return nil, fmt.Errorf("no renderer main thread in trace")
+	}
+
+	// Trace bounds, over all threads
+	traceStart, traceEnd := math.Inf(1), math.Inf(-1)
+	for i := range t.events {
+		ev := &t.events[i]
+		if ev.Ph == "M" || ev.Ts == 0 {
+			continue
+		}
+		traceStart = math.Min(traceStart, ev.Ts)
+		traceEnd = math.Max(traceEnd, ev.Ts+ev.Dur)
+	}
+	if math.IsInf(traceStart, 1) {
+		return nil, fmt.Errorf("trace has no events")
+	}
+
+	nodes := t.mainThreadTree(pid, tid)
+	segments := selfTime(nodes)
+
+	sum := &Summary{
+		Duration:      ms(traceEnd - traceStart),
+		LongTasks:     []LongTask{},
+		ForcedReflows: []ForcedReflow{},
+		Frames:        []Frame{},
+	}
+
+	totals := breakdown(segments, traceStart, traceEnd)
+	totals.Idle = traceEnd - traceStart - totals.busy()
+	sum.Totals = totals.millis()
+
+	for i := range nodes {
+		n := &nodes[i]
+		if n.parent < 0 {
+			sum.Tasks++
+			if n.end-n.start > LongTaskThreshold {
+				sum.LongTasks = append(sum.LongTasks, LongTask{
+					Start:       ms(n.start - traceStart),
+					Duration:    ms(n.end - n.start),
+					Attribution: attribute(nodes, i),
+					Breakdown:   breakdown(segments, n.start, n.end).millis(),
+				})
+			}
+		}
+
+		// A layout inside script was forced by it
+		if (n.ev.Name == "Layout" || n.ev.Name == "UpdateLayoutTree") && n.parent >= 0 {
+			if script := enclosingScript(nodes, i); script >= 0 {
+				sum.ForcedReflows = append(sum.ForcedReflows, ForcedReflow{
+					Start:       ms(n.start - traceStart),
+					Duration:    ms(n.end - n.start),
+					Event:       n.ev.Name,
+					Attribution: scriptAttribution(nodes, script),
+				})
+			}
+		}
+	}
+
+	frames := t.frameStarts(pid, tid)
+	for i := 0; i+1 < len(frames); i++ {
+		b := breakdown(segments, frames[i], frames[i+1])
+		b.Idle = frames[i+1] - frames[i] - b.busy()
+		sum.Frames = append(sum.Frames, Frame{
+			Start:     ms(frames[i] - traceStart),
+			Duration:  ms(frames[i+1] - frames[i]),
+			Breakdown: b.millis(),
+		})
+	}
+
+	return sum, nil
+}
+
+// mainThread finds the CrRendererMain thread of the process rendering the
+// main frame, or failing that the busiest renderer main thread.
+func (t *Trace) mainThread() (pid, tid int, ok bool) {
+	pagePid := -1
+	for i := range t.events {
+		ev := &t.events[i]
+		if ev.Name == "TracingStartedInBrowser" && ev.Args.Data != nil {
+			for _, f := range ev.Args.Data.Frames {
+				if f.Parent == "" {
+					pagePid = f.ProcessID
+				}
+			}
+		}
+	}
+
+	type thread struct{ pid, tid int }
+	counts := make(map[thread]int)
+	for i := range t.events {
+		ev := &t.events[i]
+		if ev.Ph == "M" && ev.Name == "thread_name" && ev.Args.Name == "CrRendererMain" {
+			counts[thread{ev.Pid, ev.Tid}] += 0
+		}
+	}
+	for i := range t.events {
+		ev := &t.events[i]
+		if n, ok := counts[thread{ev.Pid, ev.Tid}]; ok {
+			counts[thread{ev.Pid, ev.Tid}] = n + 1
+		}
+	}
+
+	// Prefer the page's process, then the busiest thread, then the lowest
+	// pid and tid, so that ties are broken the same way every time
+	better := func(a, b thread) bool {
+		if (a.pid == pagePid) != (b.pid == pagePid) {
+			return a.pid == pagePid
+		}
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if a.pid != b.pid {
+			return a.pid < b.pid
+		}
+		return a.tid < b.tid
+	}
+	var best thread
+	for th := range counts {
+		if !ok || better(th, best) {
+			best, ok = th, true
+		}
+	}
+	return best.pid, best.tid, ok
+}
+
+// mainThreadTree nests the thread's duration events by time. Begin and
+// end events are paired into complete events first.
+func (t *Trace) mainThreadTree(pid, tid int) []node {
+	var events []*event
+	var open []*event
+	for i := range t.events {
+		ev := &t.events[i]
+		if ev.Pid != pid || ev.Tid != tid {
+			continue
+		}
+		switch ev.Ph {
+		case "X":
+			events = append(events, ev)
+		case "B":
+			open = append(open, ev)
+		case "E":
+			if len(open) > 0 {
+				begin := *open[len(open)-1]
+				open = open[:len(open)-1]
+				begin.Ph = "X"
+				begin.Dur = ev.Ts - begin.Ts
+				events = append(events, &begin)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Ts != events[j].Ts {
+			return events[i].Ts < events[j].Ts
+		}
+		return events[i].Dur > events[j].Dur
+	})
+
+	nodes := make([]node, 0, len(events))
+	var stack []int
+	for _, ev := range events {
+		for len(stack) > 0 && nodes[stack[len(stack)-1]].end <= ev.Ts {
+			stack = stack[:len(stack)-1]
+		}
+		n := node{ev: ev, start: ev.Ts, end: ev.Ts + ev.Dur, parent: -1, cat: category(ev.Name)}
+		if len(stack) > 0 {
+			parent := &nodes[stack[len(stack)-1]]
+			n.parent = stack[len(stack)-1]
+			n.end = math.Min(n.end, parent.end)
+			if n.cat == "" {
+				n.cat = parent.cat
+			}
+			parent.children = append(parent.children, len(nodes))
+		}
+		if n.cat == "" {
+			n.cat = other
+		}
+		stack = append(stack, len(nodes))
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// selfTime splits the tree into the stretches of each event not covered
+// by the events inside it, in time order.
+func selfTime(nodes []node) []segment {
+	var segments []segment
+	for _, n := range nodes {
+		cursor := n.start
+		for _, c := range n.children {
+			if nodes[c].start > cursor {
+				segments = append(segments, segment{cursor, nodes[c].start, n.cat})
+			}
+			cursor = math.Max(cursor, nodes[c].end)
+		}
+		if n.end > cursor {
+			segments = append(segments, segment{cursor, n.end, n.cat})
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start < segments[j].start })
+	return segments
+}
+
+// breakdown totals the self time between start and end, in microseconds.
+func breakdown(segments []segment, start, end float64) Breakdown {
+	var b Breakdown
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].end > start })
+	for ; i < len(segments) && segments[i].start < end; i++ {
+		s := segments[i]
+		b.add(s.cat, math.Min(s.end, end)-math.Max(s.start, start))
+	}
+	return b
+}
+
+// isScript reports whether an event runs script with a known source.
+func isScript(name string) bool {
+	return name == "FunctionCall" || name == "EvaluateScript" || name == "v8.evaluateModule"
+}
+
+// enclosingScript returns the innermost script event around node i, or -1
+// if it is not inside script.
+func enclosingScript(nodes []node, i int) int {
+	for p := nodes[i].parent; p >= 0; p = nodes[p].parent {
+		if categories[nodes[p].ev.Name] == scripting {
+			for q := p; q >= 0; q = nodes[q].parent {
+				if isScript(nodes[q].ev.Name) {
+					return q
+				}
+			}
+			return p
+		}
+	}
+	return -1
+}
+
+// attribute describes task i by its longest direct child and the first
+// script run inside that.
+func attribute(nodes []node, task int) Attribution {
+	top := task
+	longest := -1.0
+	for _, c := range nodes[task].children {
+		if d := nodes[c].end - nodes[c].start; d > longest {
+			top, longest = c, d
+		}
+	}
+
+	a := Attribution{Event: nodes[top].ev.Name, Detail: detail(nodes[top].ev)}
+
+	// Breadth-first, so the outermost script is found
+	queue := []int{top}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if isScript(nodes[n].ev.Name) {
+			s := scriptAttribution(nodes, n)
+			a.URL, a.Function, a.Line = s.URL, s.Function, s.Line
+			break
+		}
+		queue = append(queue, nodes[n].children...)
+	}
+	return a
+}
+
+// scriptAttribution describes the script event at node i.
+func scriptAttribution(nodes []node, i int) Attribution {
+	ev := nodes[i].ev
+	a := Attribution{Event: ev.Name, Detail: detail(ev)}
+	if ev.Args.Data != nil {
+		a.URL = ev.Args.Data.URL
+		a.Function = ev.Args.Data.FunctionName
+		a.Line = ev.Args.Data.LineNumber
+	}
+	return a
+}
+
+// detail returns what an event was handling, where the event records it.
+func detail(ev *event) string {
+	if ev.Args.Data != nil && ev.Args.Data.Type != "" {
+		return ev.Args.Data.Type
+	}
+	if ev.Args.BeginData != nil && ev.Args.BeginData.URL != "" {
+		return ev.Args.BeginData.URL
+	}
+	return ""
+}
+
+// frameStarts returns the times the main thread began frames, falling
+// back to BeginFrame events from any thread of the renderer.
+func (t *Trace) frameStarts(pid, tid int) []float64 {
+	var starts []float64
+	for _, name := range []string{"BeginMainThreadFrame", "BeginFrame"} {
+		for i := range t.events {
+			ev := &t.events[i]
+			if ev.Name == name && ev.Pid == pid && (ev.Tid == tid || name == "BeginFrame") {
+				starts = append(starts, ev.Ts)
+			}
+		}
+		if len(starts) > 0 {
+			break
+		}
+	}
+	sort.Float64s(starts)
+	return starts
+}
@@ -0,0 +1,141 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+// testTrace is a page whose click handler forces a layout in an 80ms task,
+// followed by a 10ms rendering task. A second renderer is busier but does
+// not render the page.
+const testTrace = `{"traceEvents": [
+	{"name": "thread_name", "ph": "M", "pid": 1, "tid": 1, "ts": 0, "args": {"name": "CrRendererMain"}},
+	{"name": "thread_name", "ph": "M", "pid": 2, "tid": 5, "ts": 0, "args": {"name": "CrRendererMain"}},
+	{"name": "TracingStartedInBrowser", "ph": "I", "pid": 9, "tid": 9, "ts": 1000, "args": {"data": {"frames": [{"processId": 1}, {"processId": 2, "parent": "F1"}]}}},
+
+	{"name": "BeginMainThreadFrame", "ph": "I", "pid": 1, "tid": 1, "ts": 1000},
+	{"name": "RunTask", "ph": "X", "pid": 1, "tid": 1, "ts": 1000, "dur": 80000},
+	{"name": "EventDispatch", "ph": "X", "pid": 1, "tid": 1, "ts": 1100, "dur": 70000, "args": {"data": {"type": "click"}}},
+	{"name": "FunctionCall", "ph": "X", "pid": 1, "tid": 1, "ts": 1200, "dur": 60000, "args": {"data": {"url": "https://example.com/app.js", "functionName": "onClick", "lineNumber": 10}}},
+	{"name": "Layout", "ph": "B", "pid": 1, "tid": 1, "ts": 30000},
+	{"name": "Layout", "ph": "E", "pid": 1, "tid": 1, "ts": 40000},
+	{"name": "BeginMainThreadFrame", "ph": "I", "pid": 1, "tid": 1, "ts": 50000},
+	{"name": "MinorGC", "ph": "X", "pid": 1, "tid": 1, "ts": 50000, "dur": 5000},
+
+	{"name": "BeginMainThreadFrame", "ph": "I", "pid": 1, "tid": 1, "ts": 100000},
+	{"name": "RunTask", "ph": "X", "pid": 1, "tid": 1, "ts": 100000, "dur": 10000},
+	{"name": "Paint", "ph": "X", "pid": 1, "tid": 1, "ts": 101000, "dur": 4000},
+	{"name": "UpdateLayoutTree", "ph": "X", "pid": 1, "tid": 1, "ts": 105000, "dur": 2000},
+
+	{"name": "RunTask", "ph": "X", "pid": 2, "tid": 5, "ts": 2000, "dur": 199000},
+	{"name": "RunTask", "ph": "X", "pid": 2, "tid": 5, "ts": 2000, "dur": 1000}
+]}`
+
+func summarize(t *testing.T, data string) *Summary {
+	t.Helper()
+	tr, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	sum, err := tr.Summarize()
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	return sum
+}
+
+func TestSummarize_Totals(t *testing.T) {
+	sum := summarize(t, testTrace)
+
+	if sum.Duration != 200 {
+		t.Errorf("Duration = %v, want 200", sum.Duration)
+	}
+	if sum.Tasks != 2 {
+		t.Errorf("Tasks = %d, want 2", sum.Tasks)
+	}
+	want := Breakdown{Scripting: 55, Layout: 12, Paint: 4, GC: 5, Other: 14, Idle: 110}
+	if sum.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", sum.Totals, want)
+	}
+}
+
+func TestSummarize_LongTasks(t *testing.T) {
+	sum := summarize(t, testTrace)
+
+	if len(sum.LongTasks) != 1 {
+		t.Fatalf("expected 1 long task, got %+v", sum.LongTasks)
+	}
+	task := sum.LongTasks[0]
+	if task.Start != 0 || task.Duration != 80 {
+		t.Errorf("task at %vms for %vms, want 0ms for 80ms", task.Start, task.Duration)
+	}
+	wantAttribution := Attribution{Event: "EventDispatch", Detail: "click", URL: "https://example.com/app.js", Function: "onClick", Line: 10}
+	if task.Attribution != wantAttribution {
+		t.Errorf("Attribution = %+v, want %+v", task.Attribution, wantAttribution)
+	}
+	wantBreakdown := Breakdown{Scripting: 55, Layout: 10, GC: 5, Other: 10}
+	if task.Breakdown != wantBreakdown {
+		t.Errorf("Breakdown = %+v, want %+v", task.Breakdown, wantBreakdown)
+	}
+}
+
+func TestSummarize_ForcedReflows(t *testing.T) {
+	sum := summarize(t, testTrace)
+
+	// The UpdateLayoutTree in the rendering task is not forced
+	if len(sum.ForcedReflows) != 1 {
+		t.Fatalf("expected 1 forced reflow, got %+v", sum.ForcedReflows)
+	}
+	reflow := sum.ForcedReflows[0]
+	if reflow.Event != "Layout" || reflow.Start != 29 || reflow.Duration != 10 {
+		t.Errorf("unexpected reflow: %+v", reflow)
+	}
+	if reflow.Attribution.Function != "onClick" {
+		t.Errorf("expected reflow attributed to onClick, got %+v", reflow.Attribution)
+	}
+}
+
+func TestSummarize_Frames(t *testing.T) {
+	sum := summarize(t, testTrace)
+
+	if len(sum.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %+v", sum.Frames)
+	}
+	first, second := sum.Frames[0], sum.Frames[1]
+	if first.Start != 0 || first.Duration != 49 || first.Scripting != 38.9 || first.Layout != 10 || first.Idle != 0 {
+		t.Errorf("unexpected first frame: %+v", first)
+	}
+	if second.Start != 49 || second.GC != 5 || second.Scripting != 16.1 || second.Idle != 19 {
+		t.Errorf("unexpected second frame: %+v", second)
+	}
+}
+
+func TestSummarize_NoMainThread(t *testing.T) {
+	tr, err := Parse(strings.NewReader(`[{"name": "RunTask", "ph": "X", "pid": 1, "tid": 1, "ts": 1, "dur": 1}]`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := tr.Summarize(); err == nil {
+		t.Error("expected error for trace without a renderer main thread")
+	}
+}
+
+func TestMainThread_Ties(t *testing.T) {
+	// Equally busy renderers, none known to render the page
+	const tied = `[
+	{"name": "thread_name", "ph": "M", "pid": 7, "tid": 3, "ts": 0, "args": {"name": "CrRendererMain"}},
+	{"name": "thread_name", "ph": "M", "pid": 4, "tid": 9, "ts": 0, "args": {"name": "CrRendererMain"}},
+	{"name": "thread_name", "ph": "M", "pid": 4, "tid": 2, "ts": 0, "args": {"name": "CrRendererMain"}},
+	{"name": "thread_name", "ph": "M", "pid": 5, "tid": 1, "ts": 0, "args": {"name": "CrRendererMain"}}
+]`
+	for range 20 {
+		tr, err := Parse(strings.NewReader(tied))
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		pid, tid, ok := tr.mainThread()
+		if !ok || pid != 4 || tid != 2 {
+			t.Fatalf("mainThread() = %d, %d, %v, want 4, 2, true", pid, tid, ok)
+		}
+	}
+}